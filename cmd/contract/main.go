@@ -0,0 +1,44 @@
+// Command contract checks a running gateway against its AsyncAPI document and exits non-zero on violations.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-websocket-boilerplate/internal/contract"
+	"os"
+	"time"
+)
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:3000", "HTTP base URL of the gateway")
+	token := flag.String("token", os.Getenv("WSGW_CONTRACT_TOKEN"), "valid JWT for the auth flow checks")
+	timeout := flag.Duration("timeout", 5*time.Second, "time to wait for each expected frame")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	report := contract.NewRunner(*baseURL, *token, *timeout).Run(context.Background())
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		for _, check := range report.Checks {
+			switch {
+			case check.Skipped != "":
+				fmt.Printf("SKIP %s (%s)\n", check.Name, check.Skipped)
+			case check.Passed():
+				fmt.Printf("PASS %s\n", check.Name)
+			default:
+				fmt.Printf("FAIL %s\n", check.Name)
+				for _, problem := range check.Problems {
+					fmt.Printf("     %s\n", problem)
+				}
+			}
+		}
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,50 @@
+// Command examples writes runnable client snippets for every request documented by a running gateway.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-websocket-boilerplate/internal/asyncapi"
+	"go-websocket-boilerplate/internal/examples"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extensions maps languages to file extensions.
+var extensions = map[string]string{examples.JavaScript: "js", examples.Python: "py", examples.Go: "go"}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:3000", "HTTP base URL of the gateway")
+	out := flag.String("out", "examples", "directory the snippets are written to")
+	flag.Parse()
+
+	resp, err := http.Get(strings.TrimSuffix(*baseURL, "/") + "/asyncapi.json")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fetch asyncapi.json:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	doc := &asyncapi.Document{}
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		fmt.Fprintln(os.Stderr, "decode asyncapi.json:", err)
+		os.Exit(1)
+	}
+
+	wsURL := strings.Replace(strings.TrimSuffix(*baseURL, "/"), "http", "ws", 1) + "/ws"
+	for _, example := range examples.Generate(doc, wsURL) {
+		dir := filepath.Join(*out, example.Language)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		name := fmt.Sprintf("%s_%s.%s", example.Channel, example.Type, extensions[example.Language])
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(example.Code), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(filepath.Join(dir, name))
+	}
+}
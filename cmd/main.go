@@ -1,11 +1,524 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"go-websocket-boilerplate/internal/alerting"
+	"go-websocket-boilerplate/internal/analytics"
+	"go-websocket-boilerplate/internal/archive"
+	"go-websocket-boilerplate/internal/blocklist"
+	"go-websocket-boilerplate/internal/broker"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/conformance"
+	"go-websocket-boilerplate/internal/experiment"
+	"go-websocket-boilerplate/internal/geoip"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/ids"
+	"go-websocket-boilerplate/internal/jwt_auth"
+	"go-websocket-boilerplate/internal/location"
+	"go-websocket-boilerplate/internal/metering"
+	"go-websocket-boilerplate/internal/moderation"
+	"go-websocket-boilerplate/internal/natsbridge"
+	"go-websocket-boilerplate/internal/noderpc"
 	"go-websocket-boilerplate/internal/open_auth"
+	"go-websocket-boilerplate/internal/partition"
+	"go-websocket-boilerplate/internal/polls"
+	"go-websocket-boilerplate/internal/reactions"
+	"go-websocket-boilerplate/internal/receipts"
+	"go-websocket-boilerplate/internal/revocation"
 	"go-websocket-boilerplate/internal/server"
+	"go-websocket-boilerplate/internal/session"
+	"go-websocket-boilerplate/internal/signaling"
+	"go-websocket-boilerplate/internal/signing"
+	"go-websocket-boilerplate/internal/soak"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// envOr returns the environment variable, or the fallback if it is unset.
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt parses an integer environment variable, recording a problem if it is malformed. Unset is 0.
+func envInt(name string, problems *[]error) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		*problems = append(*problems, fmt.Errorf("%s: %q is not an integer", name, value))
+	}
+	return n
+}
+
+// envFloat parses a decimal environment variable, recording a problem if it is malformed. Unset is 0.
+func envFloat(name string, problems *[]error) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		*problems = append(*problems, fmt.Errorf("%s: %q is not a number", name, value))
+	}
+	return f
+}
+
+// auditSampling reads the egress audit sample rates: WSGW_AUDIT_SAMPLE_RATE for all channels, 1% if unset, and
+// WSGW_AUDIT_SAMPLE_CHANNELS with comma separated channel=rate overrides, e.g. "prices.*=0.001,orders=1".
+func auditSampling(problems *[]error) server.AuditSampling {
+	sampling := server.AuditSampling{Default: 0.01, Channels: make(map[string]float64)}
+	if os.Getenv("WSGW_AUDIT_SAMPLE_RATE") != "" {
+		sampling.Default = envFloat("WSGW_AUDIT_SAMPLE_RATE", problems)
+	}
+	if overrides := os.Getenv("WSGW_AUDIT_SAMPLE_CHANNELS"); overrides != "" {
+		for _, entry := range strings.Split(overrides, ",") {
+			channel, value, ok := strings.Cut(entry, "=")
+			rate, err := strconv.ParseFloat(value, 64)
+			if !ok || err != nil {
+				*problems = append(*problems, fmt.Errorf("WSGW_AUDIT_SAMPLE_CHANNELS: %q is not channel=rate", entry))
+				continue
+			}
+			sampling.Channels[channel] = rate
+		}
+	}
+	return sampling
+}
+
+// newAuthenticator selects the token authenticator from the environment. WSGW_JWT_SECRET verifies HMAC signed
+// tokens, WSGW_JWT_PUBLIC_KEY_FILE RSA or ECDSA signed ones and WSGW_JWKS_URL tokens signed with the keys of an
+// OIDC provider. WSGW_TENANTS_FILE configures several identity providers, selected by the endpoint path or the
+// token's issuer. Without any of them, tokens are accepted unverified, which the security policy refuses outside
+// the dev profile.
+func newAuthenticator(problems *[]error) server.Authenticator {
+	options := jwt_auth.Options{
+		Issuer:   os.Getenv("WSGW_JWT_ISSUER"),
+		Audience: os.Getenv("WSGW_JWT_AUDIENCE"),
+		Leeway:   time.Duration(envInt("WSGW_JWT_LEEWAY_SECONDS", problems)) * time.Second,
+	}
+	secret, keyFile, jwksURL := os.Getenv("WSGW_JWT_SECRET"), os.Getenv("WSGW_JWT_PUBLIC_KEY_FILE"), os.Getenv("WSGW_JWKS_URL")
+	tenantsFile := os.Getenv("WSGW_TENANTS_FILE")
+	configured := 0
+	for _, value := range []string{secret, keyFile, jwksURL, tenantsFile} {
+		if value != "" {
+			configured++
+		}
+	}
+	switch {
+	case configured > 1:
+		*problems = append(*problems, errors.New("WSGW_JWT_SECRET, WSGW_JWT_PUBLIC_KEY_FILE, WSGW_JWKS_URL and WSGW_TENANTS_FILE are mutually exclusive"))
+	case secret != "":
+		auth, err := jwt_auth.NewHMAC([]byte(secret), options)
+		if err != nil {
+			*problems = append(*problems, fmt.Errorf("WSGW_JWT_SECRET: %w", err))
+			break
+		}
+		return auth
+	case keyFile != "":
+		auth, err := jwt_auth.LoadPublicKeyFile(keyFile, options)
+		if err != nil {
+			*problems = append(*problems, fmt.Errorf("WSGW_JWT_PUBLIC_KEY_FILE: %w", err))
+			break
+		}
+		return auth
+	case jwksURL != "":
+		keys := jwt_auth.NewKeySet(jwksURL, time.Minute)
+		if err := keys.Refresh(); err != nil {
+			slog.Warn("Initial JWKS fetch failed, retrying on unknown keys", "error", err)
+		}
+		go keys.Run(context.Background(), time.Hour)
+		return jwt_auth.NewJWKS(keys, options)
+	case tenantsFile != "":
+		tenants, err := jwt_auth.LoadTenantsFile(tenantsFile, options.Leeway)
+		if err != nil {
+			*problems = append(*problems, fmt.Errorf("WSGW_TENANTS_FILE: %w", err))
+			break
+		}
+		for _, keys := range tenants.KeySets() {
+			go keys.Run(context.Background(), time.Hour)
+		}
+		return tenants
+	}
+	return open_auth.NewOpenAuthenticator()
+}
+
 func main() {
-	wsgw := server.NewWsGw(open_auth.NewOpenAuthenticator())
+	validateConfig := flag.Bool("validate-config", false, "validate the configuration and exit non-zero on problems")
+	soakClients := flag.Int("soak-clients", 0, "run this many synthetic in-process clients for soak testing")
+	soakChannels := flag.Int("soak-channels", 10, "number of channels the soak clients subscribe to")
+	profile := flag.String("profile", envOr("WSGW_PROFILE", server.ProfileDev), "configuration profile: dev, staging or prod")
+	allowInsecure := flag.Bool("allow-insecure", os.Getenv("WSGW_ALLOW_INSECURE") == "true", "start despite security policy violations")
+	flag.Parse()
+
+	var problems []error // Configuration problems, reported together before starting
+	config, err := server.ProfileConfig(*profile)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("profile: %w", err))
+	}
+	if configFile := os.Getenv("WSGW_CONFIG_FILE"); configFile != "" {
+		fileConfig, err := server.LoadConfigFile(configFile, config)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_CONFIG_FILE: %w", err))
+		}
+		config = fileConfig
+	}
+	config, err = server.ConfigFromEnv(config)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if *allowInsecure {
+		config.AllowInsecure = true
+	}
+	if level, err := config.SlogLevel(); err == nil {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	}
+	wsgw := server.NewWsGw(newAuthenticator(&problems), config)
+	if nodeID := os.Getenv("WSGW_NODE_ID"); nodeID != "" {
+		wsgw.SetNodeID(nodeID)
+	}
+	switch generator := os.Getenv("WSGW_ID_GENERATOR"); generator {
+	case "", "uuidv7":
+	case "ksuid":
+		wsgw.SetIDGenerator(ids.KSUID{})
+	case "snowflake":
+		snowflake, err := ids.NewSnowflake(envInt("WSGW_SNOWFLAKE_NODE", &problems))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_SNOWFLAKE_NODE: %w", err))
+		} else {
+			wsgw.SetIDGenerator(snowflake)
+		}
+	default:
+		problems = append(problems, fmt.Errorf("WSGW_ID_GENERATOR: unknown generator %q, want uuidv7, ksuid or snowflake", generator))
+	}
+	if os.Getenv("WSGW_SIGN_MESSAGES") == "true" {
+		signer, err := signing.NewRotatingSigner(24*time.Hour, 2)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_SIGN_MESSAGES: %w", err))
+		} else {
+			wsgw.SetSigner(signer)
+		}
+	}
+	registry := channels.NewRegistry(false)
+	if channelsFile := os.Getenv("WSGW_CHANNELS_FILE"); channelsFile != "" {
+		registry = channels.NewRegistry(true)
+		if err := registry.LoadFile(channelsFile); err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_CHANNELS_FILE: %w", err))
+		}
+	}
+	wsgw.SetChannelRegistry(registry)
+	var redisClient *redis.Client
+	if redisAddr := os.Getenv("WSGW_REDIS_ADDR"); redisAddr != "" {
+		redisClient = redis.NewClient(&redis.Options{Addr: redisAddr})
+		wsgw.SetSessionStore(session.NewRedisStore(redisClient))
+		if size := envInt("WSGW_RESUME_BUFFER_SIZE", &problems); size > 0 {
+			wsgw.SetResumeBuffer(session.NewRedisBuffer(redisClient, size), time.Duration(envInt("WSGW_RESUME_BUFFER_TTL_SECONDS", &problems))*time.Second)
+		}
+		wsgw.SetDirectMessagePolicy(nil, blocklist.NewRedisStore(redisClient))
+		if os.Getenv("WSGW_BACKPLANE") == "true" {
+			wsgw.SetBroker(broker.NewRedis(redisClient, "wsgw:backplane"))
+		}
+	} else {
+		wsgw.SetDirectMessagePolicy(nil, blocklist.NewMemoryStore())
+		if size := envInt("WSGW_RESUME_BUFFER_SIZE", &problems); size > 0 {
+			wsgw.SetResumeBuffer(session.NewMemoryBuffer(size), time.Duration(envInt("WSGW_RESUME_BUFFER_TTL_SECONDS", &problems))*time.Second)
+		}
+	}
+	if natsURL := os.Getenv("WSGW_NATS_URL"); natsURL != "" {
+		bridgeChannels := os.Getenv("WSGW_NATS_CHANNELS")
+		if bridgeChannels == "" {
+			problems = append(problems, errors.New("WSGW_NATS_URL requires WSGW_NATS_CHANNELS"))
+		} else if bridge, err := natsbridge.Connect(natsURL, "wsgw"); err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_NATS_URL: %w", err))
+		} else {
+			wsgw.SetBridge(bridge, strings.Split(bridgeChannels, ",")...)
+		}
+	}
+	if partitionNodes := os.Getenv("WSGW_PARTITION_NODES"); partitionNodes != "" {
+		var nodes []partition.Node
+		for _, entry := range strings.Split(partitionNodes, ",") {
+			id, url, ok := strings.Cut(entry, "=")
+			if !ok || id == "" {
+				problems = append(problems, fmt.Errorf("WSGW_PARTITION_NODES: %q is not node=url", entry))
+				continue
+			}
+			nodes = append(nodes, partition.Node{ID: id, URL: url})
+		}
+		wsgw.SetPartitioning(partition.New(nodes, envInt("WSGW_PARTITION_REPLICAS", &problems)))
+	}
+	if rpcAddr := os.Getenv("WSGW_RPC_ADDR"); rpcAddr != "" {
+		peers := make(map[string]string)
+		for _, entry := range strings.Split(os.Getenv("WSGW_RPC_PEERS"), ",") {
+			node, addr, ok := strings.Cut(entry, "=")
+			if !ok || node == "" || addr == "" {
+				problems = append(problems, fmt.Errorf("WSGW_RPC_PEERS: %q is not node=host:port", entry))
+				continue
+			}
+			peers[node] = addr
+		}
+		wsgw.SetNodeRPC(rpcAddr, noderpc.NewPeers(peers))
+	}
+	if grace := envInt("WSGW_DRAIN_GRACE_SECONDS", &problems); grace > 0 {
+		wsgw.SetDrainGrace(time.Duration(grace) * time.Second)
+	}
+	wsgw.SetLoopbackDrain(os.Getenv("WSGW_DRAIN_LOOPBACK") == "true")
+	if maxPerUser := envInt("WSGW_MAX_CONNECTIONS_PER_USER", &problems); maxPerUser != 0 {
+		switch policy := os.Getenv("WSGW_DUPLICATE_POLICY"); policy {
+		case "", "reject":
+			wsgw.SetUserConnectionLimit(maxPerUser, server.DuplicateRejectNew)
+		case "kick-oldest":
+			wsgw.SetUserConnectionLimit(maxPerUser, server.DuplicateKickOldest)
+		default:
+			problems = append(problems, fmt.Errorf("WSGW_DUPLICATE_POLICY: unknown policy %q, want reject or kick-oldest", policy))
+		}
+	}
+	wsgw.SetMaxMalformedFrames(envInt("WSGW_MAX_MALFORMED_FRAMES", &problems))
+	wsgw.SetRateLimits(server.RateLimits{
+		MessagesPerSecond: envFloat("WSGW_RATE_LIMIT_MESSAGES_PER_SECOND", &problems),
+		MessageBurst:      envInt("WSGW_RATE_LIMIT_MESSAGE_BURST", &problems),
+		BytesPerSecond:    envFloat("WSGW_RATE_LIMIT_BYTES_PER_SECOND", &problems),
+		ByteBurst:         envInt("WSGW_RATE_LIMIT_BYTE_BURST", &problems),
+		MaxBreaches:       envInt("WSGW_RATE_LIMIT_MAX_BREACHES", &problems),
+	})
+	wsgw.SetAutoscaleTargets(server.AutoscaleTargets{
+		ConnectionsPerReplica: envInt("WSGW_AUTOSCALE_CONNECTIONS", &problems),
+		MessagesPerReplica:    envFloat("WSGW_AUTOSCALE_MESSAGES_PER_SECOND", &problems),
+	})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		<-signals
+		slog.Info("Termination signal received, draining")
+		wsgw.Drain(0) // Start returns once the HTTP server stopped
+	}()
+	if handoffFile := os.Getenv("WSGW_HANDOFF_FILE"); handoffFile != "" {
+		wsgw.SetHandoffFile(handoffFile)
+		go func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGUSR2)
+			<-signals
+			if err := wsgw.Handoff(); err != nil {
+				slog.Error("Session handoff failed", "error", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}()
+	}
+	if config.TLSCertFile != "" {
+		go func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGHUP)
+			for range signals {
+				if err := wsgw.ReloadCertificates(); err != nil {
+					slog.Error("Certificate reload failed", "error", err)
+				}
+			}
+		}()
+	}
+	if geoipFile := os.Getenv("WSGW_GEOIP_DB"); geoipFile != "" {
+		resolver, err := geoip.OpenMaxMind(geoipFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_GEOIP_DB: %w", err))
+		} else {
+			wsgw.SetGeoResolver(resolver)
+		}
+	}
+	if percent, claim := os.Getenv("WSGW_CANARY_PERCENT"), os.Getenv("WSGW_CANARY_CLAIM"); percent != "" || claim != "" {
+		canaryPercent := envInt("WSGW_CANARY_PERCENT", &problems)
+		if canaryPercent < 0 || canaryPercent > 100 {
+			problems = append(problems, fmt.Errorf("WSGW_CANARY_PERCENT: %d is not between 0 and 100", canaryPercent))
+		}
+		handler.SetCanaryPolicy(handler.CanaryPolicy{Percent: canaryPercent, Claim: claim})
+	}
+	if experimentsFile := os.Getenv("WSGW_EXPERIMENTS_FILE"); experimentsFile != "" {
+		provider, err := experiment.LoadFile(experimentsFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_EXPERIMENTS_FILE: %w", err))
+		} else {
+			wsgw.SetExperimentProvider(provider)
+		}
+	}
+	if writeKey := os.Getenv("WSGW_SEGMENT_WRITE_KEY"); writeKey != "" {
+		wsgw.SetAnalyticsSink(analytics.NewSegmentSink(writeKey))
+	} else if analyticsURL := os.Getenv("WSGW_ANALYTICS_URL"); analyticsURL != "" {
+		wsgw.SetAnalyticsSink(analytics.NewHTTPSink(analyticsURL, nil))
+	} else if analyticsFile := os.Getenv("WSGW_ANALYTICS_FILE"); analyticsFile != "" {
+		sink, err := analytics.OpenFileSink(analyticsFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_ANALYTICS_FILE: %w", err))
+		} else {
+			wsgw.SetAnalyticsSink(sink)
+		}
+	}
+	if archiveFile := os.Getenv("WSGW_ARCHIVE_FILE"); archiveFile != "" {
+		sink, err := archive.OpenFileSink(archiveFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("WSGW_ARCHIVE_FILE: %w", err))
+		} else {
+			wsgw.SetEgressAudit(sink, auditSampling(&problems))
+		}
+	}
+	if billingURL, billingMetrics := os.Getenv("WSGW_BILLING_WEBHOOK"), os.Getenv("WSGW_BILLING_METRICS") == "true"; billingURL != "" || billingMetrics {
+		var store metering.Store = metering.NewMemoryStore()
+		if redisClient != nil {
+			store = metering.NewRedisStore(redisClient)
+		}
+		var exporter metering.Exporter
+		if billingURL != "" {
+			exporter = metering.NewWebhookExporter(billingURL)
+		} else {
+			prometheus := metering.NewPrometheusExporter()
+			http.Handle("/metrics/billing", prometheus)
+			exporter = prometheus
+		}
+		meter := metering.NewMeter(store, exporter, os.Getenv("WSGW_TENANT_CLAIM"))
+		go meter.Run(context.Background(), time.Minute)
+		wsgw.SetMeter(meter)
+	}
+	var alerter alerting.Alerter
+	if routingKey := os.Getenv("WSGW_PAGERDUTY_ROUTING_KEY"); routingKey != "" {
+		alerter = alerting.NewPagerDutyAlerter(routingKey)
+	} else if alertURL := os.Getenv("WSGW_ALERT_WEBHOOK"); alertURL != "" {
+		alerter = alerting.NewWebhookAlerter(alertURL)
+	}
+	if alerter != nil {
+		p99 := envInt("WSGW_SLA_P99_MS", &problems)
+		dropRate := envFloat("WSGW_SLA_DROP_RATE", &problems)
+		authFailures := envInt("WSGW_SLA_AUTH_FAILURES", &problems)
+		if p99 == 0 && dropRate == 0 && authFailures == 0 {
+			problems = append(problems, errors.New("alerting: an alerter is configured but no WSGW_SLA_* threshold is set"))
+		}
+		source, _ := os.Hostname()
+		if nodeID := os.Getenv("WSGW_NODE_ID"); nodeID != "" {
+			source = nodeID
+		}
+		monitor := alerting.NewMonitor(alerter, alerting.Thresholds{
+			P99Latency:   time.Duration(p99) * time.Millisecond,
+			DropRate:     dropRate,
+			AuthFailures: authFailures,
+		}, source)
+		go monitor.Run(context.Background(), time.Minute)
+		wsgw.SetSLAMonitor(monitor)
+	}
+	if os.Getenv("WSGW_SLOW_HANDLER_MS") != "" {
+		threshold := envInt("WSGW_SLOW_HANDLER_MS", &problems)
+		handler.SetSlowHandlerThreshold(time.Duration(threshold) * time.Millisecond)
+	}
+	if os.Getenv("WSGW_AUTH_CACHE_SECONDS") != "" {
+		ttl := envInt("WSGW_AUTH_CACHE_SECONDS", &problems)
+		wsgw.SetAuthCache(time.Duration(ttl)*time.Second, envInt("WSGW_AUTH_CACHE_SIZE", &problems))
+	}
+	if os.Getenv("WSGW_REVOCATION") == "true" {
+		var store revocation.Store = revocation.NewMemoryStore()
+		if redisClient != nil {
+			store = revocation.NewRedisStore(redisClient)
+		}
+		wsgw.SetRevocationList(store, 5*time.Second)
+	}
+	if os.Getenv("WSGW_CONNECTION_MEMORY_CAP") != "" {
+		wsgw.SetMemoryCap(envInt("WSGW_CONNECTION_MEMORY_CAP", &problems))
+	}
+	if os.Getenv("WSGW_CONFORMANCE") == "true" {
+		harness := conformance.NewHarness(5 * time.Second)
+		http.HandleFunc("/conformance/ws", harness.ServeWs)
+		http.HandleFunc("/conformance/reports", harness.ServeReports)
+	}
+	if os.Getenv("WSGW_ROOM_LIMIT") != "" {
+		wsgw.SetRoomLimit(envInt("WSGW_ROOM_LIMIT", &problems))
+	}
+	if os.Getenv("WSGW_RECEIPTS") == "true" {
+		var store receipts.Store = receipts.NewMemoryStore(100000)
+		if redisClient != nil {
+			store = receipts.NewRedisStore(redisClient, 7*24*time.Hour)
+		}
+		tracker := receipts.NewTracker(store)
+		go tracker.Run(context.Background(), time.Second)
+		wsgw.SetReceiptTracker(tracker)
+	}
+	if rooms := os.Getenv("WSGW_MODERATED_CHANNELS"); rooms != "" {
+		moderators := make([]moderation.Moderator, 0, 2)
+		if words := os.Getenv("WSGW_BLOCKED_WORDS"); words != "" {
+			moderators = append(moderators, moderation.NewProfanityFilter(strings.Split(words, ","), false))
+		}
+		if perSecond := envInt("WSGW_ROOM_RATE_LIMIT", &problems); perSecond > 0 {
+			moderators = append(moderators, moderation.NewRoomRateLimit(perSecond, time.Second))
+		}
+		if len(moderators) == 0 {
+			problems = append(problems, errors.New("WSGW_MODERATED_CHANNELS is set but neither WSGW_BLOCKED_WORDS nor WSGW_ROOM_RATE_LIMIT is"))
+		}
+		for _, room := range strings.Split(rooms, ",") {
+			wsgw.SetModeration(strings.TrimSpace(room), moderation.Chain(moderators...), false)
+		}
+	}
+	var turnMinter signaling.TurnMinter
+	turnSecret, turnURIs := os.Getenv("WSGW_TURN_SECRET"), os.Getenv("WSGW_TURN_URIS")
+	if turnSecret != "" && turnURIs == "" {
+		problems = append(problems, errors.New("WSGW_TURN_SECRET is set but WSGW_TURN_URIS is empty"))
+	} else if turnSecret == "" && turnURIs != "" {
+		problems = append(problems, errors.New("WSGW_TURN_URIS is set but the WSGW_TURN_SECRET auth key is missing"))
+	} else if turnSecret != "" {
+		turnMinter = signaling.NewSharedSecretMinter(turnSecret, strings.Split(turnURIs, ","), time.Hour)
+	}
+
+	if err := errors.Join(append(problems, wsgw.Validate(), wsgw.EnforcePolicy())...); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintln(os.Stderr, "  -", line)
+		}
+		os.Exit(1)
+	}
+	if *validateConfig {
+		fmt.Println("Configuration OK")
+		return
+	}
+
+	signaling.Register(turnMinter)
+	reactions.Register(wsgw, registry, 250*time.Millisecond)
+	polls.Register(wsgw, registry)
+	if err := location.Register(wsgw, registry, location.Options{MinInterval: time.Second, MinDistance: 10}); err != nil {
+		slog.Error("Failed to register location module", "error", err)
+		os.Exit(1)
+	}
+	if *soakClients > 0 {
+		go soak.Run(context.Background(), wsgw, soak.Options{
+			URL:             soakScheme(config) + config.Addr + config.Path,
+			Clients:         *soakClients,
+			Channels:        *soakChannels,
+			PublishInterval: time.Second,
+			RequestInterval: 5 * time.Second,
+			Lifetime:        time.Minute,
+			ReportInterval:  30 * time.Second,
+			Token:           soakToken,
+		})
+	}
 	wsgw.Start()
 }
+
+// soakScheme returns the WebSocket URL scheme of the gateway's listener.
+func soakScheme(config server.Config) string {
+	if config.TLSCertFile != "" {
+		return "wss://"
+	}
+	return "ws://"
+}
+
+// soakToken returns an unsigned token for a soak client, accepted by the open authenticator.
+func soakToken(client int) string {
+	claims := jwt.MapClaims{"sub": fmt.Sprintf("soak-%d", client), "exp": time.Now().Add(time.Hour).Unix()}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	return token
+}
@@ -0,0 +1,64 @@
+// Command sim runs randomized connection lifecycle simulations and exits non-zero if an invariant is violated.
+//
+// Every run is derived from its seed; rerun a failing run with -seed <seed> -runs 1.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-websocket-boilerplate/internal/server"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+func main() {
+	seed := flag.Uint64("seed", 1, "seed of the first run")
+	runs := flag.Int("runs", 1000, "number of runs, with consecutive seeds")
+	steps := flag.Int("steps", 200, "events per run")
+	parallel := flag.Int("parallel", 8*runtime.NumCPU(), "runs executed concurrently; runs mostly wait, so this may exceed the CPUs")
+	verbose := flag.Bool("v", false, "show gateway logs")
+	flag.Parse()
+
+	if !*verbose {
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+	started := time.Now()
+	reports := make([]*server.SimulationReport, *runs)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(*parallel, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				reports[i] = server.Simulate(*seed+uint64(i), *steps)
+			}
+		}()
+	}
+	for i := 0; i < *runs; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed, connections := 0, 0
+	for _, report := range reports {
+		connections += report.Connections
+		if len(report.Violations) == 0 {
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL seed %d\n", report.Seed)
+		for _, violation := range report.Violations {
+			fmt.Printf("     %s\n", violation)
+		}
+	}
+	fmt.Printf("%d runs, %d connections, %d failed in %s\n", *runs, connections, failed, time.Since(started).Round(time.Millisecond))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
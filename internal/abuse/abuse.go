@@ -0,0 +1,150 @@
+// Package abuse defines the hooks through which the gateway reports connection and message activity to a bot
+// and abuse detector, and the actions the detector can ask the gateway to take.
+package abuse
+
+import (
+	"sync"
+	"time"
+)
+
+// EventKind identifies the activity reported to a Detector.
+type EventKind string
+
+const (
+	Connect         EventKind = "connect"
+	Disconnect      EventKind = "disconnect"
+	Message         EventKind = "message"
+	InvalidMessage  EventKind = "invalid_message"  // Frame could not be decoded
+	ValidationError EventKind = "validation_error" // Handler rejected the request payload
+	AuthFailure     EventKind = "auth_failure"
+)
+
+// Event describes connection or message activity of a client.
+type Event struct {
+	Kind     EventKind
+	ClientID int
+	Subject  string // Empty if not authenticated
+	IP       string
+	Device   string
+	Channel  string
+	Type     string
+	Size     int // Frame size in bytes for message events
+	Time     time.Time
+}
+
+// Action is the measure a Detector asks the gateway to take.
+type Action int
+
+const (
+	Allow     Action = iota // No measure
+	Throttle                // Drop the client's messages for Verdict.Duration
+	Challenge               // Ask the client to solve a challenge, e.g. a CAPTCHA, on the challenge channel
+	Ban                     // Disconnect the client and reject its subject and IP for Verdict.Duration
+)
+
+// Verdict is a Detector's assessment of a client after an event.
+type Verdict struct {
+	Action   Action
+	Score    float64       // Abuse score, higher is worse
+	Reason   string        // Human readable reason, logged and sent with challenges
+	Duration time.Duration // Duration of a throttle or ban
+}
+
+// Detector scores clients from their activity.
+//
+// Observe is called synchronously on the connection's read path and must return quickly.
+type Detector interface {
+	Observe(event Event) Verdict
+}
+
+// RateDetector is a simple Detector that counts events per client in a sliding window.
+type RateDetector struct {
+	sync.Mutex
+	window      time.Duration         // Length of the sliding window
+	maxMessages int                   // Messages per window before throttling
+	maxInvalid  int                   // Invalid frames and validation errors per window before banning
+	throttleFor time.Duration         // Throttle duration
+	banFor      time.Duration         // Ban duration
+	events      map[int][]windowEvent // Recent events keyed by client ID
+}
+
+// windowEvent is an event counted in the sliding window.
+type windowEvent struct {
+	at      time.Time
+	invalid bool
+}
+
+// NewRateDetector creates a RateDetector.
+//
+// Params:
+// - window: Length of the sliding window.
+// - maxMessages: Messages per window before the client is throttled.
+// - maxInvalid: Invalid frames and validation errors per window before the client is banned.
+// - throttleFor: Throttle duration.
+// - banFor: Ban duration.
+func NewRateDetector(window time.Duration, maxMessages int, maxInvalid int, throttleFor time.Duration, banFor time.Duration) *RateDetector {
+	return &RateDetector{
+		window:      window,
+		maxMessages: maxMessages,
+		maxInvalid:  maxInvalid,
+		throttleFor: throttleFor,
+		banFor:      banFor,
+		events:      make(map[int][]windowEvent),
+	}
+}
+
+// Observe records the event and returns a verdict based on the client's recent activity.
+func (d *RateDetector) Observe(event Event) Verdict {
+	d.Lock()
+	defer d.Unlock()
+	if event.Kind == Disconnect {
+		delete(d.events, event.ClientID)
+		return Verdict{}
+	}
+	if event.Kind != Message && event.Kind != InvalidMessage && event.Kind != ValidationError {
+		return Verdict{}
+	}
+
+	recent := d.events[event.ClientID][:0]
+	for _, e := range d.events[event.ClientID] {
+		if event.Time.Sub(e.at) <= d.window {
+			recent = append(recent, e)
+		}
+	}
+	recent = append(recent, windowEvent{at: event.Time, invalid: event.Kind != Message})
+	d.events[event.ClientID] = recent
+
+	messages, invalid := 0, 0
+	for _, e := range recent {
+		if e.invalid {
+			invalid++
+		} else {
+			messages++
+		}
+	}
+	score := float64(messages)/float64(max(d.maxMessages, 1)) + float64(invalid)/float64(max(d.maxInvalid, 1))
+	switch {
+	case d.maxInvalid > 0 && invalid >= d.maxInvalid:
+		return Verdict{Action: Ban, Score: score, Reason: "repeated invalid messages", Duration: d.banFor}
+	case d.maxMessages > 0 && messages > d.maxMessages:
+		return Verdict{Action: Throttle, Score: score, Reason: "message rate exceeded", Duration: d.throttleFor}
+	default:
+		return Verdict{Score: score}
+	}
+}
+
+// RateLimit is a message rate advertised to clients.
+type RateLimit struct {
+	Messages int   `json:"messages"` // Messages allowed per window
+	WindowMs int64 `json:"windowMs"` // Window length in milliseconds
+}
+
+// RateLimited is implemented by detectors that enforce a fixed message rate clients can adapt to.
+type RateLimited interface {
+	RateLimit() RateLimit
+}
+
+// RateLimit returns the message rate above which clients are throttled.
+func (d *RateDetector) RateLimit() RateLimit {
+	return RateLimit{Messages: d.maxMessages, WindowMs: d.window.Milliseconds()}
+}
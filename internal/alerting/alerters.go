@@ -0,0 +1,82 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookAlerter posts alerts as JSON to a URL.
+type WebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAlerter creates a WebhookAlerter.
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the alert.
+func (a *WebhookAlerter) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, a.client, a.url, alert)
+}
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyAlerter sends alerts to PagerDuty. Recoveries resolve the incident opened by the breach.
+type PagerDutyAlerter struct {
+	routingKey string
+	client     *http.Client
+}
+
+// NewPagerDutyAlerter creates a PagerDutyAlerter for the integration's routing key.
+func NewPagerDutyAlerter(routingKey string) *PagerDutyAlerter {
+	return &PagerDutyAlerter{routingKey: routingKey, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify triggers or resolves the PagerDuty incident of the alert.
+func (a *PagerDutyAlerter) Notify(ctx context.Context, alert Alert) error {
+	event := map[string]any{
+		"routing_key":  a.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.Source + "/" + alert.Name,
+		"payload": map[string]any{
+			"summary":        alert.Summary,
+			"source":         alert.Source,
+			"severity":       "critical",
+			"timestamp":      alert.Time.Format(time.RFC3339),
+			"custom_details": alert,
+		},
+	}
+	if alert.Resolved {
+		event["event_action"] = "resolve"
+	}
+	return postJSON(ctx, a.client, pagerDutyEventsURL, event)
+}
+
+// postJSON posts the body as JSON and fails on any status other than 2xx.
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
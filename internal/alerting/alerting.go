@@ -0,0 +1,163 @@
+// Package alerting raises alerts when the gateway's service level degrades.
+//
+// A Monitor collects delivery latencies, delivered and dropped messages and authentication failures, evaluates
+// them against Thresholds once per window and notifies an Alerter when a threshold is breached or recovers.
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Alert names.
+const (
+	DeliveryLatency = "delivery_latency_p99"
+	DropRate        = "drop_rate"
+	AuthFailures    = "auth_failures"
+)
+
+// Alert describes a breached or recovered threshold.
+type Alert struct {
+	Name      string    `json:"name"`
+	Summary   string    `json:"summary"`
+	Source    string    `json:"source"` // Node raising the alert
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Resolved  bool      `json:"resolved"`
+	Time      time.Time `json:"time"`
+}
+
+// Alerter notifies operators.
+type Alerter interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Thresholds configures when alerts are raised. Zero values disable a check.
+type Thresholds struct {
+	P99Latency   time.Duration // Maximum 99th percentile delivery latency
+	DropRate     float64       // Maximum share of dropped messages, 0 to 1
+	AuthFailures int           // Maximum authentication failures per window
+}
+
+// maxLatencySamples bounds the latency samples kept per window.
+const maxLatencySamples = 10000
+
+// Monitor evaluates service level indicators against thresholds.
+type Monitor struct {
+	sync.Mutex
+	alerter    Alerter
+	thresholds Thresholds
+	source     string
+	latencies  []time.Duration // Delivery latencies of the current window
+	seen       int             // Latencies observed in the current window, including unsampled ones
+	delivered  int             // Messages delivered in the current window
+	dropped    int             // Messages dropped in the current window
+	authFailed int             // Authentication failures in the current window
+	firing     map[string]bool // Alerts currently firing
+}
+
+// NewMonitor creates a Monitor.
+//
+// Params:
+// - alerter: The alerter notified of breaches and recoveries.
+// - thresholds: The thresholds.
+// - source: The node name reported in alerts.
+func NewMonitor(alerter Alerter, thresholds Thresholds, source string) *Monitor {
+	return &Monitor{
+		alerter:    alerter,
+		thresholds: thresholds,
+		source:     source,
+		latencies:  make([]time.Duration, 0, 1024),
+		firing:     make(map[string]bool),
+	}
+}
+
+// Delivered records a delivered message and its latency from creation to write.
+func (m *Monitor) Delivered(latency time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.delivered++
+	m.seen++
+	if len(m.latencies) < maxLatencySamples {
+		m.latencies = append(m.latencies, latency)
+	} else if i := rand.IntN(m.seen); i < maxLatencySamples {
+		m.latencies[i] = latency // Reservoir sampling keeps the samples representative
+	}
+}
+
+// Dropped records a message that was not delivered.
+func (m *Monitor) Dropped() {
+	m.Lock()
+	defer m.Unlock()
+	m.dropped++
+}
+
+// AuthFailure records a failed authentication.
+func (m *Monitor) AuthFailure() {
+	m.Lock()
+	defer m.Unlock()
+	m.authFailed++
+}
+
+// Run evaluates the thresholds once per window until the context is done.
+func (m *Monitor) Run(ctx context.Context, window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.evaluate(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate checks the indicators of the current window and starts a new window.
+func (m *Monitor) evaluate(ctx context.Context) {
+	m.Lock()
+	latencies := m.latencies
+	delivered, dropped, authFailed := m.delivered, m.dropped, m.authFailed
+	m.latencies = make([]time.Duration, 0, cap(latencies))
+	m.seen, m.delivered, m.dropped, m.authFailed = 0, 0, 0, 0
+	m.Unlock()
+
+	if m.thresholds.P99Latency > 0 && len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		p99 := latencies[(len(latencies)*99)/100]
+		m.check(ctx, DeliveryLatency, float64(p99.Milliseconds()), float64(m.thresholds.P99Latency.Milliseconds()),
+			fmt.Sprintf("p99 delivery latency %s exceeds %s", p99, m.thresholds.P99Latency))
+	}
+	if m.thresholds.DropRate > 0 && delivered+dropped > 0 {
+		rate := float64(dropped) / float64(delivered+dropped)
+		m.check(ctx, DropRate, rate, m.thresholds.DropRate,
+			fmt.Sprintf("%.2f%% of messages dropped, threshold %.2f%%", rate*100, m.thresholds.DropRate*100))
+	}
+	if m.thresholds.AuthFailures > 0 {
+		m.check(ctx, AuthFailures, float64(authFailed), float64(m.thresholds.AuthFailures),
+			fmt.Sprintf("%d authentication failures, threshold %d", authFailed, m.thresholds.AuthFailures))
+	}
+}
+
+// check notifies the alerter when the alert starts or stops firing.
+func (m *Monitor) check(ctx context.Context, name string, value float64, threshold float64, summary string) {
+	breached := value > threshold
+	if breached == m.firing[name] {
+		return
+	}
+	m.firing[name] = breached
+	alert := Alert{Name: name, Summary: summary, Source: m.source, Value: value, Threshold: threshold, Resolved: !breached, Time: time.Now()}
+	if breached {
+		slog.Warn("SLA threshold breached", "alert", name, "value", value, "threshold", threshold)
+	} else {
+		slog.Info("SLA threshold recovered", "alert", name, "value", value, "threshold", threshold)
+	}
+	if err := m.alerter.Notify(ctx, alert); err != nil {
+		slog.Error("Failed to send alert", "alert", name, "error", err)
+	}
+}
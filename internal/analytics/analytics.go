@@ -0,0 +1,207 @@
+// Package analytics exports usage events of realtime features to an analytics pipeline.
+package analytics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event names emitted by the gateway.
+const (
+	Connect     = "connect"
+	Subscribe   = "subscribe"
+	Disconnect  = "disconnect"
+	StateChange = "state" // Lifecycle state transition, with "from" and "to" properties
+)
+
+// Event is a usage event of a connection.
+type Event struct {
+	Name         string         `json:"event"`
+	Time         time.Time      `json:"time"`
+	ConnectionID int            `json:"conId"`
+	Subject      string         `json:"sub,omitempty"` // Empty if not authenticated
+	Properties   map[string]any `json:"properties,omitempty"`
+}
+
+// Sink receives usage events.
+//
+// Track is called on the connection's hot path and must not block; sinks buffer and export asynchronously.
+type Sink interface {
+	Track(event Event)
+}
+
+// FileSink appends events as JSON lines to a file.
+type FileSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+// OpenFileSink opens the file for appending, creating it if necessary.
+func OpenFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open analytics file %s: %w", path, err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Track appends the event to the file.
+func (s *FileSink) Track(event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode analytics event", "error", err)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		slog.Error("Failed to write analytics event", "error", err)
+	}
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.file.Close()
+}
+
+// HTTPSink posts batches of events to an HTTP endpoint.
+//
+// Events are queued and sent when the batch is full or the flush interval elapses. When the queue is full
+// events are dropped rather than blocking the caller.
+type HTTPSink struct {
+	url       string
+	headers   map[string]string
+	encode    func(events []Event) any // Builds the request body from a batch
+	client    *http.Client
+	queue     chan Event
+	batchSize int
+	interval  time.Duration
+}
+
+// NewHTTPSink creates a sink posting {"batch": [...]} documents to the URL and starts its exporter.
+//
+// Params:
+// - url: The endpoint receiving the batches.
+// - headers: Extra request headers, e.g. an authorization header.
+func NewHTTPSink(url string, headers map[string]string) *HTTPSink {
+	return newHTTPSink(url, headers, func(events []Event) any {
+		return map[string]any{"batch": events}
+	})
+}
+
+// NewSegmentSink creates a sink exporting events as track calls to the Segment batch API.
+//
+// Params:
+// - writeKey: The Segment source write key.
+func NewSegmentSink(writeKey string) *HTTPSink {
+	headers := map[string]string{"Authorization": "Basic " + basicAuth(writeKey)}
+	return newHTTPSink("https://api.segment.io/v1/batch", headers, func(events []Event) any {
+		batch := make([]map[string]any, 0, len(events))
+		for _, e := range events {
+			properties := map[string]any{"conId": e.ConnectionID}
+			for k, v := range e.Properties {
+				properties[k] = v
+			}
+			track := map[string]any{
+				"type":       "track",
+				"event":      e.Name,
+				"timestamp":  e.Time.Format(time.RFC3339Nano),
+				"properties": properties,
+			}
+			if e.Subject != "" {
+				track["userId"] = e.Subject
+			} else {
+				track["anonymousId"] = fmt.Sprint(e.ConnectionID)
+			}
+			batch = append(batch, track)
+		}
+		return map[string]any{"batch": batch}
+	})
+}
+
+// newHTTPSink creates an HTTPSink with the body encoder and starts its exporter.
+func newHTTPSink(url string, headers map[string]string, encode func(events []Event) any) *HTTPSink {
+	s := &HTTPSink{
+		url:       url,
+		headers:   headers,
+		encode:    encode,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		queue:     make(chan Event, 10000),
+		batchSize: 100,
+		interval:  5 * time.Second,
+	}
+	go s.run()
+	return s
+}
+
+// Track queues the event for export.
+func (s *HTTPSink) Track(event Event) {
+	select {
+	case s.queue <- event:
+	default:
+		slog.Warn("Analytics queue full, event dropped", "event", event.Name)
+	}
+}
+
+// run exports queued events in batches.
+func (s *HTTPSink) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, s.batchSize)
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		s.post(batch)
+		batch = make([]Event, 0, s.batchSize)
+	}
+}
+
+// post sends a batch of events.
+func (s *HTTPSink) post(batch []Event) {
+	body, err := json.Marshal(s.encode(batch))
+	if err != nil {
+		slog.Error("Failed to encode analytics batch", "error", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Failed to create analytics request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		slog.Error("Failed to export analytics batch", "error", err, "events", len(batch))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Error("Analytics endpoint rejected batch", "status", resp.StatusCode, "events", len(batch))
+	}
+}
+
+// basicAuth encodes a Segment write key as basic auth credentials with an empty password.
+func basicAuth(writeKey string) string {
+	return base64.StdEncoding.EncodeToString([]byte(writeKey + ":"))
+}
@@ -0,0 +1,64 @@
+// Package archive records delivered messages for compliance review.
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is a message delivered to a connection, with its full envelope as written to the wire.
+type Record struct {
+	Time         time.Time       `json:"time"`
+	ConnectionID int             `json:"conId"`
+	Subject      string          `json:"sub,omitempty"` // Empty if not authenticated
+	Channel      string          `json:"ch"`
+	Envelope     json.RawMessage `json:"envelope"`
+}
+
+// Sink receives archived messages.
+//
+// Archive is called on the connection's write path and must not block for long; sinks writing to remote storage
+// buffer and export asynchronously.
+type Sink interface {
+	Archive(record Record)
+}
+
+// FileSink appends records as JSON lines to a file.
+type FileSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+// OpenFileSink opens the file for appending, creating it if necessary.
+func OpenFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open archive file %s: %w", path, err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Archive appends the record to the file.
+func (s *FileSink) Archive(record Record) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		slog.Error("Failed to encode archive record", "error", err)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		slog.Error("Failed to write archive record", "error", err)
+	}
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,225 @@
+// Package asyncapi generates an AsyncAPI 2.6 document describing the gateway's realtime API from the
+// channel registry and the registered message schemas.
+package asyncapi
+
+import (
+	"go-websocket-boilerplate/internal/channels"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Document is an AsyncAPI 2.6 document.
+type Document struct {
+	AsyncAPI           string              `json:"asyncapi"`
+	Info               Info                `json:"info"`
+	Servers            map[string]Server   `json:"servers,omitempty"`
+	DefaultContentType string              `json:"defaultContentType"`
+	Channels           map[string]*Channel `json:"channels"`
+	Components         Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a gateway endpoint.
+type Server struct {
+	URL      string `json:"url"`
+	Protocol string `json:"protocol"`
+}
+
+// Channel describes the messages exchanged on a channel.
+//
+// Operations are described from the gateway's perspective as AsyncAPI 2 prescribes: clients publish
+// requests and subscribe to responses and updates.
+type Channel struct {
+	Description string         `json:"description,omitempty"`
+	Publish     *Operation     `json:"publish,omitempty"`
+	Subscribe   *Operation     `json:"subscribe,omitempty"`
+	Settings    map[string]any `json:"x-wsgw,omitempty"` // Channel registry settings
+}
+
+// Operation lists the messages of one direction of a channel.
+type Operation struct {
+	OperationID string   `json:"operationId"`
+	Message     OneOfRef `json:"message"`
+}
+
+// OneOfRef references one or more component messages.
+type OneOfRef struct {
+	OneOf []Ref `json:"oneOf"`
+}
+
+// Ref is a JSON reference.
+type Ref struct {
+	Ref string `json:"$ref"`
+}
+
+// Components holds the reusable messages.
+type Components struct {
+	Messages map[string]*Message `json:"messages"`
+}
+
+// Message is a frame exchanged with the gateway.
+type Message struct {
+	Name    string  `json:"name"`
+	Title   string  `json:"title,omitempty"`
+	Summary string  `json:"summary,omitempty"`
+	Payload *Schema `json:"payload"`
+}
+
+// Operation directions.
+const (
+	Request  = "request"  // Sent by the client
+	Response = "response" // Sent by the gateway in answer to a request
+	Update   = "update"   // Pushed by the gateway
+	Error    = "error"    // Sent by the gateway when a request fails
+)
+
+// MessageSpec describes a message type of a channel.
+type MessageSpec struct {
+	Channel   string       // Channel the message is exchanged on
+	Type      string       // Value of the envelope's type field, empty for any
+	Direction string       // Request, Response or Update
+	Data      reflect.Type // Type of the envelope's data field
+	Stream    bool         // True if Data is sent in several StreamFrame responses
+	Summary   string
+}
+
+// Generate builds the AsyncAPI document.
+//
+// Params:
+// - info: The API title and version.
+// - servers: The gateway endpoints keyed by name.
+// - registry: The channel registry; declared channels without messages are documented with their settings.
+// - specs: The messages exchanged on the channels.
+func Generate(info Info, servers map[string]Server, registry *channels.Registry, specs []MessageSpec) *Document {
+	doc := &Document{
+		AsyncAPI:           "2.6.0",
+		Info:               info,
+		Servers:            servers,
+		DefaultContentType: "application/json",
+		Channels:           make(map[string]*Channel),
+		Components:         Components{Messages: make(map[string]*Message)},
+	}
+	channel := func(name string) *Channel {
+		if doc.Channels[name] == nil {
+			doc.Channels[name] = &Channel{}
+		}
+		return doc.Channels[name]
+	}
+
+	if registry != nil {
+		defs := registry.Definitions()
+		sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+		for _, def := range defs {
+			ch := channel(def.Name)
+			ch.Settings = map[string]any{
+				"private":    def.Private,
+				"serverOnly": def.ServerOnly,
+				"scopes":     def.Scopes,
+				"history":    def.History,
+			}
+			if def.ServerOnly {
+				ch.Description = "Server-only channel; clients may subscribe but not publish."
+			}
+		}
+	}
+
+	for _, spec := range specs {
+		name := messageName(spec)
+		doc.Components.Messages[name] = &Message{
+			Name:    name,
+			Title:   spec.Channel + " " + spec.Direction,
+			Summary: spec.Summary,
+			Payload: envelope(spec),
+		}
+		ch := channel(spec.Channel)
+		ref := Ref{Ref: "#/components/messages/" + name}
+		if spec.Direction == Request {
+			if ch.Publish == nil {
+				ch.Publish = &Operation{OperationID: operationID("send", spec.Channel)}
+			}
+			ch.Publish.Message.OneOf = append(ch.Publish.Message.OneOf, ref)
+		} else {
+			if ch.Subscribe == nil {
+				ch.Subscribe = &Operation{OperationID: operationID("receive", spec.Channel)}
+			}
+			ch.Subscribe.Message.OneOf = append(ch.Subscribe.Message.OneOf, ref)
+		}
+	}
+	return doc
+}
+
+// envelope returns the schema of the frame carrying the message.
+func envelope(spec MessageSpec) *Schema {
+	data := SchemaOf(spec.Data)
+	if spec.Stream {
+		data = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"part":     {Type: "integer", Description: "Zero based index of the frame"},
+				"data":     data,
+				"complete": {Type: "boolean", Description: "Marks the last frame of the stream"},
+			},
+			Required: []string{"part"},
+		}
+	}
+	s := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":   {Type: "string", Description: "Request identifier echoed in the response"},
+			"type": {Type: "string"},
+			"ch":   {Type: "string", Const: spec.Channel},
+			"data": data,
+		},
+		Required: []string{"type", "ch"},
+	}
+	if spec.Type != "" {
+		s.Properties["type"].Const = spec.Type
+	}
+	switch spec.Direction {
+	case Request:
+		s.Required = append(s.Required, "id")
+		s.Properties["correlationId"] = &Schema{Type: "string", Description: "Conversation the request belongs to"}
+	case Response, Error:
+		s.Required = append(s.Required, "id")
+		addEgressProperties(s)
+	case Update:
+		addEgressProperties(s)
+	}
+	return s
+}
+
+// addEgressProperties adds the envelope fields the server sets on the messages it sends.
+func addEgressProperties(s *Schema) {
+	s.Properties["msgId"] = &Schema{Type: "string", Description: "Server-originated message identifier"}
+	s.Properties["seq"] = &Schema{Type: "integer", Description: "Per-connection sequence number"}
+	s.Properties["sig"] = &Schema{Type: "string", Description: "Detached JWS over data when signing is enabled"}
+	s.Properties["causationId"] = &Schema{Type: "string", Description: "ID of the request that caused the message"}
+	s.Properties["correlationId"] = &Schema{Type: "string", Description: "Conversation the causing request belongs to"}
+	s.Properties["originClientId"] = &Schema{Type: "integer", Description: "Connection that published the update"}
+}
+
+// messageName builds a component name unique per channel, type and direction.
+func messageName(spec MessageSpec) string {
+	msgType := spec.Type
+	if msgType == "" {
+		msgType = "any"
+	}
+	return identifier(spec.Channel) + "." + identifier(msgType) + "." + spec.Direction
+}
+
+// operationID builds an operation identifier for the channel.
+func operationID(verb string, channel string) string {
+	return verb + "_" + identifier(channel)
+}
+
+// identifier replaces characters not allowed in component names.
+func identifier(s string) string {
+	return strings.NewReplacer("*", "any", "/", "_", " ", "_").Replace(s)
+}
@@ -0,0 +1,88 @@
+package asyncapi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Validate checks a decoded JSON value against the schema.
+//
+// Null is accepted wherever a value is not required, since Go encodes nil pointers, slices and maps as null.
+//
+// Returns:
+// - One message per violation, each prefixed with the JSON path of the offending value.
+func (s *Schema) Validate(value any) []string {
+	return s.validate("$", value)
+}
+
+// validate checks the value at the path.
+func (s *Schema) validate(path string, value any) []string {
+	if s == nil || value == nil {
+		return nil
+	}
+	if s.Const != nil && !reflect.DeepEqual(s.Const, value) {
+		return []string{fmt.Sprintf("%s: expected %v, got %v", path, s.Const, value)}
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{typeError(path, s.Type, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{typeError(path, s.Type, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{typeError(path, s.Type, value)}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return []string{typeError(path, s.Type, value)}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{typeError(path, s.Type, value)}
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+		return problems
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []string{typeError(path, s.Type, value)}
+		}
+		var problems []string
+		for _, name := range s.Required {
+			if v, ok := object[name]; !ok || v == nil {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				problems = append(problems, property.validate(path+"."+name, object[name])...)
+			} else if s.AdditionalProperties != nil {
+				problems = append(problems, s.AdditionalProperties.validate(path+"."+name, object[name])...)
+			}
+		}
+		return problems
+	}
+	return nil
+}
+
+// typeError describes a value of the wrong type.
+func typeError(path string, expected string, value any) string {
+	return fmt.Sprintf("%s: expected %s, got %T", path, expected, value)
+}
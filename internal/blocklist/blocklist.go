@@ -0,0 +1,102 @@
+// Package blocklist stores per-user block and mute lists consulted by the gateway's delivery layer.
+package blocklist
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Store keeps, for each user, the set of users they blocked.
+type Store interface {
+	// Block adds target to the block list of user.
+	Block(user string, target string) error
+	// Unblock removes target from the block list of user.
+	Unblock(user string, target string) error
+	// IsBlocked reports whether recipient blocked sender.
+	IsBlocked(recipient string, sender string) bool
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	sync.RWMutex
+	blocked map[string]map[string]bool
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blocked: make(map[string]map[string]bool)}
+}
+
+// Block adds target to the block list of user.
+func (m *MemoryStore) Block(user string, target string) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.blocked[user] == nil {
+		m.blocked[user] = make(map[string]bool)
+	}
+	m.blocked[user][target] = true
+	return nil
+}
+
+// Unblock removes target from the block list of user.
+func (m *MemoryStore) Unblock(user string, target string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.blocked[user], target)
+	if len(m.blocked[user]) == 0 {
+		delete(m.blocked, user)
+	}
+	return nil
+}
+
+// IsBlocked reports whether recipient blocked sender.
+func (m *MemoryStore) IsBlocked(recipient string, sender string) bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.blocked[recipient][sender]
+}
+
+// redisKeyPrefix namespaces block list keys in Redis.
+const redisKeyPrefix = "wsgw:blocks:"
+
+// redisTimeout bounds each Redis call.
+const redisTimeout = time.Second
+
+// RedisStore is a Store backed by Redis sets, shared by all nodes of a cluster.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a RedisStore using the given client.
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Block adds target to the block list of user.
+func (r *RedisStore) Block(user string, target string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return r.client.SAdd(ctx, redisKeyPrefix+user, target).Err()
+}
+
+// Unblock removes target from the block list of user.
+func (r *RedisStore) Unblock(user string, target string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return r.client.SRem(ctx, redisKeyPrefix+user, target).Err()
+}
+
+// IsBlocked reports whether recipient blocked sender. Lookup failures are logged and treated as not blocked.
+func (r *RedisStore) IsBlocked(recipient string, sender string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	blocked, err := r.client.SIsMember(ctx, redisKeyPrefix+recipient, sender).Result()
+	if err != nil {
+		slog.Error("Failed to check block list", "error", err)
+		return false
+	}
+	return blocked
+}
@@ -0,0 +1,96 @@
+// Package broker relays channel updates between the nodes of a gateway cluster, so a publish on one node reaches
+// the subscribers connected to every node.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+)
+
+// Kinds of relayed messages.
+const (
+	KindPublish   = "publish"   // Update of a channel, delivered to its subscribers
+	KindBroadcast = "broadcast" // Update delivered to every connected client
+	KindKick      = "kick"      // Closes the connections of a subject; Data holds the sanction
+	KindBan       = "ban"       // Bans a subject or IP address and closes its connections; Data holds the sanction
+)
+
+// Message is an update relayed between nodes, carrying the envelope fields the receiving nodes deliver.
+type Message struct {
+	Node           string          `json:"node"` // Node the update originated on; it ignores its own messages
+	Kind           string          `json:"kind"`
+	Channel        string          `json:"ch"`
+	Type           string          `json:"type"`
+	Data           json.RawMessage `json:"data,omitempty"`
+	MessageID      string          `json:"msgId"`
+	CausationID    string          `json:"causationId,omitempty"`
+	CorrelationID  string          `json:"correlationId,omitempty"`
+	OriginClientID int             `json:"originClientId,omitempty"`
+	OriginSubject  string          `json:"originSubject,omitempty"` // Publisher's subject, for block lists and edits
+}
+
+// Interface relays messages between nodes. Implementations deliver every published message to the subscribers
+// of all nodes, including the publishing one.
+type Interface interface {
+	// Publish sends the message to all nodes.
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe calls handle with every message published by any node until the context is cancelled.
+	Subscribe(ctx context.Context, handle func(Message)) error
+}
+
+// Redis relays messages over a Redis pub/sub channel.
+type Redis struct {
+	client redis.UniversalClient
+	topic  string
+}
+
+// NewRedis creates a broker publishing on the Redis pub/sub channel.
+//
+// Params:
+// - client: The Redis client.
+// - topic: The pub/sub channel shared by the nodes of the cluster.
+//
+// Returns:
+// - A pointer to the initialized Redis broker.
+func NewRedis(client redis.UniversalClient, topic string) *Redis {
+	return &Redis{client: client, topic: topic}
+}
+
+// Publish sends the message to all nodes.
+func (r *Redis) Publish(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode broker message: %w", err)
+	}
+	return r.client.Publish(ctx, r.topic, data).Err()
+}
+
+// Subscribe calls handle with every message published by any node until the context is cancelled. Malformed
+// messages are logged and skipped.
+func (r *Redis) Subscribe(ctx context.Context, handle func(Message)) error {
+	sub := r.client.Subscribe(ctx, r.topic)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe to %s: %w", r.topic, err)
+	}
+	messages := sub.Channel()
+	for {
+		select {
+		case payload, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var msg Message
+			if err := json.Unmarshal([]byte(payload.Payload), &msg); err != nil {
+				slog.Warn("Malformed broker message", "topic", r.topic, "error", err)
+				continue
+			}
+			handle(msg)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package channels declares the channels a gateway serves and their properties.
+//
+// The router and the subscription layer consult the Registry instead of matching channel names implicitly.
+package channels
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Definition declares a channel and its properties.
+//
+// Name may be a pattern where a "*" segment matches any single dot separated segment, e.g. "prices.*".
+//
+// BackfillURL is an http(s) URL template in which "{channel}" and "{sub}" are replaced with the subscribed channel
+// and the subscriber's subject. The gateway fetches it on subscribe and delivers the JSON response as a "snapshot"
+// update before any live update.
+type Definition struct {
+	Name         string   `json:"name"`                   // Channel name or pattern
+	Private      bool     `json:"private,omitempty"`      // Requires an authenticated client holding one of Scopes
+	ServerOnly   bool     `json:"serverOnly,omitempty"`   // Clients may subscribe but not publish
+	Scopes       []string `json:"scopes,omitempty"`       // Access control list: any of these scopes grants access
+	History      bool     `json:"history,omitempty"`      // Keep recent updates for new subscribers
+	ReplayDepth  int      `json:"replayDepth,omitempty"`  // Number of recent updates replayed on subscribe
+	ConflationMs int      `json:"conflationMs,omitempty"` // Only the latest update per interval is delivered
+	Countries    []string `json:"countries,omitempty"`    // If set, only clients from these countries may access the channel
+	Metric       bool     `json:"metric,omitempty"`       // Numeric updates subscribers may receive as min/max/avg buckets
+	BackfillURL  string   `json:"backfillUrl,omitempty"`  // URL template of a JSON snapshot sent on subscribe
+	StepUp       *StepUp  `json:"stepUp,omitempty"`       // Stronger authentication required on top of the ACL
+	Encrypted    bool     `json:"encrypted,omitempty"`    // Subscribers receive a rotating key to encrypt payloads end-to-end
+}
+
+// StepUp declares the authentication strength a sensitive channel requires. Clients lacking it may send sys/auth
+// again with a stronger token, e.g. one issued after MFA, to gain access without reconnecting.
+type StepUp struct {
+	ACR        []string `json:"acr,omitempty"`           // Accepted "acr" values or "amr" entries, e.g. "mfa"; any one suffices
+	MaxAuthAge int      `json:"maxAuthAgeSec,omitempty"` // Seconds since "auth_time" within which the user must have authenticated
+}
+
+// AllowsStepUp reports whether the claims satisfy the channel's step-up requirement at the given time.
+func (d *Definition) AllowsStepUp(claims jwt.MapClaims, now time.Time) bool {
+	if d.StepUp == nil {
+		return true
+	}
+	if claims == nil {
+		return false
+	}
+	if len(d.StepUp.ACR) > 0 && !hasACR(claims, d.StepUp.ACR) {
+		return false
+	}
+	if d.StepUp.MaxAuthAge > 0 {
+		authTime, ok := claims["auth_time"].(float64)
+		if !ok || now.Sub(time.Unix(int64(authTime), 0)) > time.Duration(d.StepUp.MaxAuthAge)*time.Second {
+			return false
+		}
+	}
+	return true
+}
+
+// hasACR reports whether the claims' "acr" or one of their "amr" entries is among the accepted values.
+func hasACR(claims jwt.MapClaims, accepted []string) bool {
+	values := make([]string, 0, 4)
+	if acr, ok := claims["acr"].(string); ok {
+		values = append(values, acr)
+	}
+	if amr, ok := claims["amr"].([]any); ok {
+		for _, method := range amr {
+			if s, ok := method.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, value := range values {
+		for _, want := range accepted {
+			if value == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conflation returns the conflation interval of the channel, zero if disabled.
+func (d *Definition) Conflation() time.Duration {
+	return time.Duration(d.ConflationMs) * time.Millisecond
+}
+
+// Allows reports whether a client with the given claims may access the channel.
+func (d *Definition) Allows(claims jwt.MapClaims) bool {
+	if !d.Private {
+		return true
+	}
+	if claims == nil {
+		return false
+	}
+	if len(d.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range d.Scopes {
+		if HasScope(claims, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsCountry reports whether a client connecting from the country may access the channel.
+//
+// Clients whose country is unknown are rejected from geo-restricted channels.
+func (d *Definition) AllowsCountry(country string) bool {
+	if len(d.Countries) == 0 {
+		return true
+	}
+	for _, allowed := range d.Countries {
+		if strings.EqualFold(allowed, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds channel definitions.
+type Registry struct {
+	sync.RWMutex
+	exact    map[string]*Definition // Definitions keyed by exact channel name
+	patterns []*Definition          // Pattern definitions in registration order
+	strict   bool                   // Reject channels without a definition
+}
+
+// NewRegistry creates an empty registry.
+//
+// Params:
+// - strict: When true, channels without a definition are rejected by the router and the subscription layer.
+func NewRegistry(strict bool) *Registry {
+	return &Registry{exact: make(map[string]*Definition), strict: strict}
+}
+
+// Strict reports whether undeclared channels are rejected.
+func (r *Registry) Strict() bool {
+	return r.strict
+}
+
+// Register declares a channel, replacing any previous definition with the same name.
+func (r *Registry) Register(def Definition) error {
+	if def.Name == "" {
+		return fmt.Errorf("channel definition without name")
+	}
+	if def.Name == "sys" || strings.HasPrefix(def.Name, "sys.") {
+		return fmt.Errorf("channel %q: the sys namespace is reserved", def.Name)
+	}
+	if def.ReplayDepth < 0 || def.ConflationMs < 0 {
+		return fmt.Errorf("channel %q: replay depth and conflation must not be negative", def.Name)
+	}
+	if def.StepUp != nil && def.StepUp.MaxAuthAge < 0 {
+		return fmt.Errorf("channel %q: step-up max auth age must not be negative", def.Name)
+	}
+	if err := ValidateName(def.Name); err != nil {
+		return err
+	}
+	for _, scope := range def.Scopes {
+		if strings.TrimSpace(scope) == "" || strings.ContainsAny(scope, " \t") {
+			return fmt.Errorf("channel %q: invalid scope %q", def.Name, scope)
+		}
+	}
+	if def.BackfillURL != "" && !strings.HasPrefix(def.BackfillURL, "http://") && !strings.HasPrefix(def.BackfillURL, "https://") {
+		return fmt.Errorf("channel %q: backfill URL must be an http or https URL", def.Name)
+	}
+	for _, country := range def.Countries {
+		if len(country) != 2 {
+			return fmt.Errorf("channel %q: invalid country code %q, expected ISO 3166-1 alpha-2", def.Name, country)
+		}
+	}
+	r.Lock()
+	defer r.Unlock()
+	if IsPattern(def.Name) {
+		for i, existing := range r.patterns {
+			if existing.Name == def.Name {
+				r.patterns[i] = &def
+				return nil
+			}
+		}
+		r.patterns = append(r.patterns, &def)
+		return nil
+	}
+	r.exact[def.Name] = &def
+	return nil
+}
+
+// Lookup returns the definition of the channel. Exact definitions take precedence over patterns.
+func (r *Registry) Lookup(channel string) (*Definition, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	if def, ok := r.exact[channel]; ok {
+		return def, true
+	}
+	for _, def := range r.patterns {
+		if Match(def.Name, channel) {
+			return def, true
+		}
+	}
+	return nil, false
+}
+
+// Definitions returns all registered definitions.
+func (r *Registry) Definitions() []Definition {
+	r.RLock()
+	defer r.RUnlock()
+	defs := make([]Definition, 0, len(r.exact)+len(r.patterns))
+	for _, def := range r.exact {
+		defs = append(defs, *def)
+	}
+	for _, def := range r.patterns {
+		defs = append(defs, *def)
+	}
+	return defs
+}
+
+// LoadFile registers the channel definitions of a JSON file containing an array of definitions.
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	defs := make([]Definition, 0)
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return fmt.Errorf("parse channel definitions %s: %w", path, err)
+	}
+	for _, def := range defs {
+		if err := r.Register(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateName checks that the channel name or pattern consists of non-empty dot separated segments and
+// that wildcards only appear as whole segments.
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty channel name")
+	}
+	for _, segment := range strings.Split(name, ".") {
+		if segment == "" {
+			return fmt.Errorf("channel %q: empty segment", name)
+		}
+		if segment != "*" && strings.Contains(segment, "*") {
+			return fmt.Errorf("channel %q: wildcard must be a whole segment", name)
+		}
+		if strings.ContainsAny(segment, " \t/") {
+			return fmt.Errorf("channel %q: invalid character in segment %q", name, segment)
+		}
+	}
+	return nil
+}
+
+// IsPattern reports whether the channel name is a pattern.
+func IsPattern(channel string) bool {
+	return strings.Contains(channel, "*")
+}
+
+// Match reports whether the channel matches the dot separated pattern.
+func Match(pattern string, channel string) bool {
+	patternParts := strings.Split(pattern, ".")
+	channelParts := strings.Split(channel, ".")
+	if len(patternParts) != len(channelParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != "*" && part != channelParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasScope reports whether the claims grant the given scope.
+//
+// Both the space separated OAuth "scope" string and a "scope"/"scp" array are supported.
+func HasScope(claims jwt.MapClaims, scope string) bool {
+	if claims == nil {
+		return false
+	}
+	for _, key := range []string{"scope", "scp"} {
+		switch v := claims[key].(type) {
+		case string:
+			for _, s := range strings.Fields(v) {
+				if s == scope {
+					return true
+				}
+			}
+		case []any:
+			for _, s := range v {
+				if str, ok := s.(string); ok && str == scope {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/msgs"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errClosed is returned when the client closed the connection while a frame was expected.
+var errClosed = errors.New("connection closed by the client")
+
+// errTimeout is returned when no matching frame arrived in time.
+var errTimeout = errors.New("timed out waiting for frame")
+
+// connection is a client connection and the frames read from it.
+type connection struct {
+	ws     *websocket.Conn
+	frames chan map[string]any // Frames read from the connection
+	done   chan struct{}       // Closed when the reader stops
+}
+
+// driver plays the gateway's side of the protocol towards the client under test.
+type driver struct {
+	writeLock  sync.Mutex           // Serializes writes of the driver and the automatic responses
+	conn       *connection          // Current connection
+	reconnects chan *websocket.Conn // Connections resuming this run
+	buffered   []map[string]any     // Frames received but not matched yet
+	seq        int64                // Sequence number of the last frame sent
+	sent       map[string][]byte    // Frames sent, keyed by message ID, for replays
+	nextID     int                  // Counter for message IDs
+	token      string               // Resume token issued to the client
+	timeout    time.Duration        // Time to wait for each expected frame
+	authTokens int                  // sys/auth frames received
+	subscribed map[string]bool      // Channels the client subscribed to on the current connection
+}
+
+// attach starts reading from a new connection of the client.
+func (d *driver) attach(ws *websocket.Conn) {
+	conn := &connection{ws: ws, frames: make(chan map[string]any, 64), done: make(chan struct{})}
+	d.conn = conn
+	d.buffered = nil
+	d.subscribed = make(map[string]bool)
+	go d.read(conn)
+}
+
+// read parses frames and answers the sys requests a client may send at any time.
+func (d *driver) read(conn *connection) {
+	defer close(conn.done)
+	for {
+		msgType, data, err := conn.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+		frame := make(map[string]any)
+		if err := json.Unmarshal(data, &frame); err != nil {
+			continue
+		}
+		if frame["ch"] == "sys" {
+			d.answerSys(conn, frame)
+		}
+		conn.frames <- frame
+	}
+}
+
+// answerSys responds to sys requests like the gateway would, so the client is not stalled waiting.
+func (d *driver) answerSys(conn *connection, frame map[string]any) {
+	id, _ := frame["id"].(string)
+	if id == "" {
+		return
+	}
+	msgType, _ := frame["type"].(string)
+	var resp any = msgs.NewErrorResponse(id, msgs.CodeUnknownMessage, "unknown sys message")
+	switch msgType {
+	case "subscribe", "unsubscribe":
+		data, _ := frame["data"].(map[string]any)
+		results := make([]map[string]any, 0)
+		for _, channel := range subscribeChannels(data) {
+			results = append(results, map[string]any{"ch": channel, "ok": true})
+		}
+		resp = results
+	case "capabilities":
+		resp = map[string]any{}
+	}
+	_ = d.write(conn, map[string]any{"id": id, "type": msgType, "ch": "sys", "data": resp})
+}
+
+// subscribeChannels returns the channels of a sys/subscribe payload.
+func subscribeChannels(data map[string]any) []string {
+	channels := make([]string, 0)
+	if channel, ok := data["ch"].(string); ok && channel != "" {
+		channels = append(channels, channel)
+	}
+	if list, ok := data["channels"].([]any); ok {
+		for _, channel := range list {
+			if s, ok := channel.(string); ok {
+				channels = append(channels, s)
+			}
+		}
+	}
+	return channels
+}
+
+// write sends a frame as JSON.
+func (d *driver) write(conn *connection, frame map[string]any) error {
+	raw, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return d.writeRaw(conn, websocket.TextMessage, raw)
+}
+
+// writeRaw sends a message as is.
+func (d *driver) writeRaw(conn *connection, msgType int, data []byte) error {
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+	return conn.ws.WriteMessage(msgType, data)
+}
+
+// update sends a server-originated update with the next sequence number and a fresh message ID.
+//
+// Returns:
+// - The message ID of the update.
+func (d *driver) update(updateType string, channel string, data any) (string, error) {
+	d.nextID++
+	msgID := "conformance-" + strconv.Itoa(d.nextID)
+	d.seq++
+	raw, err := json.Marshal(map[string]any{"type": updateType, "ch": channel, "data": data, "seq": d.seq, "msgId": msgID})
+	if err != nil {
+		return "", err
+	}
+	d.sent[msgID] = raw
+	return msgID, d.writeRaw(d.conn, websocket.TextMessage, raw)
+}
+
+// replay sends a previously sent frame again, unchanged.
+func (d *driver) replay(msgID string) error {
+	return d.writeRaw(d.conn, websocket.TextMessage, d.sent[msgID])
+}
+
+// await returns the first frame matching, consuming it, or an error once the timeout elapses or the client
+// closes the connection.
+func (d *driver) await(timeout time.Duration, match func(frame map[string]any) bool) (map[string]any, error) {
+	for i, frame := range d.buffered {
+		if match(frame) {
+			d.buffered = append(d.buffered[:i], d.buffered[i+1:]...)
+			return frame, nil
+		}
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case frame := <-d.conn.frames:
+			d.observe(frame)
+			if match(frame) {
+				return frame, nil
+			}
+			d.buffered = append(d.buffered, frame)
+		case <-d.conn.done:
+			// Drain frames read before the connection closed.
+			select {
+			case frame := <-d.conn.frames:
+				d.observe(frame)
+				if match(frame) {
+					return frame, nil
+				}
+				d.buffered = append(d.buffered, frame)
+				continue
+			default:
+			}
+			return nil, errClosed
+		case <-deadline.C:
+			return nil, errTimeout
+		}
+	}
+}
+
+// observe records protocol state carried by a frame read from the client.
+func (d *driver) observe(frame map[string]any) {
+	if frame["ch"] != "sys" {
+		return
+	}
+	switch frame["type"] {
+	case "auth":
+		if data, ok := frame["data"].(map[string]any); ok {
+			if token, _ := data["authToken"].(string); token != "" {
+				d.authTokens++
+			}
+		}
+	case "subscribe":
+		data, _ := frame["data"].(map[string]any)
+		for _, channel := range subscribeChannels(data) {
+			d.subscribed[channel] = true
+		}
+	}
+}
+
+// awaitAck waits for the client's acknowledgement of an update.
+func (d *driver) awaitAck(timeout time.Duration, msgID string) (map[string]any, error) {
+	return d.await(timeout, func(frame map[string]any) bool {
+		if frame["ch"] != Channel || frame["type"] != "ack" {
+			return false
+		}
+		data, _ := frame["data"].(map[string]any)
+		return data["msgId"] == msgID
+	})
+}
+
+// close closes the current connection.
+func (d *driver) close() {
+	_ = d.conn.ws.Close()
+}
@@ -0,0 +1,158 @@
+// Package conformance certifies client implementations of the gateway protocol.
+//
+// The harness plays the gateway towards a client under test and runs scripted scenarios: handshake and
+// authentication, update delivery, duplicate message IDs, replayed frames, malformed frames and reconnecting
+// after the connection was closed on auth expiry. The client connects to /conformance/ws with any non-empty
+// token and an adapter that subscribes to the "conformance" channel and acknowledges every update delivered to
+// the application with {"type":"ack","ch":"conformance","data":{"msgId":"<msgId>"}}. The report is sent to the
+// client as a sys "conformance" update and listed at /conformance/reports.
+package conformance
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/contract"
+	"go-websocket-boilerplate/internal/ids"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Channel is the channel the client under test subscribes to and acknowledges updates on.
+const Channel = "conformance"
+
+// maxResults is the number of reports kept for /conformance/reports.
+const maxResults = 50
+
+// Result is the outcome of one certification run.
+type Result struct {
+	ID        string          `json:"id"`
+	UserAgent string          `json:"userAgent"`
+	Started   time.Time       `json:"started"`
+	Passed    bool            `json:"passed"`
+	Report    contract.Report `json:"report"`
+}
+
+// scenario is one scripted step of a run. A returned error ends the run; problems fail only the scenario.
+type scenario struct {
+	name string
+	run  func(d *driver, check *contract.Check) error
+}
+
+// scenarios are run in order on the same connection.
+var scenarios = []scenario{
+	{"handshake", handshake},
+	{"auth", expectAuth},
+	{"subscribe", expectSubscribe},
+	{"update delivery", deliverUpdate},
+	{"duplicate message IDs", duplicateIDs},
+	{"replayed frames", replayedFrames},
+	{"malformed frames", malformedFrames},
+	{"auth expiry", authExpiry},
+	{"resume", resume},
+}
+
+// Harness accepts client connections and certifies them.
+type Harness struct {
+	sync.Mutex
+	timeout  time.Duration      // Time to wait for each expected frame
+	upgrader websocket.Upgrader // Accepts connections from any origin
+	runs     map[string]*driver // Active runs keyed by resume token
+	results  []*Result          // Recent results, newest last
+}
+
+// NewHarness creates a Harness.
+//
+// Params:
+// - timeout: Time to wait for each expected frame; reconnects may take three times as long.
+//
+// Returns:
+// - A pointer to the initialized Harness.
+func NewHarness(timeout time.Duration) *Harness {
+	return &Harness{
+		timeout:  timeout,
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		runs:     make(map[string]*driver),
+	}
+}
+
+// ServeWs starts a run for a new connection, or hands a connection resuming a run to it.
+func (h *Harness) ServeWs(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Info("Conformance upgrade failed", "error", err)
+		return
+	}
+	if token := r.URL.Query().Get("resume"); token != "" {
+		h.Lock()
+		d, ok := h.runs[token]
+		h.Unlock()
+		if ok {
+			select {
+			case d.reconnects <- ws:
+				return
+			default:
+			}
+		}
+	}
+	go h.run(ws, r.UserAgent())
+}
+
+// ServeReports lists the recent results as JSON.
+func (h *Harness) ServeReports(w http.ResponseWriter, _ *http.Request) {
+	h.Lock()
+	results := append([]*Result(nil), h.results...)
+	h.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+// run executes the scenarios against a client and records the result.
+func (h *Harness) run(ws *websocket.Conn, userAgent string) {
+	id := ids.KSUID{}.NewID()
+	d := &driver{
+		reconnects: make(chan *websocket.Conn, 1),
+		sent:       make(map[string][]byte),
+		token:      id,
+		timeout:    h.timeout,
+	}
+	d.attach(ws)
+	h.Lock()
+	h.runs[d.token] = d
+	h.Unlock()
+	defer func() {
+		h.Lock()
+		delete(h.runs, d.token)
+		h.Unlock()
+		d.close()
+	}()
+
+	result := &Result{ID: id, UserAgent: userAgent, Started: time.Now()}
+	var fatal error
+	for _, s := range scenarios {
+		check := &contract.Check{Name: s.name}
+		result.Report.Checks = append(result.Report.Checks, check)
+		if fatal != nil {
+			check.Skipped = fatal.Error()
+			continue
+		}
+		if err := s.run(d, check); err != nil {
+			check.Problems = append(check.Problems, err.Error())
+			fatal = err
+		}
+	}
+	result.Passed = !result.Report.Failed() && fatal == nil
+	slog.Info("Conformance run finished", "id", id, "userAgent", userAgent, "passed", result.Passed)
+	if fatal == nil {
+		_ = d.write(d.conn, map[string]any{"type": "conformance", "ch": "sys", "data": result})
+		_ = d.writeRaw(d.conn, websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "conformance run finished"))
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	h.results = append(h.results, result)
+	if len(h.results) > maxResults {
+		h.results = h.results[len(h.results)-maxResults:]
+	}
+}
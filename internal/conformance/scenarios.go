@@ -0,0 +1,202 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/contract"
+	"time"
+)
+
+// welcome sends the banner and session frames the gateway sends on connect.
+func (d *driver) welcome() error {
+	if _, err := d.update("welcome", "sys", map[string]any{
+		"version":       "conformance",
+		"nodeId":        "conformance",
+		"conId":         1,
+		"authenticated": false,
+		"authExpire":    0,
+		"limits":        map[string]any{"heartbeatMs": 10000, "pongTimeoutMs": 60000},
+	}); err != nil {
+		return err
+	}
+	_, err := d.update("session", "sys", map[string]any{"resumeToken": d.token})
+	return err
+}
+
+// handshake sends the welcome frames.
+func handshake(d *driver, _ *contract.Check) error {
+	return d.welcome()
+}
+
+// expectAuth verifies that the client authenticates with sys/auth.
+func expectAuth(d *driver, check *contract.Check) error {
+	_, err := d.await(d.timeout, isSys("auth"))
+	if errors.Is(err, errTimeout) {
+		check.Problems = append(check.Problems, "client sent no sys/auth with a token")
+		return nil
+	}
+	return err
+}
+
+// expectSubscribe verifies that the client subscribes to the conformance channel.
+func expectSubscribe(d *driver, check *contract.Check) error {
+	if err := awaitSubscribed(d); errors.Is(err, errTimeout) {
+		check.Problems = append(check.Problems, fmt.Sprintf("client did not subscribe to %q", Channel))
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// awaitSubscribed waits until the client subscribed to the conformance channel on the current connection.
+func awaitSubscribed(d *driver) error {
+	if d.subscribed[Channel] {
+		return nil
+	}
+	_, err := d.await(d.timeout, func(frame map[string]any) bool {
+		return isSys("subscribe")(frame) && d.subscribed[Channel]
+	})
+	return err
+}
+
+// deliverUpdate verifies that an update reaches the application.
+func deliverUpdate(d *driver, check *contract.Check) error {
+	msgID, err := d.update("tick", Channel, map[string]any{"n": 1})
+	if err != nil {
+		return err
+	}
+	return expectAck(d, check, msgID)
+}
+
+// duplicateIDs verifies that an update delivered twice under the same message ID reaches the application once.
+func duplicateIDs(d *driver, check *contract.Check) error {
+	msgID, err := d.update("tick", Channel, map[string]any{"n": 2})
+	if err != nil {
+		return err
+	}
+	if err := d.replay(msgID); err != nil {
+		return err
+	}
+	if err := expectAck(d, check, msgID); err != nil || len(check.Problems) > 0 {
+		return err
+	}
+	return expectNoAck(d, check, msgID, "duplicate message ID delivered twice")
+}
+
+// replayedFrames verifies that a frame replayed after newer frames is not delivered again.
+func replayedFrames(d *driver, check *contract.Check) error {
+	first, err := d.update("tick", Channel, map[string]any{"n": 3})
+	if err != nil {
+		return err
+	}
+	if err := expectAck(d, check, first); err != nil || len(check.Problems) > 0 {
+		return err
+	}
+	second, err := d.update("tick", Channel, map[string]any{"n": 4})
+	if err != nil {
+		return err
+	}
+	if err := expectAck(d, check, second); err != nil || len(check.Problems) > 0 {
+		return err
+	}
+	if err := d.replay(first); err != nil {
+		return err
+	}
+	return expectNoAck(d, check, first, "replayed frame delivered again")
+}
+
+// malformedFrames verifies that the client survives frames it cannot parse and keeps processing updates.
+func malformedFrames(d *driver, check *contract.Check) error {
+	malformed := []struct {
+		msgType int
+		data    string
+	}{
+		{websocket.TextMessage, "not json"},
+		{websocket.TextMessage, `{"type":"tick","ch":`},
+		{websocket.TextMessage, `[1,2,3]`},
+		{websocket.TextMessage, `{"ch":"conformance"}`},
+		{websocket.BinaryMessage, "\x00\xff\x10"},
+	}
+	for _, frame := range malformed {
+		if err := d.writeRaw(d.conn, frame.msgType, []byte(frame.data)); err != nil {
+			return err
+		}
+	}
+	msgID, err := d.update("tick", Channel, map[string]any{"n": 5})
+	if err != nil {
+		return err
+	}
+	return expectAck(d, check, msgID)
+}
+
+// authExpiry closes the connection like the gateway does when the token expires and verifies that the client
+// reconnects with its resume token and authenticates again.
+func authExpiry(d *driver, check *contract.Check) error {
+	authTokens := d.authTokens
+	_ = d.writeRaw(d.conn, websocket.CloseMessage, nil)
+	d.close()
+	select {
+	case ws := <-d.reconnects:
+		d.attach(ws)
+	case <-time.After(3 * d.timeout):
+		return errors.New("client did not reconnect with its resume token")
+	}
+	if err := d.welcome(); err != nil {
+		return err
+	}
+	if _, err := d.await(d.timeout, isSys("auth")); errors.Is(err, errTimeout) || d.authTokens == authTokens {
+		check.Problems = append(check.Problems, "client did not send sys/auth after reconnecting")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// resume verifies that the client restores its subscriptions on the resumed connection.
+func resume(d *driver, check *contract.Check) error {
+	if err := awaitSubscribed(d); errors.Is(err, errTimeout) {
+		check.Problems = append(check.Problems, fmt.Sprintf("client did not resubscribe to %q", Channel))
+		return nil
+	} else if err != nil {
+		return err
+	}
+	msgID, err := d.update("tick", Channel, map[string]any{"n": 6})
+	if err != nil {
+		return err
+	}
+	return expectAck(d, check, msgID)
+}
+
+// expectAck records a problem unless the update is acknowledged in time.
+func expectAck(d *driver, check *contract.Check, msgID string) error {
+	_, err := d.awaitAck(d.timeout, msgID)
+	if errors.Is(err, errTimeout) {
+		check.Problems = append(check.Problems, fmt.Sprintf("update %s was not acknowledged", msgID))
+		return nil
+	}
+	return err
+}
+
+// expectNoAck records a problem if the update is acknowledged again within the timeout.
+func expectNoAck(d *driver, check *contract.Check, msgID string, problem string) error {
+	_, err := d.awaitAck(d.timeout, msgID)
+	switch {
+	case err == nil:
+		check.Problems = append(check.Problems, problem)
+		return nil
+	case errors.Is(err, errTimeout):
+		return nil
+	default:
+		return err
+	}
+}
+
+// isSys matches sys frames of the given type.
+func isSys(msgType string) func(frame map[string]any) bool {
+	return func(frame map[string]any) bool {
+		return frame["ch"] == "sys" && frame["type"] == msgType
+	}
+}
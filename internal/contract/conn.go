@@ -0,0 +1,78 @@
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"strconv"
+	"time"
+)
+
+// errClosed is returned when the gateway closed the connection while a frame was expected.
+var errClosed = errors.New("connection closed")
+
+// conn is a test connection that remembers every frame it received.
+type conn struct {
+	ws       *websocket.Conn
+	nextID   int
+	received []map[string]any
+}
+
+// send writes a frame.
+func (c *conn) send(id string, msgType string, channel string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	frame := map[string]any{"type": msgType, "ch": channel, "data": json.RawMessage(raw)}
+	if id != "" {
+		frame["id"] = id
+	}
+	return c.ws.WriteJSON(frame)
+}
+
+// request sends a frame with a fresh ID and waits for the response carrying it.
+func (c *conn) request(timeout time.Duration, msgType string, channel string, data any) (map[string]any, error) {
+	c.nextID++
+	id := "contract-" + strconv.Itoa(c.nextID)
+	if err := c.send(id, msgType, channel, data); err != nil {
+		return nil, err
+	}
+	frame, err := c.await(timeout, func(f map[string]any) bool { return f["id"] == id })
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", channel, msgType, err)
+	}
+	return frame, nil
+}
+
+// await reads frames until one matches, the timeout elapses or the connection is closed.
+func (c *conn) await(timeout time.Duration, match func(frame map[string]any) bool) (map[string]any, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := c.ws.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, errors.New("timed out waiting for frame")
+			}
+			return nil, errClosed
+		}
+		frame := make(map[string]any)
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return nil, fmt.Errorf("frame is not a JSON object: %w", err)
+		}
+		c.received = append(c.received, frame)
+		if match(frame) {
+			return frame, nil
+		}
+	}
+}
+
+// close closes the connection.
+func (c *conn) close() {
+	_ = c.ws.Close()
+}
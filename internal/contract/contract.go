@@ -0,0 +1,339 @@
+// Package contract checks a running gateway against its published AsyncAPI document.
+//
+// The checks cover the envelope of every frame, the sys protocol, the authentication flow and the error
+// codes of the registered handlers, so consuming applications can catch wire-format breaks in CI.
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/asyncapi"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Check is the outcome of one contract check.
+type Check struct {
+	Name     string   `json:"name"`
+	Problems []string `json:"problems,omitempty"`
+	Skipped  string   `json:"skipped,omitempty"` // Reason the check did not run
+}
+
+// Passed reports whether the check ran without problems.
+func (c *Check) Passed() bool {
+	return c.Skipped == "" && len(c.Problems) == 0
+}
+
+// Report is the outcome of a contract run.
+type Report struct {
+	Checks []*Check `json:"checks"`
+}
+
+// Failed reports whether any check found problems.
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if len(check.Problems) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner runs the contract checks against a gateway.
+type Runner struct {
+	baseURL string        // HTTP base URL of the gateway, e.g. http://localhost:3000
+	token   string        // Valid JWT for the authentication flow; the auth checks are skipped if empty
+	timeout time.Duration // Time to wait for each expected frame
+	doc     *asyncapi.Document
+	report  *Report
+}
+
+// NewRunner creates a Runner.
+//
+// Params:
+// - baseURL: The HTTP base URL of the gateway.
+// - token: A valid JWT used for the authentication flow, or an empty string to skip it.
+// - timeout: Time to wait for each expected frame.
+func NewRunner(baseURL string, token string, timeout time.Duration) *Runner {
+	return &Runner{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, timeout: timeout}
+}
+
+// Run executes all checks.
+func (r *Runner) Run(ctx context.Context) *Report {
+	r.report = &Report{}
+	check := r.check("asyncapi document")
+	if err := r.loadDocument(ctx); err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return r.report
+	}
+
+	conn, err := r.dial(ctx)
+	check = r.check("connect")
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return r.report
+	}
+	defer conn.close()
+
+	r.checkWelcome(conn)
+	r.checkRequest(conn, "capabilities", "capabilities", map[string]any{}, "sys.capabilities.response")
+	r.checkUnknownSys(conn)
+	if r.token == "" {
+		r.skip("auth flow", "no token")
+		r.skip("handler error codes", "no token")
+	} else {
+		r.checkAuth(conn)
+		r.checkHandlerErrors(conn)
+	}
+	r.checkInvalidAuth(ctx)
+	r.checkMalformedFrame(ctx)
+	r.checkSequence(conn)
+	return r.report
+}
+
+// check adds a check to the report.
+func (r *Runner) check(name string) *Check {
+	check := &Check{Name: name}
+	r.report.Checks = append(r.report.Checks, check)
+	return check
+}
+
+// skip adds a skipped check to the report.
+func (r *Runner) skip(name string, reason string) {
+	r.check(name).Skipped = reason
+}
+
+// loadDocument fetches the gateway's AsyncAPI document.
+func (r *Runner) loadDocument(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/asyncapi.json", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch asyncapi.json: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch asyncapi.json: status %d", resp.StatusCode)
+	}
+	doc := &asyncapi.Document{}
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		return fmt.Errorf("decode asyncapi.json: %w", err)
+	}
+	r.doc = doc
+	return nil
+}
+
+// schema returns the payload schema of a component message.
+func (r *Runner) schema(name string) (*asyncapi.Schema, bool) {
+	msg, ok := r.doc.Components.Messages[name]
+	if !ok {
+		return nil, false
+	}
+	return msg.Payload, true
+}
+
+// validate checks a frame against a component message and records the problems.
+func (r *Runner) validate(check *Check, name string, frame map[string]any) {
+	schema, ok := r.schema(name)
+	if !ok {
+		check.Problems = append(check.Problems, fmt.Sprintf("message %s is not documented", name))
+		return
+	}
+	check.Problems = append(check.Problems, schema.Validate(frame)...)
+}
+
+// checkWelcome verifies the banner and session frames sent on connect.
+func (r *Runner) checkWelcome(conn *conn) {
+	check := r.check("welcome")
+	frame, err := conn.await(r.timeout, func(f map[string]any) bool { return f["ch"] == "sys" && f["type"] == "welcome" })
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	r.validate(check, "sys.welcome.update", frame)
+
+	check = r.check("session")
+	frame, err = conn.await(r.timeout, func(f map[string]any) bool { return f["ch"] == "sys" && f["type"] == "session" })
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	r.validate(check, "sys.session.update", frame)
+}
+
+// checkRequest sends a sys request and validates the response.
+func (r *Runner) checkRequest(conn *conn, name string, msgType string, data any, message string) map[string]any {
+	check := r.check(name)
+	frame, err := conn.request(r.timeout, msgType, "sys", data)
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return nil
+	}
+	r.validate(check, message, frame)
+	return frame
+}
+
+// checkUnknownSys verifies that unknown sys messages are answered rather than dropped.
+func (r *Runner) checkUnknownSys(conn *conn) {
+	check := r.check("unknown sys message")
+	frame, err := conn.request(r.timeout, "contract-unknown", "sys", map[string]any{})
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	code := ""
+	if body, ok := frame["data"].(map[string]any); ok {
+		if e, ok := body["error"].(map[string]any); ok {
+			code, _ = e["code"].(string)
+		}
+	}
+	if code != "unknown_message" {
+		check.Problems = append(check.Problems, fmt.Sprintf("expected error code unknown_message, got %v", frame["data"]))
+	}
+}
+
+// checkAuth authenticates the connection and verifies it stays usable.
+func (r *Runner) checkAuth(conn *conn) {
+	check := r.check("auth flow")
+	if err := conn.send("", "auth", "sys", map[string]any{"authToken": r.token}); err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	frame, err := conn.request(r.timeout, "hello", "sys", map[string]any{"installationId": "contract", "tabId": "contract"})
+	if err != nil {
+		check.Problems = append(check.Problems, "connection unusable after sys/auth: "+err.Error())
+		return
+	}
+	r.validate(check, "sys.connections.response", frame)
+}
+
+// checkInvalidAuth verifies that an invalid token closes the connection.
+func (r *Runner) checkInvalidAuth(ctx context.Context) {
+	check := r.check("invalid auth closes connection")
+	conn, err := r.dial(ctx)
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	defer conn.close()
+	if err := conn.send("", "auth", "sys", map[string]any{"authToken": "not-a-jwt"}); err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	if _, err := conn.await(r.timeout, func(map[string]any) bool { return false }); !errors.Is(err, errClosed) {
+		check.Problems = append(check.Problems, "connection stayed open after an invalid token")
+	}
+}
+
+// checkMalformedFrame verifies that a frame that is not JSON is answered with an error and keeps the connection
+// open.
+func (r *Runner) checkMalformedFrame(ctx context.Context) {
+	check := r.check("malformed frame answered")
+	conn, err := r.dial(ctx)
+	if err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	defer conn.close()
+	if err := conn.ws.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return
+	}
+	frame, err := conn.await(r.timeout, func(f map[string]any) bool { return f["ch"] == "sys" && f["type"] == "error" })
+	if err != nil {
+		check.Problems = append(check.Problems, "no sys/error update: "+err.Error())
+		return
+	}
+	code := ""
+	if body, ok := frame["data"].(map[string]any); ok {
+		if e, ok := body["error"].(map[string]any); ok {
+			code, _ = e["code"].(string)
+		}
+	}
+	if code != "malformed_frame" {
+		check.Problems = append(check.Problems, fmt.Sprintf("expected error code malformed_frame, got %v", frame["data"]))
+	}
+	if _, err := conn.request(r.timeout, "capabilities", "sys", map[string]any{}); err != nil {
+		check.Problems = append(check.Problems, "connection unusable after a malformed frame: "+err.Error())
+	}
+}
+
+// checkHandlerErrors sends an empty request to every handler whose request has required fields and
+// verifies the validation error frame.
+func (r *Runner) checkHandlerErrors(conn *conn) {
+	names := make([]string, 0)
+	for name := range r.doc.Components.Messages {
+		if strings.HasSuffix(name, ".request") && !strings.HasPrefix(name, "sys.") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg := r.doc.Components.Messages[name]
+		data := msg.Payload.Properties["data"]
+		channel, _ := msg.Payload.Properties["ch"].Const.(string)
+		msgType, _ := msg.Payload.Properties["type"].Const.(string)
+		if data == nil || len(data.Required) == 0 || strings.Contains(channel, "*") {
+			continue
+		}
+		if msgType == "" {
+			msgType = "contract"
+		}
+		check := r.check("validation error " + channel + "/" + msgType)
+		frame, err := conn.request(r.timeout, msgType, channel, map[string]any{})
+		if err != nil {
+			check.Problems = append(check.Problems, err.Error())
+			continue
+		}
+		r.validate(check, strings.TrimSuffix(name, ".request")+".error", frame)
+		code := ""
+		if body, ok := frame["data"].(map[string]any); ok {
+			if e, ok := body["error"].(map[string]any); ok {
+				code, _ = e["code"].(string)
+			}
+		}
+		if code != "validation_failed" {
+			check.Problems = append(check.Problems, fmt.Sprintf("expected error code validation_failed, got %q", code))
+		}
+	}
+}
+
+// checkSequence verifies that every frame received carried an increasing sequence number.
+func (r *Runner) checkSequence(conn *conn) {
+	check := r.check("envelope sequence")
+	var last float64
+	for i, frame := range conn.received {
+		seq, ok := frame["seq"].(float64)
+		if !ok {
+			check.Problems = append(check.Problems, fmt.Sprintf("frame %d has no seq", i))
+			continue
+		}
+		if seq <= last {
+			check.Problems = append(check.Problems, fmt.Sprintf("frame %d: seq %v not greater than %v", i, seq, last))
+		}
+		last = seq
+	}
+}
+
+// dial opens a WebSocket connection to the gateway.
+func (r *Runner) dial(ctx context.Context) (*conn, error) {
+	u, err := url.Parse(r.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = "/ws"
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", u, err)
+	}
+	return &conn{ws: ws}, nil
+}
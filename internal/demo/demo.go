@@ -0,0 +1,20 @@
+// Package demo serves an embedded demo page that exercises the gateway protocol from a browser.
+package demo
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the demo page and its assets.
+func Handler() http.Handler {
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // The embedded directory is part of the binary
+	}
+	return http.FileServer(http.FS(root))
+}
@@ -0,0 +1,64 @@
+// Admin dashboard: memory accounting and generated client snippets, both behind the admin scope.
+(function () {
+  const $ = (id) => document.getElementById(id);
+  let examples = [];
+  let language = "javascript";
+
+  $("token").value = sessionStorage.getItem("wsgw-admin-token") || "";
+
+  async function get(path) {
+    const response = await fetch(path, { headers: { Authorization: "Bearer " + $("token").value } });
+    if (!response.ok) throw new Error(path + ": " + response.status);
+    return response.json();
+  }
+
+  function text(tag, value) {
+    const element = document.createElement(tag);
+    element.textContent = value;
+    return element;
+  }
+
+  function renderMemory(report) {
+    const root = $("memory");
+    root.replaceChildren(text("p", report.connections + " connections, " + report.total + " bytes" +
+      (report.cap ? ", cap " + report.cap + " bytes per connection" : "")));
+    const table = document.createElement("table");
+    const header = document.createElement("tr");
+    ["conId", "sub", "ingress", "egress", "replay", "session", "subscriptions", "total"].forEach((c) => header.appendChild(text("th", c)));
+    table.appendChild(header);
+    report.top.forEach((usage) => {
+      const row = document.createElement("tr");
+      ["conId", "sub", "ingress", "egress", "replay", "session", "subscriptions", "total"].forEach((c) => row.appendChild(text("td", usage[c] ?? "")));
+      table.appendChild(row);
+    });
+    root.appendChild(table);
+  }
+
+  function renderExamples() {
+    const tabs = $("languages");
+    tabs.replaceChildren();
+    ["javascript", "python", "go"].forEach((lang) => {
+      const button = text("button", lang);
+      button.className = lang === language ? "active" : "";
+      button.onclick = () => { language = lang; renderExamples(); };
+      tabs.appendChild(button);
+    });
+    const root = $("examples");
+    root.replaceChildren();
+    examples.filter((e) => e.language === language).forEach((e) => {
+      root.appendChild(text("h3", e.ch + " / " + e.type));
+      root.appendChild(text("pre", e.code));
+    });
+  }
+
+  $("load").onclick = async () => {
+    sessionStorage.setItem("wsgw-admin-token", $("token").value);
+    try {
+      renderMemory(await get("/admin/memory"));
+      examples = await get("/admin/examples");
+      renderExamples();
+    } catch (err) {
+      $("memory").textContent = err.message;
+    }
+  };
+})();
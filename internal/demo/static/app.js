@@ -0,0 +1,66 @@
+// Demo client for the gateway protocol: {id, type, ch, data} frames over a single WebSocket.
+(function () {
+  const $ = (id) => document.getElementById(id);
+  const log = (cls, text) => {
+    const line = document.createElement("div");
+    line.className = cls;
+    line.textContent = new Date().toISOString().substring(11, 23) + " " + text;
+    $("log").appendChild(line);
+    $("log").scrollTop = $("log").scrollHeight;
+  };
+
+  let ws = null;
+  let nextId = 1;
+  const tabId = Math.random().toString(36).substring(2);
+  let installationId = localStorage.getItem("wsgw-demo-installation");
+  if (!installationId) {
+    installationId = Math.random().toString(36).substring(2);
+    localStorage.setItem("wsgw-demo-installation", installationId);
+  }
+
+  $("url").value = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws";
+
+  function send(type, ch, data) {
+    if (!ws || ws.readyState !== WebSocket.OPEN) {
+      log("info", "not connected");
+      return;
+    }
+    const frame = { id: String(nextId++), type: type, ch: ch, data: data };
+    ws.send(JSON.stringify(frame));
+    log("out", "-> " + JSON.stringify(frame));
+  }
+
+  function base64url(value) {
+    return btoa(JSON.stringify(value)).replace(/=+$/, "").replace(/\+/g, "-").replace(/\//g, "_");
+  }
+
+  $("connect").onclick = () => {
+    if (ws) ws.close();
+    ws = new WebSocket($("url").value);
+    $("status").textContent = "connecting";
+    ws.onopen = () => {
+      $("status").textContent = "connected";
+      log("info", "connected to " + $("url").value);
+    };
+    ws.onmessage = (event) => log("in", "<- " + event.data);
+    ws.onclose = (event) => {
+      $("status").textContent = "disconnected";
+      log("info", "closed " + event.code + " " + event.reason);
+    };
+  };
+  $("disconnect").onclick = () => ws && ws.close();
+
+  // Unsigned token for gateways running the open authenticator.
+  $("token").onclick = () => {
+    const now = Math.floor(Date.now() / 1000);
+    $("jwt").value = base64url({ alg: "none", typ: "JWT" }) + "." +
+      base64url({ sub: $("sub").value, iat: now, exp: now + 3600 }) + ".";
+  };
+  $("auth").onclick = () => send("auth", "sys", { authToken: $("jwt").value });
+  $("subscribe").onclick = () => send("subscribe", "sys", { ch: $("channel").value });
+  $("unsubscribe").onclick = () => send("unsubscribe", "sys", { ch: $("channel").value });
+  $("greet").onclick = () => send("hello", "greeting", { name: $("name").value });
+  $("hello").onclick = () => send("hello", "sys", { installationId: installationId, tabId: tabId });
+  $("connections").onclick = () => send("connections", "sys", {});
+  $("capabilities").onclick = () => send("capabilities", "sys", {});
+})();
@@ -0,0 +1,59 @@
+// Package device classifies connections by the User-Agent of their upgrade request.
+package device
+
+import (
+	"strings"
+)
+
+// Type is the class of device a connection originates from.
+type Type string
+
+const (
+	Mobile  Type = "mobile"
+	Tablet  Type = "tablet"
+	Desktop Type = "desktop"
+	Bot     Type = "bot"
+	Unknown Type = "unknown"
+)
+
+// botMarkers identify crawlers, scripts and HTTP libraries.
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "curl", "wget", "python", "go-http-client", "java/", "okhttp", "headless"}
+
+// tabletMarkers identify tablets; checked before mobile markers since many tablets also report "mobile".
+var tabletMarkers = []string{"ipad", "tablet", "kindle", "silk/", "playbook"}
+
+// mobileMarkers identify phones and other handhelds.
+var mobileMarkers = []string{"mobi", "iphone", "ipod", "android", "windows phone", "blackberry", "opera mini"}
+
+// desktopMarkers identify desktop operating systems.
+var desktopMarkers = []string{"windows nt", "macintosh", "x11", "linux", "cros"}
+
+// Classify derives the device type from a User-Agent header.
+func Classify(userAgent string) Type {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return Unknown
+	}
+	switch {
+	case containsAny(ua, botMarkers):
+		return Bot
+	case containsAny(ua, tabletMarkers), strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return Tablet
+	case containsAny(ua, mobileMarkers):
+		return Mobile
+	case containsAny(ua, desktopMarkers):
+		return Desktop
+	default:
+		return Unknown
+	}
+}
+
+// containsAny reports whether s contains any of the markers.
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
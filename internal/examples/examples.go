@@ -0,0 +1,155 @@
+// Package examples generates runnable client snippets in several languages for every documented request.
+package examples
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-websocket-boilerplate/internal/asyncapi"
+	"sort"
+	"strings"
+)
+
+// Languages for which snippets are generated.
+const (
+	JavaScript = "javascript"
+	Python     = "python"
+	Go         = "go"
+)
+
+// Example is a runnable snippet sending one request.
+type Example struct {
+	Channel  string `json:"ch"`
+	Type     string `json:"type"`
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+// Generate returns one snippet per language for every request message of the document.
+//
+// Params:
+// - doc: The AsyncAPI document of the gateway.
+// - wsURL: The WebSocket URL the snippets connect to.
+func Generate(doc *asyncapi.Document, wsURL string) []Example {
+	names := make([]string, 0, len(doc.Components.Messages))
+	for name := range doc.Components.Messages {
+		if strings.HasSuffix(name, "."+asyncapi.Request) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	examples := make([]Example, 0, len(names)*3)
+	for _, name := range names {
+		payload := doc.Components.Messages[name].Payload
+		channel, _ := payload.Properties["ch"].Const.(string)
+		msgType, _ := payload.Properties["type"].Const.(string)
+		if msgType == "" {
+			msgType = "example"
+		}
+		frame := map[string]any{"id": "1", "type": msgType, "ch": channel, "data": Sample(payload.Properties["data"])}
+		data, err := json.MarshalIndent(frame, "", "  ")
+		if err != nil {
+			continue
+		}
+		compact, _ := json.Marshal(frame)
+		examples = append(examples,
+			Example{Channel: channel, Type: msgType, Language: JavaScript, Code: javaScript(wsURL, string(data))},
+			Example{Channel: channel, Type: msgType, Language: Python, Code: python(wsURL, string(compact))},
+			Example{Channel: channel, Type: msgType, Language: Go, Code: golang(wsURL, string(compact))},
+		)
+	}
+	return examples
+}
+
+// Sample builds an example value satisfying the schema. Objects contain all their properties.
+func Sample(schema *asyncapi.Schema) any {
+	if schema == nil {
+		return map[string]any{}
+	}
+	if schema.Const != nil {
+		return schema.Const
+	}
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			return "2024-01-01T00:00:00Z"
+		}
+		return "string"
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "array":
+		return []any{Sample(schema.Items)}
+	case "object":
+		object := make(map[string]any, len(schema.Properties))
+		for name, property := range schema.Properties {
+			object[name] = Sample(property)
+		}
+		return object
+	default:
+		return map[string]any{}
+	}
+}
+
+func javaScript(wsURL string, frame string) string {
+	return fmt.Sprintf(`const ws = new WebSocket(%q);
+ws.onopen = () => ws.send(JSON.stringify(%s));
+ws.onmessage = (event) => {
+  const frame = JSON.parse(event.data);
+  if (frame.id === "1") {
+    console.log(frame.data);
+    ws.close();
+  }
+};
+`, wsURL, frame)
+}
+
+func python(wsURL string, frame string) string {
+	return fmt.Sprintf(`import json
+from websockets.sync.client import connect
+
+with connect(%q) as ws:
+    ws.send(%q)
+    for message in ws:
+        frame = json.loads(message)
+        if frame.get("id") == "1":
+            print(frame["data"])
+            break
+`, wsURL, frame)
+}
+
+func golang(wsURL string, frame string) string {
+	return fmt.Sprintf(`package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+func main() {
+	conn, _, err := websocket.DefaultDialer.Dial(%q, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(%s)); err != nil {
+		log.Fatal(err)
+	}
+	for {
+		var frame map[string]any
+		if err := conn.ReadJSON(&frame); err != nil {
+			log.Fatal(err)
+		}
+		if frame["id"] == "1" {
+			fmt.Println(frame["data"])
+			return
+		}
+	}
+}
+`, wsURL, "`"+frame+"`")
+}
@@ -0,0 +1,91 @@
+// Package experiment assigns A/B experiment variants to connections at connect time.
+package experiment
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+// Subject describes the connection a Provider assigns variants to.
+type Subject struct {
+	ConnectionID int            // Identifier of the connection on the node
+	Subject      string         // Subject of the authenticated user, empty if not authenticated
+	Claims       map[string]any // Claims of the authenticated user
+	Country      string         // ISO country code the client connected from, if known
+	Device       string         // Device type of the client
+}
+
+// Provider assigns experiment variants to a connection.
+//
+// Assign is called once per connection before it is upgraded and must return quickly.
+type Provider interface {
+	// Assign returns the variant of each experiment the connection takes part in, keyed by experiment name.
+	Assign(subject Subject) map[string]string
+}
+
+// Variant is one arm of an Experiment.
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"` // Relative share of the traffic
+}
+
+// Experiment is a named set of variants.
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+}
+
+// HashProvider assigns variants by hashing the subject, so a user sees the same variant on every connection
+// and every node. Unauthenticated connections are bucketed by connection ID.
+type HashProvider struct {
+	experiments []Experiment
+}
+
+// NewHashProvider creates a HashProvider for the experiments.
+func NewHashProvider(experiments []Experiment) *HashProvider {
+	return &HashProvider{experiments: experiments}
+}
+
+// LoadFile creates a HashProvider from a JSON file holding an array of experiments.
+func LoadFile(path string) (*HashProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read experiments %s: %w", path, err)
+	}
+	var experiments []Experiment
+	if err := json.Unmarshal(data, &experiments); err != nil {
+		return nil, fmt.Errorf("parse experiments %s: %w", path, err)
+	}
+	return NewHashProvider(experiments), nil
+}
+
+// Assign picks a variant of every experiment for the subject.
+func (p *HashProvider) Assign(subject Subject) map[string]string {
+	key := subject.Subject
+	if key == "" {
+		key = fmt.Sprint(subject.ConnectionID)
+	}
+	assigned := make(map[string]string, len(p.experiments))
+	for _, e := range p.experiments {
+		total := 0
+		for _, v := range e.Variants {
+			total += max(v.Weight, 0)
+		}
+		if total == 0 {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(e.Name + "/" + key))
+		bucket := int(h.Sum32() % uint32(total))
+		for _, v := range e.Variants {
+			if bucket < max(v.Weight, 0) {
+				assigned[e.Name] = v.Name
+				break
+			}
+			bucket -= max(v.Weight, 0)
+		}
+	}
+	return assigned
+}
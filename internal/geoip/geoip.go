@@ -0,0 +1,61 @@
+// Package geoip resolves client IP addresses to a country and region at connect time.
+package geoip
+
+import (
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+)
+
+// Location is the geographic origin of a connection.
+type Location struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2 country code
+	Region  string `json:"region,omitempty"`  // ISO 3166-2 subdivision code without the country prefix
+}
+
+// Resolver looks up the location of an IP address.
+type Resolver interface {
+	Lookup(ip net.IP) (*Location, error)
+}
+
+// mmdbRecord is the subset of a GeoIP2/GeoLite2 City or Country record used by MaxMindResolver.
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// MaxMindResolver resolves locations from a MaxMind DB file such as GeoLite2-City.mmdb.
+type MaxMindResolver struct {
+	db *maxminddb.Reader
+}
+
+// OpenMaxMind opens a MaxMind DB file.
+func OpenMaxMind(path string) (*MaxMindResolver, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open geoip database %s: %w", path, err)
+	}
+	return &MaxMindResolver{db: db}, nil
+}
+
+// Lookup returns the location of the IP address. Unknown addresses yield an empty location.
+func (r *MaxMindResolver) Lookup(ip net.IP) (*Location, error) {
+	record := &mmdbRecord{}
+	if err := r.db.Lookup(ip, record); err != nil {
+		return nil, err
+	}
+	location := &Location{Country: record.Country.ISOCode}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].ISOCode
+	}
+	return location, nil
+}
+
+// Close releases the database.
+func (r *MaxMindResolver) Close() error {
+	return r.db.Close()
+}
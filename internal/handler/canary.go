@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// CanaryPolicy selects the connections whose messages are routed to the canary handlers.
+//
+// A connection is in the canary if its Claim claim equals ClaimValue, or if it falls into Percent of the
+// connections. The percentage is taken over subjects, so all connections of a user are routed alike;
+// unauthenticated connections are bucketed by connection ID.
+type CanaryPolicy struct {
+	Percent    int    // Share of connections routed to the canary, 0 to 100
+	Claim      string // Claim that opts a connection into the canary, e.g. "beta"
+	ClaimValue string // Value the claim must have; any value if empty
+}
+
+// canaryRoutes holds the handlers registered with RegisterCanaryHandler.
+var canaryRoutes = NewRegistry()
+
+// canary holds the active CanaryPolicy.
+var canary = struct {
+	sync.RWMutex
+	policy CanaryPolicy
+}{}
+
+// SetCanaryPolicy sets the policy selecting the connections routed to the canary handlers.
+//
+// The policy is evaluated when a connection's message handler is created; existing connections keep
+// their assignment.
+func SetCanaryPolicy(policy CanaryPolicy) {
+	canary.Lock()
+	defer canary.Unlock()
+	canary.policy = policy
+}
+
+// RegisterCanaryHandler registers a typed handler serving canary connections in place of the handler
+// registered with RegisterHandler. Messages without a canary handler fall back to the regular routes.
+//
+// Params:
+// - channel: The channel the handler serves.
+// - msgType: The message type the handler serves, or "" for any type.
+// - fn: The typed handler.
+func RegisterCanaryHandler[TReq any, TResp any](channel string, msgType string, fn TypedHandlerFunc[TReq, TResp]) {
+	canaryRoutes.set(channel, msgType, typedHandler(fn))
+}
+
+// inCanary reports whether the client's messages are routed to the canary handlers.
+func inCanary(client Client) bool {
+	canary.RLock()
+	policy := canary.policy
+	canary.RUnlock()
+
+	claims := client.Claims()
+	if policy.Claim != "" && claims != nil {
+		if value, ok := claims[policy.Claim]; ok && (policy.ClaimValue == "" || fmt.Sprint(value) == policy.ClaimValue) {
+			return true
+		}
+	}
+	if policy.Percent <= 0 {
+		return false
+	}
+	key := fmt.Sprint(client.ID())
+	if claims != nil {
+		if sub, err := claims.GetSubject(); err == nil && sub != "" {
+			key = sub
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()%100) < policy.Percent
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// ErrorCode classifies handler errors for clients.
+type ErrorCode = msgs.ErrorCode
+
+const (
+	CodeInvalidRequest   = msgs.CodeInvalidRequest
+	CodeValidationFailed = msgs.CodeValidationFailed
+	CodeNotFound         = msgs.CodeNotFound
+	CodePermissionDenied = msgs.CodePermissionDenied
+	CodeConflict         = msgs.CodeConflict
+	CodeTimeout          = msgs.CodeTimeout
+	CodeInternal         = msgs.CodeInternal
+)
+
+// Error is a handler error carrying an error code. Handlers may return it directly or wrap one of the sentinels.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Latest  any // Current state returned with conflict errors so clients can rebase
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Sentinel errors handlers can return or wrap with fmt.Errorf("...: %w", ErrNotFound).
+var (
+	ErrNotFound         = &Error{Code: CodeNotFound, Message: "not found"}
+	ErrPermissionDenied = &Error{Code: CodePermissionDenied, Message: "permission denied"}
+	ErrConflict         = &Error{Code: CodeConflict, Message: "conflict"}
+)
+
+// NewConflict returns a conflict error carrying the latest state of the resource.
+//
+// Params:
+// - message: A human readable description.
+// - latest: The current state, sent to the client in the error frame.
+func NewConflict(message string, latest any) error {
+	return &Error{Code: CodeConflict, Message: message, Latest: latest}
+}
+
+// ErrorBody describes a failed request.
+type ErrorBody = msgs.Error
+
+// ErrorFrame is the data of a response to a failed request. Successful responses carry the handler's result instead.
+type ErrorFrame = msgs.ErrorResponse
+
+// codeOf maps a Go error to an error code.
+func codeOf(err error) ErrorCode {
+	var handlerErr *Error
+	switch {
+	case errors.As(err, &handlerErr):
+		return handlerErr.Code
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
+
+// SendError responds to the message with a standardized error frame.
+//
+// Params:
+// - client: The client to respond to.
+// - msg: The request that failed.
+// - code: The error code.
+// - message: A human readable description.
+// - details: Optional details, e.g. failed validation rules.
+func SendError(client Client, msg InMsg, code ErrorCode, message string, details ...msgs.ErrorDetail) {
+	client.SendResponse(msg.ID(), msg.Type(), msg.Channel(), msgs.NewErrorResponse(msg.ID(), code, message, details...))
+}
+
+// respond sends the result of an RPC-style handler: the response on success, an error frame otherwise.
+func respond(client Client, msg InMsg, resp any, err error) {
+	if err != nil {
+		code := codeOf(err)
+		message := err.Error()
+		if code == CodeInternal {
+			// Internal errors may leak implementation details.
+			client.Logger().Error("handler failed", "ch", msg.Channel(), "type", msg.Type(), "id", msg.ID(), "error", err)
+			message = "internal error"
+		}
+		body := ErrorBody{Code: code, Message: message, RequestID: msg.ID()}
+		var handlerErr *Error
+		if errors.As(err, &handlerErr) {
+			body.Latest = handlerErr.Latest
+		}
+		client.SendResponse(msg.ID(), msg.Type(), msg.Channel(), &ErrorFrame{Error: body})
+		return
+	}
+	client.SendResponse(msg.ID(), msg.Type(), msg.Channel(), resp)
+}
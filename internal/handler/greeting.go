@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+func init() {
+	RegisterHandler("greeting", "", greet)
+}
+
+// greet answers a greeting request with a personalised message.
+func greet(_ context.Context, _ Client, req msgs.GreetingRequest) (*msgs.GreetingResponse, error) {
+	return &msgs.GreetingResponse{Message: fmt.Sprintf("Hello %s", req.Name)}, nil
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds in seconds of the handler duration histogram buckets.
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// histogram is a cumulative duration histogram of one channel and type.
+type histogram struct {
+	buckets []uint64 // Observations per bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+// unroutedMetric is the histogram key of messages handled by the fallback handler. Their channel and type come
+// from the client, so they share one series rather than creating one per value.
+var unroutedMetric = [2]string{"unknown", "unknown"}
+
+// handlerMetrics holds the handler duration histograms keyed by the channel and type of the route.
+var handlerMetrics = struct {
+	sync.Mutex
+	histograms    map[[2]string]*histogram
+	slowThreshold time.Duration
+}{histograms: make(map[[2]string]*histogram), slowThreshold: time.Second}
+
+// SetSlowHandlerThreshold sets the duration above which a handler invocation is logged as slow.
+// Zero disables the slow-handler log. The default is one second.
+func SetSlowHandlerThreshold(threshold time.Duration) {
+	handlerMetrics.Lock()
+	defer handlerMetrics.Unlock()
+	handlerMetrics.slowThreshold = threshold
+}
+
+// timed runs the handler, records its duration under the route and logs it when it exceeds the slow threshold.
+func timed(fn HandlerFunc, route [2]string, client Client, msg InMsg) {
+	start := time.Now()
+	fn(client, msg)
+	elapsed := time.Since(start)
+
+	handlerMetrics.Lock()
+	h, ok := handlerMetrics.histograms[route]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(durationBuckets))}
+		handlerMetrics.histograms[route] = h
+	}
+	seconds := elapsed.Seconds()
+	if i := sort.SearchFloat64s(durationBuckets, seconds); i < len(durationBuckets) {
+		h.buckets[i]++
+	}
+	h.count++
+	h.sum += seconds
+	threshold := handlerMetrics.slowThreshold
+	handlerMetrics.Unlock()
+
+	if threshold > 0 && elapsed > threshold {
+		client.Logger().Warn("Slow handler",
+			"ch", msg.Channel(),
+			"type", msg.Type(),
+			"id", msg.ID(),
+			"size", len(msg.Data()),
+			"duration", elapsed,
+			"threshold", threshold)
+	}
+}
+
+// MetricsHandler serves the handler duration histograms in the Prometheus text format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var b bytes.Buffer
+		b.WriteString("# HELP wsgw_handler_duration_seconds Duration of handler invocations.\n")
+		b.WriteString("# TYPE wsgw_handler_duration_seconds histogram\n")
+
+		handlerMetrics.Lock()
+		keys := make([][2]string, 0, len(handlerMetrics.histograms))
+		for key := range handlerMetrics.histograms {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i][0] != keys[j][0] {
+				return keys[i][0] < keys[j][0]
+			}
+			return keys[i][1] < keys[j][1]
+		})
+		for _, key := range keys {
+			h := handlerMetrics.histograms[key]
+			labels := fmt.Sprintf("ch=%q,type=%q", key[0], key[1])
+			var cumulative uint64
+			for i, bound := range durationBuckets {
+				cumulative += h.buckets[i]
+				fmt.Fprintf(&b, "wsgw_handler_duration_seconds_bucket{%s,le=%q} %d\n", labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+			}
+			fmt.Fprintf(&b, "wsgw_handler_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+			fmt.Fprintf(&b, "wsgw_handler_duration_seconds_sum{%s} %g\n", labels, h.sum)
+			fmt.Fprintf(&b, "wsgw_handler_duration_seconds_count{%s} %d\n", labels, h.count)
+		}
+		handlerMetrics.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_, _ = w.Write(b.Bytes())
+	})
+}
@@ -3,10 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v5"
-	"go-websocket-boilerplate/internal/msgs"
 	"log/slog"
 )
 
@@ -22,20 +19,65 @@ type Client interface {
 	Context() context.Context
 	SendResponse(id string, reqType string, channel string, data any)
 	SendUpdate(updateType string, channel string, data any)
+	SendToClient(clientID int, updateType string, channel string, data any) error
+	SendToUser(subject string, updateType string, channel string, data any) error
+	Publish(channel string, updateType string, data any)
+	Call(ctx context.Context, channel string, callType string, data any) (json.RawMessage, error)
+	EditMessage(channel string, msgID string, data any) error
+	DeleteMessage(channel string, msgID string) error
 	Ingress() chan InMsg
 	Close()
 	Claims() jwt.MapClaims
+	Country() string
+	Region() string
+	Device() string
+	UserAgent() string
+	InstallationID() string
+	TabID() string
+	Variant(experiment string) string
 	Logger() *slog.Logger
 }
 
+// Traced is implemented by messages carrying the trace context of their ingress span. Handlers pass it to
+// downstream calls so they join the message's trace.
+type Traced interface {
+	TraceContext() context.Context
+}
+
+// TraceContext returns the trace context of the message, or context.Background if it is not traced.
+func TraceContext(msg InMsg) context.Context {
+	if traced, ok := msg.(Traced); ok {
+		return traced.TraceContext()
+	}
+	return context.Background()
+}
+
+// finisher is implemented by messages whose ingress span ends once the handler returns.
+type finisher interface {
+	Finish()
+}
+
+// Causal is implemented by clients that stamp the messages sent while handling a request with the request's
+// causation and correlation IDs.
+type Causal interface {
+	CausedBy(msg InMsg) Client
+}
+
 type MsgHandler struct {
 	client Client
+	shadow HandlerFunc
+	canary bool
 }
 
 func NewMsgHandler(client Client) *MsgHandler {
-	return &MsgHandler{
+	m := &MsgHandler{
 		client: client,
+		canary: inCanary(client),
 	}
+	if m.canary {
+		m.Logger().Info("Connection routed to canary handlers")
+	}
+	return m
 }
 
 func (m *MsgHandler) Start() {
@@ -60,27 +102,32 @@ func (m *MsgHandler) Logger() *slog.Logger {
 }
 
 func (m *MsgHandler) onMessage(msg InMsg) {
-	if msg.Channel() == "greeting" {
-		m.HandleGreeting(msg)
+	if f, ok := msg.(finisher); ok {
+		defer f.Finish()
 	}
-}
-
-func (m *MsgHandler) HandleGreeting(msg InMsg) {
-	greeting := &msgs.GreetingRequest{}
-	err := json.Unmarshal(msg.Data(), greeting)
-	if err != nil {
-		m.client.SendResponse(msg.ID(), msg.Type(), msg.Channel(), &msgs.GreetingResponse{Message: "Invalid request"})
+	client := m.client
+	if causal, ok := client.(Causal); ok {
+		client = causal.CausedBy(msg)
+	}
+	if m.shadow != nil {
+		m.dispatchWithShadow(client, msg)
 		return
 	}
-	validate := validator.New()
-	err = validate.Struct(greeting)
-	if err != nil {
-		errorMsgs := make([]string, 0)
-		for _, er := range err.(validator.ValidationErrors) {
-			errorMsgs = append(errorMsgs, fmt.Sprintf("Field '%s' failed validation: %s\n", er.Field(), er.Tag()))
+	m.dispatch(client, msg)
+}
+
+func (m *MsgHandler) dispatch(client Client, msg InMsg) {
+	if m.canary {
+		if fn, route, ok := canaryRoutes.lookup(msg); ok {
+			timed(fn, route, client, msg)
+			return
 		}
-		m.client.SendResponse(msg.ID(), msg.Type(), msg.Channel(), errorMsgs)
+	}
+	if fn, route, ok := routes.lookup(msg); ok {
+		timed(fn, route, client, msg)
 		return
 	}
-	m.client.SendResponse(msg.ID(), msg.Type(), msg.Channel(), &msgs.GreetingResponse{Message: fmt.Sprintf("Hello %s", greeting.Name)})
+	if fn := routes.fallbackHandler(); fn != nil {
+		timed(fn, unroutedMetric, client, msg)
+	}
 }
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"sync"
+)
+
+// Registry holds message handlers keyed by channel and message type, plus a fallback for messages no handler
+// serves.
+type Registry struct {
+	sync.RWMutex
+	handlers map[string]HandlerFunc
+	fallback HandlerFunc
+}
+
+// routes holds the handlers registered with RegisterHandler, RegisterStreamHandler and Register.
+var routes = NewRegistry()
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]HandlerFunc)}
+}
+
+// DefaultRegistry returns the registry connections dispatch their messages with.
+func DefaultRegistry() *Registry {
+	return routes
+}
+
+// routeKey builds the routes key. An empty msgType matches any type on the channel.
+func routeKey(channel string, msgType string) string {
+	return channel + "/" + msgType
+}
+
+// Register registers a handler for all messages of the given channel.
+//
+// The handler receives the raw message and is responsible for responding; use RegisterHandler for decoded and
+// validated requests. Handlers registered this way do not appear in Routes.
+//
+// Params:
+// - channel: The channel the handler serves.
+// - fn: The handler.
+func (r *Registry) Register(channel string, fn HandlerFunc) {
+	r.set(channel, "", fn)
+}
+
+// RegisterType registers a handler for messages of the given channel and type.
+//
+// Params:
+// - channel: The channel the handler serves.
+// - msgType: The message type the handler serves, or "" for any type.
+// - fn: The handler.
+func (r *Registry) RegisterType(channel string, msgType string, fn HandlerFunc) {
+	r.set(channel, msgType, fn)
+}
+
+// SetFallback sets the handler for messages whose channel and type have no registered handler, e.g. to reject
+// them with CodeNotFound or forward them to another service. A nil handler drops such messages, the default.
+func (r *Registry) SetFallback(fn HandlerFunc) {
+	r.Lock()
+	defer r.Unlock()
+	r.fallback = fn
+}
+
+// set registers the handler for messages of the given channel and type.
+func (r *Registry) set(channel string, msgType string, fn HandlerFunc) {
+	r.Lock()
+	defer r.Unlock()
+	r.handlers[routeKey(channel, msgType)] = fn
+}
+
+// lookup returns the handler registered for the message, preferring an exact type match.
+//
+// Returns:
+// - The handler.
+// - The channel and type it was registered for; the type is "*" for handlers of any type.
+// - false if no handler serves the message.
+func (r *Registry) lookup(msg InMsg) (HandlerFunc, [2]string, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	if fn, ok := r.handlers[routeKey(msg.Channel(), msg.Type())]; ok {
+		return fn, [2]string{msg.Channel(), msg.Type()}, true
+	}
+	fn, ok := r.handlers[routeKey(msg.Channel(), "")]
+	return fn, [2]string{msg.Channel(), "*"}, ok
+}
+
+// fallbackHandler returns the handler for unrouted messages, or nil.
+func (r *Registry) fallbackHandler() HandlerFunc {
+	r.RLock()
+	defer r.RUnlock()
+	return r.fallback
+}
+
+// Register registers a handler for all messages of the given channel in the default registry.
+//
+// Params:
+// - channel: The channel the handler serves.
+// - fn: The handler.
+func Register(channel string, fn HandlerFunc) {
+	routes.Register(channel, fn)
+}
+
+// SetFallback sets the handler for messages the default registry has no handler for.
+//
+// Params:
+// - fn: The handler, or nil to drop unrouted messages.
+func SetFallback(fn HandlerFunc) {
+	routes.SetFallback(fn)
+}
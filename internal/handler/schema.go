@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// RouteInfo describes a registered handler for API documentation.
+type RouteInfo struct {
+	Channel  string       // Channel the handler serves
+	Type     string       // Message type the handler serves, empty for any type
+	Request  reflect.Type // Type of the request data
+	Response reflect.Type // Type of the response data, or of each frame's data for streams
+	Stream   bool         // True if the handler answers with several StreamFrame responses
+}
+
+// routeInfos holds the RouteInfo of every handler registered with RegisterHandler and RegisterStreamHandler.
+var routeInfos = struct {
+	sync.RWMutex
+	infos map[string]RouteInfo
+}{infos: make(map[string]RouteInfo)}
+
+// describeRoute records the request and response types of a registered handler.
+func describeRoute[TReq any, TResp any](channel string, msgType string, stream bool) {
+	routeInfos.Lock()
+	defer routeInfos.Unlock()
+	routeInfos.infos[routeKey(channel, msgType)] = RouteInfo{
+		Channel:  channel,
+		Type:     msgType,
+		Request:  reflect.TypeFor[TReq](),
+		Response: reflect.TypeFor[TResp](),
+		Stream:   stream,
+	}
+}
+
+// Routes returns the registered handlers ordered by channel and type.
+func Routes() []RouteInfo {
+	routeInfos.RLock()
+	defer routeInfos.RUnlock()
+	infos := make([]RouteInfo, 0, len(routeInfos.infos))
+	for _, info := range routeInfos.infos {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Channel != infos[j].Channel {
+			return infos[i].Channel < infos[j].Channel
+		}
+		return infos[i].Type < infos[j].Type
+	})
+	return infos
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errShadowCall is returned to shadow handlers calling a backend service, which only the primary handler reaches.
+var errShadowCall = errors.New("calls are not made from shadow handlers")
+
+// HandlerFunc handles a single ingress message for a client.
+type HandlerFunc func(client Client, msg InMsg)
+
+// recordedMsg is a response or update captured from a handler.
+type recordedMsg struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Channel string `json:"ch"`
+	Data    any    `json:"data"`
+}
+
+// recordingClient captures the messages a handler sends and the side effects it causes instead of, or in
+// addition to, performing them.
+type recordingClient struct {
+	Client
+	sync.Mutex
+	forward  bool          // Whether captured messages and side effects also reach the wrapped client
+	recorded []recordedMsg // Messages captured so far
+	ingress  chan InMsg    // Ingress queue handed out when not forwarding; nothing is ever queued on it
+}
+
+// record captures a message or side effect.
+func (r *recordingClient) record(msg recordedMsg) {
+	r.Lock()
+	r.recorded = append(r.recorded, msg)
+	r.Unlock()
+}
+
+// SendResponse records the response and forwards it when the recorder is a tee.
+func (r *recordingClient) SendResponse(id string, reqType string, channel string, data any) {
+	r.record(recordedMsg{ID: id, Type: reqType, Channel: channel, Data: data})
+	if r.forward {
+		r.Client.SendResponse(id, reqType, channel, data)
+	}
+}
+
+// SendUpdate records the update and forwards it when the recorder is a tee.
+func (r *recordingClient) SendUpdate(updateType string, channel string, data any) {
+	r.record(recordedMsg{Type: updateType, Channel: channel, Data: data})
+	if r.forward {
+		r.Client.SendUpdate(updateType, channel, data)
+	}
+}
+
+// SendToClient records the direct message and forwards it when the recorder is a tee.
+func (r *recordingClient) SendToClient(clientID int, updateType string, channel string, data any) error {
+	r.record(recordedMsg{ID: fmt.Sprintf("client:%d", clientID), Type: updateType, Channel: channel, Data: data})
+	if r.forward {
+		return r.Client.SendToClient(clientID, updateType, channel, data)
+	}
+	return nil
+}
+
+// SendToUser records the direct message and forwards it when the recorder is a tee.
+func (r *recordingClient) SendToUser(subject string, updateType string, channel string, data any) error {
+	r.record(recordedMsg{ID: "user:" + subject, Type: updateType, Channel: channel, Data: data})
+	if r.forward {
+		return r.Client.SendToUser(subject, updateType, channel, data)
+	}
+	return nil
+}
+
+// Publish records the update and forwards it when the recorder is a tee.
+func (r *recordingClient) Publish(channel string, updateType string, data any) {
+	r.record(recordedMsg{ID: "publish", Type: updateType, Channel: channel, Data: data})
+	if r.forward {
+		r.Client.Publish(channel, updateType, data)
+	}
+}
+
+// Call records the call and makes it when the recorder is a tee. Otherwise it fails with errShadowCall, so a
+// shadow handler never reaches backend services.
+func (r *recordingClient) Call(ctx context.Context, channel string, callType string, data any) (json.RawMessage, error) {
+	r.record(recordedMsg{ID: "call", Type: callType, Channel: channel, Data: data})
+	if r.forward {
+		return r.Client.Call(ctx, channel, callType, data)
+	}
+	return nil, errShadowCall
+}
+
+// EditMessage records the edit and applies it when the recorder is a tee.
+func (r *recordingClient) EditMessage(channel string, msgID string, data any) error {
+	r.record(recordedMsg{ID: "edit:" + msgID, Type: "edit", Channel: channel, Data: data})
+	if r.forward {
+		return r.Client.EditMessage(channel, msgID, data)
+	}
+	return nil
+}
+
+// DeleteMessage records the deletion and applies it when the recorder is a tee.
+func (r *recordingClient) DeleteMessage(channel string, msgID string) error {
+	r.record(recordedMsg{ID: "delete:" + msgID, Type: "delete", Channel: channel})
+	if r.forward {
+		return r.Client.DeleteMessage(channel, msgID)
+	}
+	return nil
+}
+
+// Ingress returns the wrapped client's ingress queue when the recorder is a tee, and otherwise a queue of its
+// own, so a shadow handler cannot take or inject messages.
+func (r *recordingClient) Ingress() chan InMsg {
+	if r.forward {
+		return r.Client.Ingress()
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.ingress == nil {
+		r.ingress = make(chan InMsg)
+	}
+	return r.ingress
+}
+
+// Close records the close and closes the wrapped client when the recorder is a tee.
+func (r *recordingClient) Close() {
+	r.record(recordedMsg{ID: "close"})
+	if r.forward {
+		r.Client.Close()
+	}
+}
+
+// snapshot returns the recorded messages encoded as JSON for comparison.
+func (r *recordingClient) snapshot() []byte {
+	r.Lock()
+	defer r.Unlock()
+	data, err := json.Marshal(r.recorded)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// SetShadow mirrors every ingress message to a secondary handler whose output is compared and logged, never sent.
+//
+// Both handlers' frames are held in memory until the shadow returns, so shadowing a RegisterStreamHandler route
+// records every partial frame and grows without bound on long streams.
+//
+// Params:
+// - shadow: The secondary handler, or nil to disable shadowing.
+func (m *MsgHandler) SetShadow(shadow HandlerFunc) {
+	m.shadow = shadow
+}
+
+// dispatchWithShadow runs the primary handler and, in the background, the shadow handler on the same message.
+func (m *MsgHandler) dispatchWithShadow(client Client, msg InMsg) {
+	primary := &recordingClient{Client: client, forward: true}
+	m.dispatch(primary, msg)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				m.Logger().Error("shadow handler panicked", "ch", msg.Channel(), "type", msg.Type(), "panic", r)
+			}
+		}()
+		shadow := &recordingClient{Client: m.client}
+		start := time.Now()
+		m.shadow(shadow, msg)
+		expected, actual := primary.snapshot(), shadow.snapshot()
+		if string(expected) != string(actual) {
+			m.Logger().Warn("shadow handler mismatch", "ch", msg.Channel(), "type", msg.Type(), "id", msg.ID(),
+				"primary", string(expected), "shadow", string(actual), "duration", time.Since(start))
+			return
+		}
+		m.Logger().Debug("shadow handler matched", "ch", msg.Channel(), "type", msg.Type(), "id", msg.ID(),
+			"duration", time.Since(start))
+	}()
+}
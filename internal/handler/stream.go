@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// StreamFrame is the data of each response frame of a streamed request.
+//
+// All frames carry the request ID. The last frame has Complete set and no data.
+type StreamFrame struct {
+	Part     int  `json:"part"`               // Zero based index of the frame
+	Data     any  `json:"data,omitempty"`     // Partial result
+	Complete bool `json:"complete,omitempty"` // Marks the end of the stream
+}
+
+// Stream sends partial results of a single request to the client.
+type Stream[T any] struct {
+	ctx    context.Context
+	client Client
+	msg    InMsg
+	part   int
+}
+
+// Send delivers a partial result. It fails once the client has gone away, so handlers can stop producing.
+func (s *Stream[T]) Send(item T) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.client.SendResponse(s.msg.ID(), s.msg.Type(), s.msg.Channel(), &StreamFrame{Part: s.part, Data: item})
+	s.part++
+	return nil
+}
+
+// complete sends the final frame of the stream.
+func (s *Stream[T]) complete() {
+	s.client.SendResponse(s.msg.ID(), s.msg.Type(), s.msg.Channel(), &StreamFrame{Part: s.part, Complete: true})
+}
+
+// StreamHandlerFunc handles a decoded and validated request by sending partial results to the stream.
+type StreamHandlerFunc[TReq any, TItem any] func(ctx context.Context, client Client, req TReq, stream *Stream[TItem]) error
+
+// RegisterStreamHandler registers a handler that answers one request with several response frames.
+//
+// Decoding, validation and error frames work as in RegisterHandler. When the handler returns without error a
+// final frame with the complete marker is sent; when it fails an error frame ends the stream instead.
+//
+// Params:
+// - channel: The channel the handler serves.
+// - msgType: The message type the handler serves, or "" for any type.
+// - fn: The streaming handler.
+func RegisterStreamHandler[TReq any, TItem any](channel string, msgType string, fn StreamHandlerFunc[TReq, TItem]) {
+	routes.set(channel, msgType, func(client Client, msg InMsg) {
+		var req TReq
+		if err := json.Unmarshal(msg.Data(), &req); err != nil {
+			SendError(client, msg, CodeInvalidRequest, "Invalid request")
+			return
+		}
+		if details := validationErrors(req); len(details) > 0 {
+			SendError(client, msg, CodeValidationFailed, "Validation failed", details...)
+			return
+		}
+		stream := &Stream[TItem]{ctx: client.Context(), client: client, msg: msg}
+		if err := fn(client.Context(), client, req, stream); err != nil {
+			respond(client, msg, nil, err)
+			return
+		}
+		stream.complete()
+	})
+	describeRoute[TReq, TItem](channel, msgType, true)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"go-websocket-boilerplate/internal/msgs"
+	"reflect"
+	"strings"
+)
+
+// validate is shared by all typed handlers; validator.Validate caches struct metadata and is safe for concurrent use.
+var validate = newValidator()
+
+// newValidator creates a validator reporting fields by their JSON names, as clients know them.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// TypedHandlerFunc handles a decoded and validated request and returns the response payload.
+type TypedHandlerFunc[TReq any, TResp any] func(ctx context.Context, client Client, req TReq) (TResp, error)
+
+// RegisterHandler registers a typed handler for messages of the given channel and type.
+//
+// The message data is unmarshalled into TReq and validated with its `validate` struct tags. The handler's
+// result is sent back to the client as the response to the request; errors become an ErrorFrame whose code is
+// derived from the error (see Error). An empty msgType matches any type.
+//
+// Params:
+// - channel: The channel the handler serves.
+// - msgType: The message type the handler serves, or "" for any type.
+// - fn: The typed handler.
+func RegisterHandler[TReq any, TResp any](channel string, msgType string, fn TypedHandlerFunc[TReq, TResp]) {
+	routes.set(channel, msgType, typedHandler(fn))
+	describeRoute[TReq, TResp](channel, msgType, false)
+}
+
+// typedHandler adapts a typed handler to a HandlerFunc that decodes, validates and responds.
+func typedHandler[TReq any, TResp any](fn TypedHandlerFunc[TReq, TResp]) HandlerFunc {
+	return func(client Client, msg InMsg) {
+		var req TReq
+		if err := json.Unmarshal(msg.Data(), &req); err != nil {
+			SendError(client, msg, CodeInvalidRequest, "Invalid request")
+			return
+		}
+		if details := validationErrors(req); len(details) > 0 {
+			SendError(client, msg, CodeValidationFailed, "Validation failed", details...)
+			return
+		}
+		resp, err := fn(client.Context(), client, req)
+		respond(client, msg, resp, err)
+	}
+}
+
+// validationErrors validates a struct request and describes each failed field.
+func validationErrors(req any) []msgs.ErrorDetail {
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+	validationErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		// Non-struct requests carry no validation tags.
+		return nil
+	}
+	details := make([]msgs.ErrorDetail, 0, len(validationErrs))
+	for _, er := range validationErrs {
+		details = append(details, msgs.ErrorDetail{
+			Field:   er.Field(),
+			Rule:    er.Tag(),
+			Message: fmt.Sprintf("%s failed the %s rule", er.Field(), er.Tag()),
+		})
+	}
+	return details
+}
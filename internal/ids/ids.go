@@ -0,0 +1,121 @@
+// Package ids generates time-ordered identifiers that are unique across the cluster.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Generator creates unique, sortable identifiers.
+type Generator interface {
+	NewID() string
+}
+
+// UUIDv7 generates RFC 9562 version 7 UUIDs: a millisecond timestamp followed by random bits.
+type UUIDv7 struct{}
+
+// NewID returns a new UUIDv7 in canonical form.
+func (UUIDv7) NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = 0x70 | (b[6] & 0x0f) // Version 7
+	b[8] = 0x80 | (b[8] & 0x3f) // RFC 9562 variant
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// ksuidEpoch is the KSUID epoch, 2014-05-13T16:53:20Z.
+const ksuidEpoch = 1400000000
+
+// base62 is the KSUID alphabet.
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// KSUID generates K-Sortable Unique IDentifiers: a second timestamp and 128 random bits, base62 encoded.
+type KSUID struct{}
+
+// NewID returns a new 27 character KSUID.
+func (KSUID) NewID() string {
+	var b [20]byte
+	ts := uint32(time.Now().Unix() - ksuidEpoch)
+	b[0], b[1], b[2], b[3] = byte(ts>>24), byte(ts>>16), byte(ts>>8), byte(ts)
+	_, _ = rand.Read(b[4:])
+
+	n := new(big.Int).SetBytes(b[:])
+	out := make([]byte, 27)
+	base, mod := big.NewInt(62), new(big.Int)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = base62[mod.Int64()]
+	}
+	return string(out)
+}
+
+// snowflakeEpoch is the custom epoch of Snowflake IDs, 2024-01-01T00:00:00Z in milliseconds.
+const snowflakeEpoch = 1704067200000
+
+// Snowflake generates 63-bit IDs from a 41-bit millisecond timestamp, a 10-bit node ID and a 12-bit
+// per-millisecond sequence. Nodes must have distinct node IDs.
+type Snowflake struct {
+	sync.Mutex
+	node     int64
+	lastMs   int64
+	sequence int64
+}
+
+// NewSnowflake creates a Snowflake generator for the node.
+//
+// Params:
+// - node: The node ID, 0 to 1023.
+func NewSnowflake(node int) (*Snowflake, error) {
+	if node < 0 || node > 1023 {
+		return nil, fmt.Errorf("snowflake node ID %d is not between 0 and 1023", node)
+	}
+	return &Snowflake{node: int64(node)}, nil
+}
+
+// NewID returns the next ID as a decimal string. When the sequence of a millisecond is exhausted it waits
+// for the next millisecond.
+func (s *Snowflake) NewID() string {
+	s.Lock()
+	defer s.Unlock()
+	ms := time.Now().UnixMilli() - snowflakeEpoch
+	if ms < s.lastMs {
+		ms = s.lastMs // Clock moved backwards; keep IDs increasing
+	}
+	if ms == s.lastMs {
+		s.sequence = (s.sequence + 1) & 0xfff
+		if s.sequence == 0 {
+			for ms <= s.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				ms = time.Now().UnixMilli() - snowflakeEpoch
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastMs = ms
+	return strconv.FormatInt(ms<<22|s.node<<12|s.sequence, 10)
+}
+
+// Compare orders IDs of the same generator by creation time. Shorter IDs sort first so decimal snowflakes compare
+// numerically.
+//
+// Returns:
+// - -1, 0 or +1 if a sorts before, equal to or after b.
+func Compare(a string, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
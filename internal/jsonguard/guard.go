@@ -0,0 +1,75 @@
+// Package jsonguard checks JSON payloads against structural limits before they are decoded, protecting handlers
+// from JSON bombs that fit within the frame size limit.
+package jsonguard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the structure of a JSON document. Zero values disable a limit.
+type Limits struct {
+	MaxDepth     int // Maximum nesting depth of objects and arrays
+	MaxArrayLen  int // Maximum number of elements of a single array
+	MaxStringLen int // Maximum length in bytes of a string or object key
+	MaxFields    int // Maximum number of fields of a single object
+}
+
+// DefaultLimits are conservative limits for client messages.
+var DefaultLimits = Limits{MaxDepth: 32, MaxArrayLen: 10000, MaxStringLen: 64 * 1024, MaxFields: 1000}
+
+// ErrLimitExceeded is wrapped by the errors returned from Check when a limit is exceeded.
+var ErrLimitExceeded = errors.New("json limit exceeded")
+
+// container is an object or array being scanned.
+type container struct {
+	object bool // True for objects, false for arrays
+	tokens int  // Keys and values of an object, elements of an array
+}
+
+// Check scans the document and reports the first limit it exceeds, or a syntax error.
+func Check(data []byte, limits Limits) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	stack := make([]container, 0, 8)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if delim, ok := token.(json.Delim); !ok || delim == '{' || delim == '[' {
+				top.tokens++
+				if top.object && limits.MaxFields > 0 && top.tokens > 2*limits.MaxFields {
+					return fmt.Errorf("%w: more than %d fields", ErrLimitExceeded, limits.MaxFields)
+				}
+				if !top.object && limits.MaxArrayLen > 0 && top.tokens > limits.MaxArrayLen {
+					return fmt.Errorf("%w: array longer than %d", ErrLimitExceeded, limits.MaxArrayLen)
+				}
+			}
+		}
+		switch v := token.(type) {
+		case json.Delim:
+			switch v {
+			case '{', '[':
+				stack = append(stack, container{object: v == '{'})
+				if limits.MaxDepth > 0 && len(stack) > limits.MaxDepth {
+					return fmt.Errorf("%w: nesting deeper than %d", ErrLimitExceeded, limits.MaxDepth)
+				}
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			if limits.MaxStringLen > 0 && len(v) > limits.MaxStringLen {
+				return fmt.Errorf("%w: string longer than %d", ErrLimitExceeded, limits.MaxStringLen)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package jwt_auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwk is a public key of a JSON Web Key Set. Only the members of RSA and EC signing keys are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet caches the signing keys published at a JWKS endpoint, such as the jwks_uri of an OIDC provider.
+//
+// Keys are looked up by the token's "kid". An unknown kid triggers a refresh so rotated keys are picked up
+// immediately, but refreshes are at most one per minRefresh so forged kids cannot flood the provider.
+type KeySet struct {
+	sync.Mutex
+	url        string
+	client     *http.Client
+	minRefresh time.Duration  // Minimum time between two fetches
+	keys       map[string]any // Public keys by kid
+	fetched    time.Time      // Time of the last fetch attempt
+}
+
+// NewKeySet creates an empty key set for the JWKS endpoint. Keys are fetched on first use or by Refresh.
+//
+// Params:
+// - url: The JWKS endpoint.
+// - minRefresh: The minimum time between two fetches.
+//
+// Returns:
+// - A pointer to the initialized KeySet.
+func NewKeySet(url string, minRefresh time.Duration) *KeySet {
+	return &KeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		minRefresh: minRefresh,
+		keys:       make(map[string]any),
+	}
+}
+
+// Refresh fetches the key set and replaces the cached keys. On failure the cached keys are kept.
+func (s *KeySet) Refresh() error {
+	s.Lock()
+	s.fetched = time.Now()
+	s.Unlock()
+	return s.reload()
+}
+
+// reload fetches the key set and replaces the cached keys. The caller records the fetch attempt.
+func (s *KeySet) reload() error {
+	keys, err := s.fetch()
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.keys = keys
+	s.Unlock()
+	slog.Info("JWKS refreshed", "url", s.url, "keys", len(keys))
+	return nil
+}
+
+// fetch downloads and decodes the key set. Keys of unsupported types or not meant for signatures are skipped.
+func (s *KeySet) fetch() (map[string]any, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS %s: status %d", s.url, resp.StatusCode)
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode JWKS %s: %w", s.url, err)
+	}
+	keys := make(map[string]any, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("Skipping JWKS key", "url", s.url, "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey decodes the RSA or EC public key.
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeInt decodes a base64url encoded big-endian integer.
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// key returns the key with the kid, refreshing the set once if it is unknown and the last fetch is older than
+// minRefresh.
+func (s *KeySet) key(kid string) (any, error) {
+	s.Lock()
+	key, ok := s.keys[kid]
+	stale := time.Since(s.fetched) >= s.minRefresh
+	if !ok && stale {
+		s.fetched = time.Now() // Claim the refresh so concurrent misses do not fetch again
+	}
+	s.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// keyFunc resolves the verification key of a token from its "kid" header.
+func (s *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	return s.key(kid)
+}
+
+// Run refreshes the key set periodically until the context is cancelled, so keys removed by the provider stop
+// being accepted.
+func (s *KeySet) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				slog.Warn("JWKS refresh failed", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// NewJWKS creates an Authenticator verifying RSA and ECDSA signed tokens with the keys of the key set.
+//
+// Params:
+// - keys: The key set of the identity provider.
+// - options: The claim rules.
+//
+// Returns:
+// - A pointer to the initialized Authenticator.
+func NewJWKS(keys *KeySet, options Options) *Authenticator {
+	return newAuthenticator(append(rsaMethods[:len(rsaMethods):len(rsaMethods)], ecdsaMethods...), keys.keyFunc, options)
+}
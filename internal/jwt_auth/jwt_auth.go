@@ -0,0 +1,116 @@
+// Package jwt_auth validates bearer tokens by verifying their signature with an HMAC secret or an RSA or ECDSA
+// public key, and enforces the registered expiry, not-before, issuer and audience claims.
+package jwt_auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"time"
+)
+
+// Signing algorithms accepted for each kind of key. Restricting the algorithms to the key type prevents a token
+// signed with HS256 from being verified with the bytes of a public key.
+var (
+	hmacMethods  = []string{"HS256", "HS384", "HS512"}
+	rsaMethods   = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	ecdsaMethods = []string{"ES256", "ES384", "ES512"}
+)
+
+// Options are the claim rules enforced on every token.
+type Options struct {
+	Issuer   string        // Required "iss" claim, not checked if empty
+	Audience string        // Required entry of the "aud" claim, not checked if empty
+	Leeway   time.Duration // Clock skew tolerated when checking "exp" and "nbf"
+}
+
+// Authenticator verifies signed tokens. Tokens without an "exp" claim are rejected.
+type Authenticator struct {
+	parser  *jwt.Parser
+	keyFunc jwt.Keyfunc
+}
+
+// newAuthenticator creates an Authenticator accepting the signing methods and resolving keys with keyFunc.
+func newAuthenticator(methods []string, keyFunc jwt.Keyfunc, options Options) *Authenticator {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(options.Leeway),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+	return &Authenticator{parser: jwt.NewParser(parserOptions...), keyFunc: keyFunc}
+}
+
+// NewHMAC creates an Authenticator verifying HS256, HS384 and HS512 tokens with a shared secret.
+//
+// Params:
+// - secret: The shared secret.
+// - options: The claim rules.
+//
+// Returns:
+// - A pointer to the initialized Authenticator, or an error if the secret is empty.
+func NewHMAC(secret []byte, options Options) (*Authenticator, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty HMAC secret")
+	}
+	keyFunc := func(*jwt.Token) (any, error) { return secret, nil }
+	return newAuthenticator(hmacMethods, keyFunc, options), nil
+}
+
+// NewPublicKey creates an Authenticator verifying tokens with a PEM encoded RSA or ECDSA public key. RSA keys
+// accept the RS and PS algorithms, ECDSA keys the ES algorithms.
+//
+// Params:
+// - pemData: The PEM encoded public key or certificate.
+// - options: The claim rules.
+//
+// Returns:
+// - A pointer to the initialized Authenticator, or an error if the key cannot be parsed.
+func NewPublicKey(pemData []byte, options Options) (*Authenticator, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pemData); err == nil {
+		return newAuthenticator(rsaMethods, staticKey(key), options), nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pemData); err == nil {
+		return newAuthenticator(ecdsaMethods, staticKey(key), options), nil
+	}
+	return nil, errors.New("no RSA or ECDSA public key found in PEM data")
+}
+
+// LoadPublicKeyFile creates an Authenticator verifying tokens with the PEM encoded public key in the file.
+func LoadPublicKeyFile(path string, options Options) (*Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read public key %s: %w", path, err)
+	}
+	auth, err := NewPublicKey(data, options)
+	if err != nil {
+		return nil, fmt.Errorf("public key %s: %w", path, err)
+	}
+	return auth, nil
+}
+
+// staticKey returns a key function always resolving to the key.
+func staticKey[K *rsa.PublicKey | *ecdsa.PublicKey](key K) jwt.Keyfunc {
+	return func(*jwt.Token) (any, error) { return key, nil }
+}
+
+// ValidateJwt verifies the token's signature and registered claims.
+//
+// Returns:
+// - The token's claims, or an error if the token is malformed, badly signed, expired, not yet valid or issued
+// by or for someone else.
+func (a *Authenticator) ValidateJwt(authToken string) (jwt.MapClaims, error) {
+	token, err := a.parser.ParseWithClaims(authToken, jwt.MapClaims{}, a.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	return token.Claims.(jwt.MapClaims), nil
+}
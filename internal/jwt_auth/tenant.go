@@ -0,0 +1,158 @@
+package jwt_auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"slices"
+	"time"
+)
+
+// TenantConfig declares an identity provider served by the gateway. Exactly one of Secret, PublicKeyFile and
+// JWKSURL sets the tenant's signing keys.
+type TenantConfig struct {
+	Name          string   `json:"name"`                    // Tenant name, as used in the endpoint path
+	Issuers       []string `json:"issuers"`                 // Accepted "iss" claims; a token's issuer selects the tenant
+	Audiences     []string `json:"audiences,omitempty"`     // Accepted "aud" entries, not checked if empty
+	Secret        string   `json:"secret,omitempty"`        // HMAC secret
+	PublicKeyFile string   `json:"publicKeyFile,omitempty"` // PEM encoded RSA or ECDSA public key
+	JWKSURL       string   `json:"jwksUrl,omitempty"`       // JWKS endpoint of the provider
+}
+
+// tenant is a configured identity provider with its signature verifier.
+type tenant struct {
+	config   TenantConfig
+	verifier *Authenticator // Verifies signature, "exp" and "nbf"; issuer and audience are checked by the tenant
+}
+
+// MultiTenant validates tokens of several identity providers, each with its own issuers, audiences and keys.
+//
+// The tenant is selected by the endpoint path when the gateway passes it to ValidateTenantJwt, and otherwise by
+// the token's unverified "iss" claim. Either way the token must then verify against that tenant's keys and rules.
+type MultiTenant struct {
+	tenants  map[string]*tenant // Tenants by name
+	byIssuer map[string]*tenant // Tenants by accepted issuer
+	keySets  []*KeySet          // Key sets of JWKS tenants, refreshed by the caller
+}
+
+// NewMultiTenant creates a MultiTenant authenticator.
+//
+// Params:
+// - configs: The tenants. Names and issuers must be unique across tenants.
+// - leeway: Clock skew tolerated when checking "exp" and "nbf".
+//
+// Returns:
+// - A pointer to the initialized MultiTenant, or an error if a tenant is misconfigured.
+func NewMultiTenant(configs []TenantConfig, leeway time.Duration) (*MultiTenant, error) {
+	m := &MultiTenant{tenants: make(map[string]*tenant), byIssuer: make(map[string]*tenant)}
+	for _, config := range configs {
+		t, err := m.newTenant(config, Options{Leeway: leeway})
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", config.Name, err)
+		}
+		if _, ok := m.tenants[config.Name]; ok {
+			return nil, fmt.Errorf("tenant %q: duplicate name", config.Name)
+		}
+		m.tenants[config.Name] = t
+		for _, issuer := range config.Issuers {
+			if other, ok := m.byIssuer[issuer]; ok {
+				return nil, fmt.Errorf("tenant %q: issuer %q already belongs to tenant %q", config.Name, issuer, other.config.Name)
+			}
+			m.byIssuer[issuer] = t
+		}
+	}
+	return m, nil
+}
+
+// newTenant creates the tenant's verifier from its key configuration.
+func (m *MultiTenant) newTenant(config TenantConfig, options Options) (*tenant, error) {
+	if config.Name == "" {
+		return nil, errors.New("empty name")
+	}
+	if len(config.Issuers) == 0 {
+		return nil, errors.New("no issuers")
+	}
+	t := &tenant{config: config}
+	var err error
+	switch {
+	case config.Secret != "" && config.PublicKeyFile == "" && config.JWKSURL == "":
+		t.verifier, err = NewHMAC([]byte(config.Secret), options)
+	case config.PublicKeyFile != "" && config.Secret == "" && config.JWKSURL == "":
+		t.verifier, err = LoadPublicKeyFile(config.PublicKeyFile, options)
+	case config.JWKSURL != "" && config.Secret == "" && config.PublicKeyFile == "":
+		keys := NewKeySet(config.JWKSURL, time.Minute)
+		m.keySets = append(m.keySets, keys)
+		t.verifier = NewJWKS(keys, options)
+	default:
+		err = errors.New("exactly one of secret, publicKeyFile and jwksUrl must be set")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadTenantsFile creates a MultiTenant authenticator from a JSON file containing an array of tenants.
+func LoadTenantsFile(path string, leeway time.Duration) (*MultiTenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]TenantConfig, 0)
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parse tenants %s: %w", path, err)
+	}
+	return NewMultiTenant(configs, leeway)
+}
+
+// KeySets returns the key sets of the JWKS tenants, for the caller to refresh periodically.
+func (m *MultiTenant) KeySets() []*KeySet {
+	return m.keySets
+}
+
+// ValidateJwt validates the token against the tenant owning its issuer.
+func (m *MultiTenant) ValidateJwt(authToken string) (jwt.MapClaims, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(authToken, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	issuer, _ := unverified.Claims.GetIssuer()
+	t, ok := m.byIssuer[issuer]
+	if !ok {
+		return nil, fmt.Errorf("unknown issuer %q", issuer)
+	}
+	return t.validate(authToken)
+}
+
+// ValidateTenantJwt validates the token against the named tenant.
+func (m *MultiTenant) ValidateTenantJwt(name string, authToken string) (jwt.MapClaims, error) {
+	t, ok := m.tenants[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant %q", name)
+	}
+	return t.validate(authToken)
+}
+
+// validate verifies the token's signature and checks its issuer and audience against the tenant's rules.
+func (t *tenant) validate(authToken string) (jwt.MapClaims, error) {
+	claims, err := t.verifier.ValidateJwt(authToken)
+	if err != nil {
+		return nil, err
+	}
+	issuer, _ := claims.GetIssuer()
+	if !slices.Contains(t.config.Issuers, issuer) {
+		return nil, fmt.Errorf("issuer %q not accepted by tenant %q: %w", issuer, t.config.Name, jwt.ErrTokenInvalidIssuer)
+	}
+	if len(t.config.Audiences) == 0 {
+		return claims, nil
+	}
+	audiences, _ := claims.GetAudience()
+	for _, audience := range audiences {
+		if slices.Contains(t.config.Audiences, audience) {
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("audience %v not accepted by tenant %q: %w", audiences, t.config.Name, jwt.ErrTokenInvalidAudience)
+}
@@ -0,0 +1,166 @@
+// Package location streams GPS positions of users with server-side downsampling and smoothing, geofence entry
+// and exit events, and last-known positions for new subscribers.
+//
+// Positions of a user are published on "location.<subject>". The channel keeps the latest position as history so
+// new subscribers immediately receive the last known value. Geofence events are published on
+// "location.<subject>.geofence".
+package location
+
+import (
+	"context"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"math"
+	"sync"
+	"time"
+)
+
+// Channel is the channel clients report their positions on.
+const Channel = "location"
+
+// Publisher publishes updates to the subscribers of a channel, e.g. server.WsGw.
+type Publisher interface {
+	Publish(channel string, updateType string, data any)
+}
+
+// Position is a reported position. Positions are published as "position" updates.
+type Position struct {
+	Subject  string  `json:"sub,omitempty"` // Set by the server
+	Lat      float64 `json:"lat" validate:"min=-90,max=90"`
+	Lon      float64 `json:"lon" validate:"min=-180,max=180"`
+	Accuracy float64 `json:"accuracy,omitempty" validate:"min=0"` // Meters
+	Heading  float64 `json:"heading,omitempty"`                   // Degrees clockwise from north
+	Speed    float64 `json:"speed,omitempty"`                     // Meters per second
+	Time     int64   `json:"ts,omitempty"`                        // Unix milliseconds, set by the server if missing
+}
+
+// Ack answers a position report.
+type Ack struct {
+	Accepted bool `json:"accepted"` // False if the position was dropped by downsampling
+}
+
+// Geofence is a circular area.
+type Geofence struct {
+	ID     string  `json:"id"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Radius float64 `json:"radius"` // Meters
+}
+
+// GeofenceEvent reports that a user entered or left a geofence. Events are published as "geofence" updates.
+type GeofenceEvent struct {
+	Subject  string   `json:"sub"`
+	Geofence string   `json:"geofence"`
+	Entered  bool     `json:"entered"` // False when the user left the geofence
+	Position Position `json:"position"`
+}
+
+// Options configure the location module.
+type Options struct {
+	MinInterval time.Duration       // Positions arriving sooner after the last published one are dropped...
+	MinDistance float64             // ...unless the user moved at least this many meters
+	Smoothing   float64             // Weight of a new sample in exponential smoothing; 0 disables smoothing
+	Scopes      []string            // If set, only clients holding one of these scopes may watch positions
+	Geofences   []Geofence          // Areas whose entry and exit generate events
+	OnGeofence  func(GeofenceEvent) // Hook called for each geofence event, optional
+}
+
+// track is the state of a user's stream.
+type track struct {
+	last   *Position       // Last published position
+	inside map[string]bool // Geofences the user is in
+}
+
+// module holds the tracks of all users.
+type module struct {
+	sync.Mutex
+	publisher Publisher
+	options   Options
+	tracks    map[string]*track
+}
+
+// earthRadius is the mean radius of the earth in meters.
+const earthRadius = 6371000
+
+// distance returns the great-circle distance between two positions in meters.
+func distance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*toRad, (lon2-lon1)*toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// report downsamples and smooths the position, then publishes it and any geofence events.
+func (m *module) report(subject string, pos Position) bool {
+	pos.Subject = subject
+	if pos.Time == 0 {
+		pos.Time = time.Now().UnixMilli()
+	}
+
+	m.Lock()
+	t := m.tracks[subject]
+	if t == nil {
+		t = &track{inside: make(map[string]bool)}
+		m.tracks[subject] = t
+	}
+	if last := t.last; last != nil {
+		elapsed := time.Duration(pos.Time-last.Time) * time.Millisecond
+		if elapsed < m.options.MinInterval && distance(last.Lat, last.Lon, pos.Lat, pos.Lon) < m.options.MinDistance {
+			m.Unlock()
+			return false
+		}
+		if w := m.options.Smoothing; w > 0 && w < 1 {
+			pos.Lat = w*pos.Lat + (1-w)*last.Lat
+			pos.Lon = w*pos.Lon + (1-w)*last.Lon
+		}
+	}
+	t.last = &pos
+	events := make([]GeofenceEvent, 0)
+	for _, fence := range m.options.Geofences {
+		inside := distance(fence.Lat, fence.Lon, pos.Lat, pos.Lon) <= fence.Radius
+		if inside != t.inside[fence.ID] {
+			t.inside[fence.ID] = inside
+			events = append(events, GeofenceEvent{Subject: subject, Geofence: fence.ID, Entered: inside, Position: pos})
+		}
+	}
+	m.Unlock()
+
+	m.publisher.Publish(Channel+"."+subject, "position", &pos)
+	for _, event := range events {
+		m.publisher.Publish(Channel+"."+subject+".geofence", "geofence", &event)
+		if m.options.OnGeofence != nil {
+			m.options.OnGeofence(event)
+		}
+	}
+	return true
+}
+
+// Register declares the location channels and registers the "location" handlers.
+//
+// Authenticated clients send "update" messages with their position; watchers subscribe to "location.<subject>"
+// and receive the last known position followed by live "position" updates.
+//
+// Params:
+// - publisher: Publishes positions and geofence events.
+// - registry: The gateway's channel registry, in which the location channels are declared.
+// - options: Downsampling, smoothing and geofence configuration.
+func Register(publisher Publisher, registry *channels.Registry, options Options) error {
+	for _, def := range []channels.Definition{
+		{Name: Channel + ".*", ServerOnly: true, History: true, ReplayDepth: 1, Private: len(options.Scopes) > 0, Scopes: options.Scopes},
+		{Name: Channel + ".*.geofence", ServerOnly: true, Private: len(options.Scopes) > 0, Scopes: options.Scopes},
+	} {
+		if err := registry.Register(def); err != nil {
+			return fmt.Errorf("location: %w", err)
+		}
+	}
+	m := &module{publisher: publisher, options: options, tracks: make(map[string]*track)}
+	handler.RegisterHandler(Channel, "update", func(_ context.Context, client handler.Client, pos Position) (*Ack, error) {
+		subject, _ := client.Claims().GetSubject()
+		if subject == "" {
+			return nil, fmt.Errorf("anonymous positions: %w", handler.ErrPermissionDenied)
+		}
+		return &Ack{Accepted: m.report(subject, pos)}, nil
+	})
+	return nil
+}
@@ -0,0 +1,98 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WebhookExporter posts usage to a billing webhook.
+type WebhookExporter struct {
+	url    string
+	client *http.Client
+}
+
+// UsageReport is the body posted by WebhookExporter.
+type UsageReport struct {
+	Time    int64            `json:"time"`    // Report time in Unix timestamp
+	Tenants map[string]Usage `json:"tenants"` // Usage since the previous report keyed by tenant
+}
+
+// NewWebhookExporter creates a WebhookExporter posting to the URL.
+func NewWebhookExporter(url string) *WebhookExporter {
+	return &WebhookExporter{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Export posts the usage. Any status other than 2xx is an error, so the usage is exported again later.
+func (e *WebhookExporter) Export(ctx context.Context, usage map[string]Usage) error {
+	body, err := json.Marshal(&UsageReport{Time: time.Now().Unix(), Tenants: usage})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("billing webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// PrometheusExporter accumulates exported usage into counters served in the Prometheus text format.
+type PrometheusExporter struct {
+	sync.Mutex
+	totals map[string]Usage
+}
+
+// NewPrometheusExporter creates a PrometheusExporter.
+func NewPrometheusExporter() *PrometheusExporter {
+	return &PrometheusExporter{totals: make(map[string]Usage)}
+}
+
+// Export adds the usage to the counters.
+func (e *PrometheusExporter) Export(_ context.Context, usage map[string]Usage) error {
+	e.Lock()
+	defer e.Unlock()
+	for tenant, u := range usage {
+		total := e.totals[tenant]
+		total.add(u)
+		e.totals[tenant] = total
+	}
+	return nil
+}
+
+// ServeHTTP serves the counters.
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.Lock()
+	tenants := make([]string, 0, len(e.totals))
+	for tenant := range e.totals {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	var b bytes.Buffer
+	b.WriteString("# HELP wsgw_tenant_messages_total Messages sent by the tenant's clients.\n")
+	b.WriteString("# TYPE wsgw_tenant_messages_total counter\n")
+	for _, tenant := range tenants {
+		fmt.Fprintf(&b, "wsgw_tenant_messages_total{tenant=%q} %d\n", tenant, e.totals[tenant].Messages)
+	}
+	b.WriteString("# HELP wsgw_tenant_connection_minutes_total Time the tenant's clients were connected.\n")
+	b.WriteString("# TYPE wsgw_tenant_connection_minutes_total counter\n")
+	for _, tenant := range tenants {
+		fmt.Fprintf(&b, "wsgw_tenant_connection_minutes_total{tenant=%q} %g\n", tenant, e.totals[tenant].ConnectionMinutes)
+	}
+	e.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(b.Bytes())
+}
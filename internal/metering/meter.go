@@ -0,0 +1,195 @@
+// Package metering aggregates billable usage per tenant and exports it periodically.
+//
+// Usage is checkpointed to a Store before it is exported and only removed from the store once the export
+// succeeded, so usage survives restarts and failed exports are retried.
+package metering
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Usage is the billable usage of a tenant.
+type Usage struct {
+	Messages          int64   `json:"messages"`          // Messages sent by the tenant's clients
+	ConnectionMinutes float64 `json:"connectionMinutes"` // Time the tenant's clients were connected
+}
+
+// add adds other to the usage.
+func (u *Usage) add(other Usage) {
+	u.Messages += other.Messages
+	u.ConnectionMinutes += other.ConnectionMinutes
+}
+
+// Store persists usage that has not been exported yet.
+type Store interface {
+	// Add adds usage to the pending usage of each tenant.
+	Add(ctx context.Context, usage map[string]Usage) error
+	// Pending returns the pending usage of every tenant.
+	Pending(ctx context.Context) (map[string]Usage, error)
+	// Commit subtracts exported usage from the pending usage.
+	Commit(ctx context.Context, exported map[string]Usage) error
+}
+
+// Exporter delivers usage to a billing system.
+type Exporter interface {
+	Export(ctx context.Context, usage map[string]Usage) error
+}
+
+// Meter counts usage per tenant.
+type Meter struct {
+	sync.Mutex
+	store       Store
+	exporter    Exporter
+	tenantClaim string              // Claim holding the tenant identifier
+	usage       map[string]Usage    // Usage counted since the last checkpoint
+	open        map[int]*connection // Open connections keyed by connection ID
+}
+
+// connection is an open, metered connection.
+type connection struct {
+	tenant string
+	since  time.Time // Start of the period not yet counted
+}
+
+// NewMeter creates a Meter.
+//
+// Params:
+// - store: The store holding pending usage, e.g. a RedisStore shared by all nodes.
+// - exporter: The exporter receiving usage.
+// - tenantClaim: The claim holding the tenant identifier; "tenant" if empty.
+func NewMeter(store Store, exporter Exporter, tenantClaim string) *Meter {
+	if tenantClaim == "" {
+		tenantClaim = "tenant"
+	}
+	return &Meter{
+		store:       store,
+		exporter:    exporter,
+		tenantClaim: tenantClaim,
+		usage:       make(map[string]Usage),
+		open:        make(map[int]*connection),
+	}
+}
+
+// TenantOf returns the tenant of the claims, or an empty string if the claims carry none.
+func (m *Meter) TenantOf(claims map[string]any) string {
+	if claims == nil {
+		return ""
+	}
+	if tenant, ok := claims[m.tenantClaim]; ok {
+		return fmt.Sprint(tenant)
+	}
+	return ""
+}
+
+// Connected starts counting connection time of the connection. Connections without a tenant are not metered.
+func (m *Meter) Connected(connectionID int, tenant string) {
+	if tenant == "" {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.open[connectionID]; !ok {
+		m.open[connectionID] = &connection{tenant: tenant, since: time.Now()}
+	}
+}
+
+// Disconnected stops counting connection time of the connection.
+func (m *Meter) Disconnected(connectionID int) {
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.open[connectionID]
+	if !ok {
+		return
+	}
+	u := m.usage[c.tenant]
+	u.ConnectionMinutes += time.Since(c.since).Minutes()
+	m.usage[c.tenant] = u
+	delete(m.open, connectionID)
+}
+
+// Message counts a message of the tenant.
+func (m *Meter) Message(tenant string) {
+	if tenant == "" {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	u := m.usage[tenant]
+	u.Messages++
+	m.usage[tenant] = u
+}
+
+// Run checkpoints and exports usage at every interval until the context is done.
+func (m *Meter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Flush(ctx)
+		case <-ctx.Done():
+			if err := m.checkpoint(context.Background()); err != nil {
+				slog.Error("Failed to checkpoint usage", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// Flush checkpoints the counted usage to the store and exports all pending usage.
+func (m *Meter) Flush(ctx context.Context) {
+	if err := m.checkpoint(ctx); err != nil {
+		slog.Error("Failed to checkpoint usage", "error", err)
+		return
+	}
+	pending, err := m.store.Pending(ctx)
+	if err != nil {
+		slog.Error("Failed to load pending usage", "error", err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if err := m.exporter.Export(ctx, pending); err != nil {
+		slog.Error("Failed to export usage", "error", err, "tenants", len(pending))
+		return
+	}
+	if err := m.store.Commit(ctx, pending); err != nil {
+		slog.Error("Failed to commit exported usage", "error", err)
+	}
+}
+
+// checkpoint moves the usage counted since the last checkpoint, including the connection time of open
+// connections, to the store. On failure the usage is kept for the next checkpoint.
+func (m *Meter) checkpoint(ctx context.Context) error {
+	now := time.Now()
+	m.Lock()
+	usage := m.usage
+	for _, c := range m.open {
+		u := usage[c.tenant]
+		u.ConnectionMinutes += now.Sub(c.since).Minutes()
+		usage[c.tenant] = u
+		c.since = now
+	}
+	m.usage = make(map[string]Usage)
+	m.Unlock()
+
+	if len(usage) == 0 {
+		return nil
+	}
+	if err := m.store.Add(ctx, usage); err != nil {
+		m.Lock()
+		for tenant, u := range usage {
+			current := m.usage[tenant]
+			current.add(u)
+			m.usage[tenant] = current
+		}
+		m.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package metering
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"sync"
+)
+
+// MemoryStore keeps pending usage in memory. Usage is lost on restart.
+type MemoryStore struct {
+	sync.Mutex
+	pending map[string]Usage
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{pending: make(map[string]Usage)}
+}
+
+// Add adds usage to the pending usage of each tenant.
+func (s *MemoryStore) Add(_ context.Context, usage map[string]Usage) error {
+	s.Lock()
+	defer s.Unlock()
+	for tenant, u := range usage {
+		current := s.pending[tenant]
+		current.add(u)
+		s.pending[tenant] = current
+	}
+	return nil
+}
+
+// Pending returns a copy of the pending usage.
+func (s *MemoryStore) Pending(_ context.Context) (map[string]Usage, error) {
+	s.Lock()
+	defer s.Unlock()
+	pending := make(map[string]Usage, len(s.pending))
+	for tenant, u := range s.pending {
+		pending[tenant] = u
+	}
+	return pending, nil
+}
+
+// Commit subtracts exported usage from the pending usage.
+func (s *MemoryStore) Commit(_ context.Context, exported map[string]Usage) error {
+	s.Lock()
+	defer s.Unlock()
+	for tenant, u := range exported {
+		current := s.pending[tenant]
+		current.add(Usage{Messages: -u.Messages, ConnectionMinutes: -u.ConnectionMinutes})
+		if current.Messages <= 0 && current.ConnectionMinutes <= 0 {
+			delete(s.pending, tenant)
+			continue
+		}
+		s.pending[tenant] = current
+	}
+	return nil
+}
+
+// Redis keys holding pending usage; each is a hash keyed by tenant.
+const (
+	redisMessagesKey = "wsgw:metering:messages"
+	redisMinutesKey  = "wsgw:metering:minutes"
+)
+
+// RedisStore keeps pending usage in Redis hashes, shared by all nodes and kept across restarts.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a RedisStore.
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Add increments the pending usage of each tenant.
+func (s *RedisStore) Add(ctx context.Context, usage map[string]Usage) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for tenant, u := range usage {
+			pipe.HIncrBy(ctx, redisMessagesKey, tenant, u.Messages)
+			pipe.HIncrByFloat(ctx, redisMinutesKey, tenant, u.ConnectionMinutes)
+		}
+		return nil
+	})
+	return err
+}
+
+// Pending returns the pending usage of every tenant.
+func (s *RedisStore) Pending(ctx context.Context) (map[string]Usage, error) {
+	messages, err := s.client.HGetAll(ctx, redisMessagesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	minutes, err := s.client.HGetAll(ctx, redisMinutesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]Usage)
+	for tenant, value := range messages {
+		u := pending[tenant]
+		u.Messages, _ = strconv.ParseInt(value, 10, 64)
+		pending[tenant] = u
+	}
+	for tenant, value := range minutes {
+		u := pending[tenant]
+		u.ConnectionMinutes, _ = strconv.ParseFloat(value, 64)
+		pending[tenant] = u
+	}
+	return pending, nil
+}
+
+// Commit decrements the pending usage by the exported usage.
+func (s *RedisStore) Commit(ctx context.Context, exported map[string]Usage) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for tenant, u := range exported {
+			pipe.HIncrBy(ctx, redisMessagesKey, tenant, -u.Messages)
+			pipe.HIncrByFloat(ctx, redisMinutesKey, tenant, -u.ConnectionMinutes)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,70 @@
+// Package moderation defines the hook through which client messages published to rooms are approved, rejected
+// or edited before they are fanned out to subscribers, and built-in moderators.
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Action is the outcome of moderating a message.
+type Action int
+
+const (
+	Approve Action = iota // Deliver the message unchanged
+	Reject                // Drop the message
+	Edit                  // Deliver Decision.Data instead of the original payload
+)
+
+// Message is a client publish awaiting moderation.
+type Message struct {
+	Channel  string          // Room the message is published to
+	Type     string          // Update type
+	Subject  string          // Subject of the publisher, empty if not authenticated
+	ClientID int             // Connection of the publisher
+	Data     json.RawMessage // Payload
+}
+
+// Decision is a Moderator's verdict on a message.
+type Decision struct {
+	Action Action
+	Data   json.RawMessage // Replacement payload for Edit
+	Reason string          // Human readable reason, sent to the publisher on Reject
+}
+
+// Moderator decides whether a message may be delivered.
+//
+// Errors are treated as rejections so a failing moderator does not let messages through.
+type Moderator interface {
+	Moderate(ctx context.Context, msg *Message) (Decision, error)
+}
+
+// ModeratorFunc adapts a function to a Moderator.
+type ModeratorFunc func(ctx context.Context, msg *Message) (Decision, error)
+
+// Moderate calls the function.
+func (f ModeratorFunc) Moderate(ctx context.Context, msg *Message) (Decision, error) {
+	return f(ctx, msg)
+}
+
+// Chain runs moderators in order. The first rejection wins; edits are passed on to the following moderators.
+func Chain(moderators ...Moderator) Moderator {
+	return ModeratorFunc(func(ctx context.Context, msg *Message) (Decision, error) {
+		current := *msg
+		edited := false
+		for _, moderator := range moderators {
+			decision, err := moderator.Moderate(ctx, &current)
+			if err != nil || decision.Action == Reject {
+				return decision, err
+			}
+			if decision.Action == Edit {
+				current.Data = decision.Data
+				edited = true
+			}
+		}
+		if edited {
+			return Decision{Action: Edit, Data: current.Data}, nil
+		}
+		return Decision{Action: Approve}, nil
+	})
+}
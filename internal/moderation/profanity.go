@@ -0,0 +1,91 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// ProfanityFilter masks or rejects messages containing blocked words in any string of their payload.
+type ProfanityFilter struct {
+	pattern *regexp.Regexp
+	reject  bool
+}
+
+// NewProfanityFilter creates a ProfanityFilter.
+//
+// Params:
+// - words: The blocked words, matched case-insensitively as whole words.
+// - reject: Reject offending messages instead of masking the words with asterisks.
+func NewProfanityFilter(words []string, reject bool) *ProfanityFilter {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	f := &ProfanityFilter{reject: reject}
+	if len(quoted) > 0 {
+		f.pattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return f
+}
+
+// Moderate checks the strings of the payload for blocked words.
+func (f *ProfanityFilter) Moderate(_ context.Context, msg *Message) (Decision, error) {
+	if f.pattern == nil {
+		return Decision{Action: Approve}, nil
+	}
+	var payload any
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		return Decision{Action: Reject, Reason: "invalid payload"}, nil
+	}
+	masked, found := f.mask(payload)
+	if !found {
+		return Decision{Action: Approve}, nil
+	}
+	if f.reject {
+		return Decision{Action: Reject, Reason: "message contains blocked words"}, nil
+	}
+	data, err := json.Marshal(masked)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Action: Edit, Data: data}, nil
+}
+
+// mask replaces blocked words in every string of the decoded JSON value.
+//
+// Returns:
+// - The masked value.
+// - true if any blocked word was found.
+func (f *ProfanityFilter) mask(value any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		if !f.pattern.MatchString(v) {
+			return v, false
+		}
+		return f.pattern.ReplaceAllStringFunc(v, func(word string) string {
+			return strings.Repeat("*", len(word))
+		}), true
+	case []any:
+		found := false
+		for i, item := range v {
+			var itemFound bool
+			v[i], itemFound = f.mask(item)
+			found = found || itemFound
+		}
+		return v, found
+	case map[string]any:
+		found := false
+		for key, item := range v {
+			var itemFound bool
+			v[key], itemFound = f.mask(item)
+			found = found || itemFound
+		}
+		return v, found
+	default:
+		return v, false
+	}
+}
@@ -0,0 +1,42 @@
+package moderation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RoomRateLimit rejects messages once a room received a number of messages within a sliding window, damping
+// floods that individual per-client limits miss.
+type RoomRateLimit struct {
+	sync.Mutex
+	window      time.Duration
+	maxMessages int
+	messages    map[string][]time.Time // Recent message times keyed by room
+}
+
+// NewRoomRateLimit creates a RoomRateLimit.
+//
+// Params:
+// - maxMessages: Messages allowed per room within the window.
+// - window: Length of the sliding window.
+func NewRoomRateLimit(maxMessages int, window time.Duration) *RoomRateLimit {
+	return &RoomRateLimit{window: window, maxMessages: maxMessages, messages: make(map[string][]time.Time)}
+}
+
+// Moderate counts the message against its room.
+func (r *RoomRateLimit) Moderate(_ context.Context, msg *Message) (Decision, error) {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	recent := r.messages[msg.Channel]
+	for len(recent) > 0 && now.Sub(recent[0]) > r.window {
+		recent = recent[1:]
+	}
+	if len(recent) >= r.maxMessages {
+		r.messages[msg.Channel] = recent
+		return Decision{Action: Reject, Reason: "room rate limit exceeded"}, nil
+	}
+	r.messages[msg.Channel] = append(recent, now)
+	return Decision{Action: Approve}, nil
+}
@@ -0,0 +1,59 @@
+package msgs
+
+const MsgError = "Error"
+
+// ErrorCode classifies a failed request so clients can handle it programmatically.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest   ErrorCode = "invalid_request"   // The request data cannot be decoded
+	CodeValidationFailed ErrorCode = "validation_failed" // Fields of the request failed validation; see the details
+	CodeMalformedFrame   ErrorCode = "malformed_frame"   // The frame is not a valid message envelope
+	CodeUnknownMessage   ErrorCode = "unknown_message"   // No handler serves the channel and type
+	CodeUnauthenticated  ErrorCode = "unauthenticated"   // The request requires an authenticated connection
+	CodePermissionDenied ErrorCode = "permission_denied" // The client may not perform the request
+	CodeNotFound         ErrorCode = "not_found"         // The addressed resource or recipient does not exist
+	CodeConflict         ErrorCode = "conflict"          // The resource changed; see latest
+	CodeQuotaExceeded    ErrorCode = "quota_exceeded"    // A limit of the client or user is reached
+	CodeRateLimited      ErrorCode = "rate_limited"      // The client sends too fast and its messages are dropped
+	CodeOverloaded       ErrorCode = "overloaded"        // The gateway shed the request; retry later
+	CodeUnavailable      ErrorCode = "unavailable"       // The feature is not configured or its backend is down
+	CodeTimeout          ErrorCode = "timeout"           // The request did not complete in time
+	CodeInternal         ErrorCode = "internal"          // The request failed unexpectedly
+)
+
+// ErrorDetail describes one cause of a failed request, e.g. a field failing a validation rule.
+type ErrorDetail struct {
+	Field   string `json:"field,omitempty"` // JSON name of the field
+	Rule    string `json:"rule,omitempty"`  // Validation rule the field failed
+	Message string `json:"message"`
+}
+
+// Error describes a failed request. It is sent as the "error" of an ErrorResponse.
+type Error struct {
+	Code      ErrorCode     `json:"code"`
+	Message   string        `json:"message"` // Human readable description, not meant for matching
+	Details   []ErrorDetail `json:"details,omitempty"`
+	RequestID string        `json:"requestId,omitempty"` // ID of the failed request
+	Latest    any           `json:"latest,omitempty"`    // Current state of the resource on conflicts
+}
+
+// ErrorResponse is the data of every response to a failed request and of sys "error" updates.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+func (e *ErrorResponse) GetMsgType() MsgType {
+	return MsgError
+}
+
+// NewErrorResponse creates the response to the failed request.
+//
+// Params:
+// - requestID: The ID of the request, or "" for updates.
+// - code: The error code.
+// - message: A human readable description.
+// - details: Optional causes, e.g. failed validation rules.
+func NewErrorResponse(requestID string, code ErrorCode, message string, details ...ErrorDetail) *ErrorResponse {
+	return &ErrorResponse{Error: Error{Code: code, Message: message, Details: details, RequestID: requestID}}
+}
@@ -0,0 +1,171 @@
+// Package natsbridge connects the gateway to NATS, so backend services can serve client messages and push updates
+// to connected clients without touching the gateway code.
+//
+// Client messages on bridged channels are published on "<prefix>.ingress.<channel>". A service answers a message
+// by responding to it with the response payload; the response reaches the requesting client. Updates published on
+// "<prefix>.egress.<channel>" as {"type": ..., "data": ...} are delivered to the channel's subscribers.
+package natsbridge
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// queueGroup is the queue group of the egress subscription, so each update is delivered by exactly one node.
+const queueGroup = "wsgw"
+
+// Ingress is a client message forwarded to NATS.
+type Ingress struct {
+	Node          string          `json:"node"`
+	ClientID      int             `json:"clientId"`
+	Subject       string          `json:"sub,omitempty"` // Subject of the client's token
+	ID            string          `json:"id,omitempty"`
+	Type          string          `json:"type"`
+	Channel       string          `json:"ch"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	CorrelationID string          `json:"correlationId,omitempty"`
+}
+
+// Egress is a message received from NATS: an update for the subscribers of a channel, or a response to a client
+// message if ClientID is set.
+type Egress struct {
+	ClientID int             `json:"-"`
+	ID       string          `json:"-"`
+	Type     string          `json:"type"`
+	Channel  string          `json:"-"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// request identifies the client message a response answers. It is encoded in the reply subject.
+type request struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Channel string `json:"ch"`
+}
+
+// Bridge forwards client messages to NATS and receives updates and responses from it.
+type Bridge struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// New creates a bridge on the NATS connection.
+//
+// Params:
+// - conn: The NATS connection.
+// - prefix: The prefix of the bridge's subjects, e.g. "wsgw".
+//
+// Returns:
+// - A pointer to the initialized Bridge.
+func New(conn *nats.Conn, prefix string) *Bridge {
+	return &Bridge{conn: conn, prefix: prefix}
+}
+
+// Connect connects to the NATS server and creates a bridge on the connection.
+//
+// Params:
+// - url: The NATS server URL, or a comma separated list of URLs.
+// - prefix: The prefix of the bridge's subjects.
+//
+// Returns:
+// - A pointer to the Bridge, or an error if the connection failed.
+func Connect(url string, prefix string) (*Bridge, error) {
+	conn, err := nats.Connect(url, nats.Name("wsgw"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("connect to NATS: %w", err)
+	}
+	return New(conn, prefix), nil
+}
+
+// Forward publishes the client message on the ingress subject of its channel. Responses to it are received by
+// the Subscribe of the message's node.
+func (b *Bridge) Forward(ctx context.Context, msg Ingress) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode ingress message: %w", err)
+	}
+	token, err := json.Marshal(request{ID: msg.ID, Type: msg.Type, Channel: msg.Channel})
+	if err != nil {
+		return fmt.Errorf("encode reply subject: %w", err)
+	}
+	return b.conn.PublishMsg(&nats.Msg{
+		Subject: b.prefix + ".ingress." + msg.Channel,
+		Reply:   fmt.Sprintf("%s.reply.%s.%d.%s", b.prefix, msg.Node, msg.ClientID, base64.RawURLEncoding.EncodeToString(token)),
+		Data:    data,
+	})
+}
+
+// Subscribe calls deliver with every egress update and every response to a message forwarded by the node, until
+// the context is cancelled.
+//
+// Params:
+// - ctx: Context cancelling the subscription.
+// - node: ID of this node; only responses to its messages are received.
+// - deliver: Called with every received message.
+func (b *Bridge) Subscribe(ctx context.Context, node string, deliver func(Egress)) error {
+	egressPrefix := b.prefix + ".egress."
+	updates, err := b.conn.QueueSubscribe(egressPrefix+">", queueGroup, func(m *nats.Msg) {
+		msg := Egress{Channel: strings.TrimPrefix(m.Subject, egressPrefix)}
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			slog.Error("Invalid NATS update", "subject", m.Subject, "error", err)
+			return
+		}
+		deliver(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe to NATS updates: %w", err)
+	}
+	defer updates.Unsubscribe()
+
+	replyPrefix := b.prefix + ".reply." + node + "."
+	replies, err := b.conn.Subscribe(replyPrefix+">", func(m *nats.Msg) {
+		msg, err := parseReply(strings.TrimPrefix(m.Subject, replyPrefix), m.Data)
+		if err != nil {
+			slog.Error("Invalid NATS response", "subject", m.Subject, "error", err)
+			return
+		}
+		deliver(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe to NATS responses: %w", err)
+	}
+	defer replies.Unsubscribe()
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// parseReply decodes a response from the "<clientId>.<request>" suffix of its reply subject and its payload.
+func parseReply(suffix string, data []byte) (Egress, error) {
+	clientPart, tokenPart, ok := strings.Cut(suffix, ".")
+	if !ok {
+		return Egress{}, fmt.Errorf("malformed reply subject")
+	}
+	clientID, err := strconv.Atoi(clientPart)
+	if err != nil {
+		return Egress{}, fmt.Errorf("malformed client ID: %w", err)
+	}
+	token, err := base64.RawURLEncoding.DecodeString(tokenPart)
+	if err != nil {
+		return Egress{}, fmt.Errorf("malformed request token: %w", err)
+	}
+	req := request{}
+	if err := json.Unmarshal(token, &req); err != nil {
+		return Egress{}, fmt.Errorf("malformed request token: %w", err)
+	}
+	if !json.Valid(data) {
+		return Egress{}, fmt.Errorf("response is not JSON")
+	}
+	return Egress{ClientID: clientID, ID: req.ID, Type: req.Type, Channel: req.Channel, Data: data}, nil
+}
+
+// Close drains the NATS connection.
+func (b *Bridge) Close() error {
+	return b.conn.Drain()
+}
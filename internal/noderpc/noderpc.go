@@ -0,0 +1,227 @@
+// Package noderpc delivers messages for a user directly to the node owning the user, over gRPC, instead of relaying
+// them to every node of the cluster. It also serves the queries administrators fan out to every node, e.g. the
+// connection census.
+//
+// Messages are encoded as JSON with the "json" content subtype, so the service needs no generated code.
+package noderpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"sort"
+	"sync"
+)
+
+// codecName is the content subtype of the JSON codec.
+const codecName = "json"
+
+// Delivery is a direct message for every connection of a user.
+type Delivery struct {
+	Subject       string          `json:"sub"` // Recipient
+	Type          string          `json:"type"`
+	Channel       string          `json:"ch"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	From          string          `json:"from"`       // Subject of the sender
+	FromConID     int             `json:"fromConID"`  // Connection ID of the sender on its node
+	FromClaims    map[string]any  `json:"fromClaims"` // Claims of the sender, for the recipient node's authorization
+	CausationID   string          `json:"causationId,omitempty"`
+	CorrelationID string          `json:"correlationId,omitempty"`
+}
+
+// Receipt reports the outcome of a delivery.
+type Receipt struct {
+	Delivered int  `json:"delivered"`        // Connections the message was queued for
+	Denied    bool `json:"denied,omitempty"` // Whether every connection refused the sender
+}
+
+// CensusRequest asks a node for its connection counts.
+type CensusRequest struct {
+	TopChannels int `json:"topChannels"` // Channels reported, by subscriber count
+}
+
+// ChannelCount is the number of subscribers of a channel.
+type ChannelCount struct {
+	Channel     string `json:"ch"`
+	Subscribers int    `json:"subscribers"`
+}
+
+// NodeCensus counts the connections of a node.
+type NodeCensus struct {
+	Node          string         `json:"node"`
+	Connections   int            `json:"connections"`
+	Authenticated int            `json:"authenticated"`
+	Tenants       map[string]int `json:"tenants,omitempty"`     // Connections by tenant
+	TopChannels   []ChannelCount `json:"topChannels,omitempty"` // Channels with the most subscribers
+}
+
+// Handler serves the requests received by a node.
+type Handler interface {
+	Deliver(ctx context.Context, delivery *Delivery) (*Receipt, error)
+	Census(ctx context.Context, request *CensusRequest) (*NodeCensus, error)
+}
+
+// jsonCodec encodes messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return codecName }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// serviceDesc describes the node RPC service.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "wsgw.NodeRPC",
+	HandlerType: (*Handler)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Deliver", Handler: deliverHandler},
+		{MethodName: "Census", Handler: censusHandler},
+	},
+	Metadata: "noderpc",
+}
+
+// deliverHandler decodes a delivery and passes it to the handler.
+func deliverHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	delivery := &Delivery{}
+	if err := dec(delivery); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Handler).Deliver(ctx, delivery)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/wsgw.NodeRPC/Deliver"}
+	return interceptor(ctx, delivery, info, func(ctx context.Context, req any) (any, error) {
+		return srv.(Handler).Deliver(ctx, req.(*Delivery))
+	})
+}
+
+// censusHandler decodes a census request and passes it to the handler.
+func censusHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	request := &CensusRequest{}
+	if err := dec(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Handler).Census(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/wsgw.NodeRPC/Census"}
+	return interceptor(ctx, request, info, func(ctx context.Context, req any) (any, error) {
+		return srv.(Handler).Census(ctx, req.(*CensusRequest))
+	})
+}
+
+// NewServer creates a gRPC server delivering the received messages to the handler.
+//
+// Params:
+// - handler: Delivers the messages to the node's connections.
+// - opts: Options of the gRPC server, e.g. its TLS credentials.
+//
+// Returns:
+// - A pointer to the gRPC server, to be served on a listener.
+func NewServer(handler Handler, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&serviceDesc, handler)
+	return server
+}
+
+// Peers sends deliveries to the other nodes of the cluster, keeping one connection per node.
+type Peers struct {
+	addrs map[string]string // RPC address by node ID
+	opts  []grpc.DialOption
+	lock  sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewPeers creates the client of the other nodes. Connections are made on first use; without options they are
+// not encrypted.
+//
+// Params:
+// - addrs: The RPC address, host:port, of each node by its ID.
+// - opts: Options of the connections, e.g. their TLS credentials.
+//
+// Returns:
+// - A pointer to the initialized Peers.
+func NewPeers(addrs map[string]string, opts ...grpc.DialOption) *Peers {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return &Peers{addrs: addrs, opts: opts, conns: make(map[string]*grpc.ClientConn)}
+}
+
+// Has reports whether the node's RPC address is known.
+func (p *Peers) Has(node string) bool {
+	_, ok := p.addrs[node]
+	return ok
+}
+
+// Nodes returns the IDs of the nodes with a known RPC address, sorted.
+func (p *Peers) Nodes() []string {
+	nodes := make([]string, 0, len(p.addrs))
+	for node := range p.addrs {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Deliver sends the delivery to the node.
+func (p *Peers) Deliver(ctx context.Context, node string, delivery *Delivery) (*Receipt, error) {
+	conn, err := p.conn(node)
+	if err != nil {
+		return nil, err
+	}
+	receipt := &Receipt{}
+	if err := conn.Invoke(ctx, "/wsgw.NodeRPC/Deliver", delivery, receipt, grpc.CallContentSubtype(codecName)); err != nil {
+		return nil, fmt.Errorf("deliver to node %s: %w", node, err)
+	}
+	return receipt, nil
+}
+
+// Census asks the node for its connection counts.
+func (p *Peers) Census(ctx context.Context, node string, request *CensusRequest) (*NodeCensus, error) {
+	conn, err := p.conn(node)
+	if err != nil {
+		return nil, err
+	}
+	census := &NodeCensus{}
+	if err := conn.Invoke(ctx, "/wsgw.NodeRPC/Census", request, census, grpc.CallContentSubtype(codecName)); err != nil {
+		return nil, fmt.Errorf("census of node %s: %w", node, err)
+	}
+	return census, nil
+}
+
+// conn returns the connection to the node, creating it on first use.
+func (p *Peers) conn(node string) (*grpc.ClientConn, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if conn, ok := p.conns[node]; ok {
+		return conn, nil
+	}
+	addr, ok := p.addrs[node]
+	if !ok {
+		return nil, fmt.Errorf("no RPC address for node %s", node)
+	}
+	conn, err := grpc.NewClient(addr, p.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("connect to node %s: %w", node, err)
+	}
+	p.conns[node] = conn
+	return conn, nil
+}
+
+// Close closes the connections to the nodes.
+func (p *Peers) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for node, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, node)
+	}
+	return nil
+}
@@ -19,3 +19,8 @@ func (o OpenAuthenticator) ValidateJwt(authToken string) (jwt.MapClaims, error)
 
 	return token.Claims.(jwt.MapClaims), nil
 }
+
+// Insecure reports that the authenticator accepts tokens without verifying their signature.
+func (o OpenAuthenticator) Insecure() bool {
+	return true
+}
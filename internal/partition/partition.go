@@ -0,0 +1,81 @@
+// Package partition assigns users to the nodes of a gateway cluster with consistent hashing, so all connections
+// of a user land on the same node and user-targeted sends need not cross the cluster. Adding or removing a node
+// only moves the users of its neighbours on the ring.
+package partition
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the number of points each node has on the ring by default.
+const DefaultReplicas = 128
+
+// Node is a member of the cluster.
+type Node struct {
+	ID  string `json:"node"`          // Node ID, as set with SetNodeID
+	URL string `json:"url,omitempty"` // WebSocket URL clients connect to for the node
+}
+
+// point is a position of a node on the ring.
+type point struct {
+	hash uint64
+	node int
+}
+
+// Ring maps keys to nodes. It is immutable and safe for concurrent use.
+type Ring struct {
+	nodes  []Node
+	points []point
+}
+
+// New creates a ring of the nodes.
+//
+// Params:
+// - nodes: The members of the cluster; their order does not matter.
+// - replicas: Points per node; more points spread users more evenly. 0 uses DefaultReplicas.
+//
+// Returns:
+// - A pointer to the initialized Ring.
+func New(nodes []Node, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	r := &Ring{nodes: nodes, points: make([]point, 0, len(nodes)*replicas)}
+	for i, node := range nodes {
+		for replica := 0; replica < replicas; replica++ {
+			r.points = append(r.points, point{hash: hash(node.ID + "#" + strconv.Itoa(replica)), node: i})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r
+}
+
+// Owner returns the node a key, e.g. a user's subject, is assigned to.
+//
+// Returns:
+// - The owning node, or false if the ring is empty.
+func (r *Ring) Owner(key string) (Node, bool) {
+	if len(r.points) == 0 {
+		return Node{}, false
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.nodes[r.points[i].node], true
+}
+
+// Nodes returns the members of the ring.
+func (r *Ring) Nodes() []Node {
+	return r.nodes
+}
+
+// hash is the 64-bit FNV-1a hash of the key.
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
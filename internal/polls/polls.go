@@ -0,0 +1,201 @@
+// Package polls is a built-in poll module: clients create polls, vote once per subject and watch a live tally.
+//
+// Each poll gets its own server-only channel declared in the channel registry, which conflates tally updates
+// and restricts access to the poll's scopes.
+package polls
+
+import (
+	"context"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/ids"
+	"sync"
+	"time"
+)
+
+// Channel is the channel poll commands are sent on. Tallies are published on "polls.<pollId>".
+const Channel = "polls"
+
+// Publisher publishes updates to the subscribers of a channel, e.g. server.WsGw.
+type Publisher interface {
+	Publish(channel string, updateType string, data any)
+}
+
+// CreateRequest creates a poll.
+type CreateRequest struct {
+	Question string   `json:"question" validate:"required,max=500"`
+	Options  []string `json:"options" validate:"min=2,max=20,dive,required,max=200"`
+	Scopes   []string `json:"scopes,omitempty"` // If set, only clients holding one of these scopes may see and vote
+}
+
+// VoteRequest casts a vote.
+type VoteRequest struct {
+	PollID string `json:"pollId" validate:"required"`
+	Option int    `json:"option" validate:"min=0"` // Index of the chosen option
+}
+
+// PollRequest identifies a poll.
+type PollRequest struct {
+	PollID string `json:"pollId" validate:"required"`
+}
+
+// Tally is the state of a poll, returned by requests and published as "tally" updates.
+type Tally struct {
+	PollID   string   `json:"pollId"`
+	Channel  string   `json:"ch"` // Channel the tally updates are published on
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Votes    []int    `json:"votes"` // Votes per option
+	Closed   bool     `json:"closed,omitempty"`
+}
+
+// poll is a poll and the votes cast.
+type poll struct {
+	creator  string
+	question string
+	options  []string
+	votes    map[string]int // Chosen option by subject
+	closed   bool
+}
+
+// tally counts the votes of the poll. The caller must hold the lock.
+func (p *poll) tally(id string) *Tally {
+	tally := &Tally{PollID: id, Channel: Channel + "." + id, Question: p.question, Options: p.options,
+		Votes: make([]int, len(p.options)), Closed: p.closed}
+	for _, option := range p.votes {
+		tally.Votes[option]++
+	}
+	return tally
+}
+
+// module holds the polls.
+type module struct {
+	sync.Mutex
+	publisher Publisher
+	registry  *channels.Registry
+	ids       ids.Generator
+	polls     map[string]*poll
+}
+
+// lookup returns the poll if the client may access its channel.
+func (m *module) lookup(client handler.Client, id string) (*poll, error) {
+	if def, ok := m.registry.Lookup(Channel + "." + id); ok && !def.Allows(client.Claims()) {
+		return nil, fmt.Errorf("poll %s: %w", id, handler.ErrPermissionDenied)
+	}
+	m.Lock()
+	defer m.Unlock()
+	p, ok := m.polls[id]
+	if !ok {
+		return nil, fmt.Errorf("poll %s: %w", id, handler.ErrNotFound)
+	}
+	return p, nil
+}
+
+// create registers the poll's channel and stores the poll.
+func (m *module) create(client handler.Client, req CreateRequest) (*Tally, error) {
+	creator, _ := client.Claims().GetSubject()
+	if creator == "" {
+		return nil, fmt.Errorf("anonymous polls: %w", handler.ErrPermissionDenied)
+	}
+	id := m.ids.NewID()
+	err := m.registry.Register(channels.Definition{
+		Name:         Channel + "." + id,
+		ServerOnly:   true,
+		Private:      len(req.Scopes) > 0,
+		Scopes:       req.Scopes,
+		ConflationMs: int(tallyConflation.Milliseconds()),
+	})
+	if err != nil {
+		return nil, &handler.Error{Code: handler.CodeInvalidRequest, Message: err.Error()}
+	}
+	p := &poll{creator: creator, question: req.Question, options: req.Options, votes: make(map[string]int)}
+	m.Lock()
+	defer m.Unlock()
+	m.polls[id] = p
+	return p.tally(id), nil
+}
+
+// vote records the subject's vote and publishes the new tally.
+func (m *module) vote(client handler.Client, req VoteRequest) (*Tally, error) {
+	subject, _ := client.Claims().GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("anonymous votes: %w", handler.ErrPermissionDenied)
+	}
+	p, err := m.lookup(client, req.PollID)
+	if err != nil {
+		return nil, err
+	}
+	m.Lock()
+	if req.Option >= len(p.options) {
+		m.Unlock()
+		return nil, &handler.Error{Code: handler.CodeInvalidRequest, Message: "no such option"}
+	}
+	if p.closed {
+		tally := p.tally(req.PollID)
+		m.Unlock()
+		return nil, handler.NewConflict("poll closed", tally)
+	}
+	if _, voted := p.votes[subject]; voted {
+		tally := p.tally(req.PollID)
+		m.Unlock()
+		return nil, handler.NewConflict("already voted", tally)
+	}
+	p.votes[subject] = req.Option
+	tally := p.tally(req.PollID)
+	m.Unlock()
+	m.publisher.Publish(tally.Channel, "tally", tally)
+	return tally, nil
+}
+
+// close ends voting on the poll. Only its creator may close it.
+func (m *module) close(client handler.Client, req PollRequest) (*Tally, error) {
+	p, err := m.lookup(client, req.PollID)
+	if err != nil {
+		return nil, err
+	}
+	subject, _ := client.Claims().GetSubject()
+	m.Lock()
+	if subject == "" || subject != p.creator {
+		m.Unlock()
+		return nil, fmt.Errorf("poll %s: %w", req.PollID, handler.ErrPermissionDenied)
+	}
+	p.closed = true
+	tally := p.tally(req.PollID)
+	m.Unlock()
+	m.publisher.Publish(tally.Channel, "tally", tally)
+	return tally, nil
+}
+
+// tallyConflation is the conflation interval of tally updates.
+const tallyConflation = 500 * time.Millisecond
+
+// Register registers the poll handlers on the "polls" channel.
+//
+// Authenticated clients "create" polls, "vote" once per subject, "get" the current tally and, as the creator,
+// "close" a poll. Subscribers of the poll's channel receive conflated "tally" updates.
+//
+// Params:
+// - publisher: Publishes tally updates.
+// - registry: The gateway's channel registry, in which poll channels are declared.
+func Register(publisher Publisher, registry *channels.Registry) {
+	m := &module{publisher: publisher, registry: registry, ids: ids.KSUID{}, polls: make(map[string]*poll)}
+	handler.RegisterHandler(Channel, "create", func(_ context.Context, client handler.Client, req CreateRequest) (*Tally, error) {
+		return m.create(client, req)
+	})
+	handler.RegisterHandler(Channel, "vote", func(_ context.Context, client handler.Client, req VoteRequest) (*Tally, error) {
+		return m.vote(client, req)
+	})
+	handler.RegisterHandler(Channel, "get", func(_ context.Context, client handler.Client, req PollRequest) (*Tally, error) {
+		p, err := m.lookup(client, req.PollID)
+		if err != nil {
+			return nil, err
+		}
+		m.Lock()
+		defer m.Unlock()
+		return p.tally(req.PollID), nil
+	})
+	handler.RegisterHandler(Channel, "close", func(_ context.Context, client handler.Client, req PollRequest) (*Tally, error) {
+		return m.close(client, req)
+	})
+}
@@ -0,0 +1,142 @@
+// Package reactions aggregates emoji reactions to messages server-side and broadcasts conflated counts, so a
+// popular message produces one count update per interval instead of one update per reaction.
+package reactions
+
+import (
+	"context"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"sync"
+	"time"
+)
+
+// Channel is the channel reaction commands are sent on. Counts are published on "reactions.<ch>", where ch is
+// the channel of the reacted message; declare those channels when the registry is strict.
+const Channel = "reactions"
+
+// Publisher publishes updates to the subscribers of a channel, e.g. server.WsGw.
+type Publisher interface {
+	Publish(channel string, updateType string, data any)
+}
+
+// ReactionRequest adds or removes a reaction to a message.
+type ReactionRequest struct {
+	Channel string `json:"ch" validate:"required"`
+	MsgID   string `json:"msgId" validate:"required"`
+	Emoji   string `json:"emoji" validate:"required,max=32"`
+}
+
+// Counts are the reaction counts of a message by emoji, published as "counts" updates.
+type Counts struct {
+	Channel string         `json:"ch"`
+	MsgID   string         `json:"msgId"`
+	Counts  map[string]int `json:"counts"`
+}
+
+// message is the key of a reacted message.
+type message struct {
+	channel string
+	msgID   string
+}
+
+// aggregator keeps the reactions of each message and the messages whose counts changed since the last broadcast.
+type aggregator struct {
+	sync.Mutex
+	publisher Publisher
+	interval  time.Duration
+	reactions map[message]map[string]map[string]bool // Reacting subjects by message and emoji
+	dirty     map[message]bool
+}
+
+// counts returns the reaction counts of the message. The caller must hold the lock.
+func (a *aggregator) counts(key message) *Counts {
+	counts := &Counts{Channel: key.channel, MsgID: key.msgID, Counts: make(map[string]int)}
+	for emoji, subjects := range a.reactions[key] {
+		counts.Counts[emoji] = len(subjects)
+	}
+	return counts
+}
+
+// react adds or removes the subject's reaction and schedules a broadcast of the message's counts.
+func (a *aggregator) react(key message, emoji string, subject string, add bool) *Counts {
+	a.Lock()
+	defer a.Unlock()
+	emojis := a.reactions[key]
+	if add {
+		if emojis == nil {
+			emojis = make(map[string]map[string]bool)
+			a.reactions[key] = emojis
+		}
+		if emojis[emoji] == nil {
+			emojis[emoji] = make(map[string]bool)
+		}
+		emojis[emoji][subject] = true
+	} else if emojis[emoji] != nil {
+		delete(emojis[emoji], subject)
+		if len(emojis[emoji]) == 0 {
+			delete(emojis, emoji)
+		}
+		if len(emojis) == 0 {
+			delete(a.reactions, key)
+		}
+	}
+	if len(a.dirty) == 0 {
+		time.AfterFunc(a.interval, a.broadcast)
+	}
+	a.dirty[key] = true
+	return a.counts(key)
+}
+
+// broadcast publishes the counts of the messages changed since the last broadcast.
+func (a *aggregator) broadcast() {
+	a.Lock()
+	updates := make([]*Counts, 0, len(a.dirty))
+	for key := range a.dirty {
+		updates = append(updates, a.counts(key))
+	}
+	a.dirty = make(map[message]bool)
+	a.Unlock()
+	for _, counts := range updates {
+		a.publisher.Publish(Channel+"."+counts.Channel, "counts", counts)
+	}
+}
+
+// subjectOf identifies the reacting user, falling back to the connection for anonymous clients.
+func subjectOf(client handler.Client) string {
+	if subject, _ := client.Claims().GetSubject(); subject != "" {
+		return subject
+	}
+	return fmt.Sprintf("con%d", client.ID())
+}
+
+// Register registers the reaction handlers on the "reactions" channel.
+//
+// Clients "add" and "remove" reactions to messages of channels they may access; each subject counts once per
+// emoji. The response carries the message's current counts; subscribers of "reactions.<ch>" receive conflated
+// "counts" updates.
+//
+// Params:
+// - publisher: Publishes the count updates.
+// - registry: Authorizes access to the reacted channels; nil allows all channels.
+// - interval: The conflation interval of count updates.
+func Register(publisher Publisher, registry *channels.Registry, interval time.Duration) {
+	a := &aggregator{
+		publisher: publisher,
+		interval:  interval,
+		reactions: make(map[message]map[string]map[string]bool),
+		dirty:     make(map[message]bool),
+	}
+	for _, msgType := range []string{"add", "remove"} {
+		add := msgType == "add"
+		handler.RegisterHandler(Channel, msgType, func(_ context.Context, client handler.Client, req ReactionRequest) (*Counts, error) {
+			if registry != nil {
+				def, ok := registry.Lookup(req.Channel)
+				if (!ok && registry.Strict()) || (ok && !def.Allows(client.Claims())) {
+					return nil, fmt.Errorf("channel %s: %w", req.Channel, handler.ErrPermissionDenied)
+				}
+			}
+			return a.react(message{channel: req.Channel, msgID: req.MsgID}, req.Emoji, subjectOf(client), add), nil
+		})
+	}
+}
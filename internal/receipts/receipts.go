@@ -0,0 +1,187 @@
+// Package receipts tracks the delivery state of messages per user, channel and message, keeps the read markers
+// clients report and aggregates receipts for message authors.
+package receipts
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Receipt is the aggregated delivery state of a message.
+type Receipt struct {
+	Channel   string `json:"ch"`
+	MsgID     string `json:"msgId"`
+	Author    string `json:"author"`    // Subject of the author
+	Delivered int    `json:"delivered"` // Number of users the message was delivered to
+	Read      int    `json:"read"`      // Number of users who read the message
+}
+
+// Store keeps delivery state and read markers.
+type Store interface {
+	// Delivered records that the message of the author was delivered to the user.
+	Delivered(ctx context.Context, channel string, msgID string, author string, user string) error
+	// Read records that the user read the message. Messages whose delivery was never recorded are ignored.
+	Read(ctx context.Context, channel string, msgID string, user string) error
+	// Receipt returns the aggregated state of the message, or nil if it is not tracked.
+	Receipt(ctx context.Context, channel string, msgID string) (*Receipt, error)
+	// SetReadMarker stores the ID of the last message the user read on the channel.
+	SetReadMarker(ctx context.Context, user string, channel string, msgID string) error
+	// ReadMarker returns the ID of the last message the user read on the channel, or "" if none.
+	ReadMarker(ctx context.Context, user string, channel string) (string, error)
+}
+
+// event is a delivery or read reported to the Tracker.
+type event struct {
+	channel string
+	msgID   string
+	author  string // Set for deliveries
+	user    string
+	read    bool
+}
+
+// trackerQueueSize is the number of events buffered before the Tracker drops them.
+const trackerQueueSize = 4096
+
+// storeTimeout bounds each Store call made by the Tracker.
+const storeTimeout = 2 * time.Second
+
+// Tracker records deliveries and reads in the background and pushes aggregated receipts to authors, at most once
+// per message and interval, so large rooms do not flood authors with a receipt per recipient. Unread count changes
+// are coalesced the same way.
+type Tracker struct {
+	sync.Mutex
+	store          Store
+	events         chan event
+	dirty          map[[2]string]bool // Messages whose receipt changed since the last flush, keyed by channel and ID
+	notifier       func(receipt Receipt)
+	unread         map[[2]string]bool // Unread counts changed since the last flush, keyed by user and channel
+	unreadNotifier func(user string, channel string)
+}
+
+// NewTracker creates a Tracker backed by the store.
+func NewTracker(store Store) *Tracker {
+	return &Tracker{
+		store:  store,
+		events: make(chan event, trackerQueueSize),
+		dirty:  make(map[[2]string]bool),
+		unread: make(map[[2]string]bool),
+	}
+}
+
+// Store returns the store of the tracker.
+func (t *Tracker) Store() Store {
+	return t.store
+}
+
+// SetNotifier sets the function receiving changed receipts.
+func (t *Tracker) SetNotifier(notifier func(receipt Receipt)) {
+	t.Lock()
+	defer t.Unlock()
+	t.notifier = notifier
+}
+
+// SetUnreadNotifier sets the function called when the unread count of a user's channel may have changed.
+func (t *Tracker) SetUnreadNotifier(notifier func(user string, channel string)) {
+	t.Lock()
+	defer t.Unlock()
+	t.unreadNotifier = notifier
+}
+
+// UnreadChanged reports that the unread count of the user's channel may have changed, e.g. after a new message
+// or a read marker update. It does not block.
+func (t *Tracker) UnreadChanged(user string, channel string) {
+	t.Lock()
+	defer t.Unlock()
+	t.unread[[2]string{user, channel}] = true
+}
+
+// Delivered reports that the message of the author was delivered to the user. It does not block.
+func (t *Tracker) Delivered(channel string, msgID string, author string, user string) {
+	t.enqueue(event{channel: channel, msgID: msgID, author: author, user: user})
+}
+
+// Read reports that the user read the message. It does not block.
+func (t *Tracker) Read(channel string, msgID string, user string) {
+	t.enqueue(event{channel: channel, msgID: msgID, user: user, read: true})
+}
+
+// enqueue queues the event, dropping it if the queue is full.
+func (t *Tracker) enqueue(e event) {
+	select {
+	case t.events <- e:
+	default:
+		slog.Warn("Receipt queue full, event dropped", "ch", e.channel, "msgId", e.msgID)
+	}
+}
+
+// Run records queued events and pushes changed receipts every interval until the context is done.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-t.events:
+			t.record(ctx, e)
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+// record stores the event and marks the message's receipt as changed.
+func (t *Tracker) record(ctx context.Context, e event) {
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	var err error
+	if e.read {
+		err = t.store.Read(ctx, e.channel, e.msgID, e.user)
+	} else {
+		err = t.store.Delivered(ctx, e.channel, e.msgID, e.author, e.user)
+	}
+	if err != nil {
+		slog.Error("Failed to record receipt", "ch", e.channel, "msgId", e.msgID, "error", err)
+		return
+	}
+	t.Lock()
+	t.dirty[[2]string{e.channel, e.msgID}] = true
+	t.Unlock()
+}
+
+// flush pushes the receipts and unread counts changed since the last flush.
+func (t *Tracker) flush(ctx context.Context) {
+	t.Lock()
+	dirty, notifier := t.dirty, t.notifier
+	unread, unreadNotifier := t.unread, t.unreadNotifier
+	t.dirty = make(map[[2]string]bool)
+	t.unread = make(map[[2]string]bool)
+	t.Unlock()
+	if unreadNotifier != nil {
+		for key := range unread {
+			unreadNotifier(key[0], key[1])
+		}
+	}
+	if notifier == nil {
+		return
+	}
+	for key := range dirty {
+		receipt, err := t.receipt(ctx, key[0], key[1])
+		if err != nil {
+			slog.Error("Failed to load receipt", "ch", key[0], "msgId", key[1], "error", err)
+			continue
+		}
+		if receipt != nil {
+			notifier(*receipt)
+		}
+	}
+}
+
+// receipt loads a receipt with a bounded wait.
+func (t *Tracker) receipt(ctx context.Context, channel string, msgID string) (*Receipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	return t.store.Receipt(ctx, channel, msgID)
+}
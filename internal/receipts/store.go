@@ -0,0 +1,181 @@
+package receipts
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+// messageState is the delivery state of a message kept by the MemoryStore.
+type messageState struct {
+	author    string
+	delivered map[string]bool
+	read      map[string]bool
+}
+
+// MemoryStore keeps the delivery state of the most recent messages in memory. State is lost on restart.
+type MemoryStore struct {
+	sync.Mutex
+	maxMessages int
+	messages    map[[2]string]*messageState // Keyed by channel and message ID
+	order       [][2]string                 // Tracked messages, oldest first
+	markers     map[[2]string]string        // Read markers keyed by user and channel
+}
+
+// NewMemoryStore creates a MemoryStore.
+//
+// Params:
+// - maxMessages: Number of messages tracked; the oldest are forgotten first.
+func NewMemoryStore(maxMessages int) *MemoryStore {
+	return &MemoryStore{
+		maxMessages: maxMessages,
+		messages:    make(map[[2]string]*messageState),
+		markers:     make(map[[2]string]string),
+	}
+}
+
+// Delivered records the delivery, tracking the message if it is new.
+func (s *MemoryStore) Delivered(_ context.Context, channel string, msgID string, author string, user string) error {
+	s.Lock()
+	defer s.Unlock()
+	key := [2]string{channel, msgID}
+	state, ok := s.messages[key]
+	if !ok {
+		state = &messageState{author: author, delivered: make(map[string]bool), read: make(map[string]bool)}
+		s.messages[key] = state
+		s.order = append(s.order, key)
+		if len(s.order) > s.maxMessages {
+			delete(s.messages, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	state.delivered[user] = true
+	return nil
+}
+
+// Read records the read if the message is tracked.
+func (s *MemoryStore) Read(_ context.Context, channel string, msgID string, user string) error {
+	s.Lock()
+	defer s.Unlock()
+	if state, ok := s.messages[[2]string{channel, msgID}]; ok {
+		state.read[user] = true
+	}
+	return nil
+}
+
+// Receipt returns the aggregated state of the message.
+func (s *MemoryStore) Receipt(_ context.Context, channel string, msgID string) (*Receipt, error) {
+	s.Lock()
+	defer s.Unlock()
+	state, ok := s.messages[[2]string{channel, msgID}]
+	if !ok {
+		return nil, nil
+	}
+	return &Receipt{Channel: channel, MsgID: msgID, Author: state.author, Delivered: len(state.delivered), Read: len(state.read)}, nil
+}
+
+// SetReadMarker stores the read marker.
+func (s *MemoryStore) SetReadMarker(_ context.Context, user string, channel string, msgID string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.markers[[2]string{user, channel}] = msgID
+	return nil
+}
+
+// ReadMarker returns the read marker.
+func (s *MemoryStore) ReadMarker(_ context.Context, user string, channel string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.markers[[2]string{user, channel}], nil
+}
+
+// Redis key prefixes of the RedisStore.
+const (
+	redisReceiptPrefix = "wsgw:receipt:"
+	redisMarkerPrefix  = "wsgw:readmarker:"
+)
+
+// RedisStore keeps delivery state in Redis, shared by all nodes. Delivery state expires after the configured TTL;
+// read markers do not expire.
+type RedisStore struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+// NewRedisStore creates a RedisStore.
+//
+// Params:
+// - client: The Redis client.
+// - ttl: How long the delivery state of a message is kept.
+func NewRedisStore(client redis.UniversalClient, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+// receiptKey returns the key prefix of a message's delivery state.
+func receiptKey(channel string, msgID string) string {
+	return redisReceiptPrefix + channel + ":" + msgID
+}
+
+// Delivered adds the user to the delivered set of the message.
+func (s *RedisStore) Delivered(ctx context.Context, channel string, msgID string, author string, user string) error {
+	key := receiptKey(channel, msgID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key+":author", author, s.ttl)
+		pipe.SAdd(ctx, key+":delivered", user)
+		pipe.Expire(ctx, key+":delivered", s.ttl)
+		return nil
+	})
+	return err
+}
+
+// Read adds the user to the read set of the message if it is tracked.
+func (s *RedisStore) Read(ctx context.Context, channel string, msgID string, user string) error {
+	key := receiptKey(channel, msgID)
+	tracked, err := s.client.Exists(ctx, key+":author").Result()
+	if err != nil || tracked == 0 {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key+":read", user)
+		pipe.Expire(ctx, key+":read", s.ttl)
+		return nil
+	})
+	return err
+}
+
+// Receipt counts the delivered and read sets of the message.
+func (s *RedisStore) Receipt(ctx context.Context, channel string, msgID string) (*Receipt, error) {
+	key := receiptKey(channel, msgID)
+	author, err := s.client.Get(ctx, key+":author").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	delivered, err := s.client.SCard(ctx, key+":delivered").Result()
+	if err != nil {
+		return nil, err
+	}
+	read, err := s.client.SCard(ctx, key+":read").Result()
+	if err != nil {
+		return nil, err
+	}
+	return &Receipt{Channel: channel, MsgID: msgID, Author: author, Delivered: int(delivered), Read: int(read)}, nil
+}
+
+// SetReadMarker stores the read marker.
+func (s *RedisStore) SetReadMarker(ctx context.Context, user string, channel string, msgID string) error {
+	return s.client.Set(ctx, redisMarkerPrefix+user+":"+channel, msgID, 0).Err()
+}
+
+// ReadMarker returns the read marker.
+func (s *RedisStore) ReadMarker(ctx context.Context, user string, channel string) (string, error) {
+	msgID, err := s.client.Get(ctx, redisMarkerPrefix+user+":"+channel).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return msgID, err
+}
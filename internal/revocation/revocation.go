@@ -0,0 +1,197 @@
+// Package revocation keeps the tokens and subjects whose tokens must no longer be accepted, e.g. after a logout or
+// when a user is disabled, so the gateway can reject and disconnect them before their tokens expire.
+package revocation
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store keeps revoked token IDs and subjects. Entries expire once no token they apply to can still be valid.
+type Store interface {
+	// RevokeToken revokes the token with the "jti" until the time the token expires.
+	RevokeToken(tokenID string, until time.Time) error
+	// RevokeSubject revokes the subject's tokens issued before at. The entry is kept until the given time.
+	RevokeSubject(subject string, at time.Time, until time.Time) error
+	// TokenRevoked reports whether the token ID was revoked.
+	TokenRevoked(tokenID string) bool
+	// SubjectRevokedAt returns the time before which the subject's tokens are revoked, if any.
+	SubjectRevokedAt(subject string) (time.Time, bool)
+}
+
+// IsRevoked reports whether the token with the claims was revoked, by its "jti" or because its subject's tokens
+// issued before its "iat" were revoked. Tokens without "iat" are revoked along with their subject.
+func IsRevoked(store Store, claims jwt.MapClaims) bool {
+	if tokenID, _ := claims["jti"].(string); tokenID != "" && store.TokenRevoked(tokenID) {
+		return true
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return false
+	}
+	at, ok := store.SubjectRevokedAt(subject)
+	if !ok {
+		return false
+	}
+	issued, err := claims.GetIssuedAt()
+	return err != nil || issued == nil || issued.Before(at)
+}
+
+// entry is a revocation with its expiry.
+type entry struct {
+	at    time.Time // Subjects: tokens issued before this time are revoked
+	until time.Time
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	sync.Mutex
+	tokens   map[string]entry
+	subjects map[string]entry
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: make(map[string]entry), subjects: make(map[string]entry)}
+}
+
+// RevokeToken revokes the token with the "jti" until the time the token expires.
+func (m *MemoryStore) RevokeToken(tokenID string, until time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+	m.tokens[tokenID] = entry{until: until}
+	return nil
+}
+
+// RevokeSubject revokes the subject's tokens issued before at.
+func (m *MemoryStore) RevokeSubject(subject string, at time.Time, until time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+	m.subjects[subject] = entry{at: at, until: until}
+	return nil
+}
+
+// TokenRevoked reports whether the token ID was revoked.
+func (m *MemoryStore) TokenRevoked(tokenID string) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := lookup(m.tokens, tokenID)
+	return ok
+}
+
+// SubjectRevokedAt returns the time before which the subject's tokens are revoked, if any.
+func (m *MemoryStore) SubjectRevokedAt(subject string) (time.Time, bool) {
+	m.Lock()
+	defer m.Unlock()
+	e, ok := lookup(m.subjects, subject)
+	return e.at, ok
+}
+
+// lookup returns the unexpired entry, forgetting it if it expired. The caller must hold the lock.
+func lookup(entries map[string]entry, key string) (entry, bool) {
+	e, ok := entries[key]
+	if !ok {
+		return entry{}, false
+	}
+	if time.Now().After(e.until) {
+		delete(entries, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+// Redis key prefixes and the channel revocations are announced on.
+const (
+	redisTokenPrefix   = "wsgw:revoked:jti:"
+	redisSubjectPrefix = "wsgw:revoked:sub:"
+	redisChannel       = "wsgw:revocations"
+)
+
+// redisTimeout bounds each Redis call.
+const redisTimeout = time.Second
+
+// RedisStore is a Store backed by expiring Redis keys, shared by all nodes of a cluster. Revocations are also
+// announced on a pub/sub channel so nodes can check their connections at once.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a RedisStore using the given client.
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// RevokeToken revokes the token with the "jti" until the time the token expires.
+func (r *RedisStore) RevokeToken(tokenID string, until time.Time) error {
+	return r.set(redisTokenPrefix+tokenID, "1", until)
+}
+
+// RevokeSubject revokes the subject's tokens issued before at.
+func (r *RedisStore) RevokeSubject(subject string, at time.Time, until time.Time) error {
+	return r.set(redisSubjectPrefix+subject, strconv.FormatInt(at.Unix(), 10), until)
+}
+
+// set stores the key until the given time and announces the revocation.
+func (r *RedisStore) set(key string, value string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, redisChannel, key).Err()
+}
+
+// TokenRevoked reports whether the token ID was revoked. Lookup failures are logged and treated as not revoked.
+func (r *RedisStore) TokenRevoked(tokenID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	n, err := r.client.Exists(ctx, redisTokenPrefix+tokenID).Result()
+	if err != nil {
+		slog.Error("Failed to check token revocation", "error", err)
+		return false
+	}
+	return n > 0
+}
+
+// SubjectRevokedAt returns the time before which the subject's tokens are revoked, if any. Lookup failures are
+// logged and treated as not revoked.
+func (r *RedisStore) SubjectRevokedAt(subject string) (time.Time, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	value, err := r.client.Get(ctx, redisSubjectPrefix+subject).Int64()
+	if err == redis.Nil {
+		return time.Time{}, false
+	}
+	if err != nil {
+		slog.Error("Failed to check subject revocation", "error", err)
+		return time.Time{}, false
+	}
+	return time.Unix(value, 0), true
+}
+
+// Watch calls notify whenever any node announces a revocation, until the context is cancelled.
+func (r *RedisStore) Watch(ctx context.Context, notify func()) {
+	sub := r.client.Subscribe(ctx, redisChannel)
+	defer sub.Close()
+	messages := sub.Channel()
+	for {
+		select {
+		case _, ok := <-messages:
+			if !ok {
+				return
+			}
+			notify()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
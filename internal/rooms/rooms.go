@@ -0,0 +1,125 @@
+// Package rooms tracks memberships of connections in named rooms, ad-hoc groups that need no channel
+// declaration and disappear with their last member.
+package rooms
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrTooManyRooms is returned when a member would exceed the per-member room limit.
+var ErrTooManyRooms = errors.New("too many rooms")
+
+// Hub holds the members of every room, keyed by connection ID.
+type Hub struct {
+	sync.RWMutex
+	members  map[string]map[int]bool // Member IDs keyed by room
+	joined   map[int]map[string]bool // Rooms keyed by member ID
+	maxRooms int                     // Rooms a member may join, 0 for no limit
+}
+
+// NewHub creates an empty Hub.
+//
+// Params:
+// - maxRooms: The number of rooms a member may join at a time, or 0 for no limit.
+//
+// Returns:
+// - A pointer to the initialized Hub.
+func NewHub(maxRooms int) *Hub {
+	return &Hub{
+		members:  make(map[string]map[int]bool),
+		joined:   make(map[int]map[string]bool),
+		maxRooms: maxRooms,
+	}
+}
+
+// Join adds the member to the room. Joining a room twice is not an error.
+func (h *Hub) Join(room string, member int) error {
+	h.Lock()
+	defer h.Unlock()
+	rooms := h.joined[member]
+	if rooms[room] {
+		return nil
+	}
+	if h.maxRooms > 0 && len(rooms) >= h.maxRooms {
+		return ErrTooManyRooms
+	}
+	if rooms == nil {
+		rooms = make(map[string]bool)
+		h.joined[member] = rooms
+	}
+	rooms[room] = true
+	if h.members[room] == nil {
+		h.members[room] = make(map[int]bool)
+	}
+	h.members[room][member] = true
+	return nil
+}
+
+// Leave removes the member from the room and reports whether it was a member.
+func (h *Hub) Leave(room string, member int) bool {
+	h.Lock()
+	defer h.Unlock()
+	return h.leaveLocked(room, member)
+}
+
+// LeaveAll removes the member from all rooms, e.g. when its connection closes, and returns the rooms it left.
+func (h *Hub) LeaveAll(member int) []string {
+	h.Lock()
+	defer h.Unlock()
+	left := make([]string, 0, len(h.joined[member]))
+	for room := range h.joined[member] {
+		h.leaveLocked(room, member)
+		left = append(left, room)
+	}
+	sort.Strings(left)
+	return left
+}
+
+// leaveLocked removes the member from the room, dropping empty entries. The caller must hold the lock.
+func (h *Hub) leaveLocked(room string, member int) bool {
+	if !h.members[room][member] {
+		return false
+	}
+	delete(h.members[room], member)
+	if len(h.members[room]) == 0 {
+		delete(h.members, room)
+	}
+	delete(h.joined[member], room)
+	if len(h.joined[member]) == 0 {
+		delete(h.joined, member)
+	}
+	return true
+}
+
+// Members returns the IDs of the room's members.
+func (h *Hub) Members(room string) []int {
+	h.RLock()
+	defer h.RUnlock()
+	members := make([]int, 0, len(h.members[room]))
+	for member := range h.members[room] {
+		members = append(members, member)
+	}
+	sort.Ints(members)
+	return members
+}
+
+// Rooms returns the rooms the member has joined.
+func (h *Hub) Rooms(member int) []string {
+	h.RLock()
+	defer h.RUnlock()
+	rooms := make([]string, 0, len(h.joined[member]))
+	for room := range h.joined[member] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Size returns the number of members of the room.
+func (h *Hub) Size(room string) int {
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.members[room])
+}
@@ -0,0 +1,183 @@
+// Package roomstate provides server-authoritative state machines scoped to rooms, for turn-based games and
+// workflow UIs. Clients trigger transitions with events; accepted transitions are broadcast to the room.
+package roomstate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-websocket-boilerplate/internal/handler"
+	"sync"
+	"time"
+)
+
+// Publisher delivers updates to the subscribers of a channel.
+type Publisher interface {
+	Publish(channel string, updateType string, data any)
+}
+
+// Guard decides whether a client may trigger a transition. A returned error rejects the event.
+type Guard func(ctx context.Context, client handler.Client, room string, payload json.RawMessage) error
+
+// Transition declares that Event moves a room from any of the From states to the To state.
+type Transition struct {
+	Event string   // Event name sent by clients
+	From  []string // Source states; empty means any state
+	To    string   // Target state
+	Guard Guard    // Optional authorization or validation check
+}
+
+// Definition declares the states and transitions of a machine.
+type Definition struct {
+	Initial     string       // State of a newly created room
+	Transitions []Transition // Allowed transitions
+}
+
+// State is the current state of a room.
+type State struct {
+	Room      string `json:"room"`
+	State     string `json:"state"`
+	Version   uint64 `json:"version"`   // Incremented on every transition
+	UpdatedAt int64  `json:"updatedAt"` // Time of the last transition in Unix milliseconds
+}
+
+// TransitionEvent is broadcast to the room when a transition was accepted.
+type TransitionEvent struct {
+	Room    string          `json:"room"`
+	Event   string          `json:"event"`
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Version uint64          `json:"version"`
+	By      int             `json:"by"` // Connection ID of the client that triggered the event
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// EventRequest triggers an event in a room.
+//
+// When ExpectedVersion is set the event is only applied if the room is still at that version; otherwise a
+// conflict error carrying the latest state is returned.
+type EventRequest struct {
+	Room            string          `json:"room" validate:"required"`
+	Event           string          `json:"event" validate:"required"`
+	ExpectedVersion *uint64         `json:"expectedVersion,omitempty"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+}
+
+// StateRequest queries the state of a room.
+type StateRequest struct {
+	Room string `json:"room" validate:"required"`
+}
+
+// Machine runs a Definition for any number of rooms.
+type Machine struct {
+	sync.Mutex
+	name      string            // Channel the machine's messages are exchanged on
+	def       Definition        // States and transitions
+	publisher Publisher         // Broadcasts transitions to "<name>.<room>"
+	rooms     map[string]*State // Current state keyed by room
+}
+
+// NewMachine creates a machine.
+//
+// Params:
+// - name: The channel clients send events to; transitions are broadcast on "<name>.<room>".
+// - def: The states and transitions.
+// - publisher: Broadcasts transitions, e.g. the gateway.
+func NewMachine(name string, def Definition, publisher Publisher) *Machine {
+	return &Machine{name: name, def: def, publisher: publisher, rooms: make(map[string]*State)}
+}
+
+// RoomChannel returns the channel transitions of the room are broadcast on.
+func (m *Machine) RoomChannel(room string) string {
+	return m.name + "." + room
+}
+
+// State returns a copy of the room's state, creating the room in the initial state if needed.
+func (m *Machine) State(room string) State {
+	m.Lock()
+	defer m.Unlock()
+	return *m.stateLocked(room)
+}
+
+// stateLocked returns the room's state. The caller must hold the lock.
+func (m *Machine) stateLocked(room string) *State {
+	s, ok := m.rooms[room]
+	if !ok {
+		s = &State{Room: room, State: m.def.Initial, UpdatedAt: time.Now().UnixMilli()}
+		m.rooms[room] = s
+	}
+	return s
+}
+
+// find returns the transition for the event from the given state.
+func (m *Machine) find(event string, from string) (*Transition, bool) {
+	for i := range m.def.Transitions {
+		t := &m.def.Transitions[i]
+		if t.Event != event {
+			continue
+		}
+		if len(t.From) == 0 {
+			return t, true
+		}
+		for _, state := range t.From {
+			if state == from {
+				return t, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Fire applies the event to the room and broadcasts the transition.
+//
+// Params:
+// - expectedVersion: If not nil, the version the client based the event on.
+//
+// Returns:
+// - The transition, or a conflict error if the event is not allowed in the current state or the version differs.
+func (m *Machine) Fire(ctx context.Context, client handler.Client, room string, event string, expectedVersion *uint64, payload json.RawMessage) (*TransitionEvent, error) {
+	m.Lock()
+	s := m.stateLocked(room)
+	current, version, latest := s.State, s.Version, *s
+	t, ok := m.find(event, current)
+	m.Unlock()
+	if expectedVersion != nil && *expectedVersion != version {
+		return nil, handler.NewConflict(fmt.Sprintf("expected version %d, room is at %d", *expectedVersion, version), &latest)
+	}
+	if !ok {
+		return nil, handler.NewConflict(fmt.Sprintf("event %q not allowed in state %q", event, current), &latest)
+	}
+	if t.Guard != nil {
+		if err := t.Guard(ctx, client, room, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	m.Lock()
+	s = m.stateLocked(room)
+	if s.Version != version {
+		// Another event won the race while the guard ran.
+		latest = *s
+		m.Unlock()
+		return nil, handler.NewConflict(fmt.Sprintf("room changed to version %d", latest.Version), &latest)
+	}
+	s.State = t.To
+	s.Version++
+	s.UpdatedAt = time.Now().UnixMilli()
+	transition := &TransitionEvent{Room: room, Event: event, From: current, To: t.To, Version: s.Version, By: client.ID(), Payload: payload}
+	m.Unlock()
+
+	m.publisher.Publish(m.RoomChannel(room), "transition", transition)
+	return transition, nil
+}
+
+// Register registers the machine's handlers: "event" triggers a transition and "state" queries a room.
+func (m *Machine) Register() {
+	handler.RegisterHandler(m.name, "event", func(ctx context.Context, client handler.Client, req EventRequest) (*TransitionEvent, error) {
+		return m.Fire(ctx, client, req.Room, req.Event, req.ExpectedVersion, req.Payload)
+	})
+	handler.RegisterHandler(m.name, "state", func(_ context.Context, _ handler.Client, req StateRequest) (*State, error) {
+		s := m.State(req.Room)
+		return &s, nil
+	})
+}
@@ -0,0 +1,316 @@
+// Browser client for the gateway protocol: {id, type, ch, data} frames over a single WebSocket.
+//
+// Handles authentication, liveness probing, reconnects with session resume and re-subscription, request/response
+// correlation and subscription callbacks. Load it with <script src="/sdk/wsgw.js"></script> or as a module.
+//
+//   const gw = new WsgwClient("wss://example.com/ws", { token: () => fetchToken() });
+//   gw.subscribe("prices.BTC", (update) => console.log(update.type, update.data));
+//   const reply = await gw.request("greeting", "", { name: "Ada" });
+//   gw.onCall("confirm", async (frame) => window.confirm(frame.data.question));
+(function (root, factory) {
+  if (typeof module === "object" && module.exports) {
+    module.exports = factory();
+  } else {
+    root.WsgwClient = factory();
+  }
+})(typeof self !== "undefined" ? self : this, function () {
+  "use strict";
+
+  const defaults = {
+    token: null,              // String or (async) function returning a JWT, sent with sys/auth
+    requestTimeoutMs: 10000,  // Rejects requests without a response after this time
+    reconnectMinMs: 500,      // First reconnect delay, doubled on each failure
+    reconnectMaxMs: 30000,    // Largest reconnect delay
+    probeAfterMs: 0,          // Probe the connection when idle this long; 0 uses 3x the server heartbeat
+    onEvent: null,            // Called with (name, detail) for "open", "close", "welcome", "session" and "error"
+  };
+
+  const maxSeen = 1000; // Message IDs remembered for duplicate detection
+  const ackEvery = 100; // Frames received between sys/ack messages releasing the server's resume buffer
+
+  class WsgwError extends Error {
+    constructor(body) {
+      super(body.message || body.code || "request failed");
+      this.code = body.code;
+      this.details = body.details;
+      this.latest = body.latest;
+      this.requestId = body.requestId;
+    }
+  }
+
+  class WsgwClient {
+    constructor(url, options) {
+      this.url = url;
+      this.options = Object.assign({}, defaults, options);
+      this.nextId = 1;
+      this.pending = new Map();       // Outstanding requests by ID
+      this.seen = new Set();          // Recent message IDs, to drop duplicated and replayed frames
+      this.subscriptions = new Map(); // Channel -> {options, callbacks}
+      this.callHandlers = new Map();  // Server call type -> handler
+      this.resumeToken = null;
+      this.lastSeq = 0;               // Sequence number of the last frame received, presented on resume
+      this.ackedSeq = 0;
+      this.welcome = null;
+      this.ws = null;
+      this.closed = false;
+      this.delay = this.options.reconnectMinMs;
+      this.lastFrame = 0;
+      this.probeTimer = null;
+      this.connect();
+    }
+
+    // connect opens the WebSocket, resuming the previous session when possible.
+    connect() {
+      const url = this.resumeToken
+        ? this.url + (this.url.includes("?") ? "&" : "?") + "resume=" + encodeURIComponent(this.resumeToken) +
+          "&lastSeq=" + this.lastSeq
+        : this.url;
+      const ws = new WebSocket(url);
+      this.ws = ws;
+      ws.onopen = () => {
+        this.delay = this.options.reconnectMinMs;
+        this.lastFrame = Date.now();
+        this.emit("open", { url: this.url });
+        this.authenticate().then(() => this.resubscribe()).catch((err) => this.emit("error", err));
+        this.startProbe();
+      };
+      ws.onmessage = (event) => this.onFrame(event.data);
+      ws.onclose = (event) => {
+        this.stopProbe();
+        for (const [id, request] of this.pending) {
+          request.reject(new Error("connection closed"));
+          this.pending.delete(id);
+        }
+        this.emit("close", { code: event.code, reason: event.reason });
+        if (!this.closed) {
+          setTimeout(() => this.connect(), this.delay);
+          this.delay = Math.min(this.delay * 2, this.options.reconnectMaxMs);
+        }
+      };
+    }
+
+    // authenticate sends the configured token. Resumed sessions are authenticated already but refreshing is harmless.
+    async authenticate() {
+      const token = typeof this.options.token === "function" ? await this.options.token() : this.options.token;
+      if (token) {
+        this.send("auth", "sys", { authToken: token });
+      }
+    }
+
+    // resubscribe restores all subscriptions after a reconnect.
+    resubscribe() {
+      for (const [channel, subscription] of this.subscriptions) {
+        this.request("subscribe", "sys", Object.assign({ ch: channel }, subscription.options))
+          .catch((err) => this.emit("error", err));
+      }
+    }
+
+    // onFrame dispatches a received frame to the pending request or the channel's subscribers.
+    onFrame(raw) {
+      this.lastFrame = Date.now();
+      let frame;
+      try {
+        frame = JSON.parse(raw);
+      } catch (err) {
+        this.emit("error", err);
+        return;
+      }
+      if (frame.seq > this.lastSeq) {
+        this.lastSeq = frame.seq;
+        if (this.lastSeq - this.ackedSeq >= ackEvery) {
+          this.ackedSeq = this.lastSeq;
+          this.send("ack", "sys", { seq: this.lastSeq });
+        }
+      }
+      if (frame.msgId) {
+        if (this.seen.has(frame.msgId)) {
+          return;
+        }
+        this.seen.add(frame.msgId);
+        if (this.seen.size > maxSeen) {
+          this.seen.delete(this.seen.values().next().value);
+        }
+      }
+      if (frame.callId) {
+        this.answer(frame);
+        return;
+      }
+      if (frame.id && this.pending.has(frame.id)) {
+        const request = this.pending.get(frame.id);
+        this.pending.delete(frame.id);
+        clearTimeout(request.timer);
+        if (frame.data && frame.data.error) {
+          request.reject(new WsgwError(frame.data.error));
+        } else {
+          request.resolve(frame.data);
+        }
+        return;
+      }
+      if (frame.ch === "sys") {
+        if (frame.type === "welcome") {
+          this.welcome = frame.data;
+          this.startProbe();
+        } else if (frame.type === "session") {
+          if (frame.data.resumeToken !== this.resumeToken) {
+            this.lastSeq = this.ackedSeq = frame.seq || 0; // New session, sequence numbers restarted
+          }
+          this.resumeToken = frame.data.resumeToken;
+        }
+        this.emit(frame.type, frame.data);
+      }
+      for (const [channel, subscription] of this.subscriptions) {
+        if (matches(channel, frame.ch)) {
+          subscription.callbacks.forEach((callback) => callback(frame));
+        }
+      }
+      if (frame.ch && frame.ch !== "sys") {
+        this.grantCredit(frame.ch);
+      }
+    }
+
+    // answer runs the handler of a server call and replies with its result or error.
+    answer(frame) {
+      const handler = this.callHandlers.get(frame.type);
+      const reply = (body) => {
+        try {
+          this.ws.send(JSON.stringify(Object.assign({ replyTo: frame.callId }, body)));
+        } catch (err) {
+          this.emit("error", err);
+        }
+      };
+      if (!handler) {
+        reply({ error: "no handler for " + frame.type });
+        return;
+      }
+      Promise.resolve()
+        .then(() => handler(frame))
+        .then((data) => reply({ data: data }), (err) => reply({ error: String(err && err.message || err) }));
+    }
+
+    // onCall sets the handler answering server calls of the type. The handler receives the call frame and returns
+    // the reply data, or a promise of it; a thrown error is sent back as the call's error.
+    onCall(type, handler) {
+      this.callHandlers.set(type, handler);
+    }
+
+    // grantCredit returns one credit per received update of a paced subscription.
+    grantCredit(channel) {
+      for (const [subscribed, subscription] of this.subscriptions) {
+        if (subscription.options.window && matches(subscribed, channel)) {
+          subscription.received = (subscription.received || 0) + 1;
+          if (subscription.received >= Math.ceil(subscription.options.window / 2)) {
+            this.send("credit", "sys", { ch: subscribed, credits: subscription.received });
+            subscription.received = 0;
+          }
+        }
+      }
+    }
+
+    // startProbe checks liveness when no frame arrived for a while; browsers hide the server's ping frames.
+    startProbe() {
+      this.stopProbe();
+      const heartbeat = this.welcome && this.welcome.limits ? this.welcome.limits.heartbeatMs : 10000;
+      const idle = this.options.probeAfterMs || 3 * heartbeat;
+      this.probeTimer = setInterval(() => {
+        if (Date.now() - this.lastFrame < idle) {
+          return;
+        }
+        this.request("capabilities", "sys", {}).catch(() => this.ws && this.ws.close(4000, "probe failed"));
+      }, idle);
+    }
+
+    stopProbe() {
+      clearInterval(this.probeTimer);
+      this.probeTimer = null;
+    }
+
+    // send writes a frame without waiting for a response.
+    send(type, ch, data, id) {
+      if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+        throw new Error("not connected");
+      }
+      const frame = { type: type, ch: ch, data: data };
+      if (id) {
+        frame.id = id;
+      }
+      this.ws.send(JSON.stringify(frame));
+    }
+
+    // request sends a frame and resolves with the data of its response, or rejects with a WsgwError.
+    request(type, ch, data) {
+      return new Promise((resolve, reject) => {
+        const id = String(this.nextId++);
+        const timer = setTimeout(() => {
+          this.pending.delete(id);
+          reject(new Error("request timed out"));
+        }, this.options.requestTimeoutMs);
+        this.pending.set(id, { resolve: resolve, reject: reject, timer: timer });
+        try {
+          this.send(type, ch, data, id);
+        } catch (err) {
+          clearTimeout(timer);
+          this.pending.delete(id);
+          reject(err);
+        }
+      });
+    }
+
+    // subscribe calls the callback with every frame of the channel or pattern. Options are passed to sys/subscribe,
+    // e.g. {noEcho: true}, {resolutionMs: 1000}, {window: 20} or {channelToken: token}. Returns a function that
+    // removes the callback.
+    subscribe(channel, callback, options) {
+      let subscription = this.subscriptions.get(channel);
+      if (!subscription) {
+        subscription = { options: options || {}, callbacks: new Set() };
+        this.subscriptions.set(channel, subscription);
+        if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+          this.request("subscribe", "sys", Object.assign({ ch: channel }, subscription.options))
+            .catch((err) => this.emit("error", err));
+        }
+      }
+      subscription.callbacks.add(callback);
+      return () => {
+        subscription.callbacks.delete(callback);
+        if (subscription.callbacks.size === 0) {
+          this.subscriptions.delete(channel);
+          if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+            this.request("unsubscribe", "sys", { ch: channel }).catch((err) => this.emit("error", err));
+          }
+        }
+      };
+    }
+
+    // publish sends an update to the subscribers of the channel through its handler.
+    publish(channel, type, data) {
+      return this.request(type, channel, data);
+    }
+
+    // close closes the connection without reconnecting.
+    close() {
+      this.closed = true;
+      this.stopProbe();
+      if (this.ws) {
+        this.ws.close(1000, "client closed");
+      }
+    }
+
+    emit(name, detail) {
+      if (this.options.onEvent) {
+        this.options.onEvent(name, detail);
+      }
+    }
+  }
+
+  // matches reports whether a channel matches a subscription, where "*" segments match any single segment.
+  function matches(pattern, channel) {
+    if (pattern === channel) {
+      return true;
+    }
+    const p = pattern.split(".");
+    const c = (channel || "").split(".");
+    return p.length === c.length && p.every((segment, i) => segment === "*" || segment === c[i]);
+  }
+
+  WsgwClient.WsgwError = WsgwError;
+  return WsgwClient;
+});
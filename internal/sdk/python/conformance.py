@@ -0,0 +1,97 @@
+"""Protocol conformance checks for the Python client, run against a live gateway.
+
+The checks mirror those of cmd/contract so both clients are held to the same wire protocol:
+
+    python conformance.py --url ws://localhost:3000/ws --token "$WSGW_CONTRACT_TOKEN"
+
+Exits non-zero if any check fails.
+"""
+
+import argparse
+import asyncio
+import sys
+
+import websockets
+
+from wsgw import WsgwClient, WsgwError, matches
+
+
+async def run(url, token, timeout):
+    results = []
+
+    def check(name, ok, problem=""):
+        results.append((name, ok, problem))
+
+    check("pattern matching", matches("a.*", "a.b") and not matches("a.*", "a.b.c") and matches("a", "a"))
+
+    async with WsgwClient(url, request_timeout=timeout) as gw:
+        await asyncio.sleep(timeout / 10)
+        check("welcome", isinstance(gw.welcome, dict), "no sys/welcome update")
+        check("session", bool(gw.resume_token), "no resume token in sys/session")
+
+        caps = await gw.request("capabilities", "sys", {})
+        check("capabilities", caps is not None, "empty response")
+
+        try:
+            unknown = await gw.request("contract-unknown", "sys", {})
+            check("unknown sys message", False, "expected an error frame, got %r" % (unknown,))
+        except WsgwError as err:
+            check("unknown sys message", err.code == "unknown_message", "got code %r" % err.code)
+
+        if token:
+            await gw.send("auth", "sys", {"authToken": token})
+            connections = await gw.request("hello", "sys", {"installationId": "conformance", "tabId": "py"})
+            check("auth flow", isinstance(connections, list), "unexpected hello response %r" % (connections,))
+            try:
+                await gw.request("greeting", "greeting", {})
+                check("validation error greeting/greeting", False, "expected an error frame")
+            except WsgwError as err:
+                check("validation error greeting/greeting", err.code == "validation_failed", "got code %r" % err.code)
+        else:
+            results.append(("auth flow", None, "no token"))
+
+        resume_token = gw.resume_token
+        await gw._ws.close()
+        await asyncio.wait_for(gw._connected.wait(), timeout)
+        await gw.request("capabilities", "sys", {})
+        check("reconnect", gw._connected.is_set(), "did not reconnect")
+        check("resume", resume_token is not None, "no session to resume")
+
+    try:
+        async with websockets.connect(url) as ws:
+            await ws.send('{"type":"auth","ch":"sys","data":{"authToken":"not-a-jwt"}}')
+            closed = False
+            try:
+                while True:
+                    await asyncio.wait_for(ws.recv(), timeout)
+            except websockets.ConnectionClosed:
+                closed = True
+            except asyncio.TimeoutError:
+                pass
+            check("invalid auth closes connection", closed, "connection stayed open after an invalid token")
+    except OSError as err:
+        check("invalid auth closes connection", False, str(err))
+    return results
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__.splitlines()[0])
+    parser.add_argument("--url", default="ws://localhost:3000/ws", help="WebSocket URL of the gateway")
+    parser.add_argument("--token", default="", help="valid JWT for the auth flow checks")
+    parser.add_argument("--timeout", type=float, default=5.0, help="seconds to wait for each expected frame")
+    args = parser.parse_args()
+
+    failed = False
+    for name, ok, problem in asyncio.run(run(args.url, args.token, args.timeout)):
+        if ok is None:
+            print("SKIP %s (%s)" % (name, problem))
+        elif ok:
+            print("PASS %s" % name)
+        else:
+            failed = True
+            print("FAIL %s\n     %s" % (name, problem))
+    sys.exit(1 if failed else 0)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,247 @@
+"""Asyncio client for the gateway protocol: {id, type, ch, data} frames over a single WebSocket.
+
+Handles authentication, reconnects with session resume and re-subscription, request/response correlation and
+subscription callbacks. Requires the `websockets` package.
+
+    async with WsgwClient("ws://localhost:3000/ws", token=os.environ["WSGW_TOKEN"]) as gw:
+        gw.subscribe("prices.BTC", lambda frame: print(frame["type"], frame["data"]))
+        reply = await gw.request("greeting", "", {"name": "Ada"})
+"""
+
+import asyncio
+import collections
+import inspect
+import itertools
+import json
+import logging
+from urllib.parse import quote
+
+import websockets
+
+log = logging.getLogger("wsgw")
+
+MAX_SEEN = 1000  # Message IDs remembered for duplicate detection
+
+
+class WsgwError(Exception):
+    """Error frame returned by a handler, carrying its code, details and latest state."""
+
+    def __init__(self, body):
+        super().__init__(body.get("message") or body.get("code") or "request failed")
+        self.code = body.get("code")
+        self.details = body.get("details")
+        self.latest = body.get("latest")
+        self.request_id = body.get("requestId")
+
+
+class WsgwClient:
+    """Client connection that reconnects until closed.
+
+    Params:
+    - url: The WebSocket URL of the gateway, e.g. ws://localhost:3000/ws.
+    - token: A JWT, or a (coroutine) function returning one, sent with sys/auth after each connect.
+    - request_timeout: Seconds to wait for a response.
+    - reconnect_min, reconnect_max: Bounds of the exponential reconnect delay in seconds.
+    """
+
+    def __init__(self, url, token=None, request_timeout=10.0, reconnect_min=0.5, reconnect_max=30.0):
+        self.url = url
+        self.token = token
+        self.request_timeout = request_timeout
+        self.reconnect_min = reconnect_min
+        self.reconnect_max = reconnect_max
+        self.resume_token = None
+        self.welcome = None
+        self._ids = itertools.count(1)
+        self._pending = {}        # Outstanding requests by ID
+        self._seen = collections.OrderedDict()  # Recent message IDs, to drop duplicated and replayed frames
+        self._subscriptions = {}  # Channel -> (options, callbacks)
+        self._call_handlers = {}  # Server call type -> handler
+        self._ws = None
+        self._connected = asyncio.Event()
+        self._closed = False
+        self._task = None
+
+    async def __aenter__(self):
+        await self.connect()
+        return self
+
+    async def __aexit__(self, *exc):
+        await self.close()
+
+    async def connect(self):
+        """Starts the connection loop and waits for the first connection."""
+        self._task = asyncio.create_task(self._run())
+        await self._connected.wait()
+
+    async def close(self):
+        """Closes the connection without reconnecting."""
+        self._closed = True
+        if self._ws is not None:
+            await self._ws.close(1000, "client closed")
+        if self._task is not None:
+            await self._task
+
+    async def _run(self):
+        delay = self.reconnect_min
+        while not self._closed:
+            url = self.url
+            if self.resume_token:
+                url += ("&" if "?" in url else "?") + "resume=" + quote(self.resume_token)
+            try:
+                async with websockets.connect(url) as ws:
+                    self._ws = ws
+                    delay = self.reconnect_min
+                    reader = asyncio.create_task(self._read(ws))
+                    await self._authenticate()
+                    self._connected.set()
+                    await self._resubscribe()
+                    await reader
+            except (OSError, websockets.WebSocketException) as err:
+                log.warning("connection failed: %s", err)
+            finally:
+                self._ws = None
+                self._connected.clear()
+                for future in self._pending.values():
+                    if not future.done():
+                        future.set_exception(ConnectionError("connection closed"))
+                self._pending.clear()
+            if not self._closed:
+                await asyncio.sleep(delay)
+                delay = min(delay * 2, self.reconnect_max)
+
+    async def _authenticate(self):
+        token = self.token() if callable(self.token) else self.token
+        if inspect.isawaitable(token):
+            token = await token
+        if token:
+            await self.send("auth", "sys", {"authToken": token})
+
+    async def _resubscribe(self):
+        for channel, (options, _) in self._subscriptions.items():
+            try:
+                await self.request("subscribe", "sys", dict(options, ch=channel))
+            except (WsgwError, ConnectionError, asyncio.TimeoutError) as err:
+                log.warning("resubscribe %s failed: %s", channel, err)
+
+    async def _read(self, ws):
+        try:
+            async for raw in ws:
+                self._on_frame(raw)
+        except websockets.ConnectionClosed:
+            pass
+
+    def _on_frame(self, raw):
+        try:
+            frame = json.loads(raw)
+        except ValueError:
+            log.warning("invalid frame: %r", raw)
+            return
+        if not isinstance(frame, dict):
+            log.warning("invalid frame: %r", raw)
+            return
+        msg_id = frame.get("msgId")
+        if msg_id:
+            if msg_id in self._seen:
+                return
+            self._seen[msg_id] = True
+            if len(self._seen) > MAX_SEEN:
+                self._seen.popitem(last=False)
+        if frame.get("callId"):
+            asyncio.ensure_future(self._answer(frame))
+            return
+        future = self._pending.pop(frame.get("id"), None) if frame.get("id") else None
+        if future is not None:
+            data = frame.get("data")
+            if isinstance(data, dict) and isinstance(data.get("error"), dict):
+                future.set_exception(WsgwError(data["error"]))
+            else:
+                future.set_result(data)
+            return
+        if frame.get("ch") == "sys":
+            if frame.get("type") == "welcome":
+                self.welcome = frame.get("data")
+            elif frame.get("type") == "session":
+                self.resume_token = (frame.get("data") or {}).get("resumeToken")
+        for channel, (options, callbacks) in list(self._subscriptions.items()):
+            if matches(channel, frame.get("ch")):
+                for callback in list(callbacks):
+                    callback(frame)
+
+    async def _answer(self, frame):
+        handler = self._call_handlers.get(frame.get("type"))
+        reply = {"replyTo": frame["callId"]}
+        if handler is None:
+            reply["error"] = "no handler for " + str(frame.get("type"))
+        else:
+            try:
+                result = handler(frame)
+                if asyncio.iscoroutine(result):
+                    result = await result
+                reply["data"] = result
+            except Exception as e:
+                reply["error"] = str(e)
+        if self._ws is not None:
+            await self._ws.send(json.dumps(reply))
+
+    def on_call(self, call_type, handler):
+        """Sets the (coroutine) function answering server calls of the type.
+
+        The handler receives the call frame and returns the reply data; a raised exception is sent back as the
+        call's error.
+        """
+        self._call_handlers[call_type] = handler
+
+    async def send(self, msg_type, channel, data, msg_id=None):
+        """Writes a frame without waiting for a response."""
+        if self._ws is None:
+            raise ConnectionError("not connected")
+        frame = {"type": msg_type, "ch": channel, "data": data}
+        if msg_id:
+            frame["id"] = msg_id
+        await self._ws.send(json.dumps(frame))
+
+    async def request(self, msg_type, channel, data):
+        """Sends a frame and returns the data of its response; raises WsgwError for error frames."""
+        msg_id = "py-" + str(next(self._ids))
+        future = asyncio.get_running_loop().create_future()
+        self._pending[msg_id] = future
+        try:
+            await self.send(msg_type, channel, data, msg_id)
+            return await asyncio.wait_for(future, self.request_timeout)
+        finally:
+            self._pending.pop(msg_id, None)
+
+    def subscribe(self, channel, callback, **options):
+        """Calls the callback with every frame of the channel or pattern.
+
+        Options are passed to sys/subscribe, e.g. noEcho=True or resolutionMs=1000. Returns a coroutine function
+        that removes the callback and unsubscribes once no callbacks remain.
+        """
+        entry = self._subscriptions.get(channel)
+        if entry is None:
+            entry = (options, [])
+            self._subscriptions[channel] = entry
+            if self._ws is not None:
+                asyncio.ensure_future(self.request("subscribe", "sys", dict(options, ch=channel)))
+        entry[1].append(callback)
+
+        async def unsubscribe():
+            entry[1].remove(callback)
+            if not entry[1] and self._subscriptions.pop(channel, None) and self._ws is not None:
+                await self.request("unsubscribe", "sys", {"ch": channel})
+
+        return unsubscribe
+
+    async def publish(self, channel, msg_type, data):
+        """Sends an update to the channel's handler and returns its response."""
+        return await self.request(msg_type, channel, data)
+
+
+def matches(pattern, channel):
+    """Reports whether a channel matches a subscription, where "*" segments match any single segment."""
+    if pattern == channel:
+        return True
+    p = pattern.split(".")
+    c = (channel or "").split(".")
+    return len(p) == len(c) and all(s == "*" or s == c[i] for i, s in enumerate(p))
@@ -0,0 +1,22 @@
+// Package sdk serves the embedded client SDKs so applications can load them straight from the gateway.
+//
+// The asyncio Python client in python/ is distributed as a plain module together with its conformance checks.
+package sdk
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed js
+var files embed.FS
+
+// Handler serves the SDK files, e.g. "wsgw.js" for browsers.
+func Handler() http.Handler {
+	root, err := fs.Sub(files, "js")
+	if err != nil {
+		panic(err) // The embedded directory is part of the binary
+	}
+	return http.FileServer(http.FS(root))
+}
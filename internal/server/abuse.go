@@ -0,0 +1,118 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/abuse"
+	"go-websocket-boilerplate/internal/handler"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ChallengeNotice is sent on the challenge channel when the abuse detector asks the client to prove it is human.
+type ChallengeNotice struct {
+	Reason string `json:"reason"`
+}
+
+// ThrottleNotice is sent on the sys channel when the client's messages are being dropped.
+type ThrottleNotice struct {
+	Reason string `json:"reason"`
+	Until  int64  `json:"until"` // End of the throttle in Unix timestamp
+}
+
+// remoteIP returns the IP address of the request's peer.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isBanned reports whether the subject or IP address is currently banned.
+func (m *ConnectionManager) isBanned(subject string, ip string) bool {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	keys := []string{"ip:" + ip}
+	if subject != "" {
+		keys = append(keys, "sub:"+subject)
+	}
+	for _, key := range keys {
+		until, ok := m.bans[key]
+		if !ok {
+			continue
+		}
+		if now.After(until) {
+			delete(m.bans, key)
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// ban rejects the subject and IP address until the given time.
+func (m *ConnectionManager) ban(subject string, ip string, until time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	if subject != "" {
+		m.bans["sub:"+subject] = until
+	}
+	if ip != "" {
+		m.bans["ip:"+ip] = until
+	}
+}
+
+// observe reports an event of the client to the abuse detector and applies its verdict.
+func (c *WsClient) observe(kind abuse.EventKind, channel string, msgType string, size int) {
+	detector := c.manager.abuseDetector
+	if detector == nil {
+		return
+	}
+	verdict := detector.Observe(abuse.Event{
+		Kind:     kind,
+		ClientID: c.id,
+		Subject:  subjectOf(c.Claims()),
+		IP:       c.ip,
+		Device:   string(c.device),
+		Channel:  channel,
+		Type:     msgType,
+		Size:     size,
+		Time:     time.Now(),
+	})
+	c.enforce(verdict)
+}
+
+// enforce applies an abuse verdict to the client.
+func (c *WsClient) enforce(verdict abuse.Verdict) {
+	switch verdict.Action {
+	case abuse.Throttle:
+		until := time.Now().Add(verdict.Duration)
+		c.throttledUntil.Store(until.UnixNano())
+		c.logger.Warn("Client throttled", "reason", verdict.Reason, "score", verdict.Score, "until", until.Format(time.RFC3339))
+		go c.SendUpdate("throttled", "sys", &ThrottleNotice{Reason: verdict.Reason, Until: until.Unix()})
+	case abuse.Challenge:
+		c.logger.Warn("Client challenged", "reason", verdict.Reason, "score", verdict.Score)
+		go c.SendUpdate("challenge", c.manager.challengeChannel, &ChallengeNotice{Reason: verdict.Reason})
+	case abuse.Ban:
+		until := time.Now().Add(verdict.Duration)
+		c.logger.Warn("Client banned", "reason", verdict.Reason, "score", verdict.Score, "until", until.Format(time.RFC3339))
+		go c.manager.Ban(subjectOf(c.Claims()), c.ip, verdict.Duration, verdict.Reason)
+	}
+}
+
+// throttled reports whether the client's messages are currently dropped.
+func (c *WsClient) throttled() bool {
+	return time.Now().UnixNano() < c.throttledUntil.Load()
+}
+
+// observeResponse reports handler validation failures to the abuse detector.
+func (c *WsClient) observeResponse(channel string, reqType string, data any) {
+	frame, ok := data.(*handler.ErrorFrame)
+	if !ok {
+		return
+	}
+	if frame.Error.Code == handler.CodeValidationFailed || frame.Error.Code == handler.CodeInvalidRequest {
+		c.observe(abuse.ValidationError, channel, reqType, 0)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultAcceptWait is the time a handshake waits in the accept queue when Config.AcceptWait is not set. Waits
+// must end within the write timeout, which also bounds the handshake response.
+const defaultAcceptWait = 500 * time.Millisecond
+
+// newConnectionSlots creates the semaphore bounding the concurrent connections, or returns nil if unlimited.
+func newConnectionSlots(config Config) chan struct{} {
+	if config.MaxConnections <= 0 {
+		return nil
+	}
+	return make(chan struct{}, config.MaxConnections)
+}
+
+// acceptWait returns the time a queued handshake waits for a connection slot.
+func (m *ConnectionManager) acceptWait() time.Duration {
+	if m.config.AcceptWait > 0 {
+		return m.config.AcceptWait
+	}
+	return defaultAcceptWait
+}
+
+// acquireSlot reserves a connection slot. When all slots are taken the handshake waits in the accept queue for
+// one to free up, unless the queue is full.
+//
+// Returns:
+// - false if the connection limit is reached and no slot freed up in time.
+func (m *ConnectionManager) acquireSlot(ctx context.Context) bool {
+	if m.slots == nil {
+		return true
+	}
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if m.acceptWaiting.Add(1) > int64(m.config.AcceptQueue) {
+		m.acceptWaiting.Add(-1)
+		return false
+	}
+	defer m.acceptWaiting.Add(-1)
+	timer := time.NewTimer(m.acceptWait())
+	defer timer.Stop()
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseSlot frees a slot reserved by acquireSlot.
+func (m *ConnectionManager) releaseSlot() {
+	if m.slots != nil {
+		<-m.slots
+	}
+}
+
+// rejectOverCapacity answers a handshake refused by the connection limit, asking the client to retry after the
+// accept queue wait.
+func (m *ConnectionManager) rejectOverCapacity(w http.ResponseWriter) {
+	m.connectionsRejected.Add(1)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(m.acceptWait().Seconds()))))
+	http.Error(w, "Too many connections.", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,31 @@
+package server
+
+// ChannelHooks are callbacks invoked when a channel gains its first or loses its last subscriber.
+//
+// They let expensive upstream subscriptions, e.g. to an exchange feed, run only while someone is listening.
+// Hooks apply to exact channel subscriptions; pattern subscriptions do not activate a channel.
+type ChannelHooks struct {
+	OnFirstSubscriber  func(channel string) // Called after the first client subscribed
+	OnLastUnsubscriber func(channel string) // Called after the last client unsubscribed or disconnected
+}
+
+// SetChannelHooks registers activation hooks for a channel.
+//
+// Params:
+// - channel: The exact channel name.
+// - hooks: The callbacks; either may be nil.
+func (m *ConnectionManager) SetChannelHooks(channel string, hooks ChannelHooks) {
+	m.Lock()
+	defer m.Unlock()
+	m.activation[channel] = hooks
+}
+
+// deactivate runs the channel's OnLastUnsubscriber hook, if any.
+func (m *ConnectionManager) deactivate(channel string) {
+	m.RLock()
+	hooks := m.activation[channel]
+	m.RUnlock()
+	if hooks.OnLastUnsubscriber != nil {
+		hooks.OnLastUnsubscriber(channel)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/channels"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// authorizeAdmin checks that the request carries a bearer token with the admin scope and writes an error
+// response if it does not.
+//
+// Returns:
+// - true if the request may proceed.
+func (m *ConnectionManager) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	claims, err := m.validateToken(m.authenticator, token)
+	if err != nil {
+		slog.Info("Admin request unauthorized", "path", r.URL.Path, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	if !channels.HasScope(claims, adminScope) {
+		slog.Info("Admin request forbidden", "path", r.URL.Path, "sub", subjectOf(claims))
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
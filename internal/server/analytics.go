@@ -0,0 +1,63 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/analytics"
+	"time"
+)
+
+// track sends a usage event of the client to the analytics sink, if one is configured.
+func (m *ConnectionManager) track(client *WsClient, name string, properties map[string]any) {
+	if m.analytics == nil {
+		return
+	}
+	m.analytics.Track(analytics.Event{
+		Name:         name,
+		Time:         time.Now(),
+		ConnectionID: client.ID(),
+		Subject:      subjectOf(client.Claims()),
+		Properties:   properties,
+	})
+}
+
+// trackConnect reports a new connection.
+func (m *ConnectionManager) trackConnect(client *WsClient) {
+	m.track(client, analytics.Connect, map[string]any{
+		"device":        client.Device(),
+		"country":       client.Country(),
+		"authenticated": client.isAuthenticated(),
+		"experiments":   client.experiments,
+	})
+}
+
+// trackDisconnect reports a closed connection with its duration and message counts per channel.
+func (m *ConnectionManager) trackDisconnect(client *WsClient) {
+	if m.analytics == nil {
+		return
+	}
+	client.messageCountsLock.Lock()
+	messages := client.messageCounts
+	client.messageCounts = make(map[string]int)
+	client.messageCountsLock.Unlock()
+	m.track(client, analytics.Disconnect, map[string]any{
+		"durationMs": time.Since(client.connectedAt).Milliseconds(),
+		"messages":   messages,
+	})
+}
+
+// countMessage counts a message the client sent on a feature channel.
+func (c *WsClient) countMessage(channel string) {
+	if c.manager.analytics == nil {
+		return
+	}
+	c.messageCountsLock.Lock()
+	defer c.messageCountsLock.Unlock()
+	c.messageCounts[channel]++
+}
+
+// SetAnalyticsSink sets the sink receiving usage events of connections.
+//
+// Params:
+// - sink: The sink, e.g. an analytics.FileSink or analytics.HTTPSink.
+func (gw *WsGw) SetAnalyticsSink(sink analytics.Sink) {
+	gw.analytics = sink
+}
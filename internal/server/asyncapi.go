@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/asyncapi"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+	"go-websocket-boilerplate/internal/receipts"
+	"net/http"
+	"reflect"
+)
+
+// sysMessages documents the messages of the sys protocol.
+var sysMessages = []asyncapi.MessageSpec{
+	{Channel: sysChannel, Type: "auth", Direction: asyncapi.Request, Data: reflect.TypeFor[AuthMsg](), Summary: "Authenticate or refresh the connection's token"},
+	{Channel: sysChannel, Type: "subscribe", Direction: asyncapi.Request, Data: reflect.TypeFor[SubscribeMsg]()},
+	{Channel: sysChannel, Type: "subscribe", Direction: asyncapi.Response, Data: reflect.TypeFor[[]SubscribeResult]()},
+	{Channel: sysChannel, Type: "unsubscribe", Direction: asyncapi.Request, Data: reflect.TypeFor[SubscribeMsg]()},
+	{Channel: sysChannel, Type: "unsubscribe", Direction: asyncapi.Response, Data: reflect.TypeFor[[]SubscribeResult]()},
+	{Channel: sysChannel, Type: "hello", Direction: asyncapi.Request, Data: reflect.TypeFor[HelloMsg](), Summary: "Identify the installation and tab"},
+	{Channel: sysChannel, Type: "connections", Direction: asyncapi.Response, Data: reflect.TypeFor[[]ConnectionInfo](), Summary: "Connections of the user"},
+	{Channel: sysChannel, Type: "edit", Direction: asyncapi.Request, Data: reflect.TypeFor[EditMsg](), Summary: "Edit an own message kept in the channel history"},
+	{Channel: sysChannel, Type: "delete", Direction: asyncapi.Request, Data: reflect.TypeFor[EditMsg](), Summary: "Delete an own message kept in the channel history"},
+	{Channel: sysChannel, Type: "join", Direction: asyncapi.Request, Data: reflect.TypeFor[RoomMsg](), Summary: "Join a room to receive its broadcasts"},
+	{Channel: sysChannel, Type: "join", Direction: asyncapi.Response, Data: reflect.TypeFor[RoomResult]()},
+	{Channel: sysChannel, Type: "leave", Direction: asyncapi.Request, Data: reflect.TypeFor[RoomMsg](), Summary: "Leave a room"},
+	{Channel: sysChannel, Type: "leave", Direction: asyncapi.Response, Data: reflect.TypeFor[RoomResult]()},
+	{Channel: sysChannel, Type: "credit", Direction: asyncapi.Request, Data: reflect.TypeFor[CreditMsg](), Summary: "Extend the window of a paced subscription"},
+	{Channel: sysChannel, Type: "ack", Direction: asyncapi.Request, Data: reflect.TypeFor[AckMsg](), Summary: "Release frames buffered for a resume"},
+	{Channel: sysChannel, Type: "read", Direction: asyncapi.Request, Data: reflect.TypeFor[ReadMsg](), Summary: "Advance the read marker of a channel"},
+	{Channel: sysChannel, Type: "read", Direction: asyncapi.Response, Data: reflect.TypeFor[ReadMsg]()},
+	{Channel: sysChannel, Type: "receipt", Direction: asyncapi.Update, Data: reflect.TypeFor[receipts.Receipt](), Summary: "Delivery state of an own message"},
+	{Channel: sysChannel, Type: "unread", Direction: asyncapi.Request, Data: reflect.TypeFor[UnreadMsg](), Summary: "Unread counts of channels"},
+	{Channel: sysChannel, Type: "unread", Direction: asyncapi.Response, Data: reflect.TypeFor[[]UnreadCount]()},
+	{Channel: sysChannel, Type: "unread", Direction: asyncapi.Update, Data: reflect.TypeFor[UnreadCount](), Summary: "Changed unread count"},
+	{Channel: sysChannel, Type: "block", Direction: asyncapi.Request, Data: reflect.TypeFor[BlockMsg]()},
+	{Channel: sysChannel, Type: "unblock", Direction: asyncapi.Request, Data: reflect.TypeFor[BlockMsg]()},
+	{Channel: sysChannel, Type: "cluster", Direction: asyncapi.Response, Data: reflect.TypeFor[ClusterInfo]()},
+	{Channel: sysChannel, Type: "cluster", Direction: asyncapi.Update, Data: reflect.TypeFor[ClusterInfo](), Summary: "Failover endpoints"},
+	{Channel: sysChannel, Type: "capabilities", Direction: asyncapi.Response, Data: reflect.TypeFor[Capabilities]()},
+	{Channel: sysChannel, Type: "impersonate", Direction: asyncapi.Request, Data: reflect.TypeFor[ImpersonateMsg](), Summary: "Administrators only"},
+	{Channel: sysChannel, Type: "impersonation", Direction: asyncapi.Update, Data: reflect.TypeFor[ImpersonationNotice]()},
+	{Channel: sysChannel, Type: "maintenance", Direction: asyncapi.Request, Data: reflect.TypeFor[MaintenanceMsg](), Summary: "Administrators only"},
+	{Channel: sysChannel, Type: "maintenance", Direction: asyncapi.Update, Data: reflect.TypeFor[MaintenanceNotice]()},
+	{Channel: sysChannel, Type: "memory", Direction: asyncapi.Response, Data: reflect.TypeFor[MemoryReport](), Summary: "Administrators only"},
+	{Channel: sysChannel, Type: "welcome", Direction: asyncapi.Update, Data: reflect.TypeFor[Welcome](), Summary: "Sent on connect"},
+	{Channel: sysChannel, Type: "session", Direction: asyncapi.Update, Data: reflect.TypeFor[SessionInfo](), Summary: "Resume token"},
+	{Channel: sysChannel, Type: "moderation", Direction: asyncapi.Update, Data: reflect.TypeFor[ModerationNotice](), Summary: "Publish rejected by moderation"},
+	{Channel: sysChannel, Type: "throttled", Direction: asyncapi.Update, Data: reflect.TypeFor[ThrottleNotice]()},
+	{Channel: sysChannel, Type: "rate_limited", Direction: asyncapi.Update, Data: reflect.TypeFor[RateLimitNotice]()},
+	{Channel: sysChannel, Type: "route", Direction: asyncapi.Update, Data: reflect.TypeFor[Route]()},
+	{Channel: sysChannel, Type: "error", Direction: asyncapi.Update, Data: reflect.TypeFor[msgs.ErrorResponse](), Summary: "Rejected frame"},
+	{Channel: presenceChannel, Type: "online", Direction: asyncapi.Update, Data: reflect.TypeFor[PresenceUpdate](), Summary: "User connected"},
+	{Channel: presenceChannel, Type: "offline", Direction: asyncapi.Update, Data: reflect.TypeFor[PresenceUpdate](), Summary: "User's last connection closed"},
+}
+
+// messageSpecs returns the documented messages of the sys protocol and of the registered handlers.
+func messageSpecs() []asyncapi.MessageSpec {
+	specs := append([]asyncapi.MessageSpec(nil), sysMessages...)
+	for _, route := range handler.Routes() {
+		specs = append(specs,
+			asyncapi.MessageSpec{Channel: route.Channel, Type: route.Type, Direction: asyncapi.Request, Data: route.Request},
+			asyncapi.MessageSpec{Channel: route.Channel, Type: route.Type, Direction: asyncapi.Response, Data: route.Response, Stream: route.Stream},
+			asyncapi.MessageSpec{Channel: route.Channel, Type: route.Type, Direction: asyncapi.Error, Data: reflect.TypeFor[handler.ErrorFrame]()},
+		)
+	}
+	return specs
+}
+
+// AsyncAPI returns the AsyncAPI document of the gateway.
+func (m *ConnectionManager) AsyncAPI(serverURL string) *asyncapi.Document {
+	return asyncapi.Generate(
+		asyncapi.Info{Title: "WebSocket Gateway", Version: Version},
+		map[string]asyncapi.Server{"gateway": {URL: serverURL, Protocol: "ws"}},
+		m.registry,
+		messageSpecs(),
+	)
+}
+
+// serveAsyncAPI serves the AsyncAPI document.
+func (m *ConnectionManager) serveAsyncAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.AsyncAPI(r.Host + "/ws")); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
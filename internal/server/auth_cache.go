@@ -0,0 +1,115 @@
+package server
+
+import (
+	"crypto/sha256"
+	"github.com/golang-jwt/jwt/v5"
+	"maps"
+	"sync"
+	"time"
+)
+
+// defaultAuthCacheSize is the number of validated tokens kept when no size is configured.
+const defaultAuthCacheSize = 10000
+
+// cachedAuth is a validated token's claims and the time they stop being reused.
+type cachedAuth struct {
+	claims  jwt.MapClaims
+	expires time.Time
+}
+
+// authCache remembers the claims of validated tokens, keyed by a hash of the token, so clients reconnecting with
+// the same token skip signature verification and key lookups. Entries never outlive the token's "exp".
+type authCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[[sha256.Size]byte]cachedAuth
+}
+
+// newAuthCache creates a cache keeping up to size tokens for at most ttl.
+func newAuthCache(ttl time.Duration, size int) *authCache {
+	if size <= 0 {
+		size = defaultAuthCacheSize
+	}
+	return &authCache{ttl: ttl, size: size, entries: make(map[[sha256.Size]byte]cachedAuth)}
+}
+
+// authCacheKey hashes the token together with the tenant it was validated for.
+func authCacheKey(authenticator Authenticator, token string) [sha256.Size]byte {
+	if tenant, ok := authenticator.(tenantAuthenticator); ok {
+		return sha256.Sum256([]byte(tenant.tenant + "\x00" + token))
+	}
+	return sha256.Sum256([]byte(token))
+}
+
+// get returns a copy of the cached claims of the token, if they have not expired.
+func (c *authCache) get(key [sha256.Size]byte, now time.Time) (jwt.MapClaims, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return maps.Clone(entry.claims), true
+}
+
+// put caches the claims until the token expires or the TTL elapses, whichever is first. When the cache is full,
+// expired entries are evicted first, then arbitrary ones.
+func (c *authCache) put(key [sha256.Size]byte, claims jwt.MapClaims, now time.Time) {
+	expires := now.Add(c.ttl)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil && exp.Before(expires) {
+		expires = exp.Time
+	}
+	if !now.Before(expires) {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if len(c.entries) >= c.size {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedAuth{claims: maps.Clone(claims), expires: expires}
+}
+
+// validateCached validates the token with the authenticator, reusing the claims of an earlier validation of the
+// same token if the auth cache is enabled.
+func (m *ConnectionManager) validateCached(authenticator Authenticator, token string) (jwt.MapClaims, error) {
+	if m.authCache == nil {
+		return authenticator.ValidateJwt(token)
+	}
+	key := authCacheKey(authenticator, token)
+	now := time.Now()
+	if claims, ok := m.authCache.get(key, now); ok {
+		return claims, nil
+	}
+	claims, err := authenticator.ValidateJwt(token)
+	if err != nil {
+		return nil, err
+	}
+	m.authCache.put(key, claims, now)
+	return claims, nil
+}
+
+// SetAuthCache enables caching of validated tokens, so reconnect storms do not repeat signature verification or
+// JWKS lookups. Failed validations are not cached.
+//
+// Params:
+// - ttl: How long a validated token is reused at most; entries never outlive the token's expiry.
+// - size: The maximum number of cached tokens; values below 1 use the default of 10000.
+func (gw *WsGw) SetAuthCache(ttl time.Duration, size int) {
+	gw.authCache = newAuthCache(ttl, size)
+}
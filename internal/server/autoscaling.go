@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadSampleInterval is how often the message rates are sampled.
+const loadSampleInterval = 5 * time.Second
+
+// loadSmoothing is the weight of the newest sample in the exponentially smoothed message rates.
+const loadSmoothing = 0.3
+
+// AutoscaleTargets is the load a single replica is sized for. The recommendation scales on whichever target is
+// exceeded most.
+type AutoscaleTargets struct {
+	ConnectionsPerReplica int     // Connections a replica serves comfortably
+	MessagesPerReplica    float64 // Ingress plus egress messages per second a replica serves comfortably
+}
+
+// defaultAutoscaleTargets is used when no targets are configured.
+var defaultAutoscaleTargets = AutoscaleTargets{ConnectionsPerReplica: 10000, MessagesPerReplica: 5000}
+
+// LoadReport is the realtime load of the node and the replica recommendation derived from it, served at
+// /autoscaling for KEDA's metrics-api scaler or a custom HPA metrics adapter.
+type LoadReport struct {
+	Connections     int     `json:"connections"`
+	IngressRate     float64 `json:"ingressPerSecond"`
+	EgressRate      float64 `json:"egressPerSecond"`
+	Utilization     float64 `json:"utilization"`     // Load relative to the targets of one replica; scale on an average of 1
+	DesiredReplicas int     `json:"desiredReplicas"` // Replicas this node's load alone requires
+}
+
+// loadMeter counts messages and keeps their smoothed rates.
+type loadMeter struct {
+	ingress atomic.Int64 // Messages received from clients
+	egress  atomic.Int64 // Messages written to clients
+
+	sync.Mutex
+	ingressRate float64
+	egressRate  float64
+	lastIngress int64
+	lastEgress  int64
+}
+
+// sample updates the smoothed rates with the messages counted during the interval.
+func (l *loadMeter) sample(interval time.Duration) {
+	ingress, egress := l.ingress.Load(), l.egress.Load()
+	l.Lock()
+	defer l.Unlock()
+	seconds := interval.Seconds()
+	l.ingressRate += loadSmoothing * (float64(ingress-l.lastIngress)/seconds - l.ingressRate)
+	l.egressRate += loadSmoothing * (float64(egress-l.lastEgress)/seconds - l.egressRate)
+	l.lastIngress, l.lastEgress = ingress, egress
+}
+
+// rates returns the smoothed ingress and egress rates per second.
+func (l *loadMeter) rates() (float64, float64) {
+	l.Lock()
+	defer l.Unlock()
+	return l.ingressRate, l.egressRate
+}
+
+// sampleLoad samples the message rates until the process exits.
+func (m *ConnectionManager) sampleLoad() {
+	ticker := time.NewTicker(loadSampleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.load.sample(loadSampleInterval)
+	}
+}
+
+// Load returns the node's current load and replica recommendation.
+func (m *ConnectionManager) Load() LoadReport {
+	ingress, egress := m.load.rates()
+	report := LoadReport{Connections: m.ClientCount(), IngressRate: ingress, EgressRate: egress}
+	targets := m.autoscaleTargets
+	report.Utilization = math.Max(
+		float64(report.Connections)/float64(targets.ConnectionsPerReplica),
+		(ingress+egress)/targets.MessagesPerReplica,
+	)
+	report.DesiredReplicas = max(1, int(math.Ceil(report.Utilization)))
+	return report
+}
+
+// serveAutoscaling serves the load report as JSON.
+func (m *ConnectionManager) serveAutoscaling(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.Load()); err != nil {
+		slog.Error("Failed to write load report", "error", err)
+	}
+}
+
+// serveLoadMetrics serves the load in the Prometheus text format, for KEDA's prometheus scaler or the Prometheus
+// adapter of an HPA.
+func (m *ConnectionManager) serveLoadMetrics(w http.ResponseWriter, _ *http.Request) {
+	report := m.Load()
+	var b bytes.Buffer
+	gauge := func(name string, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+	}
+	counter := func(name string, help string, value int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	gauge("wsgw_connections", "Connected clients.", float64(report.Connections))
+	counter("wsgw_ingress_messages_total", "Messages received from clients.", m.load.ingress.Load())
+	counter("wsgw_egress_messages_total", "Messages written to clients.", m.load.egress.Load())
+	gauge("wsgw_ingress_messages_per_second", "Smoothed rate of messages received from clients.", report.IngressRate)
+	gauge("wsgw_egress_messages_per_second", "Smoothed rate of messages written to clients.", report.EgressRate)
+	counter("wsgw_rate_limited_messages_total", "Messages dropped by per-connection rate limits.", m.rateLimitedMessages.Load())
+	counter("wsgw_rate_limited_clients_total", "Clients that exceeded their rate limits.", m.rateLimitedClients.Load())
+	counter("wsgw_rate_limit_disconnects_total", "Clients disconnected after repeated rate limit breaches.", m.rateLimitDisconnects.Load())
+	gauge("wsgw_accept_queue", "Handshakes waiting for a connection slot.", float64(m.acceptWaiting.Load()))
+	counter("wsgw_connections_rejected_total", "Handshakes refused by the connection limit.", m.connectionsRejected.Load())
+	counter("wsgw_misrouted_connections_total", "Clients connected to a node not owning their user.", m.misrouted.Load())
+	m.writeStateMetrics(&b)
+	gauge("wsgw_autoscale_utilization", "Load relative to the capacity targets of one replica.", report.Utilization)
+	gauge("wsgw_autoscale_desired_replicas", "Replicas the load of this node requires.", float64(report.DesiredReplicas))
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(b.Bytes())
+}
+
+// SetAutoscaleTargets sets the load one replica is sized for, which the autoscaling recommendation is based on.
+// Zero targets keep their defaults of 10000 connections and 5000 messages per second.
+//
+// Params:
+// - targets: The per-replica capacity targets.
+func (gw *WsGw) SetAutoscaleTargets(targets AutoscaleTargets) {
+	gw.autoscaleTargets = targets
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// backfillTimeout bounds the fetch of a backfill snapshot.
+const backfillTimeout = 5 * time.Second
+
+// maxBackfillSize is the largest backfill response accepted.
+const maxBackfillSize = 4 << 20
+
+// maxBackfillBuffer is the number of live updates held back per channel while its snapshot is fetched.
+const maxBackfillBuffer = 1000
+
+// backfillClient fetches backfill snapshots.
+var backfillClient = &http.Client{Timeout: backfillTimeout}
+
+// backfillURL expands the channel's backfill URL template. "{channel}" and "{sub}" are replaced with the
+// escaped channel name and subject of the subscriber.
+func backfillURL(template string, channel string, subject string) string {
+	return strings.NewReplacer("{channel}", url.QueryEscape(channel), "{sub}", url.QueryEscape(subject)).Replace(template)
+}
+
+// startBackfill holds back live updates of the channel for the client until its snapshot was delivered.
+//
+// Returns:
+// - false if the channel has no backfill URL or is a pattern.
+func (c *WsClient) startBackfill(def *channels.Definition, channel string) bool {
+	if def == nil || def.BackfillURL == "" || channels.IsPattern(channel) {
+		return false
+	}
+	c.backfillLock.Lock()
+	defer c.backfillLock.Unlock()
+	c.backfilling[channel] = make([]*EgressMsg, 0)
+	return true
+}
+
+// backfill fetches recent data of the channel from its backfill URL, sends it to the client as a "snapshot"
+// update and then releases the live updates held back meanwhile. Failures are logged and only skip the snapshot.
+func (m *ConnectionManager) backfill(client *WsClient, def *channels.Definition, channel string) {
+	snapshot, err := fetchBackfill(client.Context(), backfillURL(def.BackfillURL, channel, subjectOf(client.Claims())))
+	if err != nil {
+		client.logger.Warn("Backfill failed", "ch", channel, "error", err)
+	} else {
+		client.send(NewEgressMsg("", "snapshot", channel, snapshot))
+	}
+	// Updates arriving while the held ones are sent queue up behind them until none are left.
+	for {
+		client.backfillLock.Lock()
+		held := client.backfilling[channel]
+		if len(held) == 0 {
+			delete(client.backfilling, channel)
+			client.backfillLock.Unlock()
+			return
+		}
+		client.backfilling[channel] = make([]*EgressMsg, 0)
+		client.backfillLock.Unlock()
+		for _, msg := range held {
+			client.sendPaced(msg)
+		}
+	}
+}
+
+// fetchBackfill GETs a JSON document.
+func fetchBackfill(ctx context.Context, target string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := backfillClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backfill %s: status %d", target, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBackfillSize))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("backfill %s: response is not JSON", target)
+	}
+	return body, nil
+}
+
+// sendLive sends a live update of a channel, holding it back while the channel's snapshot is fetched and pacing
+// it by the subscription's credit window.
+func (c *WsClient) sendLive(msg *EgressMsg) {
+	c.backfillLock.Lock()
+	if held, ok := c.backfilling[msg.Channel]; ok {
+		if len(held) < maxBackfillBuffer {
+			c.backfilling[msg.Channel] = append(held, msg)
+		} else {
+			c.manager.slaDropped()
+		}
+		c.backfillLock.Unlock()
+		return
+	}
+	c.backfillLock.Unlock()
+	c.sendPaced(msg)
+}
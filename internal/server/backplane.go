@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"go-websocket-boilerplate/internal/broker"
+	"log/slog"
+	"time"
+)
+
+// brokerTimeout bounds a single publish to the broker.
+const brokerTimeout = time.Second
+
+// brokerRetry is the delay before resubscribing after the broker subscription failed.
+const brokerRetry = 5 * time.Second
+
+// relay sends an update to the other nodes of the cluster, if a broker is configured.
+func (m *ConnectionManager) relay(kind string, msg *EgressMsg) {
+	if m.broker == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	err := m.broker.Publish(ctx, broker.Message{
+		Node:           m.nodeID,
+		Kind:           kind,
+		Channel:        msg.Channel,
+		Type:           msg.Type,
+		Data:           msg.Data,
+		MessageID:      msg.MessageID,
+		CausationID:    msg.CausationID,
+		CorrelationID:  msg.CorrelationID,
+		OriginClientID: msg.OriginClientID,
+		OriginSubject:  msg.originSubject,
+	})
+	if err != nil {
+		slog.Error("Failed to relay update to the cluster", "kind", kind, "ch", msg.Channel, "error", err)
+	}
+}
+
+// consumeBroker delivers the updates relayed by other nodes to the local clients, resubscribing after failures.
+func (m *ConnectionManager) consumeBroker() {
+	for {
+		if err := m.broker.Subscribe(context.Background(), m.receiveRelayed); err != nil {
+			slog.Error("Broker subscription failed", "error", err)
+		}
+		time.Sleep(brokerRetry)
+	}
+}
+
+// receiveRelayed delivers an update relayed by another node. The node's own updates were delivered locally when
+// published and are ignored.
+func (m *ConnectionManager) receiveRelayed(relayed broker.Message) {
+	if relayed.Node == m.nodeID {
+		return
+	}
+	if relayed.Kind == broker.KindKick || relayed.Kind == broker.KindBan {
+		m.receiveSanction(relayed)
+		return
+	}
+	msg := &EgressMsg{
+		Type:           relayed.Type,
+		Channel:        relayed.Channel,
+		MessageID:      relayed.MessageID,
+		Data:           relayed.Data,
+		CausationID:    relayed.CausationID,
+		CorrelationID:  relayed.CorrelationID,
+		OriginClientID: relayed.OriginClientID,
+		created:        time.Now(),
+		originSubject:  relayed.OriginSubject,
+	}
+	switch relayed.Kind {
+	case broker.KindPublish:
+		def, _ := m.registry.Lookup(relayed.Channel)
+		m.fanOutMsg(def, nil, msg, relayed.Data)
+	case broker.KindBroadcast:
+		m.broadcastMsg(msg, nil)
+	default:
+		slog.Warn("Unknown relayed message kind", "kind", relayed.Kind)
+	}
+}
+
+// SetBroker sets the broker relaying publishes and broadcasts between the nodes of a cluster. Every node must
+// have a distinct node ID.
+//
+// Params:
+// - b: The broker, e.g. a broker.Redis.
+func (gw *WsGw) SetBroker(b broker.Interface) {
+	gw.broker = b
+}
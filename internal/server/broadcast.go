@@ -0,0 +1,115 @@
+package server
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/broker"
+	"log/slog"
+)
+
+// Broadcast sends an update to every connected client, on all nodes if a broker is configured.
+//
+// Clients whose egress queue is full are skipped rather than waited for, so a slow client cannot stall the
+// broadcast; the drop is counted towards the SLA drop rate.
+//
+// Params:
+// - updateType: The type of the update.
+// - channel: The channel the update is sent on.
+// - data: The update payload.
+//
+// Returns:
+// - The number of clients the update was queued for.
+func (m *ConnectionManager) Broadcast(updateType string, channel string, data any) int {
+	return m.BroadcastFunc(updateType, channel, data, nil)
+}
+
+// BroadcastFunc sends an update to every connected client whose claims satisfy the filter, e.g. all users of a
+// tenant. Like Broadcast, it never waits for slow clients. Filtered broadcasts only reach the clients of this node.
+//
+// Params:
+// - updateType: The type of the update.
+// - channel: The channel the update is sent on.
+// - data: The update payload.
+// - filter: Selects the recipients by their claims; nil selects all clients.
+//
+// Returns:
+// - The number of clients the update was queued for.
+func (m *ConnectionManager) BroadcastFunc(updateType string, channel string, data any, filter func(claims jwt.MapClaims) bool) int {
+	msg := NewEgressMsg("", updateType, channel, data)
+	msg.MessageID = m.ids.NewID() // Shared by all recipients
+	if filter == nil {
+		m.relay(broker.KindBroadcast, msg)
+	}
+	return m.broadcastMsg(msg, filter)
+}
+
+// broadcastMsg queues the message for every local client whose claims satisfy the filter.
+//
+// Returns:
+// - The number of clients the message was queued for.
+func (m *ConnectionManager) broadcastMsg(msg *EgressMsg, filter func(claims jwt.MapClaims) bool) int {
+	m.RLock()
+	recipients := make([]*WsClient, 0, len(m.clients))
+	for _, client := range m.clients {
+		if filter == nil || filter(client.Claims()) {
+			recipients = append(recipients, client)
+		}
+	}
+	m.RUnlock()
+
+	queued := 0
+	for _, client := range recipients {
+		if client.offer(msg) {
+			queued++
+		}
+	}
+	if dropped := len(recipients) - queued; dropped > 0 {
+		slog.Warn("Broadcast skipped slow clients", "channel", msg.Channel, "type", msg.Type, "dropped", dropped)
+	}
+	return queued
+}
+
+// offer queues the message unless the client's egress queue is full or the client has been closed.
+//
+// Returns:
+// - false if the message was dropped.
+func (c *WsClient) offer(msg *EgressMsg) bool {
+	select {
+	case <-c.context.Done():
+		return false
+	default:
+	}
+	select {
+	case c.egress <- msg:
+	default:
+		c.manager.slaDropped()
+		return false
+	}
+	c.manager.mirror(c, msg)
+	return true
+}
+
+// Broadcast sends an update to every connected client without waiting for slow ones. It does nothing before Start.
+//
+// Params:
+// - updateType: The type of the update.
+// - channel: The channel the update is sent on.
+// - data: The update payload.
+func (gw *WsGw) Broadcast(updateType string, channel string, data any) {
+	if gw.manager != nil {
+		gw.manager.Broadcast(updateType, channel, data)
+	}
+}
+
+// BroadcastFunc sends an update to every connected client whose claims satisfy the filter. It does nothing
+// before Start.
+//
+// Params:
+// - updateType: The type of the update.
+// - channel: The channel the update is sent on.
+// - data: The update payload.
+// - filter: Selects the recipients by their claims; nil selects all clients.
+func (gw *WsGw) BroadcastFunc(updateType string, channel string, data any, filter func(claims jwt.MapClaims) bool) {
+	if gw.manager != nil {
+		gw.manager.BroadcastFunc(updateType, channel, data, filter)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrCallFailed is returned by Call when the client answered the call with an error.
+var ErrCallFailed = errors.New("call failed")
+
+// callReply is a client's answer to a server call.
+type callReply struct {
+	data json.RawMessage
+	err  string
+}
+
+// Call sends a request to the client and waits for its reply.
+//
+// The request is an update carrying a "callId"; the client answers with a frame whose "replyTo" is the call ID,
+// carrying the result in "data" or a message in "error".
+//
+// Params:
+// - ctx: Bounds the wait for the reply.
+// - channel: The channel of the request.
+// - callType: The type of the request.
+// - data: The request payload.
+//
+// Returns:
+// - The reply payload, or an error wrapping ErrCallFailed if the client answered with an error, the context's
+// error if it ended first, or an error if the connection closed.
+func (c *WsClient) Call(ctx context.Context, channel string, callType string, data any) (json.RawMessage, error) {
+	callID := c.manager.ids.NewID()
+	reply := make(chan callReply, 1)
+	c.callsLock.Lock()
+	if c.calls == nil {
+		c.calls = make(map[string]chan callReply)
+	}
+	c.calls[callID] = reply
+	c.callsLock.Unlock()
+	defer func() {
+		c.callsLock.Lock()
+		delete(c.calls, callID)
+		c.callsLock.Unlock()
+	}()
+
+	msg := NewEgressMsg("", callType, channel, data)
+	msg.CallID = callID
+	c.send(msg)
+
+	select {
+	case r := <-reply:
+		if r.err != "" {
+			return nil, fmt.Errorf("%w: %s", ErrCallFailed, r.err)
+		}
+		return r.data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.context.Done():
+		return nil, errConnectionClosed
+	}
+}
+
+// resolveCall passes a client's reply to the pending call. Replies to unknown or timed out calls are dropped.
+func (c *WsClient) resolveCall(request IngressMsg) {
+	c.callsLock.Lock()
+	reply, ok := c.calls[request.InMsgReplyTo]
+	delete(c.calls, request.InMsgReplyTo)
+	c.callsLock.Unlock()
+	if !ok {
+		c.logger.Debug("Reply to an unknown call", "replyTo", request.InMsgReplyTo)
+		return
+	}
+	reply <- callReply{data: request.Data(), err: request.InMsgError}
+}
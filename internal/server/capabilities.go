@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Capabilities describes the gateway version and the modules enabled on it so client SDKs can feature-detect.
+//
+// It is served at GET /version and returned for sys/capabilities requests.
+type Capabilities struct {
+	Version     string          `json:"version"`     // Gateway version
+	NodeID      string          `json:"nodeId"`      // Identifier of the node
+	Modules     map[string]bool `json:"modules"`     // Module name to whether it is enabled
+	Codecs      []string        `json:"codecs"`      // Supported message encodings
+	Compression bool            `json:"compression"` // True if per-message compression is negotiated
+}
+
+// Capabilities returns the gateway's version and enabled modules.
+func (m *ConnectionManager) Capabilities() *Capabilities {
+	history := false
+	for _, def := range m.registry.Definitions() {
+		if def.History {
+			history = true
+			break
+		}
+	}
+	return &Capabilities{
+		Version: Version,
+		NodeID:  m.nodeID,
+		Modules: map[string]bool{
+			"presence":       true,
+			"history":        history,
+			"subscriptions":  true,
+			"direct":         true,
+			"blocklist":      m.blockChecker != nil,
+			"resume":         true,
+			"signing":        m.signer != nil,
+			"replay":         len(m.replayChannels) > 0,
+			"geo":            m.geoResolver != nil,
+			"abuse":          m.abuseDetector != nil,
+			"impersonation":  true,
+			"strictChannels": m.registry.Strict(),
+		},
+		Codecs:      []string{"json"},
+		Compression: m.upgrader.EnableCompression,
+	}
+}
+
+// ServeVersion serves the gateway's capabilities as JSON.
+func (m *ConnectionManager) ServeVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.Capabilities()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
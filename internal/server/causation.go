@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"go-websocket-boilerplate/internal/handler"
+)
+
+// cause identifies the request that led to a message, letting clients reconcile optimistic updates with the
+// messages their requests produced.
+type cause struct {
+	causationID   string          // ID of the request that caused the message
+	correlationID string          // Conversation the request belongs to
+	trace         context.Context // Span context of the request, nil if it is not traced
+}
+
+// stamp sets the causation and correlation IDs of the message.
+func (c cause) stamp(msg *EgressMsg) *EgressMsg {
+	msg.CausationID = c.causationID
+	msg.CorrelationID = c.correlationID
+	c.linkEgress(msg)
+	return msg
+}
+
+// causeOf returns the cause of the messages sent while handling the request. The correlation ID is taken from the
+// request and defaults to the request ID.
+func causeOf(msg handler.InMsg) cause {
+	cause := cause{causationID: msg.ID()}
+	if request, ok := msg.(IngressMsg); ok {
+		cause.correlationID = request.InMsgCorrelationID
+		cause.trace = request.trace
+	}
+	if cause.correlationID == "" {
+		cause.correlationID = cause.causationID
+	}
+	return cause
+}
+
+// causedClient is the view of a client given to a handler while it processes a request. Everything sent through
+// it is stamped with the cause of the request.
+type causedClient struct {
+	*WsClient
+	cause cause
+}
+
+// CausedBy returns a view of the client that stamps the messages sent while handling msg with its causation and
+// correlation IDs.
+func (c *WsClient) CausedBy(msg handler.InMsg) handler.Client {
+	cause := causeOf(msg)
+	if cause.causationID == "" && cause.correlationID == "" && cause.trace == nil {
+		return c
+	}
+	return &causedClient{WsClient: c, cause: cause}
+}
+
+// SendResponse sends a response stamped with the request's correlation ID.
+func (c *causedClient) SendResponse(id string, reqType string, channel string, data any) {
+	c.observeResponse(channel, reqType, data)
+	c.send(c.cause.stamp(NewEgressMsg(id, reqType, channel, data)))
+}
+
+// SendUpdate sends an update stamped with the cause.
+func (c *causedClient) SendUpdate(updateType string, channel string, data any) {
+	c.send(c.cause.stamp(NewEgressMsg("", updateType, channel, data)))
+}
+
+// SendToClient sends a direct message stamped with the cause.
+func (c *causedClient) SendToClient(clientID int, updateType string, channel string, data any) error {
+	return c.sendToClient(clientID, updateType, channel, data, c.cause)
+}
+
+// SendToUser sends a direct message stamped with the cause.
+func (c *causedClient) SendToUser(subject string, updateType string, channel string, data any) error {
+	return c.sendToUser(subject, updateType, channel, data, c.cause)
+}
+
+// Publish publishes an update stamped with the cause.
+func (c *causedClient) Publish(channel string, updateType string, data any) {
+	c.manager.publish(c.WsClient, channel, updateType, data, c.cause)
+}
+
+// EditMessage edits one of the client's messages, stamping the announcement with the cause.
+func (c *causedClient) EditMessage(channel string, msgID string, data any) error {
+	return c.editMessage(channel, msgID, data, c.cause)
+}
+
+// DeleteMessage deletes one of the client's messages, stamping the announcement with the cause.
+func (c *causedClient) DeleteMessage(channel string, msgID string) error {
+	return c.manager.changeMessage(c.WsClient, channel, msgID, nil, c.cause)
+}
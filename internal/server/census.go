@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-websocket-boilerplate/internal/noderpc"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultCensusTop is the number of top channels reported by default.
+const defaultCensusTop = 10
+
+// censusTimeout bounds the wait for the other nodes' census.
+const censusTimeout = 2 * time.Second
+
+// Census counts the connections of the whole cluster.
+type Census struct {
+	Connections   int                    `json:"connections"`
+	Authenticated int                    `json:"authenticated"`
+	Tenants       map[string]int         `json:"tenants,omitempty"`     // Connections by tenant
+	TopChannels   []noderpc.ChannelCount `json:"topChannels,omitempty"` // Summed over the top channels of each node
+	Nodes         []noderpc.NodeCensus   `json:"nodes"`
+	Unreachable   map[string]string      `json:"unreachable,omitempty"` // Error by node that did not answer
+}
+
+// tenant returns the tenant of the client: the one named by its connection path, else the one of its claims.
+func (c *WsClient) tenant() string {
+	if c.pathTenant != "" {
+		return c.pathTenant
+	}
+	if c.manager.meter != nil {
+		return c.manager.meter.TenantOf(c.Claims())
+	}
+	if tenant, ok := c.Claims()["tenant"]; ok {
+		return fmt.Sprint(tenant)
+	}
+	return ""
+}
+
+// nodeCensus counts the connections of this node.
+//
+// Params:
+// - top: The number of channels reported, by subscriber count.
+func (m *ConnectionManager) nodeCensus(top int) *noderpc.NodeCensus {
+	census := &noderpc.NodeCensus{Node: m.nodeID, Tenants: make(map[string]int)}
+	m.RLock()
+	clients := make([]*WsClient, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	channels := make([]noderpc.ChannelCount, 0, len(m.subscribers))
+	for channel, subscribers := range m.subscribers {
+		channels = append(channels, noderpc.ChannelCount{Channel: channel, Subscribers: len(subscribers)})
+	}
+	m.RUnlock()
+
+	census.Connections = len(clients)
+	for _, client := range clients {
+		if client.isAuthenticated() {
+			census.Authenticated++
+		}
+		if tenant := client.tenant(); tenant != "" {
+			census.Tenants[tenant]++
+		}
+	}
+	census.TopChannels = topChannels(channels, top)
+	return census
+}
+
+// topChannels sorts the channels by subscriber count and returns the first top of them.
+func topChannels(channels []noderpc.ChannelCount, top int) []noderpc.ChannelCount {
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].Subscribers != channels[j].Subscribers {
+			return channels[i].Subscribers > channels[j].Subscribers
+		}
+		return channels[i].Channel < channels[j].Channel
+	})
+	return channels[:min(top, len(channels))]
+}
+
+// Census counts the connections of every node, asking the other nodes over node RPC. Without node RPC only this
+// node is counted.
+//
+// Params:
+// - ctx: Context bounding the wait for the other nodes.
+// - top: The number of channels reported, by subscriber count.
+//
+// Returns:
+// - The census; nodes that did not answer are listed as unreachable.
+func (m *ConnectionManager) Census(ctx context.Context, top int) *Census {
+	nodes := []noderpc.NodeCensus{*m.nodeCensus(top)}
+	unreachable := make(map[string]string)
+	if m.peers != nil {
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		for _, node := range m.peers.Nodes() {
+			if node == m.nodeID {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				census, err := m.peers.Census(ctx, node, &noderpc.CensusRequest{TopChannels: top})
+				lock.Lock()
+				defer lock.Unlock()
+				if err != nil {
+					slog.Warn("Node census failed", "node", node, "error", err)
+					unreachable[node] = err.Error()
+					return
+				}
+				nodes = append(nodes, *census)
+			}()
+		}
+		wg.Wait()
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+
+	census := &Census{Tenants: make(map[string]int), Nodes: nodes}
+	if len(unreachable) > 0 {
+		census.Unreachable = unreachable
+	}
+	subscribers := make(map[string]int)
+	for _, node := range nodes {
+		census.Connections += node.Connections
+		census.Authenticated += node.Authenticated
+		for tenant, count := range node.Tenants {
+			census.Tenants[tenant] += count
+		}
+		for _, channel := range node.TopChannels {
+			subscribers[channel.Channel] += channel.Subscribers
+		}
+	}
+	channels := make([]noderpc.ChannelCount, 0, len(subscribers))
+	for channel, count := range subscribers {
+		channels = append(channels, noderpc.ChannelCount{Channel: channel, Subscribers: count})
+	}
+	census.TopChannels = topChannels(channels, top)
+	return census
+}
+
+// Census answers the census request of another node.
+func (h nodeRPCHandler) Census(_ context.Context, request *noderpc.CensusRequest) (*noderpc.NodeCensus, error) {
+	top := request.TopChannels
+	if top <= 0 {
+		top = defaultCensusTop
+	}
+	return h.manager.nodeCensus(top), nil
+}
+
+// serveCensus serves the cluster census to administrators. The "top" query parameter sets the number of
+// channels listed, 10 by default.
+func (m *ConnectionManager) serveCensus(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	top, err := strconv.Atoi(r.URL.Query().Get("top"))
+	if err != nil || top <= 0 {
+		top = defaultCensusTop
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), censusTimeout)
+	defer cancel()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.Census(ctx, top)); err != nil {
+		slog.Error("Failed to write census", "error", err)
+	}
+}
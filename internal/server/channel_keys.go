@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"go-websocket-boilerplate/internal/channels"
+	"log/slog"
+	"strconv"
+)
+
+// channelKeySize is the size in bytes of the symmetric keys of encrypted channels, suitable for AES-256-GCM.
+const channelKeySize = 32
+
+var errEncryptedPattern = errors.New("encrypted channels cannot be subscribed by pattern")
+
+// ChannelKey is sent on the sys channel as a "key" update to every subscriber of an encrypted channel when its
+// key changes. Members encrypt payloads with the newest key and keep earlier keys to decrypt messages in flight.
+type ChannelKey struct {
+	Channel string `json:"ch"`
+	KeyID   string `json:"keyId"` // Increases with every rotation; members tag their ciphertexts with it
+	Key     []byte `json:"key"`   // Symmetric key, base64 encoded in JSON
+}
+
+// channelKey is the current key of an encrypted channel.
+type channelKey struct {
+	generation int
+	key        []byte
+}
+
+// encrypted reports whether the channel is declared as encrypted.
+func (m *ConnectionManager) encrypted(channel string) bool {
+	def, ok := m.registry.Lookup(channel)
+	return ok && def.Encrypted
+}
+
+// rotateChannelKey generates a new key for an encrypted channel and distributes it to the current subscribers.
+// Keys rotate whenever a member joins or leaves, so new members cannot read earlier messages and former members
+// cannot read later ones. The key is forgotten once the channel has no subscribers.
+func (m *ConnectionManager) rotateChannelKey(channel string) {
+	if !m.encrypted(channel) {
+		return
+	}
+	m.Lock()
+	subscribers := make([]*WsClient, 0, len(m.subscribers[channel]))
+	for _, client := range m.subscribers[channel] {
+		subscribers = append(subscribers, client)
+	}
+	if len(subscribers) == 0 {
+		delete(m.channelKeys, channel)
+		m.Unlock()
+		return
+	}
+	key := make([]byte, channelKeySize)
+	if _, err := rand.Read(key); err != nil {
+		m.Unlock()
+		slog.Error("Failed to generate channel key", "ch", channel, "error", err)
+		return
+	}
+	generation := 1
+	if current, ok := m.channelKeys[channel]; ok {
+		generation = current.generation + 1
+	}
+	m.channelKeys[channel] = &channelKey{generation: generation, key: key}
+	m.Unlock()
+
+	update := &ChannelKey{Channel: channel, KeyID: strconv.Itoa(generation), Key: key}
+	for _, client := range subscribers {
+		client.SendUpdate("key", sysChannel, update)
+	}
+}
+
+// checkEncryptedSubscription rejects pattern subscriptions matching encrypted channels, whose keys are only
+// distributed to subscribers of the exact channel.
+func (m *ConnectionManager) checkEncryptedSubscription(def *channels.Definition, channel string) error {
+	if def != nil && def.Encrypted && channels.IsPattern(channel) {
+		return errEncryptedPattern
+	}
+	return nil
+}
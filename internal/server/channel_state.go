@@ -0,0 +1,104 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+	"time"
+)
+
+var (
+	errUnknownChannel   = &handler.Error{Code: msgs.CodeNotFound, Message: "unknown channel"}
+	errPermissionDenied = &handler.Error{Code: msgs.CodePermissionDenied, Message: "permission denied"}
+	errServerOnly       = &handler.Error{Code: msgs.CodePermissionDenied, Message: "channel is server-only"}
+	errGeoRestricted    = &handler.Error{Code: msgs.CodePermissionDenied, Message: "channel not available in your region"}
+	errStepUpRequired   = &handler.Error{Code: msgs.CodeUnauthenticated, Message: "step_up_required"}
+)
+
+// conflatedUpdate is the latest pending update of a conflated channel.
+type conflatedUpdate struct {
+	sender     *WsClient
+	updateType string
+	data       any
+	cause      cause
+}
+
+// checkChannelAccess verifies the channel is declared, if the registry is strict, and that its ACL or a channel
+// token, its geographic restrictions and its step-up requirement admit the client.
+func (m *ConnectionManager) checkChannelAccess(client *WsClient, channel string) error {
+	def, ok := m.registry.Lookup(channel)
+	if !ok {
+		if m.registry.Strict() {
+			return errUnknownChannel
+		}
+		return nil
+	}
+	if !def.Allows(client.Claims()) && !client.granted(channel) {
+		return errPermissionDenied
+	}
+	if !def.AllowsCountry(client.Country()) {
+		return errGeoRestricted
+	}
+	if !def.AllowsStepUp(client.Claims(), time.Now()) {
+		return errStepUpRequired
+	}
+	return nil
+}
+
+// checkPublishAccess verifies the client may send messages to the channel.
+//
+// Channels marked server-only in the registry only carry server-originated updates.
+func (m *ConnectionManager) checkPublishAccess(client *WsClient, channel string) error {
+	if err := m.checkChannelAccess(client, channel); err != nil {
+		return err
+	}
+	if def, ok := m.registry.Lookup(channel); ok && def.ServerOnly {
+		return errServerOnly
+	}
+	return nil
+}
+
+// recordHistory keeps the message in the channel history, trimmed to the replay depth.
+func (m *ConnectionManager) recordHistory(def *channels.Definition, channel string, msg *EgressMsg) {
+	if def.ReplayDepth == 0 {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	history := append(m.history[channel], msg)
+	if len(history) > def.ReplayDepth {
+		history = history[len(history)-def.ReplayDepth:]
+	}
+	m.history[channel] = history
+}
+
+// replayHistory sends the recent updates of a channel to a new subscriber.
+func (m *ConnectionManager) replayHistory(client *WsClient, channel string) {
+	m.RLock()
+	history := make([]*EgressMsg, len(m.history[channel]))
+	copy(history, m.history[channel])
+	m.RUnlock()
+	for _, msg := range history {
+		client.send(msg)
+	}
+}
+
+// conflate holds the update until the end of the channel's conflation interval, replacing any pending update.
+func (m *ConnectionManager) conflate(def *channels.Definition, sender *WsClient, channel string, updateType string, data any, cause cause) {
+	m.Lock()
+	_, pending := m.conflated[channel]
+	m.conflated[channel] = &conflatedUpdate{sender: sender, updateType: updateType, data: data, cause: cause}
+	m.Unlock()
+	if pending {
+		return
+	}
+	time.AfterFunc(def.Conflation(), func() {
+		m.Lock()
+		update := m.conflated[channel]
+		delete(m.conflated, channel)
+		m.Unlock()
+		if update != nil {
+			m.fanOut(def, update.sender, channel, update.updateType, update.data, update.cause)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"time"
+)
+
+// channelsClaim lists the channels or patterns a channel-scoped token grants access to.
+const channelsClaim = "channels"
+
+var (
+	errNotChannelToken   = errors.New("channel token lists no channels")
+	errChannelTokenOwner = errors.New("channel token issued to another subject")
+)
+
+// addChannelGrants validates a channel-scoped token minted by the application backend and records the channels
+// it grants until the token expires. A grant admits the client to a private channel regardless of the scopes of
+// its session token; geographic and step-up restrictions still apply.
+//
+// Tokens naming a subject are only accepted from a connection authenticated as that subject.
+func (c *WsClient) addChannelGrants(token string) error {
+	claims, err := c.manager.validateCached(c.authenticator, token)
+	if err != nil {
+		return fmt.Errorf("invalid channel token: %w", err)
+	}
+	if err := c.manager.checkRevoked(claims); err != nil {
+		return err
+	}
+	list, _ := claims[channelsClaim].([]any)
+	granted := make([]string, 0, len(list))
+	for _, item := range list {
+		if channel, ok := item.(string); ok && channels.ValidateName(channel) == nil {
+			granted = append(granted, channel)
+		}
+	}
+	if len(granted) == 0 {
+		return errNotChannelToken
+	}
+	if subject := subjectOf(claims); subject != "" && subject != subjectOf(c.Claims()) {
+		return errChannelTokenOwner
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return errors.New("channel token without expiry")
+	}
+	c.grantsLock.Lock()
+	defer c.grantsLock.Unlock()
+	for _, channel := range granted {
+		if exp.After(c.grants[channel]) {
+			c.grants[channel] = exp.Time
+		}
+	}
+	c.logger.Info("Channel token accepted", "channels", granted, "expire", exp.Format(time.RFC3339))
+	return nil
+}
+
+// granted reports whether an unexpired channel token admits the client to the channel or pattern.
+func (c *WsClient) granted(channel string) bool {
+	c.grantsLock.Lock()
+	defer c.grantsLock.Unlock()
+	now := time.Now()
+	for pattern, expires := range c.grants {
+		if now.After(expires) {
+			delete(c.grants, pattern)
+			continue
+		}
+		if channels.Match(pattern, channel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"time"
+)
+
+// Clock is the time source of connection lifecycles: keepalive tickers and auth expiry timers.
+//
+// The gateway uses the system clock; simulations substitute a manual clock to drive expiries deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks at a fixed interval until stopped.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer runs a function once unless stopped first.
+type Timer interface {
+	Stop() bool
+}
+
+// systemClock implements Clock with the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// systemTicker adapts time.Ticker to Ticker.
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sort"
+)
+
+// ClusterEndpoint describes an alternative gateway endpoint a client may connect to.
+type ClusterEndpoint struct {
+	URL      string `json:"url"`              // WebSocket URL of the endpoint
+	Region   string `json:"region,omitempty"` // Region serving the endpoint
+	Priority int    `json:"priority"`         // Lower values are preferred
+}
+
+// ClusterInfo is pushed to clients on the sys channel as a "cluster" update.
+//
+// Clients should reconnect to the endpoint with the lowest priority when their current connection drops,
+// which lets operators shift traffic to another region without waiting for DNS propagation.
+type ClusterInfo struct {
+	Endpoints []ClusterEndpoint `json:"endpoints"`
+}
+
+// SetClusterEndpoints replaces the advertised failover endpoints and pushes them to every connected client.
+//
+// Params:
+// - endpoints: The alternative endpoints in any order.
+func (m *ConnectionManager) SetClusterEndpoints(endpoints []ClusterEndpoint) {
+	sorted := make([]ClusterEndpoint, len(endpoints))
+	copy(sorted, endpoints)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+
+	m.Lock()
+	m.clusterInfo = &ClusterInfo{Endpoints: sorted}
+	clients := make([]*WsClient, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	m.Unlock()
+
+	for _, client := range clients {
+		client.sendClusterInfo("")
+	}
+}
+
+// ClusterInfo returns the currently advertised failover endpoints.
+func (m *ConnectionManager) ClusterInfo() *ClusterInfo {
+	m.RLock()
+	defer m.RUnlock()
+	return m.clusterInfo
+}
+
+// sendClusterInfo sends the advertised endpoints to the client, as a response when id is set.
+func (c *WsClient) sendClusterInfo(id string) {
+	info := c.manager.ClusterInfo()
+	if info == nil {
+		if id != "" {
+			c.SendResponse(id, "cluster", "sys", &ClusterInfo{Endpoints: []ClusterEndpoint{}})
+		}
+		return
+	}
+	if id != "" {
+		c.SendResponse(id, "cluster", "sys", info)
+		return
+	}
+	c.SendUpdate("cluster", "sys", info)
+}
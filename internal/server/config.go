@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the listener, timeout and connection settings of the gateway.
+type Config struct {
+	Addr              string        `yaml:"addr"`              // Address the HTTP server listens on
+	Path              string        `yaml:"path"`              // Path of the WebSocket endpoint
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"` // Time limit for reading request headers
+	ReadTimeout       time.Duration `yaml:"readTimeout"`       // Time limit for reading the request body
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`      // Time limit for writing the response
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`       // Maximum idle time of keep-alive HTTP connections
+	PingInterval      time.Duration `yaml:"pingInterval"`      // Interval between pings sent to clients
+	ReadDeadline      time.Duration `yaml:"readDeadline"`      // Time without a frame or pong before a connection is dropped
+	ControlWriteWait  time.Duration `yaml:"controlWriteWait"`  // Time allowed to write a ping or close frame
+	ReadLimit         int64         `yaml:"readLimit"`         // Maximum size in bytes of a message read from a client
+	ReadBufferSize    int           `yaml:"readBufferSize"`    // WebSocket read buffer size in bytes
+	WriteBufferSize   int           `yaml:"writeBufferSize"`   // WebSocket write buffer size in bytes
+	MaxConnections    int           `yaml:"maxConnections"`    // Concurrent connections accepted, 0 for no limit
+	AcceptQueue       int           `yaml:"acceptQueue"`       // Handshakes waiting for a slot at the limit, 0 rejects at once
+	AcceptWait        time.Duration `yaml:"acceptWait"`        // Time a queued handshake waits, 0 for 500ms
+	TLSCertFile       string        `yaml:"tlsCertFile"`       // PEM certificate chain; serves wss:// when set
+	TLSKeyFile        string        `yaml:"tlsKeyFile"`        // PEM private key of the certificate
+	Profile           string        `yaml:"profile"`           // Profile the defaults were taken from
+	OriginPolicy      string        `yaml:"originPolicy"`      // OriginOpen or OriginStrict
+	AllowedOrigins    []string      `yaml:"allowedOrigins"`    // Origins accepted by the strict policy besides the own host
+	AuthCookie        string        `yaml:"authCookie"`        // Cookie carrying the JWT at the handshake, honoured under OriginStrict only
+	AllowInsecure     bool          `yaml:"allowInsecure"`     // Start despite security policy violations, with a warning
+	LogLevel          string        `yaml:"logLevel"`          // debug, info, warn or error
+}
+
+// DefaultConfig returns the settings used when nothing is configured.
+func DefaultConfig() Config {
+	return Config{
+		Addr:              "localhost:3000",
+		Path:              "/ws",
+		ReadHeaderTimeout: 3 * time.Second,
+		ReadTimeout:       1 * time.Second,
+		WriteTimeout:      1 * time.Second,
+		IdleTimeout:       30 * time.Second,
+		PingInterval:      9 * time.Second,
+		ReadDeadline:      100 * time.Second,
+		ControlWriteWait:  5 * time.Second,
+		ReadLimit:         1024 * 1024, // 1MB
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		OriginPolicy:      OriginOpen,
+		AllowInsecure:     true,
+		LogLevel:          "info",
+	}
+}
+
+// LoadConfigFile reads settings from a YAML file. Settings missing from the file keep their value in base.
+//
+// Durations are written as Go duration strings, e.g. "10s".
+//
+// Params:
+// - path: The YAML file.
+// - base: The settings to start from, usually DefaultConfig().
+//
+// Returns:
+// - The merged settings, or an error if the file cannot be read or parsed.
+func LoadConfigFile(path string, base Config) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return base, err
+	}
+	config := base
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return base, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ConfigFromEnv overrides settings with the WSGW_* environment variables that are set.
+//
+// Variables: WSGW_ADDR, WSGW_WS_PATH, WSGW_READ_HEADER_TIMEOUT, WSGW_READ_TIMEOUT, WSGW_WRITE_TIMEOUT,
+// WSGW_IDLE_TIMEOUT, WSGW_PING_INTERVAL, WSGW_READ_DEADLINE, WSGW_CONTROL_WRITE_WAIT (durations such as "10s"),
+// WSGW_READ_LIMIT, WSGW_READ_BUFFER_SIZE, WSGW_WRITE_BUFFER_SIZE, WSGW_MAX_CONNECTIONS, WSGW_ACCEPT_QUEUE,
+// WSGW_ACCEPT_WAIT (a duration), WSGW_TLS_CERT, WSGW_TLS_KEY, WSGW_ORIGIN_POLICY, WSGW_ALLOWED_ORIGINS (comma
+// separated), WSGW_AUTH_COOKIE and WSGW_LOG_LEVEL.
+//
+// Params:
+// - base: The settings to start from.
+//
+// Returns:
+// - The merged settings, and an error joining one error per malformed variable.
+func ConfigFromEnv(base Config) (Config, error) {
+	config := base
+	var problems []error
+	str := func(name string, target *string) {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*target = d
+		}
+	}
+	integer := func(name string, target *int) {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %q is not an integer", name, value))
+				return
+			}
+			*target = n
+		}
+	}
+	str("WSGW_ADDR", &config.Addr)
+	str("WSGW_WS_PATH", &config.Path)
+	duration("WSGW_READ_HEADER_TIMEOUT", &config.ReadHeaderTimeout)
+	duration("WSGW_READ_TIMEOUT", &config.ReadTimeout)
+	duration("WSGW_WRITE_TIMEOUT", &config.WriteTimeout)
+	duration("WSGW_IDLE_TIMEOUT", &config.IdleTimeout)
+	duration("WSGW_PING_INTERVAL", &config.PingInterval)
+	duration("WSGW_READ_DEADLINE", &config.ReadDeadline)
+	duration("WSGW_CONTROL_WRITE_WAIT", &config.ControlWriteWait)
+	readLimit := int(config.ReadLimit)
+	integer("WSGW_READ_LIMIT", &readLimit)
+	config.ReadLimit = int64(readLimit)
+	integer("WSGW_READ_BUFFER_SIZE", &config.ReadBufferSize)
+	integer("WSGW_WRITE_BUFFER_SIZE", &config.WriteBufferSize)
+	integer("WSGW_MAX_CONNECTIONS", &config.MaxConnections)
+	integer("WSGW_ACCEPT_QUEUE", &config.AcceptQueue)
+	duration("WSGW_ACCEPT_WAIT", &config.AcceptWait)
+	str("WSGW_TLS_CERT", &config.TLSCertFile)
+	str("WSGW_TLS_KEY", &config.TLSKeyFile)
+	str("WSGW_ORIGIN_POLICY", &config.OriginPolicy)
+	if value := os.Getenv("WSGW_ALLOWED_ORIGINS"); value != "" {
+		config.AllowedOrigins = strings.Split(value, ",")
+	}
+	str("WSGW_AUTH_COOKIE", &config.AuthCookie)
+	str("WSGW_LOG_LEVEL", &config.LogLevel)
+	return config, errors.Join(problems...)
+}
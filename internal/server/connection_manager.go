@@ -3,10 +3,32 @@ package server
 import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/abuse"
+	"go-websocket-boilerplate/internal/alerting"
+	"go-websocket-boilerplate/internal/analytics"
+	"go-websocket-boilerplate/internal/archive"
+	"go-websocket-boilerplate/internal/broker"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/device"
+	"go-websocket-boilerplate/internal/experiment"
+	"go-websocket-boilerplate/internal/geoip"
+	"go-websocket-boilerplate/internal/ids"
+	"go-websocket-boilerplate/internal/jsonguard"
+	"go-websocket-boilerplate/internal/metering"
+	"go-websocket-boilerplate/internal/noderpc"
+	"go-websocket-boilerplate/internal/partition"
+	"go-websocket-boilerplate/internal/receipts"
+	"go-websocket-boilerplate/internal/revocation"
+	"go-websocket-boilerplate/internal/rooms"
+	"go-websocket-boilerplate/internal/session"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 	"log/slog"
+	"net"
 	"net/http"
-	"strings"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,27 +40,124 @@ type Authenticator interface {
 	ValidateJwt(jwt string) (jwt.MapClaims, error)
 }
 
-// webSocketUpgrader configures the WebSocket upgrader with buffer sizes and a custom origin checker.
+// TenantAuthenticator is implemented by authenticators serving several identity providers.
 //
-// CheckOrigin allows all incoming connections by returning true.
-var webSocketUpgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(_ *http.Request) bool {
-		// Allow all connections
-		return true
-	},
+// Connections to a path below the gateway's Path, e.g. "/ws/acme", validate their tokens with
+// ValidateTenantJwt for the tenant named by the remaining path; other connections use ValidateJwt.
+type TenantAuthenticator interface {
+	Authenticator
+	ValidateTenantJwt(tenant string, jwt string) (jwt.MapClaims, error)
+}
+
+// MessageSigner defines an interface for signing server-originated messages.
+//
+// Sign returns a detached JWS over the payload and ServeHTTP publishes the verification keys as a JWKS document.
+type MessageSigner interface {
+	Sign(payload []byte) (string, error)
+	http.Handler
+}
+
+// newUpgrader configures the WebSocket upgrader with the configured buffer sizes and origin policy.
+//
+// The open policy allows all incoming connections; the strict one only browsers on the endpoint's own host or an
+// allowed origin.
+func newUpgrader(config Config) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  config.ReadBufferSize,
+		WriteBufferSize: config.WriteBufferSize,
+		CheckOrigin:     checkOrigin(config),
+	}
 }
 
 // ConnectionManager manages the active WebSocket clients, their connection handlers, and JWT authentication.
 //
 // It stores connected clients, handles new connections, and manages client disconnections.
 type ConnectionManager struct {
-	clients                 map[int]*WsClient       // Map of connected clients identified by an ID
-	sync.RWMutex                                    // Mutex for safely handling client operations
-	nextClientID            int                     // The ID for the next client connection
-	clientConnectionHandler ClientConnectionHandler // Interface for handling client connection events
-	authenticator           Authenticator           // Interface for validating client JWT tokens
+	clients                 map[int]*WsClient            // Map of connected clients identified by an ID
+	sync.RWMutex                                         // Mutex for safely handling client operations
+	nextClientID            int                          // The ID for the next client connection
+	clientConnectionHandler ClientConnectionHandler      // Interface for handling client connection events
+	authenticator           Authenticator                // Interface for validating client JWT tokens
+	observers               map[string]map[int]*WsClient // Impersonating agents keyed by the observed subject
+	impersonations          map[int]string               // Observed subject keyed by the agent's client ID
+	signer                  MessageSigner                // Optional signer for outgoing messages
+	replayChannels          map[string]bool              // Channels requiring a nonce and timestamp
+	replayWindow            time.Duration                // Accepted clock skew and nonce retention for replay checks
+	clusterInfo             *ClusterInfo                 // Failover endpoints advertised to clients
+	sessions                session.Store                // Store backing resume tokens and replay cursors
+	resumeBuffer            session.Buffer               // Frames replayed to resuming clients, optional
+	resumeBufferTTL         time.Duration                // Time the frames of a disconnected session are kept
+	subscribers             map[string]map[int]*WsClient // Subscribed clients keyed by channel
+	patterns                map[string]map[int]*WsClient // Subscribed clients keyed by channel pattern
+	userSubscriptions       map[string]int               // Subscription count keyed by subject
+	subscriptionLimits      SubscriptionLimits           // Per-client and per-user subscription quotas
+	activation              map[string]ChannelHooks      // Lazy activation hooks keyed by channel
+	registry                *channels.Registry           // Declared channels and their properties
+	history                 map[string][]*EgressMsg      // Recent updates of history channels
+	conflated               map[string]*conflatedUpdate  // Pending updates of conflated channels
+	dmAuthorizer            DirectMessageAuthorizer      // Optional policy for direct messages
+	blockChecker            BlockChecker                 // Optional block list consulted on client-originated delivery
+	geoResolver             geoip.Resolver               // Optional GeoIP lookup at connect time
+	abuseDetector           abuse.Detector               // Optional bot and abuse detector
+	challengeChannel        string                       // Channel abuse challenges are sent on
+	bans                    map[string]time.Time         // Banned subjects and IP addresses with ban expiry
+	jsonLimits              jsonguard.Limits             // Structural limits applied to client messages
+	ingressQueueSize        int                          // Messages buffered between the read loop and the handler
+	ingressShedPolicy       ShedPolicy                   // Policy applied when the ingress queue is full
+	egressQueueSize         int                          // Messages buffered between the senders and the write loop
+	egressPolicy            EgressPolicy                 // Policy applied when the egress queue is full
+	egressTimeout           time.Duration                // Time EgressBlock waits for room, 0 until the client closes
+	middlewares             []Middleware                 // Ingress middlewares, outermost first
+	ingressChain            MsgFunc                      // Middlewares wrapped around enqueueing for the handler
+	enrichment              *claimsEnrichment            // Adds claims to validated tokens, optional
+	authCache               *authCache                   // Claims of recently validated tokens, optional
+	revocations             revocation.Store             // Revoked tokens and subjects, optional
+	tracer                  trace.Tracer                 // Traces ingress messages, optional
+	channelKeys             map[string]*channelKey       // Current keys of encrypted channels with subscribers
+	broker                  broker.Interface             // Relays updates between cluster nodes, optional
+	bridge                  Bridge                       // Forwards messages to backend services, optional
+	bridgeChannels          []string                     // Channels and patterns forwarded to the bridge
+	nodeID                  string                       // Identifier of this gateway node reported to clients
+	ids                     ids.Generator                // Generates server-originated message IDs and resume tokens
+	maintenance             maintenance                  // Maintenance mode state
+	draining                atomic.Bool                  // Set once the node started draining for termination
+	presence                map[string]int               // Active connections per subject
+	bySubject               map[string]map[int]*WsClient // Authenticated clients keyed by subject
+	maxPerUser              int                          // Connections allowed per subject, 0 for no limit
+	duplicatePolicy         DuplicatePolicy              // Handling of connections beyond maxPerUser
+	load                    loadMeter                    // Message counts and rates for autoscaling
+	rateLimits              RateLimits                   // Ingress limits of every connection
+	rateLimitedMessages     atomic.Int64                 // Messages dropped by rate limits
+	rateLimitedClients      atomic.Int64                 // Clients that exceeded their rate limits
+	rateLimitDisconnects    atomic.Int64                 // Clients disconnected after repeated breaches
+	partitions              *partition.Ring              // Assignment of users to nodes, nil if not partitioned
+	misrouted               atomic.Int64                 // Clients connected to a node not owning their user
+	slots                   chan struct{}                // Connection slots, nil without a connection limit
+	acceptWaiting           atomic.Int64                 // Handshakes waiting in the accept queue
+	connectionsRejected     atomic.Int64                 // Handshakes refused by the connection limit
+	peers                   *noderpc.Peers               // Other nodes reachable over RPC, nil without node RPC
+	rpcServer               *grpc.Server                 // Serves the other nodes' direct messages
+	maxMalformedFrames      int                          // Consecutive malformed frames before disconnecting
+	autoscaleTargets        AutoscaleTargets             // Load one replica is sized for
+	drainGrace              time.Duration                // Default time a drain waits for clients to leave
+	drainLoopback           bool                         // Drains from the loopback interface need no admin token
+	experiments             experiment.Provider          // Assigns experiment variants to new connections, optional
+	analytics               analytics.Sink               // Receives usage events, optional
+	stateHook               StateChangeHook              // Called after every lifecycle transition, optional
+	stateTransitions        stateCounters                // Transitions into each lifecycle state
+	archive                 archive.Sink                 // Receives sampled egress frames, optional
+	auditSampling           AuditSampling                // Sample rates of egress frames archived
+	meter                   *metering.Meter              // Counts billable usage per tenant, optional
+	slaMonitor              *alerting.Monitor            // Raises alerts when service levels degrade, optional
+	moderation              []roomModeration             // Moderators of client publishes by channel or pattern
+	receipts                *receipts.Tracker            // Tracks delivery state and read markers, optional
+	downsampler             downsampler                  // Open buckets of downsampled metric channels
+	memoryCap               int                          // Approximate memory in bytes a connection may hold before its buffers are trimmed
+	rooms                   *rooms.Hub                   // Room memberships of the connections
+	roomAuthorizer          RoomAuthorizer               // Optional policy deciding who may join a room
+	config                  Config                       // Timeouts and limits applied to connections
+	upgrader                websocket.Upgrader           // Upgrades HTTP requests to WebSocket connections
+	clock                   Clock                        // Time source of keepalives and auth expiry
 }
 
 // ClientConnectionHandler defines an interface for handling client connections.
@@ -53,15 +172,48 @@ type ClientConnectionHandler interface {
 // Params:
 // - clientConnected: The handler responsible for managing connected clients.
 // - authorize: The authenticator responsible for validating JWT tokens.
+// - config: The timeouts and limits applied to connections.
 //
 // Returns:
 // - A pointer to the initialized ConnectionManager.
-func NewConnectionManager(clientConnected ClientConnectionHandler, authorize Authenticator) *ConnectionManager {
+func NewConnectionManager(clientConnected ClientConnectionHandler, authorize Authenticator, config Config) *ConnectionManager {
 	return &ConnectionManager{
 		clients:                 make(map[int]*WsClient),
 		nextClientID:            0,
 		clientConnectionHandler: clientConnected,
 		authenticator:           authorize,
+		observers:               make(map[string]map[int]*WsClient),
+		impersonations:          make(map[int]string),
+		replayChannels:          make(map[string]bool),
+		replayWindow:            30 * time.Second,
+		sessions:                session.NewMemoryStore(),
+		resumeBufferTTL:         defaultResumeBufferTTL,
+		drainGrace:              defaultDrainGrace,
+		presence:                make(map[string]int),
+		bySubject:               make(map[string]map[int]*WsClient),
+		autoscaleTargets:        defaultAutoscaleTargets,
+		subscribers:             make(map[string]map[int]*WsClient),
+		patterns:                make(map[string]map[int]*WsClient),
+		userSubscriptions:       make(map[string]int),
+		channelKeys:             make(map[string]*channelKey),
+		activation:              make(map[string]ChannelHooks),
+		registry:                channels.NewRegistry(false),
+		history:                 make(map[string][]*EgressMsg),
+		conflated:               make(map[string]*conflatedUpdate),
+		challengeChannel:        "challenge",
+		bans:                    make(map[string]time.Time),
+		jsonLimits:              jsonguard.DefaultLimits,
+		ingressQueueSize:        defaultIngressQueueSize,
+		egressQueueSize:         defaultEgressQueueSize,
+		ingressChain:            enqueueMsg,
+		nodeID:                  defaultNodeID(),
+		ids:                     ids.UUIDv7{},
+		downsampler:             downsampler{buckets: make(map[bucketKey]*bucket)},
+		rooms:                   rooms.NewHub(0),
+		config:                  config,
+		upgrader:                newUpgrader(config),
+		slots:                   newConnectionSlots(config),
+		clock:                   systemClock{},
 	}
 }
 
@@ -75,41 +227,105 @@ func (m *ConnectionManager) addClient(client *WsClient) {
 	m.clients[client.ID()] = client
 }
 
+// ClientCount returns the number of connected clients.
+func (m *ConnectionManager) ClientCount() int {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.clients)
+}
+
 // removeClient removes a WebSocket client from the connection manager and closes the connection.
 //
 // Params:
 // - client: A pointer to the WsClient that is being removed.
 func (m *ConnectionManager) removeClient(client *WsClient) {
+	if m.abuseDetector != nil {
+		m.abuseDetector.Observe(abuse.Event{Kind: abuse.Disconnect, ClientID: client.ID(), IP: client.ip, Time: time.Now()})
+	}
+	m.stopImpersonation(client)
+	m.unsubscribeAll(client)
+	m.leaveRooms(client)
+	var offline string // Subject whose last connection left, announced once the lock is released
+	defer func() {
+		if offline != "" {
+			m.announcePresence(offline, false)
+		}
+	}()
 	m.Lock()
 	defer m.Unlock()
 
 	if _, ok := m.clients[client.ID()]; ok {
+		offline = m.leavePresenceLocked(client)
+		m.unindexSubjectLocked(client)
+		m.trackDisconnect(client)
+		if m.meter != nil {
+			m.meter.Disconnected(client.ID())
+		}
+		if client.revoked.Load() {
+			client.deleteSession() // A revoked token must not be resumed
+		} else {
+			client.saveSession() // Keep the replay cursor for a later resume
+		}
 		client.Close()                 // Close the WebSocket connection
 		delete(m.clients, client.ID()) // Remove the client from the list
+		if client.slot {
+			m.releaseSlot()
+		}
+	}
+}
+
+// locate resolves the geographic origin of the request's remote address, if a GeoIP resolver is configured.
+func (m *ConnectionManager) locate(r *http.Request) *geoip.Location {
+	if m.geoResolver == nil {
+		return nil
+	}
+	host := remoteIP(r)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	location, err := m.geoResolver.Lookup(ip)
+	if err != nil {
+		slog.Info("GeoIP lookup failed", "ip", host, "error", err)
+		return nil
 	}
+	return location
 }
 
 // ServeWs handles incoming WebSocket connection requests.
 //
 // It upgrades an HTTP connection to a WebSocket connection, validates the client's JWT token, and adds the client to the connection manager.
+// The token is taken from the Authorization header, the "token" query parameter or, under the strict origin
+// policy, the cookie named by Config.AuthCookie, in that order.
 //
 // Params:
 // - w: The HTTP ResponseWriter used to send responses.
 // - r: The HTTP request containing the connection details.
 func (m *ConnectionManager) ServeWs(w http.ResponseWriter, r *http.Request) {
+	if !m.acquireSlot(r.Context()) {
+		slog.Warn("Connection rejected, limit reached.", "maxConnections", m.config.MaxConnections)
+		m.rejectOverCapacity(w)
+		return
+	}
+	admitted := false // Set once the client holds the slot, which it frees when removed
+	defer func() {
+		if !admitted {
+			m.releaseSlot()
+		}
+	}()
 	m.nextClientID++
 	log := slog.Default().With("conID", m.nextClientID) // Create a new logger with connection ID
 	log.Info("New connection received.")
-	authHeader := r.Header.Get("Authorization") // Retrieve the Authorization header
-	var user jwt.MapClaims = nil                // Placeholder for the user's JWT claims
-	var expire int64 = 0                        // Placeholder for the token expiration time
-
-	// Validate the JWT token if Authorization header is present
-	if authHeader != "" {
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 {
+	token, source, err := m.handshakeToken(r) // Token of the Authorization header, query parameter or cookie
+	authenticator := m.authenticatorFor(r)    // Authenticator of the tenant named by the path, if any
+	var user jwt.MapClaims = nil              // Placeholder for the user's JWT claims
+	var expire int64 = 0                      // Placeholder for the token expiration time
+
+	// Validate the JWT token if the handshake carries one
+	if err != nil || token != "" {
+		if err != nil {
 			// JWT token is not properly formatted
-			log.Info("Authorize failed.")
+			log.Info("Authorize failed.", "error", err)
 			w.WriteHeader(http.StatusUnauthorized)
 			_, err := w.Write([]byte("Authorize failed."))
 			if err != nil {
@@ -117,10 +333,14 @@ func (m *ConnectionManager) ServeWs(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
-		claims, err := m.authenticator.ValidateJwt(parts[1]) // Validate the token
+		claims, err := m.validateToken(authenticator, token) // Validate the token
+		if err == nil {
+			expire, err = connectionExpiry(claims)
+		}
 		if err != nil {
 			// Token validation failed
-			log.Info("Authorize failed.")
+			log.Info("Authorize failed.", "source", source, "error", err)
+			m.slaAuthFailure()
 			w.WriteHeader(http.StatusUnauthorized)
 			_, err := w.Write([]byte("Authorize failed."))
 			if err != nil {
@@ -129,14 +349,74 @@ func (m *ConnectionManager) ServeWs(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		user = claims // Store validated JWT claims
-		exp, _ := claims.GetExpirationTime()
-		expire = exp.Unix()
-		log.Info("Authorize succeeded.", "expire", time.Unix(expire, 0).Format(time.RFC3339)) // Log token expiration time
+		log.Info("Authorize succeeded.", "source", source, "expire", time.Unix(expire, 0).Format(time.RFC3339))
+	}
+
+	// Reject new connections during maintenance
+	if notice := m.Maintenance(); notice != nil {
+		log.Info("Connection rejected during maintenance.")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if _, err := w.Write([]byte(notice.Message)); err != nil {
+			log.Info("Failed to write response", "error", err)
+		}
+		return
+	}
+
+	// Resume a stored session
+	var resumed *session.Session
+	if user == nil {
+		if token := r.URL.Query().Get("resume"); token != "" {
+			if resumed = m.resume(token); resumed != nil {
+				user, expire = resumed.Claims, resumed.Expire
+				log.Info("Session resumed.", "expire", time.Unix(expire, 0).Format(time.RFC3339))
+			}
+		}
+	}
+
+	// Reject sessions resumed with a token revoked since they were stored
+	if resumed != nil {
+		if err := m.checkRevoked(resumed.Claims); err != nil {
+			log.Info("Resume rejected.", "error", err)
+			m.deleteSession(resumed.Token)
+			w.WriteHeader(http.StatusUnauthorized)
+			if _, err := w.Write([]byte("Authorize failed.")); err != nil {
+				log.Info("Failed to write response", "error", err)
+			}
+			return
+		}
+	}
+
+	// Reject banned clients, including those resuming a session
+	if m.isBanned(subjectOf(user), remoteIP(r)) {
+		log.Info("Banned client rejected.")
+		w.WriteHeader(http.StatusForbidden)
+		if _, err := w.Write([]byte("Forbidden.")); err != nil {
+			log.Info("Failed to write response", "error", err)
+		}
+		return
 	}
 
 	// Create a new WebSocket client and upgrade the connection
-	wsClient := NewClient(m.nextClientID, m, user, m.authenticator, expire)
-	conn, err := webSocketUpgrader.Upgrade(w, r, nil) // Upgrade the connection to WebSocket
+	wsClient := NewClient(m.nextClientID, m, user, authenticator, expire, m.config)
+	if resumed != nil {
+		wsClient.resumeToken = resumed.Token
+		wsClient.seq.Store(resumed.Cursor)
+		wsClient.resumed = true
+		wsClient.lastSeq, _ = strconv.ParseUint(r.URL.Query().Get("lastSeq"), 10, 64)
+	}
+	wsClient.ip = remoteIP(r)
+	if tenant, ok := authenticator.(tenantAuthenticator); ok {
+		wsClient.pathTenant = tenant.tenant
+	}
+	wsClient.userAgent = r.UserAgent()
+	wsClient.device = device.Classify(wsClient.userAgent)
+	wsClient.logger = wsClient.logger.With("device", wsClient.device)
+	if location := m.locate(r); location != nil {
+		wsClient.location = *location
+		wsClient.logger = wsClient.logger.With("country", location.Country)
+	}
+	m.assignExperiments(wsClient, resumed)
+	conn, err := m.upgrader.Upgrade(w, r, nil) // Upgrade the connection to WebSocket
 	if err != nil {
 		// WebSocket upgrade failed
 		log.Error("Websocket upgrade error", "error", err)
@@ -148,8 +428,16 @@ func (m *ConnectionManager) ServeWs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set the WebSocket connection for the client and start handling messages
+	wsClient.slot = m.slots != nil
+	admitted = true
+	m.accept(wsClient, conn)
+}
+
+// accept sets the client's connection, registers the client and starts handling its messages.
+func (m *ConnectionManager) accept(wsClient *WsClient, conn transport) {
 	wsClient.connection = conn
 	m.addClient(wsClient)
+	wsClient.observe(abuse.Connect, "", "", 0)
+	m.trackConnect(wsClient)
 	wsClient.Start() // Start handling WebSocket communication
 }
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// DirectMessage is the data of an update delivered to a client by SendToClient or SendToUser.
+type DirectMessage struct {
+	From      string `json:"from"`      // Subject of the sender
+	FromConID int    `json:"fromConID"` // Connection ID of the sender
+	Data      any    `json:"data"`      // Application payload
+}
+
+// DirectMessageAuthorizer decides whether a sender may message a recipient.
+type DirectMessageAuthorizer func(from jwt.MapClaims, to jwt.MapClaims) bool
+
+// BlockChecker reports whether the recipient has blocked the sender.
+type BlockChecker interface {
+	IsBlocked(recipient string, sender string) bool
+}
+
+// BlockList is a BlockChecker clients can manage themselves with sys/block and sys/unblock.
+type BlockList interface {
+	BlockChecker
+	Block(user string, target string) error
+	Unblock(user string, target string) error
+}
+
+// BlockMsg is the payload of sys/block and sys/unblock requests.
+type BlockMsg struct {
+	Subject string `json:"sub"` // Subject of the user to block or unblock
+}
+
+// authorizeDirect verifies that the sender may message the recipient.
+func (m *ConnectionManager) authorizeDirect(from *WsClient, to *WsClient) error {
+	if !from.isAuthenticated() {
+		return fmt.Errorf("sender not authenticated: %w", handler.ErrPermissionDenied)
+	}
+	return m.authorizeDirectFrom(from.Claims(), to)
+}
+
+// authorizeDirectFrom verifies that the authenticated sender with the claims, possibly connected to another node,
+// may message the recipient.
+func (m *ConnectionManager) authorizeDirectFrom(from jwt.MapClaims, to *WsClient) error {
+	if m.blockChecker != nil && m.blockChecker.IsBlocked(subjectOf(to.Claims()), subjectOf(from)) {
+		return fmt.Errorf("recipient blocked sender: %w", handler.ErrPermissionDenied)
+	}
+	if m.dmAuthorizer != nil && !m.dmAuthorizer(from, to.Claims()) {
+		return handler.ErrPermissionDenied
+	}
+	return nil
+}
+
+// sendDirect delivers a direct message to the recipients the sender is allowed to reach.
+func (m *ConnectionManager) sendDirect(from *WsClient, recipients []*WsClient, updateType string, channel string, data any, cause cause) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("recipient not connected: %w", handler.ErrNotFound)
+	}
+	msg := &DirectMessage{From: subjectOf(from.Claims()), FromConID: from.ID(), Data: data}
+	var lastErr error
+	delivered := 0
+	for _, to := range recipients {
+		if err := m.authorizeDirect(from, to); err != nil {
+			lastErr = err
+			continue
+		}
+		to.send(cause.stamp(NewEgressMsg("", updateType, channel, msg)))
+		delivered++
+	}
+	if delivered == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// SendToClient sends a direct message to a single connection.
+func (c *WsClient) SendToClient(clientID int, updateType string, channel string, data any) error {
+	return c.sendToClient(clientID, updateType, channel, data, cause{})
+}
+
+// sendToClient sends a direct message to a single connection, stamped with its cause.
+func (c *WsClient) sendToClient(clientID int, updateType string, channel string, data any, cause cause) error {
+	c.manager.RLock()
+	to, ok := c.manager.clients[clientID]
+	c.manager.RUnlock()
+	if !ok {
+		return c.manager.sendDirect(c, nil, updateType, channel, data, cause)
+	}
+	return c.manager.sendDirect(c, []*WsClient{to}, updateType, channel, data, cause)
+}
+
+// SendToUser sends a direct message to every connection of the subject. If users are partitioned and node RPC is
+// set, the connections on the node owning the subject receive it as well.
+func (c *WsClient) SendToUser(subject string, updateType string, channel string, data any) error {
+	return c.sendToUser(subject, updateType, channel, data, cause{})
+}
+
+// sendToUser sends a direct message to every connection of the subject, stamped with its cause.
+func (c *WsClient) sendToUser(subject string, updateType string, channel string, data any, cause cause) error {
+	c.manager.RLock()
+	recipients := c.manager.clientsBySubjectLocked(subject)
+	c.manager.RUnlock()
+	if !c.isAuthenticated() {
+		return c.manager.sendDirect(c, recipients, updateType, channel, data, cause)
+	}
+	sent, remoteErr := c.sendToOwner(subject, updateType, channel, data, cause)
+	if !sent {
+		return c.manager.sendDirect(c, recipients, updateType, channel, data, cause)
+	}
+	if len(recipients) == 0 {
+		return remoteErr
+	}
+	if err := c.manager.sendDirect(c, recipients, updateType, channel, data, cause); err != nil && remoteErr != nil {
+		return err
+	}
+	return nil
+}
+
+// handleBlockMsg processes sys/block and sys/unblock requests for the client's own block list.
+func (c *WsClient) handleBlockMsg(request IngressMsg) {
+	blocks, ok := c.manager.blockChecker.(BlockList)
+	user := subjectOf(c.Claims())
+	if !ok || user == "" {
+		c.sendError(request, msgs.CodeUnavailable, "block list not available")
+		return
+	}
+	msg := &BlockMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil || msg.Subject == "" {
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	var err error
+	if request.Type() == "block" {
+		err = blocks.Block(user, msg.Subject)
+	} else {
+		err = blocks.Unblock(user, msg.Subject)
+	}
+	if err != nil {
+		c.logger.Error("Failed to update block list", "error", err)
+		c.sendError(request, msgs.CodeInternal, "block list update failed")
+		return
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), msg)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/channels"
+	"math"
+	"sync"
+	"time"
+)
+
+// minResolution is the finest resolution subscribers may request for metric channels.
+const minResolution = 100 * time.Millisecond
+
+// FieldStats summarizes the values of a numeric field within a bucket.
+type FieldStats struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+// MetricBucket is the data of a "bucket" update aggregating the numeric updates of a metric channel over one
+// interval. Numeric payloads are reported under the field "value".
+type MetricBucket struct {
+	Start  int64                 `json:"start"` // Start of the interval in Unix milliseconds
+	End    int64                 `json:"end"`   // End of the interval in Unix milliseconds
+	Count  int                   `json:"count"` // Number of updates aggregated
+	Fields map[string]FieldStats `json:"fields"`
+}
+
+// bucketKey identifies the aggregation of a channel at a resolution.
+type bucketKey struct {
+	channel    string
+	resolution time.Duration
+}
+
+// bucket accumulates the updates of one interval.
+type bucket struct {
+	start  time.Time
+	count  int
+	sums   map[string]float64
+	fields map[string]FieldStats
+}
+
+// downsampler holds the open buckets of metric channels.
+type downsampler struct {
+	sync.Mutex
+	buckets map[bucketKey]*bucket
+}
+
+// numericFields extracts the numeric values of an update: a number becomes the field "value", objects contribute
+// their top-level numeric fields.
+func numericFields(data any) map[string]float64 {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil
+	}
+	switch v := decoded.(type) {
+	case float64:
+		return map[string]float64{"value": v}
+	case map[string]any:
+		fields := make(map[string]float64)
+		for name, value := range v {
+			if number, ok := value.(float64); ok {
+				fields[name] = number
+			}
+		}
+		return fields
+	}
+	return nil
+}
+
+// resolutionFor returns the resolution the client requested for the channel, zero for raw updates.
+func (m *ConnectionManager) resolutionFor(client *WsClient, channel string) time.Duration {
+	m.RLock()
+	defer m.RUnlock()
+	for subscribed, resolution := range client.resolutions {
+		if subscribed == channel || (channels.IsPattern(subscribed) && channels.Match(subscribed, channel)) {
+			return resolution
+		}
+	}
+	return 0
+}
+
+// downsample adds the update to the open bucket of the channel at the resolution, opening a bucket that is
+// delivered to the subscribers at that resolution when its interval ends.
+func (m *ConnectionManager) downsample(channel string, resolution time.Duration, data any) {
+	fields := numericFields(data)
+	if len(fields) == 0 {
+		return
+	}
+	key := bucketKey{channel: channel, resolution: resolution}
+	m.downsampler.Lock()
+	defer m.downsampler.Unlock()
+	b := m.downsampler.buckets[key]
+	if b == nil {
+		b = &bucket{start: time.Now(), sums: make(map[string]float64), fields: make(map[string]FieldStats)}
+		m.downsampler.buckets[key] = b
+		time.AfterFunc(resolution, func() { m.flushBucket(key) })
+	}
+	b.count++
+	for name, value := range fields {
+		stats, seen := b.fields[name]
+		if !seen {
+			stats = FieldStats{Min: value, Max: value}
+		}
+		stats.Min, stats.Max = math.Min(stats.Min, value), math.Max(stats.Max, value)
+		b.sums[name] += value
+		b.fields[name] = stats
+	}
+}
+
+// flushBucket closes the bucket and sends it to the subscribers at its resolution.
+func (m *ConnectionManager) flushBucket(key bucketKey) {
+	m.downsampler.Lock()
+	b := m.downsampler.buckets[key]
+	delete(m.downsampler.buckets, key)
+	m.downsampler.Unlock()
+	if b == nil {
+		return
+	}
+	summary := &MetricBucket{Start: b.start.UnixMilli(), End: time.Now().UnixMilli(), Count: b.count, Fields: b.fields}
+	for name, stats := range summary.Fields {
+		stats.Avg = b.sums[name] / float64(b.count)
+		summary.Fields[name] = stats
+	}
+	msg := NewEgressMsg("", "bucket", key.channel, summary)
+	msg.MessageID = m.ids.NewID()
+	for _, client := range m.Subscribers(key.channel) {
+		if m.resolutionFor(client, key.channel) == key.resolution {
+			client.sendLive(msg)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// drainMessage is shown to clients and rejected connections while the node drains.
+const drainMessage = "Server restarting."
+
+// defaultDrainGrace is how long a drain waits for clients to leave when no grace period is configured. It stays
+// below the default Kubernetes termination grace period of 30 seconds.
+const defaultDrainGrace = 25 * time.Second
+
+// drainBatches is the number of batches connected clients are asked to reconnect in, spreading their reconnects
+// over the first half of the grace period so the remaining nodes are not hit all at once.
+const drainBatches = 10
+
+// DrainReport is the response of /drain.
+type DrainReport struct {
+	Remaining int `json:"remaining"` // Clients still connected when the grace period ended
+}
+
+// Drain prepares the node for termination. New connections are rejected and readiness fails, connected clients
+// are notified and asked to reconnect in batches, resuming their sessions on another node, and Drain waits until
+// all clients left or the grace period ended. Draining twice only waits.
+//
+// Params:
+// - grace: The time the node may take to drain; 0 uses the configured grace period.
+//
+// Returns:
+// - The number of clients still connected when the grace period ended.
+func (m *ConnectionManager) Drain(grace time.Duration) int {
+	if grace <= 0 {
+		grace = m.drainGrace
+	}
+	deadline := time.Now().Add(grace)
+	if m.draining.CompareAndSwap(false, true) {
+		slog.Info("Draining connections", "clients", m.ClientCount(), "grace", grace)
+		m.StartMaintenance(drainMessage, grace)
+		clients := m.connectedClients()
+		batch := (len(clients) + drainBatches - 1) / drainBatches
+		for start := 0; start < len(clients); start += batch {
+			m.closeClients(clients[start:min(start+batch, len(clients))], websocket.CloseServiceRestart, drainMessage)
+			time.Sleep(grace / 2 / drainBatches)
+		}
+	}
+	for m.ClientCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	remaining := m.ClientCount()
+	slog.Info("Drain finished", "remaining", remaining)
+	return remaining
+}
+
+// serveDrain drains the node for a Kubernetes preStop hook and responds once it is drained. It requires an admin
+// token unless loopback drains are allowed and the request comes from the loopback interface.
+func (m *ConnectionManager) serveDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if ip := net.ParseIP(remoteIP(r)); !m.drainLoopback || ip == nil || !ip.IsLoopback() {
+		if !m.authorizeAdmin(w, r) {
+			return
+		}
+	}
+	var grace time.Duration
+	if value := r.URL.Query().Get("graceSeconds"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			http.Error(w, "graceSeconds must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		grace = time.Duration(seconds) * time.Second
+	}
+	report := DrainReport{Remaining: m.Drain(grace)}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("Failed to write drain report", "error", err)
+	}
+}
+
+// serveReady reports readiness for traffic: it fails while the node drains or is in maintenance, so load
+// balancers stop routing new connections to it.
+func (m *ConnectionManager) serveReady(w http.ResponseWriter, _ *http.Request) {
+	if m.draining.Load() || m.Maintenance() != nil {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
+
+// SetDrainGrace sets how long a drain waits for clients to leave by default. Keep it below the termination grace
+// period of the orchestrator, e.g. terminationGracePeriodSeconds in Kubernetes.
+//
+// Params:
+// - grace: The drain grace period.
+func (gw *WsGw) SetDrainGrace(grace time.Duration) {
+	gw.drainGrace = grace
+}
+
+// SetLoopbackDrain allows /drain requests from the loopback interface without an admin token, so a preStop
+// hook inside the pod needs no credentials. Keep it off when a sidecar, ingress or port-forward proxies requests
+// from the loopback interface, as every caller would then be trusted.
+//
+// Params:
+// - allow: Whether loopback requests may drain without a token.
+func (gw *WsGw) SetLoopbackDrain(allow bool) {
+	gw.drainLoopback = allow
+}
+
+// Drain drains the node and stops the HTTP server, for a graceful shutdown on SIGTERM. It does nothing before
+// Start.
+//
+// Params:
+// - grace: The time the node may take to drain; 0 uses the configured grace period.
+func (gw *WsGw) Drain(grace time.Duration) {
+	if gw.manager == nil {
+		return
+	}
+	gw.manager.Drain(grace)
+	if gw.manager.rpcServer != nil {
+		gw.manager.rpcServer.GracefulStop()
+	}
+	if gw.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := gw.server.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server shutdown failed", "error", err)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+	"time"
+)
+
+// EditMsg is the payload of sys/edit and sys/delete requests.
+type EditMsg struct {
+	Channel string          `json:"ch"`             // Channel the message was published on
+	MsgID   string          `json:"msgId"`          // Server-assigned ID of the message
+	Data    json.RawMessage `json:"data,omitempty"` // New payload for edits
+}
+
+// MessageEdited is the data of the "edit" update sent to the subscribers of a channel when a message changed.
+type MessageEdited struct {
+	MsgID    string          `json:"msgId"`
+	Data     json.RawMessage `json:"data"`
+	EditedBy string          `json:"editedBy,omitempty"` // Subject of the editor, empty for the server
+	Edited   int64           `json:"edited"`             // Time of the edit in Unix milliseconds
+}
+
+// MessageDeleted is the data of the "delete" update sent to the subscribers of a channel when a message was removed.
+type MessageDeleted struct {
+	MsgID     string `json:"msgId"`
+	DeletedBy string `json:"deletedBy,omitempty"` // Subject of the deleter, empty for the server
+	Deleted   int64  `json:"deleted"`             // Time of the deletion in Unix milliseconds
+}
+
+// changeMessage edits or deletes a message kept in the channel history and announces the change to the
+// subscribers. Only messages of channels with history can be changed; replays afterwards carry the edited payload
+// with the edited flag, or a tombstone for deleted messages.
+//
+// Params:
+// - editor: The client changing the message, or nil for the server. Clients may change their own messages;
+// admins may change any message.
+// - channel: The channel of the message.
+// - msgID: The server-assigned message ID.
+// - data: The new payload, or nil to delete the message.
+// - cause: The cause stamped on the announcement.
+func (m *ConnectionManager) changeMessage(editor *WsClient, channel string, msgID string, data json.RawMessage, cause cause) error {
+	editorSubject := ""
+	if editor != nil {
+		editorSubject = subjectOf(editor.Claims())
+	}
+	now := time.Now().UnixMilli()
+
+	m.Lock()
+	history := m.history[channel]
+	index := -1
+	for i, msg := range history {
+		if msg.MessageID == msgID && !msg.Deleted {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		m.Unlock()
+		return fmt.Errorf("message %s on %s: %w", msgID, channel, handler.ErrNotFound)
+	}
+	original := history[index]
+	if editor != nil && (editorSubject == "" || editorSubject != original.originSubject) &&
+		!channels.HasScope(editor.Claims(), adminScope) {
+		m.Unlock()
+		return handler.ErrPermissionDenied
+	}
+	// Queued copies of the original may still be written, so the history entry is replaced rather than modified.
+	changed := *original
+	if data == nil {
+		changed.Data = nil
+		changed.Deleted = true
+	} else {
+		changed.Data = data
+		changed.Edited = now
+	}
+	history[index] = &changed
+	m.Unlock()
+
+	var notice *EgressMsg
+	if data == nil {
+		notice = NewEgressMsg("", "delete", channel, &MessageDeleted{MsgID: msgID, DeletedBy: editorSubject, Deleted: now})
+	} else {
+		notice = NewEgressMsg("", "edit", channel, &MessageEdited{MsgID: msgID, Data: data, EditedBy: editorSubject, Edited: now})
+	}
+	notice = cause.stamp(notice)
+	notice.MessageID = m.ids.NewID()
+	for _, client := range m.Subscribers(channel) {
+		client.sendLive(notice)
+	}
+	return nil
+}
+
+// EditMessage replaces the payload of a message in the channel history on behalf of the server.
+func (m *ConnectionManager) EditMessage(channel string, msgID string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return m.changeMessage(nil, channel, msgID, payload, cause{})
+}
+
+// DeleteMessage replaces a message in the channel history with a tombstone on behalf of the server.
+func (m *ConnectionManager) DeleteMessage(channel string, msgID string) error {
+	return m.changeMessage(nil, channel, msgID, nil, cause{})
+}
+
+// EditMessage replaces the payload of one of the client's messages.
+func (c *WsClient) EditMessage(channel string, msgID string, data any) error {
+	return c.editMessage(channel, msgID, data, cause{})
+}
+
+// DeleteMessage deletes one of the client's messages.
+func (c *WsClient) DeleteMessage(channel string, msgID string) error {
+	return c.manager.changeMessage(c, channel, msgID, nil, cause{})
+}
+
+// editMessage replaces the payload of one of the client's messages, stamping the announcement with its cause.
+func (c *WsClient) editMessage(channel string, msgID string, data any, cause cause) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if string(payload) == "null" {
+		return errors.New("edit requires a payload")
+	}
+	return c.manager.changeMessage(c, channel, msgID, payload, cause)
+}
+
+// handleEditMsg processes sys/edit and sys/delete requests.
+func (c *WsClient) handleEditMsg(request IngressMsg) {
+	msg := &EditMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil || msg.Channel == "" || msg.MsgID == "" ||
+		(request.Type() == "edit" && len(msg.Data) == 0) {
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	cause := causeOf(request)
+	var err error
+	if request.Type() == "edit" {
+		err = c.manager.changeMessage(c, msg.Channel, msg.MsgID, msg.Data, cause)
+	} else {
+		err = c.manager.changeMessage(c, msg.Channel, msg.MsgID, nil, cause)
+	}
+	if err != nil {
+		c.sendErrorOf(request, err, msgs.CodeInvalidRequest)
+		return
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), msg)
+}
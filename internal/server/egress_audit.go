@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"go-websocket-boilerplate/internal/archive"
+	"go-websocket-boilerplate/internal/channels"
+	"math/rand/v2"
+)
+
+// AuditSampling sets the fraction of egress frames recorded to the archive sink, between 0 and 1.
+type AuditSampling struct {
+	Default  float64            // Rate of channels without an entry in Channels
+	Channels map[string]float64 // Rates keyed by channel or channel pattern; exact names take precedence
+}
+
+// validate checks that all rates are between 0 and 1.
+func (s AuditSampling) validate() error {
+	if s.Default < 0 || s.Default > 1 {
+		return fmt.Errorf("audit sample rate %v outside [0, 1]", s.Default)
+	}
+	for channel, rate := range s.Channels {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("audit sample rate %v of channel %q outside [0, 1]", rate, channel)
+		}
+	}
+	return nil
+}
+
+// rate returns the sample rate of the channel.
+func (s AuditSampling) rate(channel string) float64 {
+	if rate, ok := s.Channels[channel]; ok {
+		return rate
+	}
+	for pattern, rate := range s.Channels {
+		if channels.IsPattern(pattern) && channels.Match(pattern, channel) {
+			return rate
+		}
+	}
+	return s.Default
+}
+
+// auditEgress records a sample of the frames written to the client to the archive sink.
+//
+// Params:
+// - msg: The delivered message.
+// - frame: The envelope as written to the connection.
+func (c *WsClient) auditEgress(msg *EgressMsg, frame []byte) {
+	sink := c.manager.archive
+	if sink == nil {
+		return
+	}
+	rate := c.manager.auditSampling.rate(msg.Channel)
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+	sink.Archive(archive.Record{
+		Time:         c.manager.clock.Now(),
+		ConnectionID: c.ID(),
+		Subject:      subjectOf(c.Claims()),
+		Channel:      msg.Channel,
+		Envelope:     frame,
+	})
+}
+
+// SetEgressAudit records a sample of the frames delivered to clients, with their full envelopes, to the archive
+// sink. Sampling keeps compliance visibility on high-volume feeds at a fraction of the storage cost.
+//
+// Params:
+// - sink: The archive sink.
+// - sampling: The sample rates, e.g. {Default: 0.01} for 1% of the frames of every channel.
+func (gw *WsGw) SetEgressAudit(sink archive.Sink, sampling AuditSampling) {
+	gw.archive = sink
+	gw.auditSampling = sampling
+}
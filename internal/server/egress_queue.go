@@ -0,0 +1,109 @@
+package server
+
+import (
+	"time"
+)
+
+// EgressPolicy decides what happens to a message when the client's egress queue is full.
+type EgressPolicy int
+
+const (
+	// EgressBlock makes the sender wait for room in the queue, up to the egress timeout if one is set, after
+	// which the message is dropped.
+	EgressBlock EgressPolicy = iota
+	// EgressDropOldest drops the oldest queued message to make room for the new one.
+	EgressDropOldest
+	// EgressDropNewest drops the new message.
+	EgressDropNewest
+	// EgressDisconnect closes the connection of a client that cannot keep up with its messages.
+	EgressDisconnect
+)
+
+// defaultEgressQueueSize is the number of messages buffered between the senders and the write loop.
+const defaultEgressQueueSize = 64
+
+// EgressDepth returns the number of messages queued for writing to the client and the queue's capacity.
+func (c *WsClient) EgressDepth() (queued int, capacity int) {
+	return len(c.egress), cap(c.egress)
+}
+
+// EgressDropped returns the number of messages dropped because the client's egress queue was full.
+func (c *WsClient) EgressDropped() int64 {
+	return c.egressDropped.Load()
+}
+
+// dropEgress counts a message dropped because the egress queue was full.
+func (c *WsClient) dropEgress() {
+	c.egressDropped.Add(1)
+	c.manager.slaDropped()
+}
+
+// deliver queues the message on the egress channel unless the client has been closed. When the queue is full
+// the manager's egress policy applies.
+func (c *WsClient) deliver(msg *EgressMsg) {
+	select {
+	case c.egress <- msg:
+		return
+	case <-c.context.Done():
+		c.manager.slaDropped()
+		return
+	default:
+	}
+	switch c.manager.egressPolicy {
+	case EgressDropNewest:
+		c.dropEgress()
+	case EgressDropOldest:
+		for {
+			select {
+			case c.egress <- msg:
+				return
+			default:
+			}
+			select {
+			case <-c.egress:
+				c.dropEgress()
+			case <-c.context.Done():
+				c.manager.slaDropped()
+				return
+			default:
+			}
+		}
+	case EgressDisconnect:
+		c.logger.Warn("Egress queue full, disconnecting slow consumer", "queue", cap(c.egress))
+		c.dropEgress()
+		c.Close()
+	default:
+		c.deliverBlocking(msg)
+	}
+}
+
+// deliverBlocking waits for room in the egress queue, up to the egress timeout if one is set.
+func (c *WsClient) deliverBlocking(msg *EgressMsg) {
+	var timeout <-chan time.Time
+	if c.manager.egressTimeout > 0 {
+		timer := time.NewTimer(c.manager.egressTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case c.egress <- msg:
+	case <-c.context.Done():
+		c.manager.slaDropped()
+	case <-timeout:
+		c.logger.Warn("Egress queue full, message dropped", "ch", msg.Channel, "type", msg.Type, "timeout", c.manager.egressTimeout)
+		c.dropEgress()
+	}
+}
+
+// SetEgressQueue sets the size of the per-client queue between the senders and the write loop, and the policy
+// applied when a slow client lets it fill up.
+//
+// Params:
+// - size: The queue size; values below 1 keep the default.
+// - policy: The backpressure policy.
+// - timeout: How long EgressBlock waits for room before dropping the message; 0 waits until the client closes.
+func (gw *WsGw) SetEgressQueue(size int, policy EgressPolicy, timeout time.Duration) {
+	gw.egressQueueSize = size
+	gw.egressPolicy = policy
+	gw.egressTimeout = timeout
+}
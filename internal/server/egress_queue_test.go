@@ -0,0 +1,149 @@
+package server
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"testing"
+	"time"
+)
+
+// stalledConn is a transport whose data writes block until released, like a peer that stopped reading. Control
+// frames are recorded with their write deadline.
+type stalledConn struct {
+	inbound   chan []byte
+	release   chan struct{}
+	controls  chan controlFrame
+	deadlines chan time.Time
+	pong      chan func(string) error
+}
+
+// controlFrame is a control frame written to a stalledConn.
+type controlFrame struct {
+	messageType int
+	deadline    time.Time
+}
+
+func newStalledConn() *stalledConn {
+	return &stalledConn{
+		inbound:   make(chan []byte),
+		release:   make(chan struct{}),
+		controls:  make(chan controlFrame, 16),
+		deadlines: make(chan time.Time, 16),
+		pong:      make(chan func(string) error, 1),
+	}
+}
+
+func (c *stalledConn) ReadMessage() (int, []byte, error) {
+	data, ok := <-c.inbound
+	if !ok {
+		return 0, nil, errConnectionClosed
+	}
+	return websocket.TextMessage, data, nil
+}
+
+func (c *stalledConn) WriteMessage(int, []byte) error {
+	<-c.release
+	return nil
+}
+
+func (c *stalledConn) WriteControl(messageType int, _ []byte, deadline time.Time) error {
+	c.controls <- controlFrame{messageType: messageType, deadline: deadline}
+	return nil
+}
+
+func (c *stalledConn) SetReadDeadline(t time.Time) error {
+	c.deadlines <- t
+	return nil
+}
+
+func (c *stalledConn) SetReadLimit(int64) {}
+
+func (c *stalledConn) SetPongHandler(h func(string) error) {
+	c.pong <- h
+}
+
+func (c *stalledConn) Close() error {
+	return nil
+}
+
+// awaitControl waits for the next control frame, failing the test if none is written within the control write
+// deadline.
+func awaitControl(t *testing.T, conn *stalledConn, config Config, want int) controlFrame {
+	t.Helper()
+	select {
+	case frame := <-conn.controls:
+		if frame.messageType != want {
+			t.Fatalf("control frame type = %d, want %d", frame.messageType, want)
+		}
+		return frame
+	case <-time.After(config.ControlWriteWait):
+		t.Fatalf("no control frame of type %d within %s while the egress queue is saturated", want, config.ControlWriteWait)
+	}
+	return controlFrame{}
+}
+
+// TestSaturatedEgressQueueKeepsControlFrames fills the egress queue of a client whose data writes are stalled and
+// checks that pings, pongs and close frames still go out within the control write deadline.
+func TestSaturatedEgressQueueKeepsControlFrames(t *testing.T) {
+	config := DefaultConfig()
+	clock := &manualClock{now: time.Unix(1700000000, 0), timers: make(map[*manualTimer]bool)}
+	manager := NewConnectionManager(DefaultClientConnectionHandler{}, simAuthenticator{}, config)
+	manager.clock = clock
+	manager.egressPolicy = EgressDropNewest
+
+	expire := clock.Now().Add(time.Hour).Unix()
+	claims := jwt.MapClaims{"sub": "slow", "exp": float64(expire)}
+	conn := newStalledConn()
+	client := NewClient(1, manager, claims, manager.authenticator, expire, config)
+	manager.accept(client, conn)
+	defer func() {
+		close(conn.release)
+		close(conn.inbound)
+	}()
+
+	for queued, _ := client.EgressDepth(); queued < cap(client.egress); queued, _ = client.EgressDepth() {
+		client.deliver(NewEgressMsg("", "flood", "flood", map[string]any{"n": queued}))
+	}
+	client.deliver(NewEgressMsg("", "flood", "flood", map[string]any{"n": "overflow"}))
+	if client.EgressDropped() == 0 {
+		t.Fatal("egress queue is not saturated")
+	}
+
+	// The ticker is created by the control goroutine; wait for it next to the auth expiry timer.
+	for deadline := time.Now().Add(time.Second); clock.active() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("keepalive ticker not started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(config.PingInterval)
+	ping := awaitControl(t, conn, config, websocket.PingMessage)
+	if want := clock.Now().Add(config.ControlWriteWait); ping.deadline.After(want) {
+		t.Errorf("ping deadline = %s, want at most %s", ping.deadline, want)
+	}
+
+	select {
+	case pong := <-conn.pong:
+		for len(conn.deadlines) > 0 {
+			<-conn.deadlines
+		}
+		start := time.Now()
+		if err := pong(""); err != nil {
+			t.Fatalf("pong handler: %v", err)
+		}
+		select {
+		case <-conn.deadlines:
+		default:
+			t.Error("pong did not extend the read deadline")
+		}
+		if elapsed := time.Since(start); elapsed > config.ControlWriteWait {
+			t.Errorf("pong handled in %s, want within %s", elapsed, config.ControlWriteWait)
+		}
+	case <-time.After(config.ControlWriteWait):
+		t.Fatal("pong handler not installed")
+	}
+
+	client.setAuthExpireTime(clock.Now().Unix())
+	clock.Advance(2 * time.Second)
+	awaitControl(t, conn, config, websocket.CloseMessage)
+}
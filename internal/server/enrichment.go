@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"maps"
+	"sync"
+	"time"
+)
+
+// ClaimsEnricher adds claims, such as roles or the subscription plan, to those of a validated token.
+type ClaimsEnricher interface {
+	// Enrich returns the additional claims of the token's subject. The context expires after the configured timeout.
+	Enrich(ctx context.Context, claims jwt.MapClaims) (map[string]any, error)
+}
+
+// EnrichOptions control how the claims enricher is called.
+type EnrichOptions struct {
+	Timeout  time.Duration // Deadline of a single Enrich call, 2 seconds if 0
+	CacheTTL time.Duration // How long the additional claims of a subject are reused, not cached if 0
+	FailOpen bool          // Accept the token with its own claims if enrichment fails, instead of rejecting it
+}
+
+// registeredClaims are never replaced by enrichment, so an enricher cannot change who a token belongs to or how
+// long it is valid.
+var registeredClaims = map[string]bool{"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true}
+
+// enrichedClaims caches the additional claims of a subject.
+type enrichedClaims struct {
+	claims  map[string]any
+	expires time.Time
+}
+
+// claimsEnrichment calls the enricher and caches its results by subject.
+type claimsEnrichment struct {
+	sync.Mutex
+	enricher ClaimsEnricher
+	options  EnrichOptions
+	cache    map[string]enrichedClaims
+}
+
+// newClaimsEnrichment creates the enrichment step for the enricher.
+func newClaimsEnrichment(enricher ClaimsEnricher, options EnrichOptions) *claimsEnrichment {
+	if options.Timeout <= 0 {
+		options.Timeout = 2 * time.Second
+	}
+	return &claimsEnrichment{enricher: enricher, options: options, cache: make(map[string]enrichedClaims)}
+}
+
+// enrich returns a copy of the claims with the subject's additional claims merged in.
+func (e *claimsEnrichment) enrich(claims jwt.MapClaims) (jwt.MapClaims, error) {
+	subject := subjectOf(claims)
+	extra, ok := e.cached(subject)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), e.options.Timeout)
+		defer cancel()
+		var err error
+		if extra, err = e.enricher.Enrich(ctx, claims); err != nil {
+			if e.options.FailOpen {
+				return claims, nil
+			}
+			return nil, fmt.Errorf("enrich claims of %q: %w", subject, err)
+		}
+		e.store(subject, extra)
+	}
+	merged := maps.Clone(claims)
+	for name, value := range extra {
+		if !registeredClaims[name] {
+			merged[name] = value
+		}
+	}
+	return merged, nil
+}
+
+// cached returns the unexpired additional claims of the subject.
+func (e *claimsEnrichment) cached(subject string) (map[string]any, bool) {
+	if e.options.CacheTTL <= 0 || subject == "" {
+		return nil, false
+	}
+	e.Lock()
+	defer e.Unlock()
+	entry, ok := e.cache[subject]
+	if !ok || time.Now().After(entry.expires) {
+		delete(e.cache, subject)
+		return nil, false
+	}
+	return entry.claims, true
+}
+
+// store caches the additional claims of the subject and evicts expired entries.
+func (e *claimsEnrichment) store(subject string, claims map[string]any) {
+	if e.options.CacheTTL <= 0 || subject == "" {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	now := time.Now()
+	for s, entry := range e.cache {
+		if now.After(entry.expires) {
+			delete(e.cache, s)
+		}
+	}
+	e.cache[subject] = enrichedClaims{claims: claims, expires: now.Add(e.options.CacheTTL)}
+}
+
+// validateToken validates the token with the authenticator, rejects it if it was revoked and enriches its claims,
+// if an enricher is set.
+func (m *ConnectionManager) validateToken(authenticator Authenticator, token string) (jwt.MapClaims, error) {
+	claims, err := m.validateCached(authenticator, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkRevoked(claims); err != nil {
+		return nil, err
+	}
+	if m.enrichment == nil {
+		return claims, nil
+	}
+	return m.enrichment.enrich(claims)
+}
+
+// SetClaimsEnricher sets the hook adding claims to those of every validated token, for both bearer tokens at
+// connect time and sys/auth messages. Handlers and channel authorization see the merged claims.
+//
+// Params:
+// - enricher: The enricher, e.g. looking up roles in a database.
+// - options: Timeout, caching and failure policy.
+func (gw *WsGw) SetClaimsEnricher(enricher ClaimsEnricher, options EnrichOptions) {
+	gw.enrichment = newClaimsEnrichment(enricher, options)
+}
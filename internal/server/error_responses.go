@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// sendError responds to the request with a structured error.
+func (c *WsClient) sendError(request handler.InMsg, code msgs.ErrorCode, message string, details ...msgs.ErrorDetail) {
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), msgs.NewErrorResponse(request.ID(), code, message, details...))
+}
+
+// sendErrorOf responds to the request with the error, classified by errorCode.
+func (c *WsClient) sendErrorOf(request handler.InMsg, err error, fallback msgs.ErrorCode) {
+	c.sendError(request, errorCode(err, fallback), err.Error())
+}
+
+// errorCode classifies an error for clients, using the fallback for errors carrying no code.
+func errorCode(err error, fallback msgs.ErrorCode) msgs.ErrorCode {
+	var handlerErr *handler.Error
+	var quotaErr *QuotaError
+	switch {
+	case errors.As(err, &handlerErr):
+		return handlerErr.Code
+	case errors.As(err, &quotaErr):
+		return msgs.CodeQuotaExceeded
+	case errors.Is(err, context.DeadlineExceeded):
+		return msgs.CodeTimeout
+	default:
+		return fallback
+	}
+}
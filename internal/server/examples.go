@@ -0,0 +1,23 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/examples"
+	"net/http"
+)
+
+// serveExamples serves client snippets for every documented request to administrators.
+func (m *ConnectionManager) serveExamples(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	scheme := "ws"
+	if r.TLS != nil {
+		scheme = "wss"
+	}
+	wsURL := scheme + "://" + r.Host + "/ws"
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(examples.Generate(m.AsyncAPI(r.Host+"/ws"), wsURL)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/experiment"
+	"go-websocket-boilerplate/internal/session"
+)
+
+// assignExperiments sets the client's experiment variants.
+//
+// A resumed session keeps the variants it was assigned on its first connect; otherwise the experiment
+// provider assigns them. Each variant is added to the client's log attributes as "exp.<name>".
+func (m *ConnectionManager) assignExperiments(client *WsClient, resumed *session.Session) {
+	if resumed != nil && resumed.Experiments != nil {
+		client.experiments = resumed.Experiments
+	} else if m.experiments != nil {
+		client.experiments = m.experiments.Assign(experiment.Subject{
+			ConnectionID: client.ID(),
+			Subject:      subjectOf(client.Claims()),
+			Claims:       client.Claims(),
+			Country:      client.Country(),
+			Device:       client.Device(),
+		})
+	}
+	for name, variant := range client.experiments {
+		client.logger = client.logger.With("exp."+name, variant)
+	}
+}
+
+// Variant returns the variant of the experiment assigned to the client, or an empty string if the client
+// does not take part in it.
+func (c *WsClient) Variant(name string) string {
+	return c.experiments[name]
+}
+
+// Experiments returns the experiment variants assigned to the client keyed by experiment name.
+func (c *WsClient) Experiments() map[string]string {
+	return c.experiments
+}
+
+// SetExperimentProvider sets the provider assigning experiment variants to new connections.
+//
+// Params:
+// - provider: The provider, e.g. an experiment.HashProvider.
+func (gw *WsGw) SetExperimentProvider(provider experiment.Provider) {
+	gw.experiments = provider
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// HelloMsg is the payload of a sys/hello message identifying the client installation and browser tab.
+type HelloMsg struct {
+	InstallationID string `json:"installationId"` // Stable identifier of the app installation or browser profile
+	TabID          string `json:"tabId"`          // Identifier of the tab or window, unique per installation
+}
+
+// ConnectionInfo describes one connection of a user.
+type ConnectionInfo struct {
+	ClientID       int    `json:"clientId"`
+	InstallationID string `json:"installationId,omitempty"`
+	TabID          string `json:"tabId,omitempty"`
+	Device         string `json:"device"`
+	Country        string `json:"country,omitempty"`
+	ConnectedAt    int64  `json:"connectedAt"` // Unix timestamp
+	Current        bool   `json:"current,omitempty"`
+}
+
+// InstallationID returns the installation identifier the client sent in sys/hello.
+func (c *WsClient) InstallationID() string {
+	c.fingerprintLock.RLock()
+	defer c.fingerprintLock.RUnlock()
+	return c.installationID
+}
+
+// TabID returns the tab identifier the client sent in sys/hello.
+func (c *WsClient) TabID() string {
+	c.fingerprintLock.RLock()
+	defer c.fingerprintLock.RUnlock()
+	return c.tabID
+}
+
+// info describes the client's connection.
+func (c *WsClient) info() ConnectionInfo {
+	return ConnectionInfo{
+		ClientID:       c.id,
+		InstallationID: c.InstallationID(),
+		TabID:          c.TabID(),
+		Device:         string(c.device),
+		Country:        c.location.Country,
+		ConnectedAt:    c.connectedAt.Unix(),
+	}
+}
+
+// UserConnections lists the connections of a subject, e.g. to show "you're signed in elsewhere".
+//
+// Params:
+// - subject: The JWT subject of the user.
+//
+// Returns:
+// - The connections ordered by connection time.
+func (m *ConnectionManager) UserConnections(subject string) []ConnectionInfo {
+	m.RLock()
+	clients := m.clientsBySubjectLocked(subject)
+	m.RUnlock()
+	infos := make([]ConnectionInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, client.info())
+	}
+	sortConnections(infos)
+	return infos
+}
+
+// sortConnections orders connection infos by connection time and ID.
+func sortConnections(infos []ConnectionInfo) {
+	for i := 1; i < len(infos); i++ {
+		for j := i; j > 0 && (infos[j].ConnectedAt < infos[j-1].ConnectedAt ||
+			infos[j].ConnectedAt == infos[j-1].ConnectedAt && infos[j].ClientID < infos[j-1].ClientID); j-- {
+			infos[j], infos[j-1] = infos[j-1], infos[j]
+		}
+	}
+}
+
+// handleHelloMsg stores the installation and tab identifiers and replies with the user's connections.
+func (c *WsClient) handleHelloMsg(request IngressMsg) {
+	msg := &HelloMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil {
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	c.fingerprintLock.Lock()
+	c.installationID = msg.InstallationID
+	c.tabID = msg.TabID
+	c.fingerprintLock.Unlock()
+	c.logger.Info("Client hello", "installationId", msg.InstallationID, "tabId", msg.TabID)
+	c.sendConnections(request.ID())
+}
+
+// sendConnections replies with the connections of the client's user, marking the current one.
+func (c *WsClient) sendConnections(id string) {
+	subject := subjectOf(c.Claims())
+	infos := []ConnectionInfo{c.info()}
+	if subject != "" {
+		infos = c.manager.UserConnections(subject)
+	}
+	for i := range infos {
+		infos[i].Current = infos[i].ClientID == c.id
+	}
+	c.SendResponse(id, "connections", "sys", infos)
+}
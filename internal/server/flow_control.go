@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// maxPendingPerFlow is the number of updates held per paused subscription; older updates are dropped first.
+const maxPendingPerFlow = 1000
+
+// CreditMsg is the payload of sys/credit requests extending the window of a paced subscription.
+type CreditMsg struct {
+	Channel string `json:"ch"`      // Subscribed channel or pattern
+	Credits int    `json:"credits"` // Number of further updates the client accepts
+}
+
+// flow is the credit window of a paced subscription.
+type flow struct {
+	credits int          // Updates that may still be sent
+	pending []*EgressMsg // Updates waiting for credits
+}
+
+// setWindow paces the subscription: at most window updates are sent until the client grants more credits.
+// A window of zero removes pacing and releases any held updates.
+func (c *WsClient) setWindow(channel string, window int) {
+	if window <= 0 {
+		c.flowLock.Lock()
+		f := c.flows[channel]
+		delete(c.flows, channel)
+		c.flowLock.Unlock()
+		if f != nil {
+			for _, msg := range f.pending {
+				c.send(msg)
+			}
+		}
+		return
+	}
+	c.flowLock.Lock()
+	defer c.flowLock.Unlock()
+	if f, ok := c.flows[channel]; ok {
+		f.credits = window
+		return
+	}
+	c.flows[channel] = &flow{credits: window}
+}
+
+// flowForLocked returns the flow of the subscription matching the channel, if it is paced. The caller must hold the
+// flow lock.
+func (c *WsClient) flowForLocked(channel string) *flow {
+	if f, ok := c.flows[channel]; ok {
+		return f
+	}
+	for subscribed, f := range c.flows {
+		if channels.IsPattern(subscribed) && channels.Match(subscribed, channel) {
+			return f
+		}
+	}
+	return nil
+}
+
+// sendPaced sends the update if its subscription has credits left, holding it back otherwise.
+func (c *WsClient) sendPaced(msg *EgressMsg) {
+	c.flowLock.Lock()
+	f := c.flowForLocked(msg.Channel)
+	if f != nil && (f.credits <= 0 || len(f.pending) > 0) {
+		if len(f.pending) >= maxPendingPerFlow {
+			f.pending = f.pending[1:]
+			c.manager.slaDropped()
+		}
+		f.pending = append(f.pending, msg)
+		c.flowLock.Unlock()
+		return
+	}
+	if f != nil {
+		f.credits--
+	}
+	c.flowLock.Unlock()
+	c.send(msg)
+}
+
+// grantCredits extends the window of the subscription and sends held updates the new credits cover.
+func (c *WsClient) grantCredits(channel string, credits int) bool {
+	c.flowLock.Lock()
+	f, ok := c.flows[channel]
+	if !ok {
+		c.flowLock.Unlock()
+		return false
+	}
+	f.credits += credits
+	n := min(f.credits, len(f.pending))
+	release := f.pending[:n:n]
+	f.pending = f.pending[n:]
+	f.credits -= n
+	c.flowLock.Unlock()
+	for _, msg := range release {
+		c.send(msg)
+	}
+	return true
+}
+
+// handleCreditMsg processes sys/credit requests.
+func (c *WsClient) handleCreditMsg(request IngressMsg) {
+	msg := &CreditMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil || msg.Channel == "" || msg.Credits <= 0 {
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if !c.grantCredits(msg.Channel, msg.Credits) {
+		c.sendError(request, msgs.CodeNotFound, "subscription not paced")
+		return
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), msg)
+}
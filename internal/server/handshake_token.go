@@ -0,0 +1,67 @@
+package server
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strings"
+)
+
+// tokenQueryParam is the query parameter carrying a JWT at the handshake, for clients such as browsers that cannot
+// set an Authorization header on a WebSocket request. Query strings end up in proxy and access logs, so tokens
+// passed this way should be short-lived, e.g. a ticket minted for the handshake, rather than the session token.
+const tokenQueryParam = "token"
+
+// errTokenWithoutExpiry is returned for a connection token without an "exp" claim.
+var errTokenWithoutExpiry = errors.New("token without expiry")
+
+// errMalformedAuthHeader is returned for an Authorization header that is not of the form "<scheme> <token>".
+var errMalformedAuthHeader = errors.New("malformed Authorization header")
+
+// handshakeToken extracts the JWT presented with the handshake request. The Authorization header takes precedence
+// over the "token" query parameter, which takes precedence over the cookie named by Config.AuthCookie.
+//
+// Browsers attach cookies to handshakes started by any page, so the cookie is only accepted under the strict
+// origin policy; otherwise a third-party page could open a socket authenticated as the visitor.
+//
+// Params:
+// - r: The handshake request.
+//
+// Returns:
+// - The token, "" if the request carries none.
+// - Where the token was found: "header", "query" or "cookie".
+// - An error if the Authorization header is malformed.
+func (m *ConnectionManager) handshakeToken(r *http.Request) (string, string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		parts := strings.Split(header, " ")
+		if len(parts) != 2 {
+			return "", "", errMalformedAuthHeader
+		}
+		return parts[1], "header", nil
+	}
+	if token := r.URL.Query().Get(tokenQueryParam); token != "" {
+		return token, "query", nil
+	}
+	if m.config.AuthCookie != "" && m.config.OriginPolicy == OriginStrict {
+		if cookie, err := r.Cookie(m.config.AuthCookie); err == nil && cookie.Value != "" {
+			return cookie.Value, "cookie", nil
+		}
+	}
+	return "", "", nil
+}
+
+// connectionExpiry returns the expiry of a token authenticating a connection. Tokens without an "exp" claim are
+// refused, whichever way they were presented, since the connection is closed when its token expires.
+//
+// Returns:
+// - The expiry as a Unix time, or an error if the token has none.
+func connectionExpiry(claims jwt.MapClaims) (int64, error) {
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return 0, err
+	}
+	if exp == nil {
+		return 0, errTokenWithoutExpiry
+	}
+	return exp.Unix(), nil
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+	"time"
+)
+
+// adminScope is the claim scope required to impersonate another user.
+const adminScope = "admin"
+
+// ImpersonateMsg is the payload of a sys/impersonate request sent by a support agent.
+type ImpersonateMsg struct {
+	Subject string `json:"sub"`    // Subject of the user whose channels are attached
+	Reason  string `json:"reason"` // Free-form justification recorded in the audit trail
+}
+
+// ImpersonationNotice is pushed to the impersonated user's connections as a banner.
+type ImpersonationNotice struct {
+	Active bool   `json:"active"` // True when the session starts, false when it ends
+	Agent  string `json:"agent"`  // Subject of the support agent
+	Reason string `json:"reason,omitempty"`
+}
+
+// MirroredMsg wraps a message delivered to an impersonated user so the observing agent can see it.
+type MirroredMsg struct {
+	Subject string     `json:"sub"` // Subject of the impersonated user
+	Msg     *EgressMsg `json:"msg"` // The original message sent to the user
+}
+
+// subjectOf returns the subject claim of the client or an empty string.
+func subjectOf(claims jwt.MapClaims) string {
+	if claims == nil {
+		return ""
+	}
+	sub, _ := claims.GetSubject()
+	return sub
+}
+
+// audit records an impersonation audit event.
+func (m *ConnectionManager) audit(event string, agent *WsClient, subject string, reason string) {
+	agent.Logger().Info("audit",
+		"event", event,
+		"agent", subjectOf(agent.Claims()),
+		"agentConID", agent.ID(),
+		"subject", subject,
+		"reason", reason,
+		"time", time.Now().Format(time.RFC3339))
+}
+
+// startImpersonation attaches the agent to all channels of the given subject in read-only mode.
+//
+// The agent must carry the admin scope. The impersonated user's connections receive a banner notice.
+//
+// Params:
+// - agent: The support agent's client.
+// - msg: The impersonation request.
+//
+// Returns:
+// - An error for the client, or nil on success.
+func (m *ConnectionManager) startImpersonation(agent *WsClient, msg *ImpersonateMsg) error {
+	if !channels.HasScope(agent.Claims(), adminScope) {
+		m.audit("impersonation_denied", agent, msg.Subject, msg.Reason)
+		return handler.ErrPermissionDenied
+	}
+	if msg.Subject == "" {
+		return &handler.Error{Code: msgs.CodeInvalidRequest, Message: "subject is required"}
+	}
+
+	m.Lock()
+	if previous, ok := m.impersonations[agent.ID()]; ok {
+		delete(m.observers[previous], agent.ID())
+	}
+	if m.observers[msg.Subject] == nil {
+		m.observers[msg.Subject] = make(map[int]*WsClient)
+	}
+	m.observers[msg.Subject][agent.ID()] = agent
+	m.impersonations[agent.ID()] = msg.Subject
+	targets := m.clientsBySubjectLocked(msg.Subject)
+	m.Unlock()
+
+	m.audit("impersonation_started", agent, msg.Subject, msg.Reason)
+	notice := &ImpersonationNotice{Active: true, Agent: subjectOf(agent.Claims()), Reason: msg.Reason}
+	for _, target := range targets {
+		target.SendUpdate("impersonation", "sys", notice)
+	}
+	return nil
+}
+
+// stopImpersonation detaches the agent from the user it is currently impersonating, if any.
+func (m *ConnectionManager) stopImpersonation(agent *WsClient) {
+	m.Lock()
+	subject, ok := m.impersonations[agent.ID()]
+	if !ok {
+		m.Unlock()
+		return
+	}
+	delete(m.impersonations, agent.ID())
+	delete(m.observers[subject], agent.ID())
+	if len(m.observers[subject]) == 0 {
+		delete(m.observers, subject)
+	}
+	targets := m.clientsBySubjectLocked(subject)
+	m.Unlock()
+
+	m.audit("impersonation_stopped", agent, subject, "")
+	notice := &ImpersonationNotice{Active: false, Agent: subjectOf(agent.Claims())}
+	for _, target := range targets {
+		target.SendUpdate("impersonation", "sys", notice)
+	}
+}
+
+// isImpersonating reports whether the client is currently attached to another user's channels.
+func (m *ConnectionManager) isImpersonating(client *WsClient) bool {
+	m.RLock()
+	defer m.RUnlock()
+	_, ok := m.impersonations[client.ID()]
+	return ok
+}
+
+// unmirrored lists the sys updates never mirrored to impersonating agents, as they carry the user's credentials:
+// the resume token and the keys of encrypted channels.
+var unmirrored = map[string]bool{"session": true, "key": true}
+
+// mirror forwards a message delivered to the client to any agents impersonating its subject, except for the
+// credential-bearing updates listed in unmirrored.
+func (m *ConnectionManager) mirror(client *WsClient, msg *EgressMsg) {
+	if msg.Channel == sysChannel && unmirrored[msg.Type] {
+		return
+	}
+	subject := subjectOf(client.Claims())
+	if subject == "" {
+		return
+	}
+	m.RLock()
+	agents := make([]*WsClient, 0, len(m.observers[subject]))
+	for _, agent := range m.observers[subject] {
+		agents = append(agents, agent)
+	}
+	m.RUnlock()
+
+	for _, agent := range agents {
+		agent.deliver(NewEgressMsg("", "impersonation.mirror", "sys", &MirroredMsg{Subject: subject, Msg: msg}))
+	}
+}
+
+// clientsBySubjectLocked returns the authenticated clients of the subject. The caller must hold the lock.
+func (m *ConnectionManager) clientsBySubjectLocked(subject string) []*WsClient {
+	clients := make([]*WsClient, 0, len(m.bySubject[subject]))
+	for _, client := range m.bySubject[subject] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// handleImpersonationMsg processes sys/impersonate and sys/unimpersonate requests.
+func (c *WsClient) handleImpersonationMsg(request IngressMsg) {
+	if request.Type() == "unimpersonate" {
+		c.manager.stopImpersonation(c)
+		c.SendResponse(request.ID(), request.Type(), request.Channel(), &ImpersonationNotice{Active: false})
+		return
+	}
+	msg := &ImpersonateMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil {
+		c.logger.Error("error unmarshalling impersonate msg", "error", err)
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if err := c.manager.startImpersonation(c, msg); err != nil {
+		c.sendErrorOf(request, err, msgs.CodeInvalidRequest)
+		return
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), &ImpersonationNotice{Active: true, Agent: subjectOf(c.Claims()), Reason: msg.Reason})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"testing"
+	"time"
+)
+
+// TestMirrorWithholdsCredentials impersonates a user and sends it a resume token, a channel key and an ordinary
+// update; only the ordinary update may reach the agent.
+func TestMirrorWithholdsCredentials(t *testing.T) {
+	sim := newSimulation(1)
+	expire := sim.clock.Now().Add(time.Hour).Unix()
+	user := NewClient(1, sim.manager, jwt.MapClaims{"sub": "alice", "exp": float64(expire)}, sim.manager.authenticator, expire, sim.manager.config)
+	agent := NewClient(2, sim.manager, jwt.MapClaims{"sub": "support", "scope": adminScope, "exp": float64(expire)}, sim.manager.authenticator, expire, sim.manager.config)
+	if err := sim.manager.startImpersonation(agent, &ImpersonateMsg{Subject: "alice", Reason: "test"}); err != nil {
+		t.Fatalf("startImpersonation: %v", err)
+	}
+
+	const resumeToken = "resume-token-of-alice"
+	user.resumeToken = resumeToken
+	user.issueResumeToken()
+	user.SendUpdate("key", sysChannel, &ChannelKey{Key: []byte("channel-key-of-alice")})
+	user.SendUpdate("greeting", "greeting", map[string]any{"name": "alice"})
+
+	var mirrored []*EgressMsg
+	for len(agent.egress) > 0 {
+		msg := <-agent.egress
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if bytes.Contains(data, []byte(resumeToken)) {
+			t.Errorf("resume token reached the agent in %s/%s", msg.Channel, msg.Type)
+		}
+		if msg.Type == "impersonation.mirror" {
+			var wrapped MirroredMsg
+			if err := json.Unmarshal(msg.Data, &wrapped); err != nil {
+				t.Fatalf("unmarshal mirrored message: %v", err)
+			}
+			mirrored = append(mirrored, wrapped.Msg)
+		}
+	}
+	if len(mirrored) != 1 || mirrored[0].Type != "greeting" {
+		t.Errorf("mirrored %d messages, want only the greeting", len(mirrored))
+	}
+}
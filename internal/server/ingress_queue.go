@@ -0,0 +1,42 @@
+package server
+
+import "go-websocket-boilerplate/internal/msgs"
+
+// ShedPolicy decides what happens to a message when the client's ingress queue is full.
+type ShedPolicy int
+
+const (
+	// ShedDropNewest drops the incoming message and answers it with an "overloaded" response.
+	ShedDropNewest ShedPolicy = iota
+	// ShedDisconnect closes the connection of a client that outpaces its handler.
+	ShedDisconnect
+)
+
+// defaultIngressQueueSize is the number of messages buffered between the read loop and the handler.
+const defaultIngressQueueSize = 64
+
+// enqueue passes the message to the handler without blocking the read loop.
+//
+// The read loop must keep reading so pongs are processed and the read deadline is extended even when the
+// handler is slow.
+//
+// Returns:
+// - false if the connection must be closed.
+func (c *WsClient) enqueue(request IngressMsg) bool {
+	select {
+	case c.ingress <- request:
+		return true
+	default:
+	}
+	c.manager.slaDropped()
+	failSpan(request, "ingress queue full")
+	switch c.manager.ingressShedPolicy {
+	case ShedDisconnect:
+		c.logger.Warn("Ingress queue full, disconnecting slow consumer", "queue", cap(c.ingress))
+		return false
+	default:
+		c.logger.Warn("Ingress queue full, message dropped", "ch", request.Channel(), "type", request.Type(), "id", request.ID())
+		go c.sendError(request, msgs.CodeOverloaded, "overloaded")
+		return true
+	}
+}
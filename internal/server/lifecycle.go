@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-websocket-boilerplate/internal/analytics"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LifecycleState is the stage of a connection's lifecycle.
+//
+// Connections move forward only: Connecting → Authenticated → Active, and from any state to Closing. Handlers
+// are started exactly once, on the transition to Active.
+type LifecycleState int
+
+const (
+	StateConnecting    LifecycleState = iota // Upgraded, waiting for a sys/auth message
+	StateAuthenticated                       // Authenticated, handlers not started yet
+	StateActive                              // Handlers started
+	StateClosing                             // Closed or closing; terminal
+)
+
+// String returns the name of the state.
+func (s LifecycleState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateAuthenticated:
+		return "authenticated"
+	case StateActive:
+		return "active"
+	case StateClosing:
+		return "closing"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the state as its name.
+func (s LifecycleState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// StateChangeHook is called after a connection moved from one lifecycle state to another.
+type StateChangeHook func(client *WsClient, from LifecycleState, to LifecycleState)
+
+// stateCounters counts events per lifecycle state.
+type stateCounters [StateClosing + 1]atomic.Int64
+
+// allowedTransitions lists the states each state may move to.
+var allowedTransitions = map[LifecycleState][]LifecycleState{
+	StateConnecting:    {StateAuthenticated, StateClosing},
+	StateAuthenticated: {StateActive, StateClosing},
+	StateActive:        {StateClosing},
+}
+
+// lifecycle holds a connection's state. Transitions are atomic, so concurrent attempts of the same transition
+// have a single winner.
+type lifecycle struct {
+	sync.Mutex
+	state         LifecycleState
+	authenticated bool                        // Set on reaching StateAuthenticated, kept while closing
+	entered       [StateClosing + 1]time.Time // Time each state was entered, zero if not reached
+}
+
+// transition moves from the state to the next one if the connection is in that state and the move is allowed.
+//
+// Returns:
+// - true if this call performed the transition.
+func (l *lifecycle) transition(from LifecycleState, to LifecycleState, at time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.state != from || !allowed(from, to) {
+		return false
+	}
+	l.state = to
+	l.entered[to] = at
+	if to == StateAuthenticated {
+		l.authenticated = true
+	}
+	return true
+}
+
+// close moves the connection to StateClosing from any state.
+//
+// Returns:
+// - The state the connection was in.
+// - true if the connection was not closing yet.
+func (l *lifecycle) close(at time.Time) (LifecycleState, bool) {
+	l.Lock()
+	defer l.Unlock()
+	from := l.state
+	if from == StateClosing {
+		return from, false
+	}
+	l.state = StateClosing
+	l.entered[StateClosing] = at
+	return from, true
+}
+
+// allowed reports whether the transition is part of the lifecycle.
+func allowed(from LifecycleState, to LifecycleState) bool {
+	for _, next := range allowedTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// State returns the connection's lifecycle state.
+func (c *WsClient) State() LifecycleState {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	return c.lifecycle.state
+}
+
+// StateTimes returns the time the connection entered each state it has reached so far.
+func (c *WsClient) StateTimes() map[LifecycleState]time.Time {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	times := make(map[LifecycleState]time.Time, len(c.lifecycle.entered))
+	for state, at := range c.lifecycle.entered {
+		if !at.IsZero() {
+			times[LifecycleState(state)] = at
+		}
+	}
+	return times
+}
+
+// isAuthenticated reports whether the connection has been authenticated, by bearer token, resume or sys/auth.
+func (c *WsClient) isAuthenticated() bool {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	return c.lifecycle.authenticated
+}
+
+// authenticate records a successful authentication and starts the handlers if they are not running yet.
+//
+// Returns:
+// - false if the user's connection limit rejected the client and it was closed.
+func (c *WsClient) authenticate() bool {
+	if !c.manager.admitSubject(c) {
+		return false
+	}
+	if c.lifecycle.transition(StateConnecting, StateAuthenticated, c.manager.clock.Now()) {
+		c.stateChanged(StateConnecting, StateAuthenticated)
+		c.checkAffinity()
+		c.activate()
+	}
+	return true
+}
+
+// activate starts the handlers of an authenticated connection. Only the first call after authentication does so,
+// and none once the connection is closing.
+func (c *WsClient) activate() {
+	if c.lifecycle.transition(StateAuthenticated, StateActive, c.manager.clock.Now()) {
+		c.stateChanged(StateAuthenticated, StateActive)
+		c.publishConnected()
+	}
+}
+
+// closing moves the connection to StateClosing unless it is already there.
+func (c *WsClient) closing() {
+	if from, ok := c.lifecycle.close(c.manager.clock.Now()); ok {
+		c.stateChanged(from, StateClosing)
+	}
+}
+
+// stateChanged logs and counts a transition, reports it to the analytics sink and calls the state change hook.
+func (c *WsClient) stateChanged(from LifecycleState, to LifecycleState) {
+	c.logger.Debug("Connection state changed", "from", from, "to", to)
+	c.manager.stateTransitions[to].Add(1)
+	c.manager.track(c, analytics.StateChange, map[string]any{
+		"from": from.String(),
+		"to":   to.String(),
+	})
+	if c.manager.stateHook != nil {
+		c.manager.stateHook(c, from, to)
+	}
+}
+
+// SetStateChangeHook registers a callback run after every lifecycle transition of a connection.
+//
+// The hook runs on the goroutine performing the transition, possibly while the gateway holds its locks, so it must
+// return quickly and must not call back into the gateway.
+//
+// Params:
+// - hook: The callback.
+func (gw *WsGw) SetStateChangeHook(hook StateChangeHook) {
+	gw.stateHook = hook
+}
+
+// stateCounts returns the number of connections in each lifecycle state.
+func (m *ConnectionManager) stateCounts() [StateClosing + 1]int {
+	var counts [StateClosing + 1]int
+	m.RLock()
+	defer m.RUnlock()
+	for _, client := range m.clients {
+		counts[client.State()]++
+	}
+	return counts
+}
+
+// writeStateMetrics writes the connections in each lifecycle state and the transitions into each state in the
+// Prometheus text format.
+func (m *ConnectionManager) writeStateMetrics(b *bytes.Buffer) {
+	counts := m.stateCounts()
+	b.WriteString("# HELP wsgw_connection_state Connections in each lifecycle state.\n# TYPE wsgw_connection_state gauge\n")
+	for state := StateConnecting; state <= StateClosing; state++ {
+		fmt.Fprintf(b, "wsgw_connection_state{state=%q} %d\n", state, counts[state])
+	}
+	b.WriteString("# HELP wsgw_state_transitions_total Transitions into each lifecycle state.\n# TYPE wsgw_state_transitions_total counter\n")
+	for state := StateAuthenticated; state <= StateClosing; state++ {
+		fmt.Fprintf(b, "wsgw_state_transitions_total{state=%q} %d\n", state, m.stateTransitions[state].Load())
+	}
+}
+
+// ConnectionState is the lifecycle state and egress queue depth of a connection as listed to administrators.
+type ConnectionState struct {
+	ConnectionID int                          `json:"conId"`
+	Subject      string                       `json:"sub,omitempty"`
+	State        LifecycleState               `json:"state"`
+	Entered      map[LifecycleState]time.Time `json:"entered"`       // Time each reached state was entered
+	EgressQueue  int                          `json:"egressQueue"`   // Messages waiting to be written
+	EgressCap    int                          `json:"egressCap"`     // Capacity of the egress queue
+	EgressDrops  int64                        `json:"egressDropped"` // Messages dropped because the queue was full
+}
+
+// ConnectionStates returns the lifecycle state of every connection, ordered by connection ID.
+func (m *ConnectionManager) ConnectionStates() []ConnectionState {
+	m.RLock()
+	states := make([]ConnectionState, 0, len(m.clients))
+	for _, client := range m.clients {
+		queued, capacity := client.EgressDepth()
+		states = append(states, ConnectionState{
+			ConnectionID: client.ID(),
+			Subject:      subjectOf(client.Claims()),
+			State:        client.State(),
+			Entered:      client.StateTimes(),
+			EgressQueue:  queued,
+			EgressCap:    capacity,
+			EgressDrops:  client.EgressDropped(),
+		})
+	}
+	m.RUnlock()
+	sort.Slice(states, func(i, j int) bool { return states[i].ConnectionID < states[j].ConnectionID })
+	return states
+}
+
+// serveConnections serves the lifecycle state of every connection to administrators.
+func (m *ConnectionManager) serveConnections(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.ConnectionStates()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+	var l lifecycle
+	start := time.Unix(1700000000, 0)
+	steps := []struct {
+		from LifecycleState
+		to   LifecycleState
+	}{
+		{StateConnecting, StateAuthenticated},
+		{StateAuthenticated, StateActive},
+	}
+	for i, step := range steps {
+		if !l.transition(step.from, step.to, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("transition %s → %s refused", step.from, step.to)
+		}
+		if l.state != step.to {
+			t.Fatalf("state = %s after %s → %s", l.state, step.from, step.to)
+		}
+	}
+	from, ok := l.close(start.Add(2 * time.Second))
+	if !ok || from != StateActive {
+		t.Fatalf("close() = %s, %t, want %s, true", from, ok, StateActive)
+	}
+	if _, ok := l.close(start.Add(3 * time.Second)); ok {
+		t.Error("closing twice reported a transition")
+	}
+	if !l.authenticated {
+		t.Error("authentication forgotten after closing")
+	}
+	for state := StateConnecting + 1; state <= StateClosing; state++ {
+		if want := start.Add(time.Duration(state-1) * time.Second); !l.entered[state].Equal(want) {
+			t.Errorf("entered %s at %s, want %s", state, l.entered[state], want)
+		}
+	}
+}
+
+func TestLifecycleRejectsIllegalTransitions(t *testing.T) {
+	illegal := []struct {
+		state LifecycleState // State the connection is in
+		from  LifecycleState
+		to    LifecycleState
+	}{
+		{StateConnecting, StateConnecting, StateActive},
+		{StateConnecting, StateAuthenticated, StateActive},
+		{StateAuthenticated, StateAuthenticated, StateConnecting},
+		{StateAuthenticated, StateConnecting, StateAuthenticated},
+		{StateActive, StateActive, StateAuthenticated},
+		{StateActive, StateAuthenticated, StateActive},
+		{StateClosing, StateClosing, StateConnecting},
+		{StateClosing, StateAuthenticated, StateActive},
+	}
+	for _, tc := range illegal {
+		t.Run(fmt.Sprintf("%s/%s→%s", tc.state, tc.from, tc.to), func(t *testing.T) {
+			l := lifecycle{state: tc.state}
+			if l.transition(tc.from, tc.to, time.Now()) {
+				t.Fatalf("transition %s → %s allowed in state %s", tc.from, tc.to, tc.state)
+			}
+			if l.state != tc.state {
+				t.Fatalf("state = %s, want %s", l.state, tc.state)
+			}
+		})
+	}
+}
+
+// TestPublishConnectedOnce connects with a bearer token and authenticates again with sys/auth; ClientConnected
+// must fire once.
+func TestPublishConnectedOnce(t *testing.T) {
+	sim := newSimulation(1)
+	expire := sim.clock.Now().Add(time.Hour).Unix()
+	sim.manager.nextClientID++
+	id := sim.manager.nextClientID
+	conn := newSimConn(sim, id)
+	client := NewClient(id, sim.manager, jwt.MapClaims{"sub": "user-1", "exp": float64(expire)}, sim.manager.authenticator, expire, sim.manager.config)
+	sim.manager.accept(client, conn)
+	sim.conns = append(sim.conns, conn)
+
+	token := fmt.Sprintf("sim:user-1:%d", expire)
+	conn.send("auth", "sys", map[string]any{"authToken": token})
+	conn.send("auth", "sys", map[string]any{"authToken": token})
+	conn.send("greeting", "greeting", map[string]any{"name": "test"}) // Read once both auth messages were handled
+
+	if state := client.State(); state != StateActive {
+		t.Errorf("state = %s, want %s", state, StateActive)
+	}
+	sim.finish()
+	sim.Lock()
+	defer sim.Unlock()
+	if calls := sim.connected[id]; calls != 1 {
+		t.Errorf("ClientConnected fired %d times, want 1", calls)
+	}
+	for _, violation := range sim.violations {
+		t.Error(violation)
+	}
+}
+
+// TestStateChangeHook connects and disconnects a client; the hook must see each transition once, in order, and the
+// load metrics must count them.
+func TestStateChangeHook(t *testing.T) {
+	sim := newSimulation(1)
+	var lock sync.Mutex
+	var seen []string
+	sim.manager.stateHook = func(_ *WsClient, from LifecycleState, to LifecycleState) {
+		lock.Lock()
+		defer lock.Unlock()
+		seen = append(seen, fmt.Sprintf("%s→%s", from, to))
+	}
+	expire := sim.clock.Now().Add(time.Hour).Unix()
+	sim.manager.nextClientID++
+	id := sim.manager.nextClientID
+	conn := newSimConn(sim, id)
+	client := NewClient(id, sim.manager, jwt.MapClaims{"sub": "user-1", "exp": float64(expire)}, sim.manager.authenticator, expire, sim.manager.config)
+	sim.manager.accept(client, conn)
+	sim.conns = append(sim.conns, conn)
+	sim.finish()
+
+	lock.Lock()
+	want := []string{"connecting→authenticated", "authenticated→active", "active→closing"}
+	if strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Errorf("hook saw %v, want %v", seen, want)
+	}
+	lock.Unlock()
+
+	w := httptest.NewRecorder()
+	sim.manager.serveLoadMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics/load", nil))
+	for _, line := range []string{
+		`wsgw_connection_state{state="active"} 0`,
+		`wsgw_state_transitions_total{state="active"} 1`,
+		`wsgw_state_transitions_total{state="closing"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("load metrics lack %q", line)
+		}
+	}
+}
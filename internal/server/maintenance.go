@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/msgs"
+	"log/slog"
+	"time"
+)
+
+// defaultMaintenanceMessage is returned to connections rejected during maintenance when no message is set.
+const defaultMaintenanceMessage = "Service under maintenance."
+
+// MaintenanceMsg is the payload of a sys/maintenance request sent by an administrator.
+type MaintenanceMsg struct {
+	Active       bool   `json:"active"`                 // True to enter maintenance mode, false to leave it
+	Message      string `json:"message,omitempty"`      // Message shown to clients and rejected connections
+	DrainSeconds int    `json:"drainSeconds,omitempty"` // If positive, existing connections are closed after this delay
+}
+
+// MaintenanceNotice is pushed to every client on the sys channel as a "maintenance" update.
+type MaintenanceNotice struct {
+	Active  bool   `json:"active"`
+	Message string `json:"message,omitempty"`
+	DrainAt int64  `json:"drainAt,omitempty"` // Unix time existing connections are closed, 0 if they are kept
+}
+
+// maintenance is the maintenance mode state of a ConnectionManager.
+type maintenance struct {
+	notice *MaintenanceNotice // Active notice, nil when not in maintenance
+	drain  *time.Timer        // Pending drain of existing connections
+}
+
+// StartMaintenance enters maintenance mode: new connections are rejected with the message and every connected
+// client is notified.
+//
+// Params:
+// - message: The message shown to clients; a default is used if empty.
+// - drain: If positive, existing connections are closed after this delay.
+func (m *ConnectionManager) StartMaintenance(message string, drain time.Duration) {
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	notice := &MaintenanceNotice{Active: true, Message: message}
+	if drain > 0 {
+		notice.DrainAt = time.Now().Add(drain).Unix()
+	}
+
+	m.Lock()
+	if m.maintenance.drain != nil {
+		m.maintenance.drain.Stop()
+		m.maintenance.drain = nil
+	}
+	m.maintenance.notice = notice
+	if drain > 0 {
+		m.maintenance.drain = time.AfterFunc(drain, func() {
+			m.closeAll(websocket.CloseServiceRestart, message)
+		})
+	}
+	m.Unlock()
+
+	slog.Info("Maintenance mode started", "message", message, "drain", drain)
+	m.broadcastSys("maintenance", notice)
+}
+
+// StopMaintenance leaves maintenance mode, cancels a pending drain and notifies every connected client.
+func (m *ConnectionManager) StopMaintenance() {
+	m.Lock()
+	if m.maintenance.drain != nil {
+		m.maintenance.drain.Stop()
+		m.maintenance.drain = nil
+	}
+	m.maintenance.notice = nil
+	m.Unlock()
+
+	slog.Info("Maintenance mode stopped")
+	m.broadcastSys("maintenance", &MaintenanceNotice{Active: false})
+}
+
+// Maintenance returns the active maintenance notice, or nil when not in maintenance.
+func (m *ConnectionManager) Maintenance() *MaintenanceNotice {
+	m.RLock()
+	defer m.RUnlock()
+	return m.maintenance.notice
+}
+
+// broadcastSys sends an update on the sys channel to every connected client.
+func (m *ConnectionManager) broadcastSys(updateType string, data any) {
+	m.RLock()
+	clients := make([]*WsClient, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	m.RUnlock()
+
+	for _, client := range clients {
+		client.SendUpdate(updateType, sysChannel, data)
+	}
+}
+
+// handleMaintenanceMsg processes sys/maintenance requests. Only administrators may toggle maintenance mode.
+func (c *WsClient) handleMaintenanceMsg(request IngressMsg) {
+	if !channels.HasScope(c.Claims(), adminScope) {
+		c.sendError(request, msgs.CodePermissionDenied, "permission denied")
+		return
+	}
+	msg := &MaintenanceMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil {
+		c.logger.Error("error unmarshalling maintenance msg", "error", err)
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	c.logger.Info("audit", "event", "maintenance", "active", msg.Active, "message", msg.Message, "drainSeconds", msg.DrainSeconds)
+	if msg.Active {
+		c.manager.StartMaintenance(msg.Message, time.Duration(msg.DrainSeconds)*time.Second)
+	} else {
+		c.manager.StopMaintenance()
+	}
+	notice := c.manager.Maintenance()
+	if notice == nil {
+		notice = &MaintenanceNotice{Active: false}
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), notice)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// defaultMaxMalformedFrames is the number of consecutive malformed frames after which a client is disconnected.
+const defaultMaxMalformedFrames = 5
+
+// reasonMalformedFrames is the close reason of clients disconnected for sending malformed frames.
+const reasonMalformedFrames = "too many malformed frames"
+
+// rejectMalformed answers a frame that is not a valid message with a sys "error" update and counts a strike.
+// Valid frames reset the count; the connection is closed once it reaches the limit.
+//
+// Returns:
+// - false if the connection was closed.
+func (c *WsClient) rejectMalformed(err error) bool {
+	c.malformedFrames++
+	maxFrames := c.manager.maxMalformedFrames
+	if maxFrames <= 0 {
+		maxFrames = defaultMaxMalformedFrames
+	}
+	if c.malformedFrames >= maxFrames {
+		c.logger.Warn("Client disconnected for sending malformed frames", "frames", c.malformedFrames, "error", err)
+		c.manager.closeClients([]*WsClient{c}, websocket.CloseInvalidFramePayloadData, reasonMalformedFrames)
+		return false
+	}
+	c.logger.Info("Malformed frame rejected", "frames", c.malformedFrames, "error", err)
+	c.SendUpdate("error", sysChannel, msgs.NewErrorResponse("", msgs.CodeMalformedFrame, "malformed message: "+err.Error()))
+	return true
+}
+
+// SetMaxMalformedFrames sets the number of consecutive malformed frames after which a client is disconnected.
+// Malformed frames before that are answered with a sys "error" update. The default is 5.
+//
+// Params:
+// - frames: The number of consecutive malformed frames that closes the connection.
+func (gw *WsGw) SetMaxMalformedFrames(frames int) {
+	gw.maxMalformedFrames = frames
+}
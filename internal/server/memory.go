@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/msgs"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Approximate bookkeeping overhead in bytes of a queued message, a map entry and a connection.
+const (
+	queuedMsgOverhead  = 96
+	mapEntryOverhead   = 48
+	connectionOverhead = 8 * 1024 // Goroutine stacks, read and write buffers
+)
+
+// MemoryUsage is the approximate memory in bytes held by a connection.
+type MemoryUsage struct {
+	ConnectionID  int    `json:"conId"`
+	Subject       string `json:"sub,omitempty"`
+	Ingress       int    `json:"ingress"`       // Messages queued for the handler
+	Egress        int    `json:"egress"`        // Messages queued for writing
+	Replay        int    `json:"replay"`        // Nonces remembered for replay protection
+	Session       int    `json:"session"`       // Claims, resume token and client metadata
+	Subscriptions int    `json:"subscriptions"` // Subscription bookkeeping
+	Total         int    `json:"total"`
+}
+
+// MemoryReport summarizes the approximate memory held by all connections.
+type MemoryReport struct {
+	Connections int           `json:"connections"`
+	Total       int           `json:"total"`
+	Cap         int           `json:"cap,omitempty"` // Per-connection cap, 0 if unlimited
+	Top         []MemoryUsage `json:"top"`           // Connections holding the most memory
+}
+
+// recordFrameSize updates the running frame size statistics used to estimate queued message sizes.
+func (c *WsClient) recordFrameSize(size int) {
+	c.frameBytes.Add(int64(size))
+	c.frames.Add(1)
+}
+
+// averageFrameSize returns the average size of the frames the client sent.
+func (c *WsClient) averageFrameSize() int {
+	frames := c.frames.Load()
+	if frames == 0 {
+		return 0
+	}
+	return int(c.frameBytes.Load() / frames)
+}
+
+// memoryUsageLocked estimates the memory held by the client. The caller must hold the manager's lock.
+func (c *WsClient) memoryUsageLocked() MemoryUsage {
+	frame := c.averageFrameSize() + queuedMsgOverhead
+	usage := MemoryUsage{
+		ConnectionID: c.ID(),
+		Subject:      subjectOf(c.Claims()),
+		Ingress:      len(c.ingress) * frame,
+		Egress:       len(c.egress) * frame,
+		Replay:       c.replay.size(),
+		Session:      c.sessionSize(),
+	}
+	for channel := range c.subscriptions {
+		usage.Subscriptions += len(channel) + mapEntryOverhead
+	}
+	usage.Total = connectionOverhead + usage.Ingress + usage.Egress + usage.Replay + usage.Session + usage.Subscriptions
+	return usage
+}
+
+// sessionSize estimates the memory held by the client's claims and metadata.
+func (c *WsClient) sessionSize() int {
+	size := len(c.resumeToken) + len(c.userAgent) + len(c.ip) + len(c.InstallationID()) + len(c.TabID())
+	if claims := c.Claims(); claims != nil {
+		if data, err := json.Marshal(claims); err == nil {
+			size += len(data)
+		}
+	}
+	return size
+}
+
+// size estimates the memory held by the remembered nonces.
+func (g *replayGuard) size() int {
+	g.Lock()
+	defer g.Unlock()
+	size := 0
+	for nonce := range g.seen {
+		size += len(nonce) + mapEntryOverhead
+	}
+	return size
+}
+
+// trim forgets nonces older than the window. Their timestamps are already outside the window, so replays
+// are still rejected as stale unless the client clock is skewed.
+func (g *replayGuard) trim(now time.Time) {
+	g.Lock()
+	defer g.Unlock()
+	for n, at := range g.seen {
+		if now.Sub(at) > g.window {
+			delete(g.seen, n)
+		}
+	}
+}
+
+// MemoryReport returns the approximate memory held by all connections and the topN connections holding most.
+func (m *ConnectionManager) MemoryReport(topN int) *MemoryReport {
+	m.RLock()
+	usages := make([]MemoryUsage, 0, len(m.clients))
+	for _, client := range m.clients {
+		usages = append(usages, client.memoryUsageLocked())
+	}
+	m.RUnlock()
+
+	report := &MemoryReport{Connections: len(usages), Cap: m.memoryCap}
+	for _, usage := range usages {
+		report.Total += usage.Total
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Total > usages[j].Total })
+	report.Top = usages[:min(topN, len(usages))]
+	return report
+}
+
+// enforceMemoryCap trims the client's buffers when its estimated memory exceeds the per-connection cap.
+//
+// Expired replay nonces are forgotten first, then the oldest queued messages are dropped and answered with
+// "overloaded" until the client is within the cap.
+func (c *WsClient) enforceMemoryCap() {
+	limit := c.manager.memoryCap
+	if limit <= 0 {
+		return
+	}
+	c.manager.RLock()
+	usage := c.memoryUsageLocked()
+	c.manager.RUnlock()
+	if usage.Total <= limit {
+		return
+	}
+
+	c.replay.trim(time.Now())
+	excess := usage.Total - limit - (usage.Replay - c.replay.size())
+	frame := c.averageFrameSize() + queuedMsgOverhead
+	dropped := 0
+	for excess > 0 {
+		select {
+		case msg := <-c.ingress:
+			dropped++
+			excess -= frame
+			failSpan(msg, "memory cap exceeded")
+			c.manager.slaDropped()
+			go c.sendError(msg, msgs.CodeOverloaded, "overloaded")
+			continue
+		default:
+		}
+		break
+	}
+	c.logger.Warn("Connection memory cap exceeded, buffers trimmed", "usage", usage.Total, "cap", limit, "dropped", dropped)
+}
+
+// serveMemory serves the memory report to administrators. The "top" query parameter sets the number of
+// connections listed, 10 by default.
+func (m *ConnectionManager) serveMemory(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	topN, err := strconv.Atoi(r.URL.Query().Get("top"))
+	if err != nil || topN <= 0 {
+		topN = 10
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.MemoryReport(topN)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// sendMemoryReport answers a sys/memory request from an administrator.
+func (c *WsClient) sendMemoryReport(request IngressMsg) {
+	if !channels.HasScope(c.Claims(), adminScope) {
+		c.sendError(request, msgs.CodePermissionDenied, "permission denied")
+		return
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), c.manager.MemoryReport(10))
+}
+
+// SetMemoryCap sets the approximate memory in bytes a connection may hold before its buffers are trimmed.
+//
+// Params:
+// - bytes: The per-connection cap; 0 disables it.
+func (gw *WsGw) SetMemoryCap(bytes int) {
+	gw.memoryCap = bytes
+}
@@ -1,15 +1,28 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
+	"time"
 )
 
 type IngressMsg struct {
-	InMsgType string          `json:"type,omitempty"`
-	InMsgCh   string          `json:"ch,omitempty"`
-	InMsgID   string          `json:"id,omitempty"`
-	InMsgData json.RawMessage `json:"data,omitempty"`
+	InMsgType  string          `json:"type,omitempty"`
+	InMsgCh    string          `json:"ch,omitempty"`
+	InMsgID    string          `json:"id,omitempty"`
+	InMsgData  json.RawMessage `json:"data,omitempty"`
+	InMsgNonce string          `json:"nonce,omitempty"` // Client nonce required on replay protected channels
+	InMsgTs    int64           `json:"ts,omitempty"`    // Client timestamp in Unix milliseconds
+
+	InMsgCorrelationID string `json:"correlationId,omitempty"` // Conversation the request belongs to, defaults to its ID
+	InMsgTraceParent   string `json:"traceparent,omitempty"`   // W3C trace context of the client's span, optional
+	InMsgReplyTo       string `json:"replyTo,omitempty"`       // Call ID of the server call the message answers
+	InMsgError         string `json:"error,omitempty"`         // Failure of the answered call
+
+	trace context.Context // Context of the message's span, nil if tracing is disabled
+	span  trace.Span      // Span of the message, ended by the handler
 }
 
 func (i IngressMsg) ID() string {
@@ -29,10 +42,26 @@ func (i IngressMsg) Data() json.RawMessage {
 }
 
 type EgressMsg struct {
-	Type    string          `json:"type,omitempty"`
-	Channel string          `json:"ch,omitempty"`
-	ID      string          `json:"id,omitempty"`
-	Data    json.RawMessage `json:"data,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Channel   string          `json:"ch,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	MessageID string          `json:"msgId,omitempty"` // Server-originated ID, sortable and unique across the cluster
+	Data      json.RawMessage `json:"data,omitempty"`
+	Signature string          `json:"sig,omitempty"`    // Detached JWS over Data when message signing is enabled
+	Seq       uint64          `json:"seq,omitempty"`    // Per-connection sequence number used as replay cursor
+	CallID    string          `json:"callId,omitempty"` // Set on server calls the client must answer with replyTo
+
+	CausationID    string `json:"causationId,omitempty"`    // ID of the request that caused the message
+	CorrelationID  string `json:"correlationId,omitempty"`  // Conversation the causing request belongs to
+	OriginClientID int    `json:"originClientId,omitempty"` // Connection that published the update
+	TraceParent    string `json:"traceparent,omitempty"`    // W3C trace context of the causing request's span
+
+	Edited  int64 `json:"edited,omitempty"`  // Time of the last edit in Unix milliseconds, set on replayed history
+	Deleted bool  `json:"deleted,omitempty"` // Tombstone of a deleted message, set on replayed history
+
+	created       time.Time // Time the message was created, used to measure delivery latency
+	frame         []byte    // Encoded frame replayed as is to a resuming client, nil for new messages
+	originSubject string    // Subject of the publisher, authorizes edits and deletes
 }
 
 func NewEgressMsg(id string, outMsgType string, channel string, data any) *EgressMsg {
@@ -40,7 +69,7 @@ func NewEgressMsg(id string, outMsgType string, channel string, data any) *Egres
 	if err != nil {
 		slog.Info("error marshalling data", "error", err)
 	}
-	return &EgressMsg{ID: id, Type: outMsgType, Channel: channel, Data: dt}
+	return &EgressMsg{ID: id, Type: outMsgType, Channel: channel, Data: dt, created: time.Now()}
 }
 
 type AuthMsg struct {
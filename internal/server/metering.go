@@ -0,0 +1,27 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/metering"
+)
+
+// meterConnected starts metering the connection time of the client's tenant.
+func (c *WsClient) meterConnected() {
+	if c.manager.meter != nil {
+		c.manager.meter.Connected(c.ID(), c.manager.meter.TenantOf(c.Claims()))
+	}
+}
+
+// meterMessage counts a message of the client's tenant.
+func (c *WsClient) meterMessage() {
+	if c.manager.meter != nil {
+		c.manager.meter.Message(c.manager.meter.TenantOf(c.Claims()))
+	}
+}
+
+// SetMeter sets the meter counting billable usage per tenant. The caller runs the meter's export loop.
+//
+// Params:
+// - meter: The meter.
+func (gw *WsGw) SetMeter(meter *metering.Meter) {
+	gw.meter = meter
+}
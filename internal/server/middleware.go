@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"go-websocket-boilerplate/internal/msgs"
+)
+
+// ErrCloseConnection is returned by a MsgFunc to close the client's connection.
+var ErrCloseConnection = errors.New("close connection")
+
+// MsgFunc processes an ingress message of a client on its way to the handler.
+//
+// Returns:
+// - nil if the message was handled, an error answered to the client otherwise, or ErrCloseConnection.
+type MsgFunc func(client *WsClient, msg IngressMsg) error
+
+// Middleware wraps a MsgFunc with a cross-cutting concern such as an authorization check, logging, rate limiting
+// or metrics. A middleware may pass the message on to next, answer it itself or reject it with an error.
+//
+// Middlewares run on the client's read loop, after the gateway's own access and replay checks and before the
+// message is queued for the handler. They must not block; slow work belongs in the handler.
+type Middleware func(next MsgFunc) MsgFunc
+
+// Use adds middlewares to the ingress chain. Middlewares added first run first.
+//
+// Params:
+// - middlewares: The middlewares to add.
+func (m *ConnectionManager) Use(middlewares ...Middleware) {
+	m.Lock()
+	defer m.Unlock()
+	m.middlewares = append(m.middlewares, middlewares...)
+	m.ingressChain = chain(m.middlewares, enqueueMsg)
+}
+
+// Use adds middlewares to the ingress chain of every connection. Middlewares added first run first.
+//
+// Params:
+// - middlewares: The middlewares to add.
+func (gw *WsGw) Use(middlewares ...Middleware) {
+	gw.middlewares = append(gw.middlewares, middlewares...)
+}
+
+// chain wraps the final MsgFunc with the middlewares, the first middleware outermost.
+func chain(middlewares []Middleware, final MsgFunc) MsgFunc {
+	fn := final
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+	return fn
+}
+
+// enqueueMsg is the end of the ingress chain: it queues the message for the handler.
+func enqueueMsg(client *WsClient, msg IngressMsg) error {
+	if !client.enqueue(msg) {
+		return ErrCloseConnection
+	}
+	return nil
+}
+
+// dispatch passes the message through the ingress chain.
+//
+// Returns:
+// - false if the connection must be closed.
+func (c *WsClient) dispatch(request IngressMsg) bool {
+	c.startSpan(&request)
+	c.manager.RLock()
+	fn := c.manager.ingressChain
+	c.manager.RUnlock()
+	err := fn(c, request)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrCloseConnection):
+		failSpan(request, err.Error())
+		c.logger.Info("Connection closed by middleware", "ch", request.Channel(), "type", request.Type(), "id", request.ID())
+		return false
+	default:
+		failSpan(request, err.Error())
+		c.sendErrorOf(request, err, msgs.CodeInvalidRequest)
+		return true
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/moderation"
+	"time"
+)
+
+// moderationTimeout bounds a single moderation decision.
+const moderationTimeout = 5 * time.Second
+
+// roomModeration applies a moderator to the rooms matching a channel or pattern.
+type roomModeration struct {
+	channel   string
+	moderator moderation.Moderator
+	async     bool
+}
+
+// ModerationNotice is sent to the publisher on the sys channel as a "moderation" update when a message is rejected.
+type ModerationNotice struct {
+	Channel string `json:"ch"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SetModeration moderates client publishes on the channel before fan-out.
+//
+// Synchronous moderation runs on the publisher's handler goroutine. Asynchronous moderation runs in the background
+// so slow moderators, e.g. remote classification services, do not hold up the publisher's other requests.
+//
+// Params:
+// - channel: The channel or pattern, e.g. "chat.*". The first matching registration applies.
+// - moderator: The moderator.
+// - async: Moderate in the background.
+func (m *ConnectionManager) SetModeration(channel string, moderator moderation.Moderator, async bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.moderation = append(m.moderation, roomModeration{channel: channel, moderator: moderator, async: async})
+}
+
+// moderationFor returns the moderation of the channel, if any.
+func (m *ConnectionManager) moderationFor(channel string) (roomModeration, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	for _, room := range m.moderation {
+		if room.channel == channel || (channels.IsPattern(room.channel) && channels.Match(room.channel, channel)) {
+			return room, true
+		}
+	}
+	return roomModeration{}, false
+}
+
+// moderate runs the channel's moderator on a client publish and publishes the approved or edited message.
+//
+// Returns:
+// - false if the channel is not moderated and the caller must publish the message itself.
+func (m *ConnectionManager) moderate(sender *WsClient, channel string, updateType string, data any, cause cause) bool {
+	room, ok := m.moderationFor(channel)
+	if !ok {
+		return false
+	}
+	run := func() {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			sender.logger.Error("Failed to marshal message for moderation", "ch", channel, "error", err)
+			return
+		}
+		msg := &moderation.Message{
+			Channel:  channel,
+			Type:     updateType,
+			Subject:  subjectOf(sender.Claims()),
+			ClientID: sender.ID(),
+			Data:     payload,
+		}
+		ctx, cancel := context.WithTimeout(sender.Context(), moderationTimeout)
+		defer cancel()
+		decision, err := room.moderator.Moderate(ctx, msg)
+		if err != nil {
+			sender.logger.Error("Moderation failed, message rejected", "ch", channel, "type", updateType, "error", err)
+			decision = moderation.Decision{Action: moderation.Reject, Reason: "moderation unavailable"}
+		}
+		switch decision.Action {
+		case moderation.Reject:
+			sender.logger.Info("Message rejected by moderation", "ch", channel, "type", updateType, "reason", decision.Reason)
+			sender.send(cause.stamp(NewEgressMsg("", "moderation", "sys",
+				&ModerationNotice{Channel: channel, Type: updateType, Reason: decision.Reason})))
+		case moderation.Edit:
+			m.distribute(sender, channel, updateType, decision.Data, cause)
+		default:
+			m.distribute(sender, channel, updateType, payload, cause)
+		}
+	}
+	if room.async {
+		go run()
+	} else {
+		run()
+	}
+	return true
+}
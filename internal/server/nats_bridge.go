@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/msgs"
+	"go-websocket-boilerplate/internal/natsbridge"
+	"log/slog"
+	"time"
+)
+
+// errBridgeUnavailable answers a message on a bridged channel that could not be forwarded.
+var errBridgeUnavailable = &handler.Error{Code: msgs.CodeUnavailable, Message: "service unavailable"}
+
+// Bridge forwards client messages to backend services and receives their updates and responses, e.g. a
+// natsbridge.Bridge.
+type Bridge interface {
+	// Forward sends a client message to the services.
+	Forward(ctx context.Context, msg natsbridge.Ingress) error
+	// Subscribe calls deliver with every update and every response to a message forwarded by the node until the
+	// context is cancelled.
+	Subscribe(ctx context.Context, node string, deliver func(natsbridge.Egress)) error
+}
+
+// bridged reports whether messages on the channel are forwarded to the bridge.
+func (m *ConnectionManager) bridged(channel string) bool {
+	for _, pattern := range m.bridgeChannels {
+		if pattern == channel || channels.Match(pattern, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// bridgeMiddleware forwards messages on bridged channels to the bridge instead of the handler.
+func (m *ConnectionManager) bridgeMiddleware(next MsgFunc) MsgFunc {
+	return func(client *WsClient, msg IngressMsg) error {
+		if !m.bridged(msg.Channel()) {
+			return next(client, msg)
+		}
+		ctx, cancel := context.WithTimeout(client.Context(), brokerTimeout)
+		defer cancel()
+		err := m.bridge.Forward(ctx, natsbridge.Ingress{
+			Node:          m.nodeID,
+			ClientID:      client.ID(),
+			Subject:       subjectOf(client.Claims()),
+			ID:            msg.ID(),
+			Type:          msg.Type(),
+			Channel:       msg.Channel(),
+			Data:          msg.Data(),
+			CorrelationID: msg.InMsgCorrelationID,
+		})
+		if err != nil {
+			client.logger.Error("Failed to forward message to the bridge", "ch", msg.Channel(), "type", msg.Type(), "error", err)
+			return errBridgeUnavailable
+		}
+		msg.Finish()
+		return nil
+	}
+}
+
+// consumeBridge delivers the bridge's updates and responses to the local clients, resubscribing after failures.
+func (m *ConnectionManager) consumeBridge() {
+	for {
+		if err := m.bridge.Subscribe(context.Background(), m.nodeID, m.receiveBridged); err != nil {
+			slog.Error("Bridge subscription failed", "error", err)
+		}
+		time.Sleep(brokerRetry)
+	}
+}
+
+// receiveBridged sends a response to the client that sent the request, or publishes an update to the channel's
+// subscribers. Updates are relayed to the other nodes if a broker is configured.
+func (m *ConnectionManager) receiveBridged(msg natsbridge.Egress) {
+	if msg.ClientID == 0 {
+		m.Publish(msg.Channel, msg.Type, msg.Data)
+		return
+	}
+	m.RLock()
+	client := m.clients[msg.ClientID]
+	m.RUnlock()
+	if client == nil {
+		slog.Debug("Bridge response for a disconnected client", "clientId", msg.ClientID, "id", msg.ID)
+		return
+	}
+	client.SendResponse(msg.ID, msg.Type, msg.Channel, msg.Data)
+}
+
+// SetBridge forwards client messages on the given channels to backend services through the bridge, instead of
+// the handler, and delivers the services' updates and responses to the clients.
+//
+// Updates are received by a single node of the cluster; configure a broker to reach the subscribers of every
+// node.
+//
+// Params:
+// - bridge: The bridge, e.g. a natsbridge.Bridge.
+// - channels: Channels or channel patterns whose messages are forwarded.
+func (gw *WsGw) SetBridge(bridge Bridge, channels ...string) {
+	gw.bridge = bridge
+	gw.bridgeChannels = channels
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/noderpc"
+	"log/slog"
+	"net"
+)
+
+// nodeRPCHandler delivers the direct messages other nodes send to the users owned by this node.
+type nodeRPCHandler struct {
+	manager *ConnectionManager
+}
+
+// Deliver queues the message for the local connections of its recipient the sender may reach.
+func (h nodeRPCHandler) Deliver(_ context.Context, delivery *noderpc.Delivery) (*noderpc.Receipt, error) {
+	m := h.manager
+	m.RLock()
+	recipients := m.clientsBySubjectLocked(delivery.Subject)
+	m.RUnlock()
+	from := jwt.MapClaims(delivery.FromClaims)
+	msg := &DirectMessage{From: delivery.From, FromConID: delivery.FromConID, Data: delivery.Data}
+	receipt := &noderpc.Receipt{}
+	for _, to := range recipients {
+		if m.authorizeDirectFrom(from, to) != nil {
+			continue
+		}
+		update := NewEgressMsg("", delivery.Type, delivery.Channel, msg)
+		update.CausationID = delivery.CausationID
+		update.CorrelationID = delivery.CorrelationID
+		to.send(update)
+		receipt.Delivered++
+	}
+	receipt.Denied = receipt.Delivered == 0 && len(recipients) > 0
+	return receipt, nil
+}
+
+// sendToOwner delivers a direct message to the connections of the subject on the node owning it, if users are
+// partitioned and that node is another one reachable over RPC.
+//
+// Returns:
+// - sent: Whether the message was sent to another node.
+// - err: The delivery error, wrapping handler.ErrNotFound or handler.ErrPermissionDenied if no connection got it.
+func (c *WsClient) sendToOwner(subject string, updateType string, channel string, data any, cause cause) (bool, error) {
+	m := c.manager
+	if m.peers == nil {
+		return false, nil
+	}
+	route, ok := m.route(subject)
+	if !ok || route.Local || !m.peers.Has(route.Node) {
+		return false, nil
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return true, fmt.Errorf("encode direct message: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), brokerTimeout)
+	defer cancel()
+	receipt, err := m.peers.Deliver(ctx, route.Node, &noderpc.Delivery{
+		Subject:       subject,
+		Type:          updateType,
+		Channel:       channel,
+		Data:          payload,
+		From:          subjectOf(c.Claims()),
+		FromConID:     c.ID(),
+		FromClaims:    c.Claims(),
+		CausationID:   cause.causationID,
+		CorrelationID: cause.correlationID,
+	})
+	switch {
+	case err != nil:
+		c.logger.Error("Failed to deliver direct message to the owning node", "node", route.Node, "error", err)
+		return true, err
+	case receipt.Denied:
+		return true, handler.ErrPermissionDenied
+	case receipt.Delivered == 0:
+		return true, fmt.Errorf("recipient not connected: %w", handler.ErrNotFound)
+	}
+	return true, nil
+}
+
+// serveNodeRPC serves the direct messages of the other nodes on the address until the server is stopped.
+func (m *ConnectionManager) serveNodeRPC(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("Node RPC listen failed", "addr", addr, "error", err)
+		return
+	}
+	if err := m.rpcServer.Serve(listener); err != nil {
+		slog.Error("Node RPC server failed", "error", err)
+	}
+}
+
+// SetNodeRPC delivers SendToUser messages for users owned by another node directly to that node over gRPC, and
+// serves the messages of the other nodes on addr. Requires partitioning, which tells the owning node; the users'
+// connections on other nodes still receive the message locally.
+//
+// Params:
+// - addr: The address the node RPC server listens on, e.g. ":7000".
+// - peers: The RPC addresses of the other nodes.
+func (gw *WsGw) SetNodeRPC(addr string, peers *noderpc.Peers) {
+	gw.rpcAddr = addr
+	gw.peers = peers
+}
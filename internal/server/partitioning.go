@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/partition"
+	"log/slog"
+	"net/http"
+)
+
+// Route tells where a user's connections belong when users are partitioned between the nodes. It is served by
+// /route and pushed on the sys channel as a "route" update to clients connected to another node.
+type Route struct {
+	Subject string `json:"sub"`
+	Node    string `json:"node"`          // Node owning the user
+	URL     string `json:"url,omitempty"` // WebSocket URL of the owning node
+	Local   bool   `json:"local"`         // Whether this node owns the user
+}
+
+// route returns the route of the subject, or false if users are not partitioned.
+func (m *ConnectionManager) route(subject string) (Route, bool) {
+	if m.partitions == nil {
+		return Route{}, false
+	}
+	owner, ok := m.partitions.Owner(subject)
+	if !ok {
+		return Route{}, false
+	}
+	return Route{Subject: subject, Node: owner.ID, URL: owner.URL, Local: owner.ID == m.nodeID}, true
+}
+
+// checkAffinity tells an authenticated client owned by another node where to reconnect. The connection is kept,
+// but user-targeted sends from the owning node do not reach it.
+func (c *WsClient) checkAffinity() {
+	subject := subjectOf(c.Claims())
+	if subject == "" {
+		return
+	}
+	route, ok := c.manager.route(subject)
+	if !ok || route.Local {
+		return
+	}
+	c.manager.misrouted.Add(1)
+	c.logger.Debug("Client connected to a node not owning its user", "owner", route.Node)
+	c.SendUpdate("route", sysChannel, &route)
+}
+
+// serveRoute serves the route of the user given by the sub query parameter, for load balancers and clients
+// choosing the node to connect to.
+func (m *ConnectionManager) serveRoute(w http.ResponseWriter, r *http.Request) {
+	subject := r.URL.Query().Get("sub")
+	if subject == "" {
+		http.Error(w, "missing sub parameter", http.StatusBadRequest)
+		return
+	}
+	route, ok := m.route(subject)
+	if !ok {
+		http.Error(w, "no nodes to route to", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(route); err != nil {
+		slog.Error("Failed to write route", "error", err)
+	}
+}
+
+// SetPartitioning assigns users to the nodes of the ring and serves the assignment at /route, so a load balancer
+// or the clients can connect every user to its owning node. All connections of a user then share a node, and
+// user-targeted sends, which only reach the local connections, reach all of them without the broker.
+//
+// Clients connected to another node receive a "route" update on the sys channel. The ring must contain this
+// node's ID and be the same on every node.
+//
+// Params:
+// - ring: The nodes of the cluster.
+func (gw *WsGw) SetPartitioning(ring *partition.Ring) {
+	gw.partitions = ring
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// PolicyViolation is a setting the security policy considers unsafe for production.
+type PolicyViolation struct {
+	Rule    string // Name of the violated rule
+	Message string // What is unsafe and how to fix it
+}
+
+// insecureAuthenticator is implemented by authenticators accepting tokens without verifying them.
+type insecureAuthenticator interface {
+	Insecure() bool
+}
+
+// policyRule checks one aspect of the configuration, returning a violation message or "".
+type policyRule struct {
+	name  string
+	check func(gw *WsGw) string
+}
+
+// policyRules are the checks of the security policy.
+var policyRules = []policyRule{
+	{"verified-auth", func(gw *WsGw) string {
+		if auth, ok := gw.authenticator.(insecureAuthenticator); ok && auth.Insecure() {
+			return "tokens are accepted without verifying their signature; configure a JWT secret, public key, JWKS URL or tenants file"
+		}
+		return ""
+	}},
+	{"origin-check", func(gw *WsGw) string {
+		if gw.config.OriginPolicy == OriginOpen {
+			return "connections are accepted from any origin; use the strict origin policy with the allowed origins"
+		}
+		return ""
+	}},
+	{"cookie-origin", func(gw *WsGw) string {
+		if gw.config.AuthCookie != "" && gw.config.OriginPolicy == OriginOpen {
+			return "the auth cookie is ignored since any origin could use it; use the strict origin policy with the allowed origins"
+		}
+		return ""
+	}},
+}
+
+// CheckPolicy evaluates the security policy over the configuration.
+//
+// Returns:
+// - The violations, empty if the configuration is safe for production.
+func (gw *WsGw) CheckPolicy() []PolicyViolation {
+	var violations []PolicyViolation
+	for _, rule := range policyRules {
+		if message := rule.check(gw); message != "" {
+			violations = append(violations, PolicyViolation{Rule: rule.name, Message: message})
+		}
+	}
+	return violations
+}
+
+// EnforcePolicy refuses a configuration violating the security policy unless insecure settings are allowed, by
+// the profile or explicitly. Tolerated violations are logged as warnings.
+//
+// Returns:
+// - An error joining one error per violation, or nil if the gateway may start.
+func (gw *WsGw) EnforcePolicy() error {
+	violations := gw.CheckPolicy()
+	if gw.config.AllowInsecure {
+		for _, v := range violations {
+			slog.Warn("Insecure configuration", "rule", v.Rule, "profile", gw.config.Profile, "problem", v.Message)
+		}
+		return nil
+	}
+	problems := make([]error, 0, len(violations))
+	for _, v := range violations {
+		problems = append(problems, fmt.Errorf("policy %s: %s", v.Rule, v.Message))
+	}
+	return errors.Join(problems...)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"time"
+)
+
+// presenceChannel carries "online" and "offline" updates of subjects.
+const presenceChannel = "presence"
+
+// PresenceUpdate is published on the presence channel when a subject's first connection becomes active ("online")
+// or its last connection closes ("offline").
+type PresenceUpdate struct {
+	Subject string `json:"sub"`
+	Online  bool   `json:"online"`
+	At      int64  `json:"at"` // Unix time in milliseconds of the change
+}
+
+// enterPresence counts the client's active connection for its subject and announces subjects coming online.
+// Clients already removed are not counted.
+func (m *ConnectionManager) enterPresence(client *WsClient) {
+	subject := subjectOf(client.Claims())
+	if subject == "" {
+		return
+	}
+	m.Lock()
+	if _, connected := m.clients[client.ID()]; !connected || client.presentSubject != "" {
+		m.Unlock()
+		return
+	}
+	client.presentSubject = subject
+	m.presence[subject]++
+	online := m.presence[subject] == 1
+	m.Unlock()
+	if online {
+		m.announcePresence(subject, true)
+	}
+}
+
+// leavePresenceLocked stops counting the client's connection. The caller must hold the lock.
+//
+// Returns:
+// - The subject if its last connection left, "" otherwise.
+func (m *ConnectionManager) leavePresenceLocked(client *WsClient) string {
+	subject := client.presentSubject // Counted subject, even if a later sys/auth changed the claims
+	if subject == "" {
+		return ""
+	}
+	client.presentSubject = ""
+	m.presence[subject]--
+	if m.presence[subject] > 0 {
+		return ""
+	}
+	delete(m.presence, subject)
+	return subject
+}
+
+// announcePresence publishes a presence change of the subject.
+func (m *ConnectionManager) announcePresence(subject string, online bool) {
+	updateType := "offline"
+	if online {
+		updateType = "online"
+	}
+	m.Publish(presenceChannel, updateType, &PresenceUpdate{Subject: subject, Online: online, At: time.Now().UnixMilli()})
+}
+
+// Online reports whether the subject has an active connection on this node.
+func (m *ConnectionManager) Online(subject string) bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.presence[subject] > 0
+}
+
+// FindBySubject returns the connected clients of the subject, e.g. to target a user directly.
+//
+// Params:
+// - subject: The "sub" claim of the user.
+//
+// Returns:
+// - The clients, empty if the user is not connected to this node.
+func (m *ConnectionManager) FindBySubject(subject string) []*WsClient {
+	m.RLock()
+	defer m.RUnlock()
+	return m.clientsBySubjectLocked(subject)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"testing"
+	"time"
+)
+
+// TestReauthAsOtherSubject authenticates a connection as one user and sends sys/auth with another user's token;
+// presence, the subject index and the subscription counts must stay with the first user.
+func TestReauthAsOtherSubject(t *testing.T) {
+	sim := newSimulation(1)
+	expire := sim.clock.Now().Add(time.Hour).Unix()
+	sim.manager.nextClientID++
+	id := sim.manager.nextClientID
+	conn := newSimConn(sim, id)
+	client := NewClient(id, sim.manager, jwt.MapClaims{"sub": "alice", "exp": float64(expire)}, sim.manager.authenticator, expire, sim.manager.config)
+	sim.manager.accept(client, conn)
+	sim.conns = append(sim.conns, conn)
+
+	conn.send("subscribe", "sys", map[string]any{"ch": "news"})
+	conn.send("auth", "sys", map[string]any{"authToken": fmt.Sprintf("sim:mallory:%d", expire)})
+	conn.send("greeting", "greeting", map[string]any{"name": "test"}) // Read once the auth message was handled
+
+	if subject := subjectOf(client.Claims()); subject != "alice" {
+		t.Errorf("subject = %q after re-authentication as mallory, want alice", subject)
+	}
+	if found := sim.manager.FindBySubject("mallory"); len(found) != 0 {
+		t.Errorf("FindBySubject(mallory) = %d connections, want 0", len(found))
+	}
+	if found := sim.manager.FindBySubject("alice"); len(found) != 1 {
+		t.Errorf("FindBySubject(alice) = %d connections, want 1", len(found))
+	}
+	sim.manager.RLock()
+	alice, mallory := sim.manager.presence["alice"], sim.manager.presence["mallory"]
+	subscriptions := sim.manager.userSubscriptions["alice"]
+	sim.manager.RUnlock()
+	if alice != 1 || mallory != 0 {
+		t.Errorf("presence alice=%d mallory=%d, want 1 and 0", alice, mallory)
+	}
+	if subscriptions != 1 {
+		t.Errorf("subscriptions of alice = %d, want 1", subscriptions)
+	}
+
+	sim.finish()
+	sim.manager.RLock()
+	defer sim.manager.RUnlock()
+	if len(sim.manager.presence) != 0 || len(sim.manager.userSubscriptions) != 0 {
+		t.Errorf("counts left after disconnect: presence %v, subscriptions %v", sim.manager.presence, sim.manager.userSubscriptions)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Configuration profiles selecting environment specific defaults.
+const (
+	ProfileDev     = "dev"     // Any origin, insecure settings tolerated, debug logging
+	ProfileStaging = "staging" // Same-origin or listed origins, insecure settings refused, info logging
+	ProfileProd    = "prod"    // Same-origin or listed origins, insecure settings refused, info logging
+)
+
+// Origin policies of the WebSocket endpoint.
+const (
+	OriginOpen   = "open"   // Accept connections from any origin
+	OriginStrict = "strict" // Accept requests without an Origin header, from the endpoint's own host or from AllowedOrigins
+)
+
+// ProfileConfig returns the default settings of a configuration profile. Production-like profiles refuse to start
+// with insecure settings such as the unverified open authenticator, see CheckPolicy, so a gateway cannot reach
+// production accepting forged tokens by accident.
+//
+// Params:
+// - profile: ProfileDev, ProfileStaging or ProfileProd.
+//
+// Returns:
+// - The profile's settings, or an error if the profile is unknown.
+func ProfileConfig(profile string) (Config, error) {
+	config := DefaultConfig()
+	config.Profile = profile
+	switch profile {
+	case ProfileDev:
+		config.OriginPolicy = OriginOpen
+		config.AllowInsecure = true
+		config.LogLevel = "debug"
+	case ProfileStaging, ProfileProd:
+		config.OriginPolicy = OriginStrict
+		config.AllowInsecure = false
+		config.LogLevel = "info"
+	default:
+		return config, fmt.Errorf("unknown profile %q, want %s, %s or %s", profile, ProfileDev, ProfileStaging, ProfileProd)
+	}
+	return config, nil
+}
+
+// SlogLevel returns the log level of the configuration.
+//
+// Returns:
+// - The level, or an error if LogLevel is not debug, info, warn or error.
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("log level %q: want debug, info, warn or error", c.LogLevel)
+	}
+	return level, nil
+}
+
+// checkOrigin returns the upgrader's origin check for the configured policy.
+func checkOrigin(config Config) func(r *http.Request) bool {
+	if config.OriginPolicy != OriginStrict {
+		return func(_ *http.Request) bool {
+			// Allow all connections
+			return true
+		}
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(config.AllowedOrigins, origin) {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"strings"
+)
+
+// SubscriptionLimits bounds the subscriptions a client or user may hold. Zero values disable a limit.
+type SubscriptionLimits struct {
+	MaxPerClient        int // Maximum subscriptions of a single connection
+	MaxPerUser          int // Maximum subscriptions across all connections of a subject
+	MaxPatternWildcards int // Maximum "*" segments in a subscription pattern
+	MaxPatternSegments  int // Maximum dot separated segments in a subscription pattern
+}
+
+// QuotaError is returned in a SubscribeResult when a subscription limit is exceeded.
+type QuotaError struct {
+	Limit string `json:"limit"` // Name of the exceeded limit
+	Max   int    `json:"max"`   // Configured maximum
+}
+
+// Error implements the error interface.
+func (q *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (max %d)", q.Limit, q.Max)
+}
+
+// checkSubscriptionQuota verifies that the client may add a subscription to the channel. The caller must hold the lock.
+func (m *ConnectionManager) checkSubscriptionQuota(client *WsClient, channel string) *QuotaError {
+	limits := m.subscriptionLimits
+	if channels.IsPattern(channel) {
+		segments := strings.Split(channel, ".")
+		if limits.MaxPatternSegments > 0 && len(segments) > limits.MaxPatternSegments {
+			return &QuotaError{Limit: "pattern_segments", Max: limits.MaxPatternSegments}
+		}
+		if limits.MaxPatternWildcards > 0 && strings.Count(channel, "*") > limits.MaxPatternWildcards {
+			return &QuotaError{Limit: "pattern_wildcards", Max: limits.MaxPatternWildcards}
+		}
+	}
+	if limits.MaxPerClient > 0 && len(client.subscriptions) >= limits.MaxPerClient {
+		return &QuotaError{Limit: "client_subscriptions", Max: limits.MaxPerClient}
+	}
+	if subject := subjectOf(client.Claims()); limits.MaxPerUser > 0 && subject != "" {
+		if m.userSubscriptions[subject] >= limits.MaxPerUser {
+			return &QuotaError{Limit: "user_subscriptions", Max: limits.MaxPerUser}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"github.com/gorilla/websocket"
+	"time"
+)
+
+// defaultMaxBreaches is the number of rate limit breaches after which a client is disconnected by default.
+const defaultMaxBreaches = 3
+
+// reasonRateLimited is the close reason of clients disconnected for exceeding their rate limits.
+const reasonRateLimited = "rate limit exceeded"
+
+// RateLimits bounds the ingress of a single connection with token buckets. Zero rates disable a limit.
+type RateLimits struct {
+	MessagesPerSecond float64 // Sustained message rate
+	MessageBurst      int     // Messages accepted at once above the rate, at least 1
+	BytesPerSecond    float64 // Sustained rate of frame bytes
+	ByteBurst         int     // Bytes accepted at once above the rate; 0 uses the read limit
+	MaxBreaches       int     // Breaches tolerated before disconnecting; 0 uses the default of 3
+}
+
+// RateLimitNotice is sent on the sys channel when a client first exceeds its rate limits. Its messages are dropped
+// while it stays above them and it is disconnected after repeated breaches.
+type RateLimitNotice struct {
+	Limit       string `json:"limit"`       // "messages" or "bytes"
+	MaxBreaches int    `json:"maxBreaches"` // Breaches tolerated before disconnecting
+}
+
+// tokenBucket refills at rate tokens per second up to burst.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket.
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// take removes n tokens if available.
+func (b *tokenBucket) take(n float64, now time.Time) bool {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// rateLimiter holds the token buckets of a connection. It is only used by the client's read loop.
+type rateLimiter struct {
+	messages *tokenBucket // nil if unlimited
+	bytes    *tokenBucket // nil if unlimited
+	breaches int          // Times the client went above its limits
+	limited  bool         // Set while messages are dropped, cleared by the next accepted message
+}
+
+// newRateLimiter creates the limiter of a connection, or returns nil if no limit is configured.
+func newRateLimiter(limits RateLimits, readLimit int64, now time.Time) *rateLimiter {
+	if limits.MessagesPerSecond <= 0 && limits.BytesPerSecond <= 0 {
+		return nil
+	}
+	l := &rateLimiter{}
+	if limits.MessagesPerSecond > 0 {
+		l.messages = newTokenBucket(limits.MessagesPerSecond, max(limits.MessageBurst, 1), now)
+	}
+	if limits.BytesPerSecond > 0 {
+		burst := limits.ByteBurst
+		if burst == 0 {
+			burst = int(readLimit)
+		}
+		l.bytes = newTokenBucket(limits.BytesPerSecond, burst, now)
+	}
+	return l
+}
+
+// allowIngress takes a frame of the given size from the client's token buckets. Frames above the limits are
+// dropped; the first breach is answered with a warning and repeated breaches close the connection.
+//
+// Returns:
+// - accepted: Whether the frame may be processed.
+// - open: false if the connection was closed.
+func (c *WsClient) allowIngress(size int) (accepted bool, open bool) {
+	l := c.limiter
+	if l == nil {
+		return true, true
+	}
+	now := c.manager.clock.Now()
+	limit := ""
+	if l.messages != nil && !l.messages.take(1, now) {
+		limit = "messages"
+	} else if l.bytes != nil && !l.bytes.take(float64(size), now) {
+		limit = "bytes"
+	}
+	if limit == "" {
+		l.limited = false
+		return true, true
+	}
+	c.manager.rateLimitedMessages.Add(1)
+	if l.limited {
+		return false, true
+	}
+	l.limited = true
+	l.breaches++
+	maxBreaches := c.manager.rateLimits.MaxBreaches
+	if maxBreaches <= 0 {
+		maxBreaches = defaultMaxBreaches
+	}
+	if l.breaches > maxBreaches {
+		c.logger.Warn("Client disconnected for exceeding its rate limits", "limit", limit, "breaches", l.breaches)
+		c.manager.rateLimitDisconnects.Add(1)
+		c.manager.closeClients([]*WsClient{c}, websocket.ClosePolicyViolation, reasonRateLimited)
+		return false, false
+	}
+	if l.breaches == 1 {
+		c.logger.Info("Client exceeded its rate limits", "limit", limit)
+		c.manager.rateLimitedClients.Add(1)
+		c.SendUpdate("rate_limited", sysChannel, &RateLimitNotice{Limit: limit, MaxBreaches: maxBreaches})
+	}
+	return false, true
+}
+
+// SetRateLimits limits the ingress of every connection with token buckets on messages and bytes per second.
+//
+// Params:
+// - limits: The per-connection limits.
+func (gw *WsGw) SetRateLimits(limits RateLimits) {
+	gw.rateLimits = limits
+}
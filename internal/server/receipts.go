@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"go-websocket-boilerplate/internal/ids"
+	"go-websocket-boilerplate/internal/msgs"
+	"go-websocket-boilerplate/internal/receipts"
+)
+
+// ReadMsg is the payload of sys/read requests and their responses.
+type ReadMsg struct {
+	Channel string `json:"ch"`    // Channel the user read
+	MsgID   string `json:"msgId"` // ID of the last message read; earlier messages count as read too
+}
+
+// trackDelivered reports the delivery of a client-authored channel message to the client's user.
+func (c *WsClient) trackDelivered(msg *EgressMsg) {
+	if c.manager.receipts == nil || msg.MessageID == "" || msg.originSubject == "" || isSysChannel(msg.Channel) {
+		return
+	}
+	user := subjectOf(c.Claims())
+	if user == "" || user == msg.originSubject {
+		return
+	}
+	c.manager.receipts.Delivered(msg.Channel, msg.MessageID, msg.originSubject, user)
+}
+
+// pushReceipt sends the receipt to the connections of the message's author.
+func (m *ConnectionManager) pushReceipt(receipt receipts.Receipt) {
+	m.RLock()
+	authors := m.clientsBySubjectLocked(receipt.Author)
+	m.RUnlock()
+	for _, client := range authors {
+		client.SendUpdate("receipt", sysChannel, &receipt)
+	}
+}
+
+// handleReadMsg processes sys/read requests, advancing the user's read marker of the channel and marking the
+// messages up to the marker as read.
+func (c *WsClient) handleReadMsg(request IngressMsg) {
+	tracker := c.manager.receipts
+	user := subjectOf(c.Claims())
+	if tracker == nil || user == "" {
+		c.sendError(request, msgs.CodeUnavailable, "read receipts not available")
+		return
+	}
+	msg := &ReadMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil || msg.Channel == "" || msg.MsgID == "" {
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if err := c.manager.checkChannelAccess(c, msg.Channel); err != nil {
+		c.sendErrorOf(request, err, msgs.CodePermissionDenied)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.context, sessionStoreTimeout)
+	defer cancel()
+	previous, err := tracker.Store().ReadMarker(ctx, user, msg.Channel)
+	if err != nil {
+		c.logger.Error("Failed to load read marker", "ch", msg.Channel, "error", err)
+		c.sendError(request, msgs.CodeInternal, "read marker update failed")
+		return
+	}
+	if ids.Compare(msg.MsgID, previous) <= 0 {
+		// Markers only move forward; report the current one.
+		c.SendResponse(request.ID(), request.Type(), request.Channel(), &ReadMsg{Channel: msg.Channel, MsgID: previous})
+		return
+	}
+	if err := tracker.Store().SetReadMarker(ctx, user, msg.Channel, msg.MsgID); err != nil {
+		c.logger.Error("Failed to store read marker", "ch", msg.Channel, "error", err)
+		c.sendError(request, msgs.CodeInternal, "read marker update failed")
+		return
+	}
+
+	tracker.Read(msg.Channel, msg.MsgID, user)
+	tracker.UnreadChanged(user, msg.Channel)
+	for _, read := range c.manager.historySince(msg.Channel, previous, msg.MsgID) {
+		if read.originSubject != "" && read.originSubject != user && read.MessageID != msg.MsgID {
+			tracker.Read(msg.Channel, read.MessageID, user)
+		}
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), msg)
+}
+
+// historySince returns the messages of the channel history after the message `after`, or from the start if it is
+// empty, up to and including the message `until`.
+func (m *ConnectionManager) historySince(channel string, after string, until string) []*EgressMsg {
+	m.RLock()
+	defer m.RUnlock()
+	messages := make([]*EgressMsg, 0)
+	for _, msg := range m.history[channel] {
+		if msg.Deleted || msg.MessageID == "" {
+			continue
+		}
+		if (after == "" || ids.Compare(msg.MessageID, after) > 0) && ids.Compare(msg.MessageID, until) <= 0 {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// SetReceiptTracker enables read receipts and delivery-state tracking. The caller runs the tracker's loop.
+//
+// Params:
+// - tracker: The tracker recording deliveries and reads.
+func (gw *WsGw) SetReceiptTracker(tracker *receipts.Tracker) {
+	gw.receipts = tracker
+}
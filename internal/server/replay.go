@@ -0,0 +1,62 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	errNonceMissing  = errors.New("nonce and timestamp are required")
+	errNonceStale    = errors.New("timestamp outside replay window")
+	errNonceReplayed = errors.New("nonce already used")
+)
+
+// replayGuard remembers the nonces a client used within the replay window.
+type replayGuard struct {
+	sync.Mutex
+	window time.Duration        // Maximum allowed clock skew and nonce retention time
+	seen   map[string]time.Time // Nonces seen within the window and the time they were accepted
+}
+
+// newReplayGuard creates a replayGuard with the given window.
+func newReplayGuard(window time.Duration) *replayGuard {
+	return &replayGuard{window: window, seen: make(map[string]time.Time)}
+}
+
+// check verifies that the nonce has not been used and that the timestamp lies within the window.
+//
+// Params:
+// - nonce: The client generated nonce from the envelope.
+// - timestamp: The client timestamp from the envelope in Unix milliseconds.
+// - now: The current time.
+//
+// Returns:
+// - An error if the message must be rejected as a possible replay.
+func (g *replayGuard) check(nonce string, timestamp int64, now time.Time) error {
+	if nonce == "" || timestamp == 0 {
+		return errNonceMissing
+	}
+	sent := time.UnixMilli(timestamp)
+	if now.Sub(sent) > g.window || sent.Sub(now) > g.window {
+		return errNonceStale
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	for n, at := range g.seen {
+		if now.Sub(at) > 2*g.window {
+			delete(g.seen, n)
+		}
+	}
+	if _, ok := g.seen[nonce]; ok {
+		return errNonceReplayed
+	}
+	g.seen[nonce] = now
+	return nil
+}
+
+// requiresNonce reports whether messages on the channel must carry a nonce and timestamp.
+func (m *ConnectionManager) requiresNonce(channel string) bool {
+	return m.replayChannels[channel]
+}
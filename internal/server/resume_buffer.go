@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"go-websocket-boilerplate/internal/session"
+	"time"
+)
+
+// defaultResumeBufferTTL is how long the frames of a disconnected session stay replayable by default.
+const defaultResumeBufferTTL = 2 * time.Minute
+
+// AckMsg acknowledges the frames a client received, up to and including a sequence number.
+type AckMsg struct {
+	Seq uint64 `json:"seq"`
+}
+
+// bufferFrame keeps a frame written to the client for replay after a resume. Only frames of resumable,
+// authenticated sessions are buffered.
+func (c *WsClient) bufferFrame(seq uint64, frame []byte) {
+	if c.manager.resumeBuffer == nil || c.resumeToken == "" || !c.isAuthenticated() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	err := c.manager.resumeBuffer.Append(ctx, c.resumeToken, session.Frame{Seq: seq, Data: frame}, c.manager.resumeBufferTTL)
+	if err != nil {
+		c.logger.Error("Failed to buffer frame", "seq", seq, "error", err)
+	}
+}
+
+// replayMissed sends a resuming client the buffered frames after the last sequence number it received, in their
+// original form and order.
+func (c *WsClient) replayMissed() {
+	if c.manager.resumeBuffer == nil || !c.resumed {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	frames, err := c.manager.resumeBuffer.Since(ctx, c.resumeToken, c.lastSeq)
+	if err != nil {
+		c.logger.Error("Failed to load buffered frames", "error", err)
+		return
+	}
+	if err := c.manager.resumeBuffer.Ack(ctx, c.resumeToken, c.lastSeq); err != nil {
+		c.logger.Error("Failed to acknowledge buffered frames", "error", err)
+	}
+	for _, frame := range frames {
+		c.deliver(&EgressMsg{frame: frame.Data, created: time.Now()})
+	}
+	c.logger.Info("Missed frames replayed", "from", c.lastSeq, "count", len(frames))
+}
+
+// handleAckMsg discards the buffered frames a sys/ack acknowledges.
+func (c *WsClient) handleAckMsg(request IngressMsg) {
+	msg := &AckMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil {
+		c.logger.Error("error unmarshalling ack msg", "error", err)
+		return
+	}
+	if c.manager.resumeBuffer == nil || c.resumeToken == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	if err := c.manager.resumeBuffer.Ack(ctx, c.resumeToken, msg.Seq); err != nil {
+		c.logger.Error("Failed to acknowledge buffered frames", "error", err)
+	}
+}
+
+// SetResumeBuffer buffers the frames written to resumable sessions, so a client reconnecting with its resume
+// token and the last sequence number it received ("resume" and "lastSeq" query parameters) is sent the frames it
+// missed. Clients acknowledge received frames with sys/ack to release them early.
+//
+// Params:
+// - buffer: The frame buffer, e.g. a session.MemoryBuffer or a session.RedisBuffer shared by all nodes.
+// - ttl: How long the frames of a disconnected session stay replayable; 0 uses the default of two minutes.
+func (gw *WsGw) SetResumeBuffer(buffer session.Buffer, ttl time.Duration) {
+	gw.resumeBuffer = buffer
+	gw.resumeBufferTTL = ttl
+}
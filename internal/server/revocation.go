@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/revocation"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrTokenRevoked is returned when a valid token was revoked by a logout or because its user was disabled.
+var ErrTokenRevoked = errors.New("token revoked")
+
+// RevokeRequest is the body of a POST to /admin/revoke. Either TokenID or Subject must be set.
+type RevokeRequest struct {
+	TokenID string `json:"jti,omitempty"` // Revokes a single token
+	Subject string `json:"sub,omitempty"` // Revokes every token of the subject issued until now
+	Until   int64  `json:"until"`         // Unix time the revocation can be forgotten: the latest expiry of an affected token
+}
+
+// revocationWatcher is implemented by revocation stores announcing revocations made on any node.
+type revocationWatcher interface {
+	Watch(ctx context.Context, notify func())
+}
+
+// checkRevoked rejects the claims if their token was revoked.
+func (m *ConnectionManager) checkRevoked(claims jwt.MapClaims) error {
+	if m.revocations != nil && revocation.IsRevoked(m.revocations, claims) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// watchRevocations checks the connected clients periodically and whenever the store announces a revocation.
+func (m *ConnectionManager) watchRevocations(interval time.Duration) {
+	sweep := make(chan struct{}, 1)
+	if watcher, ok := m.revocations.(revocationWatcher); ok {
+		go watcher.Watch(context.Background(), func() {
+			select {
+			case sweep <- struct{}{}:
+			default:
+			}
+		})
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-sweep:
+		}
+		m.disconnectRevoked()
+	}
+}
+
+// disconnectRevoked closes the connections whose token was revoked.
+func (m *ConnectionManager) disconnectRevoked() {
+	m.RLock()
+	clients := make([]*WsClient, 0, len(m.clients))
+	for _, client := range m.clients {
+		if client.isAuthenticated() {
+			clients = append(clients, client)
+		}
+	}
+	m.RUnlock()
+	for _, client := range clients {
+		if m.checkRevoked(client.Claims()) != nil {
+			client.logger.Info("Token revoked, disconnecting")
+			client.revoked.Store(true) // Its session is deleted rather than saved for a resume
+			client.Close()
+		}
+	}
+}
+
+// serveRevoke records a revocation posted by an administrator or the identity provider's logout hook.
+func (m *ConnectionManager) serveRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	var request RevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || (request.TokenID == "") == (request.Subject == "") {
+		http.Error(w, "exactly one of jti and sub is required", http.StatusBadRequest)
+		return
+	}
+	until := time.Unix(request.Until, 0)
+	if !until.After(time.Now()) {
+		http.Error(w, "until must be in the future, at the latest expiry of an affected token", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if request.TokenID != "" {
+		err = m.revocations.RevokeToken(request.TokenID, until)
+	} else {
+		err = m.revocations.RevokeSubject(request.Subject, time.Now(), until)
+	}
+	if err != nil {
+		slog.Error("Failed to store revocation", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Token revoked", "jti", request.TokenID, "sub", request.Subject)
+	w.WriteHeader(http.StatusNoContent)
+	go m.disconnectRevoked()
+}
+
+// SetRevocationList sets the store of revoked tokens. Revoked tokens are rejected on connect and sys/auth, and
+// connected clients holding one are disconnected within the check interval. Revocations are posted to
+// /admin/revoke or written to the store directly.
+//
+// Params:
+// - store: The revocation store, e.g. a revocation.RedisStore shared by the cluster.
+// - interval: How often connected clients are checked; 5 seconds if 0.
+func (gw *WsGw) SetRevocationList(store revocation.Store, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	gw.revocations = store
+	gw.revocationInterval = interval
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/revocation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRevokedSessionNotResumable revokes the token of a connected user; the disconnect must delete its stored
+// session instead of saving it, and a resume of a session stored before the revocation must be refused.
+func TestRevokedSessionNotResumable(t *testing.T) {
+	sim := newSimulation(1)
+	revocations := revocation.NewMemoryStore()
+	sim.manager.revocations = revocations
+	expire := time.Now().Add(time.Hour).Unix() // Sessions are stored until the token expires in real time
+	sim.manager.nextClientID++
+	id := sim.manager.nextClientID
+	conn := newSimConn(sim, id)
+	client := NewClient(id, sim.manager, jwt.MapClaims{"sub": "alice", "exp": float64(expire)}, sim.manager.authenticator, expire, sim.manager.config)
+	sim.manager.accept(client, conn)
+	sim.conns = append(sim.conns, conn)
+	conn.send("greeting", "greeting", map[string]any{"name": "test"})
+
+	token := client.resumeToken
+	stored := client.session()
+
+	if err := revocations.RevokeSubject("alice", time.Now().Add(time.Second), time.Unix(expire, 0)); err != nil {
+		t.Fatalf("RevokeSubject: %v", err)
+	}
+	sim.manager.disconnectRevoked()
+	sim.finish()
+	if sim.manager.resume(token) != nil {
+		t.Error("session of a revoked client saved on disconnect")
+	}
+
+	// A session stored before the revocation, e.g. by another node
+	if err := sim.manager.sessions.Save(context.Background(), stored, time.Hour); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	w := httptest.NewRecorder()
+	sim.manager.ServeWs(w, httptest.NewRequest(http.MethodGet, "/ws?resume="+token, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("resume with a revoked token answered %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if sim.manager.resume(token) != nil {
+		t.Error("session kept after a resume with a revoked token")
+	}
+}
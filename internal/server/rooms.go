@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"go-websocket-boilerplate/internal/msgs"
+	"go-websocket-boilerplate/internal/rooms"
+)
+
+// RoomMsg is the payload of sys/join and sys/leave requests.
+type RoomMsg struct {
+	Room string `json:"room"` // Name of the room
+}
+
+// RoomAuthorizer decides whether the client with the claims may join the room.
+type RoomAuthorizer func(claims jwt.MapClaims, room string) bool
+
+// RoomResult is the response to sys/join and sys/leave requests.
+type RoomResult struct {
+	Room    string `json:"room"`
+	OK      bool   `json:"ok"`
+	Members int    `json:"members"`         // Members of the room after the request
+	Error   string `json:"error,omitempty"` // Reason the request failed
+}
+
+// handleRoomMsg processes sys/join and sys/leave requests of authenticated clients.
+func (c *WsClient) handleRoomMsg(request IngressMsg) {
+	msg := &RoomMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil || msg.Room == "" {
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	result := RoomResult{Room: msg.Room, OK: true}
+	switch {
+	case subjectOf(c.Claims()) == "":
+		result = RoomResult{Room: msg.Room, Error: "not authenticated"}
+	case request.Type() == "join" && c.manager.roomAuthorizer != nil && !c.manager.roomAuthorizer(c.Claims(), msg.Room):
+		result = RoomResult{Room: msg.Room, Error: errPermissionDenied.Message}
+	case request.Type() == "join":
+		if err := c.manager.rooms.Join(msg.Room, c.ID()); err != nil {
+			result = RoomResult{Room: msg.Room, Error: err.Error()}
+		}
+	default:
+		c.manager.rooms.Leave(msg.Room, c.ID())
+	}
+	result.Members = c.manager.rooms.Size(msg.Room)
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), result)
+}
+
+// BroadcastToRoom sends the message to every connection that joined the room.
+//
+// A message with an OriginClientID is not delivered to members who blocked the sender. The message is shared by
+// all members, so it must not be modified afterwards.
+//
+// Params:
+// - room: The room to broadcast to.
+// - msg: The message to send.
+func (m *ConnectionManager) BroadcastToRoom(room string, msg *EgressMsg) {
+	if msg.MessageID == "" {
+		msg.MessageID = m.ids.NewID() // Shared by all members
+	}
+	members := m.rooms.Members(room)
+	m.RLock()
+	sender := msg.originSubject
+	if sender == "" && msg.OriginClientID != 0 {
+		if origin, ok := m.clients[msg.OriginClientID]; ok {
+			sender = subjectOf(origin.Claims())
+		}
+	}
+	recipients := make([]*WsClient, 0, len(members))
+	for _, id := range members {
+		if client, ok := m.clients[id]; ok {
+			recipients = append(recipients, client)
+		}
+	}
+	m.RUnlock()
+	for _, client := range recipients {
+		if sender != "" && m.blockChecker != nil && m.blockChecker.IsBlocked(subjectOf(client.Claims()), sender) {
+			continue
+		}
+		client.send(msg)
+	}
+}
+
+// leaveRooms removes a disconnecting client from its rooms.
+func (m *ConnectionManager) leaveRooms(client *WsClient) {
+	if left := m.rooms.LeaveAll(client.ID()); len(left) > 0 {
+		client.logger.Debug("Left rooms on disconnect", "rooms", left)
+	}
+}
+
+// SetRoomLimit limits the number of rooms a connection may join at a time. It must be called before Start.
+//
+// Params:
+// - maxRooms: The number of rooms, or 0 for no limit.
+func (gw *WsGw) SetRoomLimit(maxRooms int) {
+	gw.maxRooms = maxRooms
+}
+
+// SetRoomAuthorizer sets the policy deciding who may join a room with sys/join, like the channel ACLs do for
+// subscriptions. It must be called before Start.
+//
+// Params:
+// - authorizer: Decides whether a client may join a room; nil lets every authenticated client join any room.
+func (gw *WsGw) SetRoomAuthorizer(authorizer RoomAuthorizer) {
+	gw.roomAuthorizer = authorizer
+}
+
+// BroadcastToRoom sends the message to every connection that joined the room. It does nothing before Start.
+//
+// Params:
+// - room: The room to broadcast to.
+// - msg: The message to send.
+func (gw *WsGw) BroadcastToRoom(room string, msg *EgressMsg) {
+	if gw.manager != nil {
+		gw.manager.BroadcastToRoom(room, msg)
+	}
+}
+
+// Rooms returns the hub tracking room memberships, or nil before Start.
+func (gw *WsGw) Rooms() *rooms.Hub {
+	if gw.manager == nil {
+		return nil
+	}
+	return gw.manager.rooms
+}
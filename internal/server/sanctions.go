@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/broker"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Close reasons of sanctioned clients.
+const (
+	reasonKicked = "kicked"
+	reasonBanned = "banned"
+)
+
+// Sanction is a kick or ban applied on every node of the cluster.
+type Sanction struct {
+	Subject string `json:"sub,omitempty"`    // Subject whose connections are closed
+	IP      string `json:"ip,omitempty"`     // IP address whose connections are closed
+	Reason  string `json:"reason,omitempty"` // Logged and sent as the close reason
+	Until   int64  `json:"until,omitempty"`  // End of a ban in Unix timestamp; 0 for a kick
+}
+
+// BanRequest is the body of a POST to /admin/ban.
+type BanRequest struct {
+	Subject string `json:"sub,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Seconds int64  `json:"seconds"` // Duration of the ban
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SanctionResult is the response of /admin/kick and /admin/ban.
+type SanctionResult struct {
+	Disconnected int `json:"disconnected"` // Connections closed on the node serving the request
+}
+
+// Kick closes the connections of the subject on every node of the cluster. The user may reconnect.
+//
+// Params:
+// - subject: The subject whose connections are closed.
+// - reason: The close reason sent to the clients; "kicked" if empty.
+//
+// Returns:
+// - The number of connections closed on this node.
+func (m *ConnectionManager) Kick(subject string, reason string) int {
+	sanction := Sanction{Subject: subject, Reason: reason}
+	m.relaySanction(broker.KindKick, sanction)
+	return m.applySanction(sanction)
+}
+
+// Ban closes the connections of the subject and of the IP address on every node of the cluster and rejects
+// their new connections until the ban ends.
+//
+// Params:
+// - subject: The subject to ban, or "".
+// - ip: The IP address to ban, or "".
+// - duration: How long the ban lasts.
+// - reason: The close reason sent to the clients; "banned" if empty.
+//
+// Returns:
+// - The number of connections closed on this node.
+func (m *ConnectionManager) Ban(subject string, ip string, duration time.Duration, reason string) int {
+	sanction := Sanction{Subject: subject, IP: ip, Reason: reason, Until: time.Now().Add(duration).Unix()}
+	m.relaySanction(broker.KindBan, sanction)
+	return m.applySanction(sanction)
+}
+
+// applySanction records a ban and closes the local connections of the sanctioned subject and IP address.
+//
+// Returns:
+// - The number of connections closed.
+func (m *ConnectionManager) applySanction(sanction Sanction) int {
+	reason := sanction.Reason
+	if sanction.Until != 0 {
+		m.ban(sanction.Subject, sanction.IP, time.Unix(sanction.Until, 0))
+		if reason == "" {
+			reason = reasonBanned
+		}
+	} else if reason == "" {
+		reason = reasonKicked
+	}
+	m.RLock()
+	var sanctioned []*WsClient
+	if sanction.Subject != "" {
+		sanctioned = m.clientsBySubjectLocked(sanction.Subject)
+	}
+	if sanction.IP != "" {
+		for _, client := range m.clients {
+			if client.ip == sanction.IP && (sanction.Subject == "" || subjectOf(client.Claims()) != sanction.Subject) {
+				sanctioned = append(sanctioned, client)
+			}
+		}
+	}
+	m.RUnlock()
+	if len(sanctioned) > 0 {
+		slog.Info("Closing sanctioned connections", "sub", sanction.Subject, "ip", sanction.IP, "reason", reason, "count", len(sanctioned))
+	}
+	m.closeClients(sanctioned, websocket.ClosePolicyViolation, reason)
+	return len(sanctioned)
+}
+
+// relaySanction sends a kick or ban to the other nodes of the cluster, if a broker is configured.
+func (m *ConnectionManager) relaySanction(kind string, sanction Sanction) {
+	if m.broker == nil {
+		return
+	}
+	data, err := json.Marshal(sanction)
+	if err != nil {
+		slog.Error("Failed to encode sanction", "kind", kind, "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	if err := m.broker.Publish(ctx, broker.Message{Node: m.nodeID, Kind: kind, Data: data}); err != nil {
+		slog.Error("Failed to relay sanction to the cluster", "kind", kind, "sub", sanction.Subject, "error", err)
+	}
+}
+
+// receiveSanction applies a kick or ban relayed by another node.
+func (m *ConnectionManager) receiveSanction(relayed broker.Message) {
+	var sanction Sanction
+	if err := json.Unmarshal(relayed.Data, &sanction); err != nil {
+		slog.Warn("Malformed relayed sanction", "kind", relayed.Kind, "node", relayed.Node, "error", err)
+		return
+	}
+	m.applySanction(sanction)
+}
+
+// serveKick kicks the subject posted by an administrator as {"sub": ..., "reason": ...} from every node.
+func (m *ConnectionManager) serveKick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	var request Sanction
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Subject == "" {
+		http.Error(w, "sub is required", http.StatusBadRequest)
+		return
+	}
+	writeSanctionResult(w, m.Kick(request.Subject, request.Reason))
+}
+
+// serveBan bans the subject or IP address posted by an administrator as a BanRequest on every node.
+func (m *ConnectionManager) serveBan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.authorizeAdmin(w, r) {
+		return
+	}
+	var request BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || (request.Subject == "" && request.IP == "") || request.Seconds <= 0 {
+		http.Error(w, "sub or ip and positive seconds are required", http.StatusBadRequest)
+		return
+	}
+	writeSanctionResult(w, m.Ban(request.Subject, request.IP, time.Duration(request.Seconds)*time.Second, request.Reason))
+}
+
+// writeSanctionResult writes the number of connections a sanction closed.
+func writeSanctionResult(w http.ResponseWriter, disconnected int) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&SanctionResult{Disconnected: disconnected}); err != nil {
+		slog.Error("Failed to write sanction result", "error", err)
+	}
+}
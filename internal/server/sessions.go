@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/session"
+	"log/slog"
+	"time"
+)
+
+// SessionInfo is sent to the client on the sys channel as a "session" update after connecting.
+type SessionInfo struct {
+	ResumeToken string `json:"resumeToken"` // Token to present in the "resume" query parameter when reconnecting
+}
+
+// sessionStoreTimeout bounds calls to the session store.
+const sessionStoreTimeout = 2 * time.Second
+
+// assignResumeToken assigns a resume token to the client, unless it resumed an existing session. It is called
+// before the client's goroutines start, which read the token.
+func (c *WsClient) assignResumeToken() {
+	if c.resumeToken != "" {
+		return
+	}
+	token, err := session.NewToken(c.manager.ids.NewID())
+	if err != nil {
+		c.logger.Error("Failed to issue resume token", "error", err)
+		return
+	}
+	c.resumeToken = token
+}
+
+// issueResumeToken stores the client's session and sends its resume token.
+func (c *WsClient) issueResumeToken() {
+	if c.resumeToken == "" {
+		return
+	}
+	c.saveSession()
+	c.SendUpdate("session", "sys", &SessionInfo{ResumeToken: c.resumeToken})
+}
+
+// session returns the resumable state of the client.
+func (c *WsClient) session() *session.Session {
+	return &session.Session{
+		Token:   c.resumeToken,
+		Subject: subjectOf(c.Claims()),
+		Claims:  c.Claims(),
+		Expire:  c.expire.Load(),
+		Cursor:  c.seq.Load(),
+		Updated: time.Now().Unix(),
+
+		Experiments: c.experiments,
+	}
+}
+
+// saveSession stores the client's session until its authentication expires. Unauthenticated clients are not stored.
+func (c *WsClient) saveSession() {
+	if !c.isAuthenticated() || c.resumeToken == "" {
+		return
+	}
+	ttl := time.Until(time.Unix(c.expire.Load(), 0))
+	if ttl <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	if err := c.manager.sessions.Save(ctx, c.session(), ttl); err != nil {
+		c.logger.Error("Failed to save session", "error", err)
+	}
+}
+
+// deleteSession removes the client's stored session, so it can no longer be resumed.
+func (c *WsClient) deleteSession() {
+	if c.resumeToken != "" {
+		c.manager.deleteSession(c.resumeToken)
+	}
+}
+
+// deleteSession removes the session stored for the resume token.
+func (m *ConnectionManager) deleteSession(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	if err := m.sessions.Delete(ctx, token); err != nil {
+		slog.Error("Failed to delete session", "error", err)
+	}
+}
+
+// resume looks up a stored session by its resume token.
+//
+// Expired sessions are discarded.
+//
+// Returns:
+// - The session, or nil if no valid session was found.
+func (m *ConnectionManager) resume(token string) *session.Session {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	s, err := m.sessions.Load(ctx, token)
+	if err != nil {
+		if !errors.Is(err, session.ErrNotFound) {
+			slog.Error("Failed to load session", "error", err)
+		}
+		return nil
+	}
+	if s.Expire <= time.Now().Unix() || s.Claims == nil {
+		_ = m.sessions.Delete(ctx, token)
+		return nil
+	}
+	return s
+}
+
+// Snapshot returns the resumable state of all connected, authenticated clients.
+func (m *ConnectionManager) Snapshot() *session.Snapshot {
+	m.RLock()
+	defer m.RUnlock()
+	sessions := make([]*session.Session, 0, len(m.clients))
+	for _, client := range m.clients {
+		if client.isAuthenticated() && client.resumeToken != "" {
+			sessions = append(sessions, client.session())
+		}
+	}
+	return session.NewSnapshot(sessions)
+}
+
+// LoadSnapshot makes the sessions of a snapshot resumable by reconnecting clients.
+func (m *ConnectionManager) LoadSnapshot(snapshot *session.Snapshot) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	for _, s := range snapshot.Sessions {
+		ttl := time.Until(time.Unix(s.Expire, 0))
+		if ttl <= 0 {
+			continue
+		}
+		if err := m.sessions.Save(ctx, s, ttl); err != nil {
+			slog.Error("Failed to restore session", "error", err)
+		}
+	}
+}
+
+// closeAll asks every connected client to reconnect with the given close code and reason.
+func (m *ConnectionManager) closeAll(code int, reason string) {
+	m.closeClients(m.connectedClients(), code, reason)
+}
+
+// connectedClients returns the connected clients.
+func (m *ConnectionManager) connectedClients() []*WsClient {
+	m.RLock()
+	defer m.RUnlock()
+	clients := make([]*WsClient, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// closeClients asks the clients to reconnect with the given close code and reason.
+func (m *ConnectionManager) closeClients(clients []*WsClient, code int, reason string) {
+	deadline := time.Now().Add(time.Second)
+	for _, client := range clients {
+		if client.connection != nil {
+			msg := websocket.FormatCloseMessage(code, reason)
+			if err := client.connection.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+				client.Logger().Info("Failed to send close message", "error", err)
+			}
+		}
+		m.removeClient(client)
+	}
+}
+
+// SetSessionStore sets the store backing resume tokens. The default is an in-memory store.
+//
+// Params:
+// - store: The session store, e.g. a session.RedisStore shared by all nodes.
+func (gw *WsGw) SetSessionStore(store session.Store) {
+	gw.sessions = store
+}
+
+// SetHandoffFile sets the file sessions are loaded from at startup and written to by Handoff.
+//
+// This is experimental: a restarting process writes the snapshot, and the new process picks it up so clients
+// reconnecting with their resume token keep their authenticated session.
+//
+// Params:
+// - path: The snapshot file path.
+func (gw *WsGw) SetHandoffFile(path string) {
+	gw.handoffFile = path
+}
+
+// loadHandoff loads sessions handed over by a previous process, if a snapshot file exists.
+func (gw *WsGw) loadHandoff(manager *ConnectionManager) {
+	if gw.handoffFile == "" {
+		return
+	}
+	snapshot, err := session.ReadSnapshotFile(gw.handoffFile)
+	if err != nil {
+		slog.Info("No session snapshot loaded", "file", gw.handoffFile, "error", err)
+		return
+	}
+	manager.LoadSnapshot(snapshot)
+	slog.Info("Session snapshot loaded", "file", gw.handoffFile, "sessions", len(snapshot.Sessions))
+}
+
+// Handoff writes the sessions of all connected clients to the handoff file and asks the clients to reconnect.
+//
+// Returns:
+// - An error if the snapshot could not be written.
+func (gw *WsGw) Handoff() error {
+	if gw.manager == nil || gw.handoffFile == "" {
+		return nil
+	}
+	snapshot := gw.manager.Snapshot()
+	if err := session.WriteSnapshotFile(gw.handoffFile, snapshot); err != nil {
+		return err
+	}
+	slog.Info("Session snapshot written", "file", gw.handoffFile, "sessions", len(snapshot.Sessions))
+	gw.manager.closeAll(websocket.CloseServiceRestart, "handoff")
+	return nil
+}
@@ -0,0 +1,388 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SimulationReport is the outcome of one simulated run of connection lifecycles.
+type SimulationReport struct {
+	Seed        uint64   `json:"seed"`
+	Steps       int      `json:"steps"`
+	Connections int      `json:"connections"` // Connections opened during the run
+	Violations  []string `json:"violations,omitempty"`
+}
+
+// Simulate runs a randomized interleaving of connects, authentications, messages, clock advances, expiries and
+// disconnects against a connection manager with an in-memory transport and a manual clock, and checks the
+// lifecycle invariants:
+//
+//   - ClientConnected fires at most once per connection, and only for authenticated connections.
+//   - ClientConnected fires only after the connection has moved to StateActive.
+//   - No frame is written to a connection after it was closed.
+//   - Once every connection is gone, no keepalive ticker or auth expiry timer is left running.
+//
+// The sequence of events is derived from the seed, so a failing seed can be replayed.
+//
+// Params:
+// - seed: Seeds the random choice of events.
+// - steps: The number of events.
+//
+// Returns:
+// - The report listing any violated invariants.
+func Simulate(seed uint64, steps int) *SimulationReport {
+	sim := newSimulation(seed)
+	for step := 0; step < steps; step++ {
+		sim.step()
+	}
+	sim.finish()
+	sort.Strings(sim.violations)
+	return &SimulationReport{Seed: seed, Steps: steps, Connections: sim.opened, Violations: sim.violations}
+}
+
+// simulation holds the state of one run.
+type simulation struct {
+	sync.Mutex
+	rng        *rand.Rand
+	clock      *manualClock
+	manager    *ConnectionManager
+	conns      []*simConn      // Connections not yet disconnected by the peer
+	connected  map[int]int     // ClientConnected calls by connection ID
+	opened     int             // Connections opened
+	violations []string        // Violated invariants
+	seen       map[string]bool // Violations already reported
+}
+
+// newSimulation creates a manager wired to the manual clock and the simulation's connection handler.
+func newSimulation(seed uint64) *simulation {
+	sim := &simulation{
+		rng:       rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		clock:     &manualClock{now: time.Unix(1700000000, 0), timers: make(map[*manualTimer]bool)},
+		connected: make(map[int]int),
+		seen:      make(map[string]bool),
+	}
+	sim.manager = NewConnectionManager(sim, simAuthenticator{}, DefaultConfig())
+	sim.manager.clock = sim.clock
+	return sim
+}
+
+// violate records a violated invariant once.
+func (s *simulation) violate(format string, args ...any) {
+	s.Lock()
+	defer s.Unlock()
+	violation := fmt.Sprintf(format, args...)
+	if !s.seen[violation] {
+		s.seen[violation] = true
+		s.violations = append(s.violations, violation)
+	}
+}
+
+// ClientConnected counts the calls per connection and starts the regular message handler.
+func (s *simulation) ClientConnected(client *WsClient) {
+	s.Lock()
+	s.connected[client.ID()]++
+	calls := s.connected[client.ID()]
+	s.Unlock()
+	if calls > 1 {
+		s.violate("ClientConnected fired %d times for connection %d", calls, client.ID())
+	}
+	if client.Claims() == nil {
+		s.violate("ClientConnected fired for unauthenticated connection %d", client.ID())
+	}
+	if state := client.State(); state != StateActive && state != StateClosing {
+		s.violate("ClientConnected fired in state %s for connection %d", state, client.ID())
+	}
+	DefaultClientConnectionHandler{}.ClientConnected(client)
+}
+
+// step performs one random event.
+func (s *simulation) step() {
+	switch n := s.rng.IntN(100); {
+	case n < 20 || len(s.conns) == 0:
+		s.connect()
+	case n < 35:
+		s.pick().send("auth", "sys", map[string]any{"authToken": s.token()})
+	case n < 55:
+		s.pick().send("greeting", "greeting", map[string]any{"name": "sim"})
+	case n < 65:
+		s.pick().send("subscribe", "sys", map[string]any{"ch": "sim." + strconv.Itoa(s.rng.IntN(5))})
+	case n < 85:
+		s.clock.Advance(time.Duration(1+s.rng.IntN(40)) * time.Second)
+	default:
+		conn := s.pick()
+		conn.disconnect()
+		s.drop(conn)
+	}
+	s.settle()
+}
+
+// connect opens a connection, authenticated with a bearer token half of the time.
+func (s *simulation) connect() {
+	s.manager.nextClientID++
+	var claims jwt.MapClaims
+	var expire int64
+	if s.rng.IntN(2) == 0 {
+		expire = s.clock.Now().Add(time.Duration(1+s.rng.IntN(120)) * time.Second).Unix()
+		claims = jwt.MapClaims{"sub": "user-" + strconv.Itoa(s.rng.IntN(10)), "exp": float64(expire)}
+	}
+	conn := newSimConn(s, s.manager.nextClientID)
+	client := NewClient(s.manager.nextClientID, s.manager, claims, s.manager.authenticator, expire, s.manager.config)
+	s.manager.accept(client, conn)
+	s.conns = append(s.conns, conn)
+	s.opened++
+}
+
+// token returns a simulated token; one in ten is invalid.
+func (s *simulation) token() string {
+	if s.rng.IntN(10) == 0 {
+		return "invalid"
+	}
+	expire := s.clock.Now().Add(time.Duration(1+s.rng.IntN(120)) * time.Second).Unix()
+	return fmt.Sprintf("sim:user-%d:%d", s.rng.IntN(10), expire)
+}
+
+// pick returns a random open connection.
+func (s *simulation) pick() *simConn {
+	return s.conns[s.rng.IntN(len(s.conns))]
+}
+
+// drop forgets a connection the peer disconnected.
+func (s *simulation) drop(conn *simConn) {
+	for i, c := range s.conns {
+		if c == conn {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// settle gives the connection goroutines time to process the last event.
+func (s *simulation) settle() {
+	time.Sleep(200 * time.Microsecond)
+}
+
+// finish disconnects every connection and checks that nothing outlives them.
+func (s *simulation) finish() {
+	for _, conn := range s.conns {
+		conn.disconnect()
+	}
+	s.conns = nil
+	deadline := time.Now().Add(2 * time.Second)
+	for s.manager.ClientCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if count := s.manager.ClientCount(); count > 0 {
+		s.violate("%d connections still registered after all peers disconnected", count)
+	}
+	for s.clock.active() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if timers := s.clock.active(); timers > 0 {
+		s.violate("%d tickers or timers still running after all connections closed", timers)
+	}
+}
+
+// simAuthenticator accepts tokens of the form "sim:<subject>:<expiry unix time>".
+type simAuthenticator struct{}
+
+func (simAuthenticator) ValidateJwt(token string) (jwt.MapClaims, error) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 || parts[0] != "sim" {
+		return nil, errors.New("invalid simulated token")
+	}
+	expire, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.MapClaims{"sub": parts[1], "exp": float64(expire)}, nil
+}
+
+// simConn is an in-memory transport. The simulated peer sends frames to it and disconnects, and it closes itself
+// like a browser when it receives a close frame.
+type simConn struct {
+	sim      *simulation
+	id       int
+	inbound  chan []byte   // Frames sent by the peer
+	peerGone chan struct{} // Closed when the peer disconnected or answered a close frame
+	peerOnce sync.Once
+	lock     sync.Mutex
+	closed   bool          // Set once the server closed the transport
+	done     chan struct{} // Closed together with closed
+}
+
+func newSimConn(sim *simulation, id int) *simConn {
+	return &simConn{sim: sim, id: id, inbound: make(chan []byte), peerGone: make(chan struct{}), done: make(chan struct{})}
+}
+
+// send delivers a frame from the peer, giving up if the connection is gone.
+func (c *simConn) send(msgType string, channel string, data any) {
+	raw, _ := json.Marshal(map[string]any{"id": "sim", "type": msgType, "ch": channel, "data": data})
+	select {
+	case c.inbound <- raw:
+	case <-c.peerGone:
+	case <-c.done:
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// disconnect simulates the peer going away.
+func (c *simConn) disconnect() {
+	c.peerOnce.Do(func() { close(c.peerGone) })
+}
+
+func (c *simConn) ReadMessage() (int, []byte, error) {
+	select {
+	case data := <-c.inbound:
+		return websocket.TextMessage, data, nil
+	case <-c.peerGone:
+		return 0, nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	case <-c.done:
+		return 0, nil, errConnectionClosed
+	}
+}
+
+func (c *simConn) WriteMessage(int, []byte) error {
+	return c.checkWrite("data frame")
+}
+
+func (c *simConn) WriteControl(messageType int, _ []byte, _ time.Time) error {
+	if err := c.checkWrite("control frame"); err != nil {
+		return err
+	}
+	if messageType == websocket.CloseMessage {
+		c.disconnect()
+	}
+	return nil
+}
+
+// checkWrite records a violation for writes after Close.
+func (c *simConn) checkWrite(kind string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		c.sim.violate("%s written to connection %d after close", kind, c.id)
+		return errConnectionClosed
+	}
+	return nil
+}
+
+func (c *simConn) SetReadDeadline(time.Time) error { return nil }
+
+func (c *simConn) SetReadLimit(int64) {}
+
+func (c *simConn) SetPongHandler(func(string) error) {}
+
+func (c *simConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
+
+// manualClock is a Clock that only moves when advanced.
+type manualClock struct {
+	sync.Mutex
+	now    time.Time
+	timers map[*manualTimer]bool // Pending timers and running tickers
+}
+
+// manualTimer is a timer or, if period is set, a ticker of a manualClock.
+type manualTimer struct {
+	clock  *manualClock
+	at     time.Time      // Next time the timer fires
+	period time.Duration  // Tick period, zero for timers
+	fn     func()         // Function run by timers
+	ch     chan time.Time // Tick channel of tickers
+}
+
+func (c *manualClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTicker(d time.Duration) Ticker {
+	c.Lock()
+	defer c.Unlock()
+	t := &manualTimer{clock: c, at: c.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	c.timers[t] = true
+	return manualTicker{t}
+}
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.Lock()
+	defer c.Unlock()
+	t := &manualTimer{clock: c, at: c.now.Add(d), fn: f}
+	c.timers[t] = true
+	return t
+}
+
+// Advance moves the clock forward, firing due timers and ticks in time order.
+func (c *manualClock) Advance(d time.Duration) {
+	c.Lock()
+	target := c.now.Add(d)
+	for {
+		var next *manualTimer
+		for t := range c.timers {
+			if !t.at.After(target) && (next == nil || t.at.Before(next.at)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.now = next.at
+		if next.period > 0 {
+			select {
+			case next.ch <- c.now:
+			default: // Ticks are dropped like with time.Ticker
+			}
+			next.at = next.at.Add(next.period)
+			continue
+		}
+		delete(c.timers, next)
+		go next.fn() // Like time.AfterFunc, the function runs in its own goroutine
+	}
+	c.now = target
+	c.Unlock()
+}
+
+// active returns the number of pending timers and running tickers.
+func (c *manualClock) active() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.timers)
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+	active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+// manualTicker adapts a periodic manualTimer to Ticker.
+type manualTicker struct {
+	*manualTimer
+}
+
+func (t manualTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t manualTicker) Stop() {
+	t.manualTimer.Stop()
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// TestSimulation runs a fixed batch of seeded lifecycle simulations and fails on any violated invariant. Rerun a
+// failing seed with go run ./cmd/sim -seed <seed> -runs 1 -v.
+//
+// The interleavings mainly exercise concurrent access, which the invariants cannot observe, so the test only runs
+// under the race detector: go test -race ./internal/server.
+func TestSimulation(t *testing.T) {
+	const (
+		firstSeed = 1
+		runs      = 64
+		steps     = 200
+	)
+	if !raceEnabled {
+		t.Skip("simulation runs under the race detector only; use go test -race")
+	}
+	if testing.Short() {
+		t.Skip("simulation skipped in short mode")
+	}
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(logger)
+
+	reports := make([]*SimulationReport, runs)
+	var wg sync.WaitGroup
+	for i := range reports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i] = Simulate(firstSeed+uint64(i), steps)
+		}()
+	}
+	wg.Wait()
+	for _, report := range reports {
+		for _, violation := range report.Violations {
+			t.Errorf("seed %d: %s", report.Seed, violation)
+		}
+	}
+}
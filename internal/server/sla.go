@@ -0,0 +1,36 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/alerting"
+	"time"
+)
+
+// slaDelivered reports a message written to a client to the SLA monitor.
+func (m *ConnectionManager) slaDelivered(created time.Time) {
+	if m.slaMonitor != nil && !created.IsZero() {
+		m.slaMonitor.Delivered(time.Since(created))
+	}
+}
+
+// slaDropped reports a message that was not delivered to the SLA monitor.
+func (m *ConnectionManager) slaDropped() {
+	if m.slaMonitor != nil {
+		m.slaMonitor.Dropped()
+	}
+}
+
+// slaAuthFailure reports a failed authentication to the SLA monitor.
+func (m *ConnectionManager) slaAuthFailure() {
+	if m.slaMonitor != nil {
+		m.slaMonitor.AuthFailure()
+	}
+}
+
+// SetSLAMonitor sets the monitor raising alerts on delivery latency, drop rate and authentication failure
+// spikes. The caller runs the monitor's evaluation loop.
+//
+// Params:
+// - monitor: The monitor.
+func (gw *WsGw) SetSLAMonitor(monitor *alerting.Monitor) {
+	gw.slaMonitor = monitor
+}
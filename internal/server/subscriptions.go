@@ -0,0 +1,357 @@
+package server
+
+import (
+	"encoding/json"
+	"go-websocket-boilerplate/internal/analytics"
+	"go-websocket-boilerplate/internal/broker"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/msgs"
+	"time"
+)
+
+// SubscribeMsg is the payload of sys/subscribe and sys/unsubscribe requests.
+//
+// Either a single channel or a batch of channels may be given. Channels containing a "*" segment are patterns
+// matching any single segment in that position, e.g. "prices.*".
+type SubscribeMsg struct {
+	Channel  string   `json:"ch,omitempty"`       // Single channel
+	Channels []string `json:"channels,omitempty"` // Batch of channels
+	NoEcho   bool     `json:"noEcho,omitempty"`   // Exclude updates the client publishes itself on these channels
+
+	ResolutionMs int `json:"resolutionMs,omitempty"` // Receive min/max/avg buckets of metric channels at this interval
+	Window       int `json:"window,omitempty"`       // Send at most this many updates until granted more with sys/credit
+
+	ChannelToken string `json:"channelToken,omitempty"` // Short-lived token granting access to the channels it lists
+}
+
+// SubscribeResult reports the outcome of subscribing to or unsubscribing from a single channel.
+type SubscribeResult struct {
+	Channel string      `json:"ch"`
+	OK      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+	Quota   *QuotaError `json:"quota,omitempty"` // Set when a subscription limit was exceeded
+}
+
+// channels returns all channels of the request in order.
+func (s *SubscribeMsg) channels() []string {
+	channels := make([]string, 0, len(s.Channels)+1)
+	if s.Channel != "" {
+		channels = append(channels, s.Channel)
+	}
+	return append(channels, s.Channels...)
+}
+
+// subscribe adds the client to the subscribers of the channel or pattern.
+//
+// The channel's OnFirstSubscriber hook runs when the client is its first subscriber. Subscribing again only
+// updates the noEcho option.
+func (m *ConnectionManager) subscribe(client *WsClient, channel string, noEcho bool, resolution time.Duration) error {
+	m.Lock()
+	if client.subscriptions[channel] {
+		m.setNoEchoLocked(client, channel, noEcho)
+		m.setResolutionLocked(client, channel, resolution)
+		m.Unlock()
+		return nil
+	}
+	if err := m.checkChannelAccess(client, channel); err != nil {
+		m.Unlock()
+		return err
+	}
+	if quotaErr := m.checkSubscriptionQuota(client, channel); quotaErr != nil {
+		m.Unlock()
+		return quotaErr
+	}
+	def, _ := m.registry.Lookup(channel)
+	if err := m.checkEncryptedSubscription(def, channel); err != nil {
+		m.Unlock()
+		return err
+	}
+	backfill := client.startBackfill(def, channel)
+	index := m.subscribers
+	if channels.IsPattern(channel) {
+		index = m.patterns
+	}
+	first := len(index[channel]) == 0
+	if index[channel] == nil {
+		index[channel] = make(map[int]*WsClient)
+	}
+	index[channel][client.ID()] = client
+	client.subscriptions[channel] = true
+	m.setNoEchoLocked(client, channel, noEcho)
+	m.setResolutionLocked(client, channel, resolution)
+	if subject := subjectOf(client.Claims()); subject != "" {
+		m.userSubscriptions[subject]++
+		client.subscribedAs[channel] = subject
+	}
+	hooks := m.activation[channel]
+	m.Unlock()
+
+	if first && hooks.OnFirstSubscriber != nil {
+		hooks.OnFirstSubscriber(channel)
+	}
+	m.rotateChannelKey(channel)
+	m.replayHistory(client, channel)
+	if backfill {
+		go m.backfill(client, def, channel)
+	}
+	m.track(client, analytics.Subscribe, map[string]any{"ch": channel})
+	return nil
+}
+
+// setNoEchoLocked sets whether the subscription excludes the client's own publishes. The caller must hold the lock.
+func (m *ConnectionManager) setNoEchoLocked(client *WsClient, channel string, noEcho bool) {
+	if noEcho {
+		client.noEcho[channel] = true
+	} else {
+		delete(client.noEcho, channel)
+	}
+}
+
+// setResolutionLocked sets the downsampling resolution of the subscription. The caller must hold the lock.
+func (m *ConnectionManager) setResolutionLocked(client *WsClient, channel string, resolution time.Duration) {
+	if resolution > 0 {
+		client.resolutions[channel] = max(resolution, minResolution)
+	} else {
+		delete(client.resolutions, channel)
+	}
+}
+
+// excludesEcho reports whether a subscription of the client matching the channel excludes its own publishes.
+func (m *ConnectionManager) excludesEcho(client *WsClient, channel string) bool {
+	m.RLock()
+	defer m.RUnlock()
+	for subscribed := range client.noEcho {
+		if subscribed == channel || (channels.IsPattern(subscribed) && channels.Match(subscribed, channel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsubscribe removes the client from the subscribers of the channel or pattern.
+func (m *ConnectionManager) unsubscribe(client *WsClient, channel string) error {
+	m.Lock()
+	subscribed := client.subscriptions[channel]
+	last := m.unsubscribeLocked(client, channel)
+	m.Unlock()
+	if last {
+		m.deactivate(channel)
+	}
+	if subscribed {
+		m.rotateChannelKey(channel)
+	}
+	return nil
+}
+
+// unsubscribeLocked removes a subscription. The caller must hold the lock.
+//
+// Returns:
+// - true if the client was the channel's last subscriber.
+func (m *ConnectionManager) unsubscribeLocked(client *WsClient, channel string) bool {
+	if !client.subscriptions[channel] {
+		return false
+	}
+	if subject, counted := client.subscribedAs[channel]; counted {
+		delete(client.subscribedAs, channel)
+		m.userSubscriptions[subject]--
+		if m.userSubscriptions[subject] <= 0 {
+			delete(m.userSubscriptions, subject)
+		}
+	}
+	index := m.subscribers
+	if channels.IsPattern(channel) {
+		index = m.patterns
+	}
+	delete(index[channel], client.ID())
+	delete(client.subscriptions, channel)
+	delete(client.noEcho, channel)
+	delete(client.resolutions, channel)
+	client.flowLock.Lock()
+	delete(client.flows, channel)
+	client.flowLock.Unlock()
+	if len(index[channel]) == 0 {
+		delete(index, channel)
+		return true
+	}
+	return false
+}
+
+// unsubscribeAll removes every subscription of the client.
+func (m *ConnectionManager) unsubscribeAll(client *WsClient) {
+	m.Lock()
+	emptied := make([]string, 0)
+	left := make([]string, 0, len(client.subscriptions))
+	for channel := range client.subscriptions {
+		left = append(left, channel)
+		if m.unsubscribeLocked(client, channel) {
+			emptied = append(emptied, channel)
+		}
+	}
+	m.Unlock()
+	for _, channel := range emptied {
+		m.deactivate(channel)
+	}
+	for _, channel := range left {
+		m.rotateChannelKey(channel)
+	}
+}
+
+// Subscribers returns the clients subscribed to the channel, either directly or by pattern.
+//
+// Access to a pattern is checked against the pattern only, so clients matching by pattern are only returned if
+// they may also access the concrete channel; encrypted channels are never delivered by pattern.
+func (m *ConnectionManager) Subscribers(channel string) []*WsClient {
+	def, _ := m.registry.Lookup(channel)
+	m.RLock()
+	defer m.RUnlock()
+	seen := make(map[int]bool)
+	clients := make([]*WsClient, 0, len(m.subscribers[channel]))
+	for id, client := range m.subscribers[channel] {
+		seen[id] = true
+		clients = append(clients, client)
+	}
+	for pattern, subscribers := range m.patterns {
+		if !channels.Match(pattern, channel) {
+			continue
+		}
+		for id, client := range subscribers {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if m.checkChannelAccess(client, channel) != nil || m.checkEncryptedSubscription(def, pattern) != nil {
+				continue
+			}
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// Publish sends an update to every client subscribed to the channel.
+//
+// Params:
+// - channel: The channel the update is published on.
+// - updateType: The type of the update.
+// - data: The update payload.
+func (m *ConnectionManager) Publish(channel string, updateType string, data any) {
+	m.publish(nil, channel, updateType, data, cause{})
+}
+
+// publish sends an update on behalf of the sender, or the server when sender is nil, stamped with its cause.
+//
+// Client publishes on moderated channels are delivered once the moderator approved them.
+func (m *ConnectionManager) publish(sender *WsClient, channel string, updateType string, data any, cause cause) {
+	if sender != nil && m.moderate(sender, channel, updateType, data, cause) {
+		return
+	}
+	m.distribute(sender, channel, updateType, data, cause)
+}
+
+// distribute conflates or fans out an update.
+func (m *ConnectionManager) distribute(sender *WsClient, channel string, updateType string, data any, cause cause) {
+	def, _ := m.registry.Lookup(channel)
+	if def != nil && def.Conflation() > 0 {
+		m.conflate(def, sender, channel, updateType, data, cause)
+		return
+	}
+	m.fanOut(def, sender, channel, updateType, data, cause)
+}
+
+// fanOut records the update in the channel history and sends it to the subscribers.
+//
+// Client-originated updates carry the sender's connection ID. Subscribers who blocked the sender do not receive
+// them, nor does the sender if it subscribed with noEcho.
+func (m *ConnectionManager) fanOut(def *channels.Definition, sender *WsClient, channel string, updateType string, data any, cause cause) {
+	msg := cause.stamp(NewEgressMsg("", updateType, channel, data))
+	msg.MessageID = m.ids.NewID() // Shared by all subscribers and the history
+	if sender != nil {
+		msg.OriginClientID = sender.ID()
+		msg.originSubject = subjectOf(sender.Claims())
+	}
+	m.relay(broker.KindPublish, msg)
+	m.fanOutMsg(def, sender, msg, data)
+}
+
+// fanOutMsg records the update in the channel history and sends it to the local subscribers. The sender is nil
+// for server-originated updates and updates relayed from other nodes.
+func (m *ConnectionManager) fanOutMsg(def *channels.Definition, sender *WsClient, msg *EgressMsg, data any) {
+	channel := msg.Channel
+	senderSubject := msg.originSubject
+	if def != nil && def.History {
+		m.recordHistory(def, channel, msg)
+	}
+	subscribers := m.Subscribers(channel)
+	if def != nil && def.History {
+		m.unreadChanged(channel, senderSubject, subscribers)
+	}
+	metric := def != nil && def.Metric
+	downsampled := make(map[time.Duration]bool)
+	for _, client := range subscribers {
+		if client == sender && m.excludesEcho(client, channel) {
+			continue
+		}
+		if metric {
+			if resolution := m.resolutionFor(client, channel); resolution > 0 {
+				if !downsampled[resolution] {
+					downsampled[resolution] = true
+					m.downsample(channel, resolution, data)
+				}
+				continue
+			}
+		}
+		if senderSubject != "" && m.blockChecker != nil && m.blockChecker.IsBlocked(subjectOf(client.Claims()), senderSubject) {
+			continue
+		}
+		client.sendLive(msg)
+	}
+}
+
+// Publish sends an update to the subscribers of the channel on behalf of the client.
+func (c *WsClient) Publish(channel string, updateType string, data any) {
+	c.manager.publish(c, channel, updateType, data, cause{})
+}
+
+// handleSubscribeMsg processes sys/subscribe and sys/unsubscribe requests, replying with per-channel results.
+func (c *WsClient) handleSubscribeMsg(request IngressMsg) {
+	msg := &SubscribeMsg{}
+	if err := json.Unmarshal(request.Data(), msg); err != nil {
+		c.logger.Error("error unmarshalling subscribe msg", "error", err)
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if msg.ChannelToken != "" && request.Type() == "subscribe" {
+		if err := c.addChannelGrants(msg.ChannelToken); err != nil {
+			c.logger.Info("Channel token rejected", "error", err)
+			c.sendErrorOf(request, err, msgs.CodePermissionDenied)
+			return
+		}
+	}
+	channels := msg.channels()
+	results := make([]SubscribeResult, 0, len(channels))
+	for _, channel := range channels {
+		var err error
+		switch {
+		case channel == "" || isSysChannel(channel):
+			results = append(results, SubscribeResult{Channel: channel, Error: "invalid channel"})
+			continue
+		case request.Type() == "subscribe":
+			err = c.manager.subscribe(c, channel, msg.NoEcho, time.Duration(msg.ResolutionMs)*time.Millisecond)
+		default:
+			err = c.manager.unsubscribe(c, channel)
+		}
+		if err != nil {
+			result := SubscribeResult{Channel: channel, Error: err.Error()}
+			if quotaErr, ok := err.(*QuotaError); ok {
+				result.Quota = quotaErr
+			}
+			results = append(results, result)
+			continue
+		}
+		if request.Type() == "subscribe" {
+			c.setWindow(channel, msg.Window)
+		}
+		results = append(results, SubscribeResult{Channel: channel, OK: true})
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), results)
+}
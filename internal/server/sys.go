@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/abuse"
+	"go-websocket-boilerplate/internal/msgs"
+	"strings"
+	"time"
+)
+
+// sysChannel is the reserved channel for protocol messages handled by the gateway itself.
+const sysChannel = "sys"
+
+// isSysChannel reports whether the channel lies in the reserved "sys" namespace.
+//
+// Messages in this namespace are handled by the gateway and never reach application handlers.
+func isSysChannel(channel string) bool {
+	return channel == sysChannel || strings.HasPrefix(channel, sysChannel+".")
+}
+
+// handleSysMessage processes a message on the reserved "sys" channel.
+//
+// Returns:
+// - false if the connection must be closed.
+func (c *WsClient) handleSysMessage(request IngressMsg) bool {
+	if request.Channel() != sysChannel {
+		c.sendError(request, msgs.CodePermissionDenied, "reserved channel")
+		return true
+	}
+	switch request.Type() {
+	case "auth":
+		return c.handleAuthMsg(request)
+	case "impersonate", "unimpersonate":
+		c.handleImpersonationMsg(request)
+	case "cluster":
+		c.sendClusterInfo(request.ID())
+	case "subscribe", "unsubscribe":
+		c.handleSubscribeMsg(request)
+	case "edit", "delete":
+		c.handleEditMsg(request)
+	case "join", "leave":
+		c.handleRoomMsg(request)
+	case "credit":
+		c.handleCreditMsg(request)
+	case "ack":
+		c.handleAckMsg(request)
+	case "read":
+		c.handleReadMsg(request)
+	case "unread":
+		c.handleUnreadMsg(request)
+	case "block", "unblock":
+		c.handleBlockMsg(request)
+	case "hello":
+		c.handleHelloMsg(request)
+	case "connections":
+		c.sendConnections(request.ID())
+	case "maintenance":
+		c.handleMaintenanceMsg(request)
+	case "memory":
+		c.sendMemoryReport(request)
+	case "capabilities":
+		c.SendResponse(request.ID(), request.Type(), request.Channel(), c.manager.Capabilities())
+	default:
+		c.sendError(request, msgs.CodeUnknownMessage, "unknown sys message")
+	}
+	return true
+}
+
+// handleAuthMsg authenticates the client with the token of a sys/auth message. An authenticated client may only
+// refresh its token for the same subject.
+//
+// Returns:
+// - false if the token is invalid and the connection was closed.
+func (c *WsClient) handleAuthMsg(request IngressMsg) bool {
+	authMsg := &AuthMsg{}
+	if err := json.Unmarshal(request.Data(), authMsg); err != nil {
+		c.logger.Error("error unmarshalling auth msg", "error", err)
+		c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+		return true
+	}
+	if authMsg.AuthToken == "" {
+		c.logger.Error("invalid auth msg", "error", "empty auth token")
+		c.sendError(request, msgs.CodeInvalidRequest, "token is required")
+		return true
+	}
+	claims, err := c.manager.validateToken(c.authenticator, authMsg.AuthToken)
+	var expire int64
+	if err == nil {
+		expire, err = connectionExpiry(claims)
+	}
+	if err != nil {
+		c.logger.Error("invalid auth msg", "error", err)
+		c.observe(abuse.AuthFailure, request.Channel(), request.Type(), 0)
+		c.manager.slaAuthFailure()
+		c.Close()
+		return false
+	}
+	if current := subjectOf(c.Claims()); current != "" && subjectOf(claims) != current {
+		// The connection is counted, indexed and present under its subject, so it cannot switch users
+		c.logger.Info("Re-authentication as another subject rejected", "newSub", subjectOf(claims))
+		c.sendError(request, msgs.CodePermissionDenied, "token subject differs from the connection's")
+		return true
+	}
+	if c.manager.isBanned(subjectOf(claims), c.ip) {
+		c.logger.Info("Banned client rejected.", "sub", subjectOf(claims))
+		c.manager.closeClients([]*WsClient{c}, websocket.ClosePolicyViolation, reasonBanned)
+		return false
+	}
+	c.logger.Info("Successfully authenticated")
+	c.setClaims(claims) // Set before authenticating so the handlers see the claims
+	if !c.authenticate() {
+		return false
+	}
+	c.logger.Info("Authorize succeeded.", "expire", time.Unix(expire, 0).Format(time.RFC3339))
+	c.setAuthExpireTime(expire)
+	c.saveSession()
+	return true
+}
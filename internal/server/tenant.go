@@ -0,0 +1,40 @@
+package server
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strings"
+)
+
+// tenantAuthenticator validates tokens of a connection against a single tenant.
+type tenantAuthenticator struct {
+	authenticator TenantAuthenticator
+	tenant        string
+}
+
+// ValidateJwt validates the token against the tenant.
+func (a tenantAuthenticator) ValidateJwt(token string) (jwt.MapClaims, error) {
+	return a.authenticator.ValidateTenantJwt(a.tenant, token)
+}
+
+// tenantOf returns the tenant named by the request path below the gateway's Path, or "" if there is none.
+func (m *ConnectionManager) tenantOf(r *http.Request) string {
+	rest, ok := strings.CutPrefix(r.URL.Path, strings.TrimSuffix(m.config.Path, "/")+"/")
+	if !ok {
+		return ""
+	}
+	return strings.Trim(rest, "/")
+}
+
+// authenticatorFor returns the authenticator for a connection request, bound to the tenant named by the path
+// if the gateway's authenticator serves several tenants.
+func (m *ConnectionManager) authenticatorFor(r *http.Request) Authenticator {
+	tenants, ok := m.authenticator.(TenantAuthenticator)
+	if !ok {
+		return m.authenticator
+	}
+	if tenant := m.tenantOf(r); tenant != "" {
+		return tenantAuthenticator{authenticator: tenants, tenant: tenant}
+	}
+	return m.authenticator
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// certificateReloader serves a certificate loaded from files and replaces it on Reload, so certificates can be
+// rotated without restarting the gateway.
+type certificateReloader struct {
+	sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+}
+
+// newCertificateReloader loads the certificate and key.
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	r := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate and key files again. The previous certificate stays in use if they are invalid.
+func (r *certificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate %s: %w", r.certFile, err)
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+// GetCertificate returns the current certificate for tls.Config.GetCertificate.
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// SetTLSConfig serves wss:// with the given TLS configuration instead of the certificate files of the Config.
+//
+// Params:
+// - config: The TLS configuration; it must provide certificates or GetCertificate.
+func (gw *WsGw) SetTLSConfig(config *tls.Config) {
+	gw.tlsConfig = config
+}
+
+// tlsEnabled reports whether the gateway serves TLS.
+func (gw *WsGw) tlsEnabled() bool {
+	return gw.tlsConfig != nil || gw.config.TLSCertFile != ""
+}
+
+// serverTLSConfig returns the TLS configuration of the HTTP server, loading the certificate files if configured.
+func (gw *WsGw) serverTLSConfig() (*tls.Config, error) {
+	if gw.tlsConfig != nil {
+		return gw.tlsConfig, nil
+	}
+	reloader, err := newCertificateReloader(gw.config.TLSCertFile, gw.config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	gw.certificates = reloader
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: reloader.GetCertificate}, nil
+}
+
+// ReloadCertificates reads the certificate files of the Config again, e.g. on SIGHUP after a rotation.
+// Connections established before keep their certificate.
+//
+// Returns:
+// - An error if the files are invalid, in which case the previous certificate stays in use, or if the gateway
+// does not serve certificate files.
+func (gw *WsGw) ReloadCertificates() error {
+	if gw.certificates == nil {
+		return fmt.Errorf("no certificate files configured")
+	}
+	if err := gw.certificates.Reload(); err != nil {
+		return err
+	}
+	slog.Info("Certificates reloaded", "cert", gw.config.TLSCertFile)
+	return nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"go-websocket-boilerplate/internal/handler"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the gateway's instrumentation.
+const tracerName = "go-websocket-boilerplate/internal/server"
+
+// traceContextPropagator reads and writes W3C traceparent values.
+var traceContextPropagator = propagation.TraceContext{}
+
+// startSpan starts the span of an ingress message, continuing the client's trace if the message carries a
+// traceparent. The span travels with the message to the handler, which ends it.
+func (c *WsClient) startSpan(request *IngressMsg) {
+	tracer := c.manager.tracer
+	if tracer == nil {
+		return
+	}
+	ctx := context.Background()
+	if request.InMsgTraceParent != "" {
+		ctx = traceContextPropagator.Extract(ctx, propagation.MapCarrier{"traceparent": request.InMsgTraceParent})
+	}
+	request.trace, request.span = tracer.Start(ctx, "wsgw.message",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("ws.channel", request.Channel()),
+			attribute.String("ws.type", request.Type()),
+			attribute.String("ws.message_id", request.ID()),
+			attribute.Int("ws.connection_id", c.ID()),
+		))
+}
+
+// TraceContext returns the context carrying the message's span, or context.Background if tracing is disabled.
+func (i IngressMsg) TraceContext() context.Context {
+	if i.trace == nil {
+		return context.Background()
+	}
+	return i.trace
+}
+
+// Finish ends the message's span once it has been handled.
+func (i IngressMsg) Finish() {
+	if i.span != nil {
+		i.span.End()
+	}
+}
+
+// failSpan ends the span of a message that never reached the handler.
+func failSpan(msg handler.InMsg, reason string) {
+	request, ok := msg.(IngressMsg)
+	if !ok || request.span == nil {
+		return
+	}
+	request.span.SetStatus(codes.Error, reason)
+	request.span.End()
+}
+
+// traceParent formats the span context of the trace as a W3C traceparent value, "" if there is no span.
+func traceParent(ctx context.Context) string {
+	if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	traceContextPropagator.Inject(ctx, carrier)
+	return carrier["traceparent"]
+}
+
+// linkEgress records the message on the span of the request that caused it and stamps the message with the
+// request's traceparent, so a client can follow its request through the trace.
+func (c cause) linkEgress(msg *EgressMsg) {
+	if c.trace == nil {
+		return
+	}
+	msg.TraceParent = traceParent(c.trace)
+	trace.SpanFromContext(c.trace).AddEvent("egress", trace.WithAttributes(
+		attribute.String("ws.channel", msg.Channel),
+		attribute.String("ws.type", msg.Type),
+	))
+}
+
+// SetTracerProvider enables OpenTelemetry tracing of ingress messages with the provider, e.g. otel.GetTracerProvider()
+// after the application configured its SDK and exporter.
+//
+// Params:
+// - provider: The tracer provider.
+func (gw *WsGw) SetTracerProvider(provider trace.TracerProvider) {
+	gw.tracer = provider.Tracer(tracerName)
+}
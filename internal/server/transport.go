@@ -0,0 +1,20 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+// errConnectionClosed is returned when writing to a client whose connection was closed.
+var errConnectionClosed = errors.New("connection closed")
+
+// transport is the part of *websocket.Conn a client uses, so simulations can substitute an in-memory connection.
+type transport interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetReadLimit(limit int64)
+	SetPongHandler(h func(appData string) error)
+	Close() error
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/ids"
+	"go-websocket-boilerplate/internal/msgs"
+	"log/slog"
+)
+
+// UnreadMsg is the payload of sys/unread requests.
+type UnreadMsg struct {
+	Channels []string `json:"channels,omitempty"` // Channels to count; defaults to the client's channel subscriptions
+}
+
+// UnreadCount is the number of unread messages of a user on a channel, sent in sys/unread responses and pushed
+// as "unread" updates when it changes.
+type UnreadCount struct {
+	Channel string `json:"ch"`
+	Count   int    `json:"count"`
+	Capped  bool   `json:"capped,omitempty"` // The count covers the whole channel history and may be higher
+	MsgID   string `json:"msgId,omitempty"`  // Read marker the count is based on
+}
+
+// unreadCount counts the messages in the channel history after the user's read marker that the user did not
+// author. Counts are bounded by the channel's replay depth.
+func (m *ConnectionManager) unreadCount(ctx context.Context, user string, channel string) (*UnreadCount, error) {
+	marker, err := m.receipts.Store().ReadMarker(ctx, user, channel)
+	if err != nil {
+		return nil, err
+	}
+	count := &UnreadCount{Channel: channel, MsgID: marker}
+	m.RLock()
+	history := m.history[channel]
+	for _, msg := range history {
+		if msg.Deleted || msg.MessageID == "" || msg.originSubject == user {
+			continue
+		}
+		if marker == "" || ids.Compare(msg.MessageID, marker) > 0 {
+			count.Count++
+		}
+	}
+	def, _ := m.registry.Lookup(channel)
+	// Messages older than the history may be unread too when the marker precedes the oldest kept message.
+	count.Capped = def != nil && len(history) > 0 && len(history) >= def.ReplayDepth &&
+		(marker == "" || ids.Compare(history[0].MessageID, marker) > 0)
+	m.RUnlock()
+	return count, nil
+}
+
+// unreadChanged marks the unread counts of the channel's subscribers, except the author, as changed.
+func (m *ConnectionManager) unreadChanged(channel string, author string, subscribers []*WsClient) {
+	if m.receipts == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, client := range subscribers {
+		user := subjectOf(client.Claims())
+		if user != "" && user != author && !seen[user] {
+			seen[user] = true
+			m.receipts.UnreadChanged(user, channel)
+		}
+	}
+}
+
+// pushUnread sends the user's unread count of the channel to all the user's connections.
+func (m *ConnectionManager) pushUnread(user string, channel string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStoreTimeout)
+	defer cancel()
+	count, err := m.unreadCount(ctx, user, channel)
+	if err != nil {
+		slog.Error("Failed to count unread messages", "ch", channel, "error", err)
+		return
+	}
+	m.RLock()
+	clients := m.clientsBySubjectLocked(user)
+	m.RUnlock()
+	for _, client := range clients {
+		client.SendUpdate("unread", sysChannel, count)
+	}
+}
+
+// handleUnreadMsg processes sys/unread requests, answering with the unread counts of the requested channels.
+func (c *WsClient) handleUnreadMsg(request IngressMsg) {
+	user := subjectOf(c.Claims())
+	if c.manager.receipts == nil || user == "" {
+		c.sendError(request, msgs.CodeUnavailable, "unread counts not available")
+		return
+	}
+	msg := &UnreadMsg{}
+	if len(request.Data()) > 0 {
+		if err := json.Unmarshal(request.Data(), msg); err != nil {
+			c.sendError(request, msgs.CodeInvalidRequest, "Invalid request")
+			return
+		}
+	}
+	requested := msg.Channels
+	if len(requested) == 0 {
+		c.manager.RLock()
+		for channel := range c.subscriptions {
+			if !channels.IsPattern(channel) {
+				requested = append(requested, channel)
+			}
+		}
+		c.manager.RUnlock()
+	}
+	ctx, cancel := context.WithTimeout(c.context, sessionStoreTimeout)
+	defer cancel()
+	counts := make([]*UnreadCount, 0, len(requested))
+	for _, channel := range requested {
+		if err := c.manager.checkChannelAccess(c, channel); err != nil {
+			continue
+		}
+		count, err := c.manager.unreadCount(ctx, user, channel)
+		if err != nil {
+			c.logger.Error("Failed to count unread messages", "ch", channel, "error", err)
+			c.sendError(request, msgs.CodeUnavailable, "unread counts not available")
+			return
+		}
+		counts = append(counts, count)
+	}
+	c.SendResponse(request.ID(), request.Type(), request.Channel(), counts)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"github.com/gorilla/websocket"
+	"sort"
+)
+
+// DuplicatePolicy decides what happens when a user exceeds the connection limit per subject.
+type DuplicatePolicy int
+
+const (
+	// DuplicateRejectNew closes the new connection with "too many connections".
+	DuplicateRejectNew DuplicatePolicy = iota
+	// DuplicateKickOldest closes the user's oldest connections with "session superseded" to make room.
+	DuplicateKickOldest
+)
+
+// Close reasons of connections closed by the per-user limit.
+const (
+	reasonTooManyConnections = "too many connections"
+	reasonSessionSuperseded  = "session superseded"
+)
+
+// admitSubject indexes an authenticated client under its subject, enforcing the connection limit per subject.
+// Clients already indexed and anonymous clients are always admitted.
+//
+// Returns:
+// - false if the client was rejected and closed.
+func (m *ConnectionManager) admitSubject(client *WsClient) bool {
+	subject := subjectOf(client.Claims())
+	m.Lock()
+	if subject == "" || client.indexedSubject != "" {
+		m.Unlock()
+		return true
+	}
+	existing := m.bySubject[subject]
+	var superseded []*WsClient
+	if m.maxPerUser > 0 && len(existing) >= m.maxPerUser {
+		if m.duplicatePolicy == DuplicateRejectNew {
+			m.Unlock()
+			client.logger.Info("Connection rejected, user connection limit reached", "limit", m.maxPerUser)
+			m.closeClients([]*WsClient{client}, websocket.ClosePolicyViolation, reasonTooManyConnections)
+			return false
+		}
+		superseded = oldestClients(existing, len(existing)-m.maxPerUser+1)
+	}
+	if existing == nil {
+		existing = make(map[int]*WsClient)
+		m.bySubject[subject] = existing
+	}
+	existing[client.ID()] = client
+	client.indexedSubject = subject
+	m.Unlock()
+
+	if len(superseded) > 0 {
+		client.logger.Info("Oldest connections of the user superseded", "limit", m.maxPerUser, "closed", len(superseded))
+		m.closeClients(superseded, websocket.ClosePolicyViolation, reasonSessionSuperseded)
+	}
+	return true
+}
+
+// unindexSubjectLocked removes the client from the subject index. The caller must hold the lock.
+func (m *ConnectionManager) unindexSubjectLocked(client *WsClient) {
+	if client.indexedSubject == "" {
+		return
+	}
+	clients := m.bySubject[client.indexedSubject]
+	delete(clients, client.ID())
+	if len(clients) == 0 {
+		delete(m.bySubject, client.indexedSubject)
+	}
+	client.indexedSubject = ""
+}
+
+// oldestClients returns the n clients that connected first.
+func oldestClients(clients map[int]*WsClient, n int) []*WsClient {
+	sorted := make([]*WsClient, 0, len(clients))
+	for _, client := range clients {
+		sorted = append(sorted, client)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].connectedAt.Before(sorted[j].connectedAt) })
+	return sorted[:min(n, len(sorted))]
+}
+
+// SetUserConnectionLimit limits the simultaneous connections of a user, identified by the "sub" claim.
+//
+// Params:
+// - maxPerUser: The maximum number of connections per subject, 0 for no limit.
+// - policy: Whether a connection beyond the limit is rejected or replaces the user's oldest connection.
+func (gw *WsGw) SetUserConnectionLimit(maxPerUser int, policy DuplicatePolicy) {
+	gw.maxPerUser = maxPerUser
+	gw.duplicatePolicy = policy
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"go-websocket-boilerplate/internal/channels"
+	"net/url"
+	"strings"
+)
+
+// Validate checks the gateway configuration for missing settings and conflicting values.
+//
+// All problems are reported together so they can be fixed in one pass.
+//
+// Returns:
+// - nil if the configuration is valid, otherwise an error joining one error per problem.
+func (gw *WsGw) Validate() error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if gw.authenticator == nil {
+		add("auth: no authenticator configured")
+	}
+
+	// Timeouts
+	if gw.config.PingInterval <= 0 {
+		add("timeouts: ping interval %s must be positive", gw.config.PingInterval)
+	}
+	if gw.config.PingInterval >= gw.config.ReadDeadline {
+		add("timeouts: ping interval %s must be shorter than the read deadline %s", gw.config.PingInterval, gw.config.ReadDeadline)
+	}
+	if gw.config.ControlWriteWait >= gw.config.PingInterval {
+		add("timeouts: control write wait %s must be shorter than the ping interval %s", gw.config.ControlWriteWait, gw.config.PingInterval)
+	}
+
+	// Profile
+	if gw.config.OriginPolicy != OriginOpen && gw.config.OriginPolicy != OriginStrict {
+		add("origin: unknown policy %q, want %s or %s", gw.config.OriginPolicy, OriginOpen, OriginStrict)
+	}
+	for _, origin := range gw.config.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			add("origin: allowed origin %q is not a scheme://host URL", origin)
+		}
+	}
+	if _, err := gw.config.SlogLevel(); err != nil {
+		add("logging: %v", err)
+	}
+
+	// Listener
+	if gw.config.Addr == "" {
+		add("listener: no address configured")
+	}
+	if !strings.HasPrefix(gw.config.Path, "/") {
+		add("listener: WebSocket path %q must start with /", gw.config.Path)
+	}
+	if gw.config.ReadLimit <= 0 {
+		add("listener: read limit %d must be positive", gw.config.ReadLimit)
+	}
+	if gw.config.MaxConnections < 0 {
+		add("listener: max connections %d must not be negative", gw.config.MaxConnections)
+	}
+	acceptWait := gw.config.AcceptWait
+	if acceptWait == 0 {
+		acceptWait = defaultAcceptWait
+	}
+	if gw.config.AcceptQueue < 0 || gw.config.AcceptWait < 0 {
+		add("listener: accept queue and wait must not be negative")
+	} else if gw.config.AcceptQueue > 0 && gw.config.MaxConnections == 0 {
+		add("listener: accept queue requires max connections")
+	} else if gw.config.AcceptQueue > 0 && gw.config.WriteTimeout > 0 && acceptWait >= gw.config.WriteTimeout {
+		add("listener: accept wait %s must be shorter than the write timeout %s", acceptWait, gw.config.WriteTimeout)
+	}
+	if (gw.config.TLSCertFile == "") != (gw.config.TLSKeyFile == "") {
+		add("tls: certificate and key files must be set together")
+	} else if gw.config.TLSCertFile != "" && gw.tlsConfig == nil {
+		if _, err := tls.LoadX509KeyPair(gw.config.TLSCertFile, gw.config.TLSKeyFile); err != nil {
+			add("tls: %v", err)
+		}
+	}
+	if gw.replayWindow < 0 {
+		add("replay: window %s must not be negative", gw.replayWindow)
+	}
+	if len(gw.replayChannels) > 0 && gw.replayWindow == 0 {
+		add("replay: channels %v are protected but the replay window is zero", gw.replayChannels)
+	}
+
+	// Channels
+	for _, ch := range gw.replayChannels {
+		if err := channels.ValidateName(ch); err != nil {
+			add("replay: %v", err)
+		} else if gw.registry != nil && gw.registry.Strict() {
+			if _, ok := gw.registry.Lookup(ch); !ok {
+				add("replay: channel %q is not declared in the strict channel registry", ch)
+			}
+		}
+	}
+	for ch := range gw.hooks {
+		if err := channels.ValidateName(ch); err != nil {
+			add("channel hooks: %v", err)
+		}
+	}
+	if gw.challenge != "" && isSysChannel(gw.challenge) {
+		add("abuse: challenge channel %q is in the reserved sys namespace", gw.challenge)
+	}
+
+	// Limits
+	if gw.limits.MaxPerClient < 0 || gw.limits.MaxPerUser < 0 || gw.limits.MaxPatternWildcards < 0 || gw.limits.MaxPatternSegments < 0 {
+		add("subscription limits: limits must not be negative")
+	}
+	if gw.limits.MaxPerUser > 0 && gw.limits.MaxPerClient > gw.limits.MaxPerUser {
+		add("subscription limits: per-client limit %d exceeds per-user limit %d", gw.limits.MaxPerClient, gw.limits.MaxPerUser)
+	}
+	if gw.jsonLimits != nil {
+		l := gw.jsonLimits
+		if l.MaxDepth < 0 || l.MaxArrayLen < 0 || l.MaxStringLen < 0 || l.MaxFields < 0 {
+			add("json limits: limits must not be negative")
+		}
+		if int64(l.MaxStringLen) > gw.config.ReadLimit {
+			add("json limits: string length %d exceeds the maximum message size %d", l.MaxStringLen, gw.config.ReadLimit)
+		}
+	}
+	if gw.maxPerUser < 0 {
+		add("user connection limit: %d must not be negative", gw.maxPerUser)
+	}
+	if r := gw.rateLimits; r.MessagesPerSecond < 0 || r.BytesPerSecond < 0 || r.MessageBurst < 0 || r.ByteBurst < 0 || r.MaxBreaches < 0 {
+		add("rate limits: limits must not be negative")
+	} else if r.BytesPerSecond > 0 && r.ByteBurst > 0 && int64(r.ByteBurst) < gw.config.ReadLimit {
+		add("rate limits: byte burst %d cannot hold a maximum size message of %d bytes", r.ByteBurst, gw.config.ReadLimit)
+	}
+	if gw.maxMalformedFrames < 0 {
+		add("malformed frames: limit %d must not be negative", gw.maxMalformedFrames)
+	}
+	if gw.ingressQueueSize < 0 {
+		add("ingress queue: size %d must not be negative", gw.ingressQueueSize)
+	}
+	if gw.memoryCap < 0 {
+		add("memory cap: %d must not be negative", gw.memoryCap)
+	} else if gw.memoryCap > 0 && int64(gw.memoryCap) < connectionOverhead+gw.config.ReadLimit {
+		add("memory cap: %d bytes cannot hold a single maximum size message; use at least %d", gw.memoryCap, connectionOverhead+gw.config.ReadLimit)
+	}
+
+	if err := gw.auditSampling.validate(); err != nil {
+		add("audit: %v", err)
+	}
+
+	// Cluster
+	for _, endpoint := range gw.endpoints {
+		u, err := url.Parse(endpoint.URL)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			add("cluster: endpoint %q is not a ws:// or wss:// URL", endpoint.URL)
+		}
+	}
+	if gw.peers != nil && gw.partitions == nil {
+		add("node rpc: requires partitioning to find the node owning a user")
+	}
+	if gw.partitions != nil {
+		nodeID := gw.nodeID
+		if nodeID == "" {
+			nodeID = defaultNodeID()
+		}
+		member := false
+		for _, node := range gw.partitions.Nodes() {
+			member = member || node.ID == nodeID
+		}
+		if !member {
+			add("partitioning: node %q is not in the ring", nodeID)
+		}
+	}
+
+	return errors.Join(problems...)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"go-websocket-boilerplate/internal/abuse"
+	"os"
+)
+
+// Version is the gateway version reported to clients. Set at build time with
+// -ldflags "-X go-websocket-boilerplate/internal/server.Version=1.2.3".
+var Version = "dev"
+
+// WelcomeLimits are the limits negotiated for a connection.
+type WelcomeLimits struct {
+	MaxPayload       int              `json:"maxPayload"`       // Maximum frame size in bytes
+	MaxDepth         int              `json:"maxDepth"`         // Maximum JSON nesting depth
+	MaxArrayLen      int              `json:"maxArrayLen"`      // Maximum JSON array length
+	MaxStringLen     int              `json:"maxStringLen"`     // Maximum JSON string length in bytes
+	MaxFields        int              `json:"maxFields"`        // Maximum fields of a JSON object
+	IngressQueue     int              `json:"ingressQueue"`     // Messages buffered before load is shed
+	EgressQueue      int              `json:"egressQueue"`      // Messages buffered for writing before backpressure applies
+	HeartbeatMs      int64            `json:"heartbeatMs"`      // Interval between server pings
+	PongTimeoutMs    int64            `json:"pongTimeoutMs"`    // Time without a pong before the connection is dropped
+	MaxSubscriptions int              `json:"maxSubscriptions"` // Subscriptions per connection, 0 if unlimited
+	Rate             *abuse.RateLimit `json:"rate,omitempty"`   // Message rate above which the client is throttled
+}
+
+// Welcome is sent on the sys channel as a "welcome" update when a client connects.
+type Welcome struct {
+	Version       string        `json:"version"`       // Gateway version
+	NodeID        string        `json:"nodeId"`        // Identifier of the gateway node serving the connection
+	ConnectionID  int           `json:"conId"`         // Identifier of the connection on the node
+	Authenticated bool          `json:"authenticated"` // False if the client must still send sys/auth
+	AuthExpire    int64         `json:"authExpire"`    // Unix time the authentication expires
+	Limits        WelcomeLimits `json:"limits"`
+
+	Experiments map[string]string `json:"experiments,omitempty"` // Experiment variants assigned to the connection
+}
+
+// defaultNodeID returns the host name, which identifies the node unless configured otherwise.
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// SetNodeID sets the node identifier reported to clients.
+func (m *ConnectionManager) SetNodeID(nodeID string) {
+	m.nodeID = nodeID
+}
+
+// limits returns the limits negotiated for the manager's connections.
+func (m *ConnectionManager) limits() WelcomeLimits {
+	limits := WelcomeLimits{
+		MaxPayload:       int(m.config.ReadLimit),
+		MaxDepth:         m.jsonLimits.MaxDepth,
+		MaxArrayLen:      m.jsonLimits.MaxArrayLen,
+		MaxStringLen:     m.jsonLimits.MaxStringLen,
+		MaxFields:        m.jsonLimits.MaxFields,
+		IngressQueue:     m.ingressQueueSize,
+		EgressQueue:      m.egressQueueSize,
+		HeartbeatMs:      m.config.PingInterval.Milliseconds(),
+		PongTimeoutMs:    m.config.ReadDeadline.Milliseconds(),
+		MaxSubscriptions: m.subscriptionLimits.MaxPerClient,
+	}
+	if limited, ok := m.abuseDetector.(abuse.RateLimited); ok {
+		rate := limited.RateLimit()
+		limits.Rate = &rate
+	}
+	return limits
+}
+
+// sendWelcome sends the welcome banner to the client.
+func (c *WsClient) sendWelcome() {
+	c.SendUpdate("welcome", "sys", &Welcome{
+		Version:       Version,
+		NodeID:        c.manager.nodeID,
+		ConnectionID:  c.ID(),
+		Authenticated: c.isAuthenticated(),
+		AuthExpire:    c.expire.Load(),
+		Limits:        c.manager.limits(),
+		Experiments:   c.experiments,
+	})
+}
@@ -4,35 +4,84 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
+	"go-websocket-boilerplate/internal/abuse"
+	"go-websocket-boilerplate/internal/device"
+	"go-websocket-boilerplate/internal/geoip"
 	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/jsonguard"
+	"go-websocket-boilerplate/internal/msgs"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Time allowed to read the next pong message from the client.
-var pongWait = 10 * time.Second
-
-// Send pings to client with this period. Must be less than pongWait.
-var pingInterval = (pongWait * 9) / 10
-
 // WsClient represents a WebSocket client, responsible for managing the connection,
 // reading and writing messages, and handling authentication.
 type WsClient struct {
-	id            int                // Unique identifier for the client.
-	manager       *ConnectionManager // Reference to the WebSocket connection manager.
-	connection    *websocket.Conn    // WebSocket connection.
-	ingress       chan handler.InMsg // Channel for incoming messages.
-	egress        chan *EgressMsg    // Channel for outgoing messages.
-	claims        jwt.MapClaims      // Claims associated with the client jwt token.
-	context       context.Context    // Context to manage client lifecycle.
-	cancel        context.CancelFunc // Cancel function to stop the client.
-	expire        int64              // Authentication expiration time in Unix timestamp.
-	authChannel   chan int64         // Channel for handling authentication expiration.
-	authenticated bool               // Flag to indicate if the client is authenticated.
-	authenticator Authenticator      // Authenticator for validating tokens.
-	logger        *slog.Logger       // Logger for client specific logging
+	id                int                // Unique identifier for the client.
+	manager           *ConnectionManager // Reference to the WebSocket connection manager.
+	config            Config             // Timeouts and limits of the connection.
+	connection        transport          // WebSocket connection.
+	ingress           chan handler.InMsg // Channel for incoming messages.
+	egress            chan *EgressMsg    // Channel for outgoing messages.
+	claims            jwt.MapClaims      // Claims associated with the client jwt token, guarded by claimsLock.
+	claimsLock        sync.RWMutex       // Guards claims, replaced by sys/auth while other goroutines read them.
+	context           context.Context    // Context to manage client lifecycle.
+	cancel            context.CancelFunc // Cancel function to stop the client.
+	expire            atomic.Int64       // Authentication expiration time in Unix timestamp.
+	authChannel       chan int64         // Channel for handling authentication expiration.
+	authTimer         Timer              // Pending auth expiry timer, stopped when replaced or on close.
+	lifecycle         lifecycle          // Lifecycle state of the connection.
+	authenticator     Authenticator      // Authenticator for validating tokens.
+	logger            *slog.Logger       // Logger for client specific logging
+	replay            *replayGuard       // Nonces used on replay protected channels
+	resumeToken       string             // Token identifying the client's resumable session
+	resumed           bool               // Set if the client resumed a stored session
+	revoked           atomic.Bool        // Set when the client is disconnected because its token was revoked
+	lastSeq           uint64             // Last sequence number the resuming client received
+	seq               atomic.Uint64      // Sequence number of the last message written to the client
+	subscriptions     map[string]bool    // Subscribed channels and patterns, guarded by the manager lock
+	subscribedAs      map[string]string  // Subject each subscription is counted under, guarded by the manager lock
+	presentSubject    string             // Subject the client is counted as present under, guarded by the manager lock
+	indexedSubject    string             // Subject the client is indexed under, guarded by the manager lock
+	noEcho            map[string]bool    // Subscriptions excluding the client's own publishes, guarded by the manager lock
+	location          geoip.Location     // Geographic origin of the connection
+	device            device.Type        // Device class derived from the User-Agent
+	userAgent         string             // User-Agent of the upgrade request
+	ip                string             // Remote IP address of the connection
+	throttledUntil    atomic.Int64       // End of an abuse throttle in Unix nanoseconds
+	limiter           *rateLimiter       // Ingress token buckets, nil if unlimited
+	slot              bool               // Holds a connection slot, freed when removed
+	pathTenant        string             // Tenant named by the connection path, if any
+	malformedFrames   int                // Consecutive malformed frames, counted by the read loop
+	connectedAt       time.Time          // Time the client connected
+	installationID    string             // Installation identifier from sys/hello
+	tabID             string             // Tab identifier from sys/hello
+	fingerprintLock   sync.RWMutex       // Guards installationID and tabID
+	experiments       map[string]string  // Experiment variants keyed by experiment name; not modified after connect
+	messageCounts     map[string]int     // Messages sent per feature channel, reported on disconnect
+	messageCountsLock sync.Mutex
+	frameBytes        atomic.Int64 // Total size of the frames the client sent
+	frames            atomic.Int64 // Number of frames the client sent
+	egressDropped     atomic.Int64 // Messages dropped because the egress queue was full
+
+	resolutions  map[string]time.Duration // Downsampling resolution of metric subscriptions, guarded by the manager lock
+	backfilling  map[string][]*EgressMsg  // Live updates held back per channel while its snapshot is fetched
+	backfillLock sync.Mutex
+	flows        map[string]*flow // Credit windows of paced subscriptions
+	flowLock     sync.Mutex
+	grants       map[string]time.Time // Channels or patterns granted by channel tokens, with the tokens' expiry
+	grantsLock   sync.Mutex
+	calls        map[string]chan callReply // Pending server calls by call ID
+	callsLock    sync.Mutex
+
+	timerLock sync.Mutex   // Guards authTimer
+	closeLock sync.RWMutex // Held for reading by writes, for writing by Close
+	closed    bool         // Set once the connection is closed
 }
 
 // Logger returns the logger associated with the client.
@@ -42,27 +91,66 @@ func (c *WsClient) Logger() *slog.Logger {
 
 // publishConnected sends a signal to the manager that the client has successfully connected.
 func (c *WsClient) publishConnected() {
+	c.meterConnected()
+	c.manager.enterPresence(c)
 	c.manager.clientConnectionHandler.ClientConnected(c)
 }
 
 // SendResponse sends a response message to the client with the given details.
 func (c *WsClient) SendResponse(id string, reqType string, channel string, data any) {
-	c.egress <- NewEgressMsg(id, reqType, channel, data)
+	c.observeResponse(channel, reqType, data)
+	c.send(NewEgressMsg(id, reqType, channel, data))
 }
 
 // SendUpdate sends an update message to the client.
 func (c *WsClient) SendUpdate(updateType string, channel string, data any) {
-	c.egress <- NewEgressMsg("", updateType, channel, data)
+	c.send(NewEgressMsg("", updateType, channel, data))
+}
+
+// send delivers the message to the client and mirrors it to any impersonating agents.
+func (c *WsClient) send(msg *EgressMsg) {
+	c.deliver(msg)
+	c.manager.mirror(c, msg)
 }
 
 // Close closes the WebSocket connection for the client.
 func (c *WsClient) Close() {
+	c.closing()
 	c.cancel()
+	c.timerLock.Lock()
+	if c.authTimer != nil {
+		c.authTimer.Stop()
+	}
+	c.timerLock.Unlock()
 	if c.connection != nil {
+		c.closeLock.Lock()
+		c.closed = true
 		_ = c.connection.Close()
+		c.closeLock.Unlock()
 	}
 }
 
+// write writes a data frame unless the connection has been closed.
+func (c *WsClient) write(data []byte) error {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+	if c.closed {
+		return errConnectionClosed
+	}
+	return c.connection.WriteMessage(websocket.TextMessage, data)
+}
+
+// writeCtl writes a control frame unless the connection has been closed. Control frames may be written
+// concurrently with data frames.
+func (c *WsClient) writeCtl(messageType int) error {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+	if c.closed {
+		return errConnectionClosed
+	}
+	return c.connection.WriteControl(messageType, nil, c.manager.clock.Now().Add(c.config.ControlWriteWait))
+}
+
 // ID returns the client's unique identifier.
 func (c *WsClient) ID() int {
 	return c.id
@@ -78,17 +166,46 @@ func (c *WsClient) Ingress() chan handler.InMsg {
 	return c.ingress
 }
 
+// Country returns the ISO country code the client connected from, or an empty string if unknown.
+func (c *WsClient) Country() string {
+	return c.location.Country
+}
+
+// Region returns the subdivision code the client connected from, or an empty string if unknown.
+func (c *WsClient) Region() string {
+	return c.location.Region
+}
+
+// Device returns the device class of the client, derived from the User-Agent of the upgrade request.
+func (c *WsClient) Device() string {
+	return string(c.device)
+}
+
+// UserAgent returns the User-Agent of the upgrade request.
+func (c *WsClient) UserAgent() string {
+	return c.userAgent
+}
+
 // Claims returns the claims associated with the client.
 func (c *WsClient) Claims() jwt.MapClaims {
+	c.claimsLock.RLock()
+	defer c.claimsLock.RUnlock()
 	return c.claims
 }
 
+// setClaims replaces the claims of the client after a sys/auth.
+func (c *WsClient) setClaims(claims jwt.MapClaims) {
+	c.claimsLock.Lock()
+	defer c.claimsLock.Unlock()
+	c.claims = claims
+}
+
 // NewClient initializes and returns a new WebSocket client.
-func NewClient(id int, manager *ConnectionManager, claims jwt.MapClaims, authenticator Authenticator, authExpire int64) *WsClient {
+func NewClient(id int, manager *ConnectionManager, claims jwt.MapClaims, authenticator Authenticator, authExpire int64, config Config) *WsClient {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	expire := authExpire
 	if expire == 0 {
-		expire = time.Now().Add(30 * time.Second).Unix()
+		expire = manager.clock.Now().Add(30 * time.Second).Unix()
 	}
 	clientLogger := slog.Default().With("conID", id)
 	if claims != nil {
@@ -97,21 +214,35 @@ func NewClient(id int, manager *ConnectionManager, claims jwt.MapClaims, authent
 	} else {
 		clientLogger = clientLogger.With("sub", "not_authenticated")
 	}
-	return &WsClient{
+	client := &WsClient{
 		manager:       manager,
+		config:        config,
 		connection:    nil,
-		egress:        make(chan *EgressMsg),
-		ingress:       make(chan handler.InMsg),
+		egress:        make(chan *EgressMsg, manager.egressQueueSize),
+		ingress:       make(chan handler.InMsg, manager.ingressQueueSize),
 		id:            id,
 		context:       ctx,
 		cancel:        cancelFunc,
 		claims:        claims,
-		authenticated: claims != nil,
-		expire:        expire,
 		authChannel:   make(chan int64),
 		authenticator: authenticator,
 		logger:        clientLogger,
+		replay:        newReplayGuard(manager.replayWindow),
+		limiter:       newRateLimiter(manager.rateLimits, config.ReadLimit, manager.clock.Now()),
+		subscriptions: make(map[string]bool),
+		subscribedAs:  make(map[string]string),
+		noEcho:        make(map[string]bool),
+		resolutions:   make(map[string]time.Duration),
+		backfilling:   make(map[string][]*EgressMsg),
+		flows:         make(map[string]*flow),
+		grants:        make(map[string]time.Time),
+		device:        device.Unknown,
+		connectedAt:   time.Now(),
+		messageCounts: make(map[string]int),
+		lifecycle:     lifecycle{entered: [StateClosing + 1]time.Time{StateConnecting: manager.clock.Now()}},
 	}
+	client.expire.Store(expire)
+	return client
 }
 
 // readMessages reads and processes incoming WebSocket messages from the client.
@@ -122,16 +253,16 @@ func (c *WsClient) readMessages() {
 	}()
 
 	// Set initial read deadline and limit message size.
-	if err := c.connection.SetReadDeadline(time.Now().Add(pongWait * 10)); err != nil {
+	if err := c.connection.SetReadDeadline(time.Now().Add(c.config.ReadDeadline)); err != nil {
 		c.logger.Error("Error setting read deadline:", "error", err)
 		return
 	}
-	c.connection.SetReadLimit(1024 * 1024) // 1MB
+	c.connection.SetReadLimit(c.config.ReadLimit)
 
 	// Set pong handler for ping/pong mechanism.
 	c.connection.SetPongHandler(func(string) error {
 		c.logger.Debug("pong")
-		return c.connection.SetReadDeadline(time.Now().Add(pongWait * 10))
+		return c.connection.SetReadDeadline(time.Now().Add(c.config.ReadDeadline))
 	})
 
 	for {
@@ -144,91 +275,172 @@ func (c *WsClient) readMessages() {
 			break
 		}
 
+		c.recordFrameSize(len(message))
+
+		// Drop frames above the connection's rate limits, closing it on repeated breaches.
+		if accepted, open := c.allowIngress(len(message)); !open {
+			return
+		} else if !accepted {
+			continue
+		}
+
+		// Reject messages whose structure exceeds the JSON limits before decoding them.
+		if err := jsonguard.Check(message, c.manager.jsonLimits); errors.Is(err, jsonguard.ErrLimitExceeded) {
+			c.logger.Warn("Message rejected", "error", err, "size", len(message))
+			c.observe(abuse.InvalidMessage, "", "", len(message))
+			c.SendUpdate("error", sysChannel, msgs.NewErrorResponse("", msgs.CodeMalformedFrame, err.Error()))
+			continue
+		}
+
 		// Unmarshal the message into an IngressMsg.
 		var request IngressMsg
 		if err := json.Unmarshal(message, &request); err != nil {
-			c.logger.Error("error unmarshalling event", "error", err)
-			break
+			c.observe(abuse.InvalidMessage, "", "", len(message))
+			if !c.rejectMalformed(err) {
+				return
+			}
+			continue
 		}
+		c.malformedFrames = 0
 
-		// Handle authentication messages.
-		if request.Channel() == "sys" && request.Type() == "auth" {
-			authMsg := &AuthMsg{}
-			if err := json.Unmarshal(request.Data(), authMsg); err != nil {
-				c.logger.Error("error unmarshalling auth msg", "error", err)
-			} else {
-				if authMsg.AuthToken == "" {
-					c.logger.Error("invalid auth msg", "error", err)
-				} else {
-					claims, err := c.authenticator.ValidateJwt(authMsg.AuthToken)
-					if err != nil {
-						c.logger.Error("invalid auth msg", "error", err)
-						c.Close()
-						return
-					}
-					c.logger.Info("Successfully authenticated")
-					if !c.authenticated {
-						c.authenticated = true
-						c.publishConnected()
-					}
-					c.claims = claims
-					expirationTime, _ := claims.GetExpirationTime()
-					c.logger.Info("Authorize succeeded.", "expire", time.Unix(expirationTime.Unix(), 0).Format(time.RFC3339))
-					c.setAuthExpireTime(expirationTime.Unix())
-				}
+		// Report the message to the abuse detector and drop it while the client is throttled.
+		c.observe(abuse.Message, request.Channel(), request.Type(), len(message))
+		if c.throttled() {
+			c.sendError(request, msgs.CodeRateLimited, "throttled")
+			continue
+		}
+
+		// Pass replies to server calls to the waiting caller.
+		if request.InMsgReplyTo != "" {
+			c.resolveCall(request)
+			continue
+		}
+
+		// Handle system messages. They are never passed to the handlers.
+		if isSysChannel(request.Channel()) {
+			if !c.handleSysMessage(request) {
+				return
+			}
+			continue
+		} else if c.manager.isImpersonating(c) {
+			// Impersonation sessions are read-only.
+			c.sendError(request, msgs.CodePermissionDenied, "read-only impersonation session")
+			continue
+		} else if err := c.manager.checkPublishAccess(c, request.Channel()); err != nil {
+			// Only declared, client-writable channels the client may access are routed.
+			c.sendErrorOf(request, err, msgs.CodePermissionDenied)
+			continue
+		}
+
+		// Reject replayed messages on sensitive channels.
+		if c.manager.requiresNonce(request.Channel()) {
+			if err := c.replay.check(request.InMsgNonce, request.InMsgTs, time.Now()); err != nil {
+				c.logger.Warn("replay check failed", "error", err, "ch", request.Channel(), "id", request.ID())
+				c.sendError(request, msgs.CodeInvalidRequest, err.Error())
+				continue
 			}
 		}
 
-		// Pass the message to the ingress channel.
-		c.ingress <- request
+		// Pass the message through the middlewares to the ingress queue.
+		if !c.dispatch(request) {
+			return
+		}
+		c.countMessage(request.Channel())
+		c.manager.load.ingress.Add(1)
+		c.meterMessage()
+		c.enforceMemoryCap()
 		c.logger.Debug("InMsg received")
 	}
 }
 
 // writeMessages writes messages from the egress channel to the WebSocket connection.
+//
+// Keepalives and close frames are written by writeControl so heavy egress traffic cannot delay them.
 func (c *WsClient) writeMessages() {
-	ticker := time.NewTicker(pingInterval)
-	defer func() {
-		c.manager.removeClient(c)
-		ticker.Stop()
-	}()
+	defer c.manager.removeClient(c)
 
 	for {
 		select {
 		// Handle outgoing messages.
 		case message, ok := <-c.egress:
 			if !ok {
-				if err := c.connection.WriteMessage(websocket.CloseMessage, nil); err != nil {
-					c.logger.Error("Error connection closed", "error", err)
-				}
+				c.writeClose()
 				return
 			}
 
+			if message.frame != nil {
+				if err := c.write(message.frame); err != nil {
+					c.logger.Error("Error sending replayed frame", "error", err)
+				}
+				continue
+			}
+
+			out := *message
+			if out.MessageID == "" {
+				out.MessageID = c.manager.ids.NewID()
+			}
+			out.Seq = c.seq.Add(1)
+			if c.manager.signer != nil {
+				sig, err := c.manager.signer.Sign(message.Data)
+				if err != nil {
+					c.logger.Error("error signing message", "error", err)
+				}
+				out.Signature = sig
+			}
+			message = &out
+
 			data, err := json.Marshal(message)
 			if err != nil {
 				c.logger.Error("error marshalling event", "error", err)
 			}
-			if err := c.connection.WriteMessage(websocket.TextMessage, data); err != nil {
+			if err := c.write(data); err != nil {
 				c.logger.Error("Error sending message", "error", err)
+				c.manager.slaDropped()
+			} else {
+				c.manager.slaDelivered(message.created)
+				c.trackDelivered(message)
+				c.manager.load.egress.Add(1)
+				c.auditEgress(message, data)
+				c.bufferFrame(message.Seq, data)
 			}
 			c.logger.Debug("Message sent", "message", string(data))
 
+		// Stop the client when the context is done.
+		case <-c.context.Done():
+			return
+		}
+	}
+}
+
+// writeControl writes ping and close frames independently of the data write path.
+//
+// Control frames are written with WriteControl, which may be called concurrently with WriteMessage, so a
+// saturated egress queue or a slow data write never delays keepalives.
+func (c *WsClient) writeControl() {
+	ticker := c.manager.clock.NewTicker(c.config.PingInterval)
+	defer func() {
+		c.manager.removeClient(c)
+		ticker.Stop()
+	}()
+
+	for {
+		select {
 		// Handle ping messages at regular intervals.
-		case <-ticker.C:
+		case <-ticker.C():
 			c.logger.Debug("Ping ticker...")
-			if err := c.connection.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.writeCtl(websocket.PingMessage); err != nil {
 				c.logger.Error("Error sending ping", "error", err)
 				return
 			}
 
 		// Handle authentication expiration.
 		case <-c.authChannel:
-			c.logger.Info("Auth channel", "expire", c.expire, "now", time.Now().Unix(), "expireTime", time.Unix(c.expire, 0).Format(time.RFC3339), "nowTime", time.Now().Format(time.RFC3339))
-			if c.expire <= time.Now().Unix() {
+			now := c.manager.clock.Now()
+			expire := c.expire.Load()
+			c.logger.Info("Auth channel", "expire", expire, "now", now.Unix(), "expireTime", time.Unix(expire, 0).Format(time.RFC3339), "nowTime", now.Format(time.RFC3339))
+			if expire <= now.Unix() {
 				c.logger.Error("Auth expire timeout")
-				if err := c.connection.WriteMessage(websocket.CloseMessage, nil); err != nil {
-					c.logger.Error("Error connection closed", "error", err)
-				}
+				c.writeClose()
 			}
 
 		// Stop the client when the context is done.
@@ -238,23 +450,47 @@ func (c *WsClient) writeMessages() {
 	}
 }
 
+// writeClose sends a close frame to the client.
+func (c *WsClient) writeClose() {
+	if err := c.writeCtl(websocket.CloseMessage); err != nil {
+		c.logger.Error("Error connection closed", "error", err)
+	}
+}
+
 // setAuthExpireTime sets the authentication expiration time and schedules an action after expiration.
 func (c *WsClient) setAuthExpireTime(expire int64) {
-	c.expire = expire
-	time.AfterFunc(time.Unix(c.expire+1, 0).Sub(time.Now()), func() {
-		c.authChannel <- c.expire
+	c.expire.Store(expire)
+	c.timerLock.Lock()
+	defer c.timerLock.Unlock()
+	if c.authTimer != nil {
+		c.authTimer.Stop()
+	}
+	if c.context.Err() != nil {
+		return
+	}
+	clock := c.manager.clock
+	c.authTimer = clock.AfterFunc(time.Unix(expire+1, 0).Sub(clock.Now()), func() {
+		select {
+		case c.authChannel <- expire:
+		case <-c.context.Done():
+		}
 	})
 }
 
 // Start initializes the client's message reading and writing processes.
 func (c *WsClient) Start() {
+	c.assignResumeToken()
 	go c.readMessages()
 	go c.writeMessages()
-	c.setAuthExpireTime(c.expire)
-	if !c.authenticated {
+	go c.writeControl()
+	c.setAuthExpireTime(c.expire.Load())
+	c.sendWelcome()
+	c.sendClusterInfo("")
+	c.issueResumeToken()
+	c.replayMissed()
+	if c.Claims() == nil {
 		c.Logger().Info("Client not authenticated using bearer token. Waiting for auth message.")
+		return
 	}
-	if c.authenticated {
-		c.publishConnected()
-	}
+	c.authenticate()
 }
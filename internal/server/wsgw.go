@@ -1,26 +1,289 @@
 package server
 
 import (
+	"crypto/tls"
+	"errors"
+	"go-websocket-boilerplate/internal/abuse"
+	"go-websocket-boilerplate/internal/alerting"
+	"go-websocket-boilerplate/internal/analytics"
+	"go-websocket-boilerplate/internal/archive"
+	"go-websocket-boilerplate/internal/broker"
+	"go-websocket-boilerplate/internal/channels"
+	"go-websocket-boilerplate/internal/demo"
+	"go-websocket-boilerplate/internal/experiment"
+	"go-websocket-boilerplate/internal/geoip"
 	"go-websocket-boilerplate/internal/handler"
+	"go-websocket-boilerplate/internal/ids"
+	"go-websocket-boilerplate/internal/jsonguard"
+	"go-websocket-boilerplate/internal/metering"
+	"go-websocket-boilerplate/internal/moderation"
+	"go-websocket-boilerplate/internal/noderpc"
+	"go-websocket-boilerplate/internal/partition"
+	"go-websocket-boilerplate/internal/receipts"
+	"go-websocket-boilerplate/internal/revocation"
+	"go-websocket-boilerplate/internal/rooms"
+	"go-websocket-boilerplate/internal/sdk"
+	"go-websocket-boilerplate/internal/session"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // WsGw represents a WebSocket gateway that handles WebSocket server setup and authentication.
 type WsGw struct {
-	authenticator Authenticator // Interface for handling client authentication.
+	authenticator      Authenticator           // Interface for handling client authentication.
+	signer             MessageSigner           // Optional signer for outgoing messages.
+	replayWindow       time.Duration           // Replay window for nonce protected channels.
+	replayChannels     []string                // Channels requiring a nonce and timestamp.
+	manager            *ConnectionManager      // Connection manager created on Start.
+	endpoints          []ClusterEndpoint       // Failover endpoints advertised to clients.
+	shadow             handler.HandlerFunc     // Optional shadow handler mirrored with ingress messages.
+	handoffFile        string                  // Session snapshot file used for process handoff.
+	sessions           session.Store           // Optional store backing resume tokens.
+	resumeBuffer       session.Buffer          // Optional buffer of frames replayed on resume.
+	resumeBufferTTL    time.Duration           // Time the frames of a disconnected session are kept.
+	drainGrace         time.Duration           // Default time a drain waits for clients to leave.
+	drainLoopback      bool                    // Accept drains from the loopback interface without a token.
+	autoscaleTargets   AutoscaleTargets        // Load one replica is sized for.
+	maxPerUser         int                     // Connections allowed per subject.
+	rateLimits         RateLimits              // Ingress limits of every connection.
+	partitions         *partition.Ring         // Assignment of users to nodes.
+	rpcAddr            string                  // Address of the node RPC server.
+	peers              *noderpc.Peers          // Other nodes reachable over RPC.
+	maxMalformedFrames int                     // Consecutive malformed frames before disconnecting.
+	duplicatePolicy    DuplicatePolicy         // Handling of connections beyond maxPerUser.
+	server             *http.Server            // HTTP server, set by Start and stopped by Drain.
+	limits             SubscriptionLimits      // Subscription quotas.
+	hooks              map[string]ChannelHooks // Lazy channel activation hooks.
+	moderation         []roomModeration        // Moderators of client publishes.
+	registry           *channels.Registry      // Declared channels.
+	dmAuthorizer       DirectMessageAuthorizer // Direct message policy.
+	blockChecker       BlockChecker            // Block list for client-originated delivery.
+	geoResolver        geoip.Resolver          // GeoIP lookup at connect time.
+	abuseDetector      abuse.Detector          // Bot and abuse detector.
+	challenge          string                  // Channel abuse challenges are sent on.
+	jsonLimits         *jsonguard.Limits       // Structural limits for client messages.
+	ingressQueueSize   int                     // Messages buffered between the read loop and the handler.
+	ingressShedPolicy  ShedPolicy              // Policy applied when the ingress queue is full.
+	egressQueueSize    int                     // Messages buffered between the senders and the write loop.
+	egressPolicy       EgressPolicy            // Policy applied when the egress queue is full.
+	egressTimeout      time.Duration           // Time EgressBlock waits for room.
+	middlewares        []Middleware            // Ingress middlewares, outermost first.
+	enrichment         *claimsEnrichment       // Adds claims to validated tokens.
+	authCache          *authCache              // Claims of recently validated tokens.
+	revocations        revocation.Store        // Revoked tokens and subjects.
+	tracer             trace.Tracer            // Traces ingress messages.
+	broker             broker.Interface        // Relays updates between cluster nodes.
+	bridge             Bridge                  // Forwards messages to backend services.
+	bridgeChannels     []string                // Channels and patterns forwarded to the bridge.
+	revocationInterval time.Duration           // How often connected clients are checked for revoked tokens.
+	nodeID             string                  // Identifier of this node reported to clients.
+	ids                ids.Generator           // Generates server-originated message IDs and resume tokens.
+	experiments        experiment.Provider     // Assigns experiment variants to new connections.
+	analytics          analytics.Sink          // Receives usage events.
+	stateHook          StateChangeHook         // Called after every lifecycle transition.
+	archive            archive.Sink            // Receives sampled egress frames.
+	auditSampling      AuditSampling           // Sample rates of egress frames archived.
+	meter              *metering.Meter         // Counts billable usage per tenant.
+	receipts           *receipts.Tracker       // Tracks delivery state and read markers.
+	slaMonitor         *alerting.Monitor       // Raises alerts when service levels degrade.
+	memoryCap          int                     // Approximate per-connection memory cap in bytes.
+	maxRooms           int                     // Rooms a connection may join at a time.
+	roomAuthorizer     RoomAuthorizer          // Decides who may join a room.
+	config             Config                  // Listener, timeout and connection settings.
+	tlsConfig          *tls.Config             // TLS configuration replacing the certificate files.
+	certificates       *certificateReloader    // Certificate loaded from the files, reloadable.
 }
 
 // NewWsGw creates a new instance of WsGw (WebSocket Gateway) with the provided Authenticator.
 //
 // Params:
 // - authenticator: An interface that defines the authentication logic for WebSocket clients.
+// - config: The listener, timeout and connection settings, e.g. DefaultConfig().
 //
 // Returns:
 // - A pointer to the WsGw struct initialized with the given authenticator.
-func NewWsGw(authenticator Authenticator) *WsGw {
-	return &WsGw{authenticator: authenticator}
+func NewWsGw(authenticator Authenticator, config Config) *WsGw {
+	return &WsGw{authenticator: authenticator, config: config}
+}
+
+// SetSigner enables signing of all outgoing messages.
+//
+// The signer's verification keys are published at /.well-known/jwks.json.
+//
+// Params:
+// - signer: The signer used for outgoing messages.
+func (gw *WsGw) SetSigner(signer MessageSigner) {
+	gw.signer = signer
+}
+
+// SetReplayProtection requires a nonce and timestamp on messages sent to the given channels.
+//
+// Messages whose timestamp differs from the server clock by more than the window, or whose nonce was already
+// used by the same client, are rejected.
+//
+// Params:
+// - window: The accepted clock skew and nonce retention time.
+// - channels: The channels carrying sensitive commands.
+func (gw *WsGw) SetReplayProtection(window time.Duration, channels ...string) {
+	gw.replayWindow = window
+	gw.replayChannels = channels
+}
+
+// SetClusterEndpoints sets the failover endpoints advertised to clients on the sys channel.
+//
+// When the gateway is running, connected clients receive the new list immediately.
+//
+// Params:
+// - endpoints: The alternative endpoints with their priorities.
+func (gw *WsGw) SetClusterEndpoints(endpoints []ClusterEndpoint) {
+	gw.endpoints = endpoints
+	if gw.manager != nil {
+		gw.manager.SetClusterEndpoints(endpoints)
+	}
+}
+
+// SetShadowHandler mirrors ingress messages to a secondary handler whose responses are only compared and logged.
+//
+// Params:
+// - shadow: The secondary handler implementation.
+func (gw *WsGw) SetShadowHandler(shadow handler.HandlerFunc) {
+	gw.shadow = shadow
+}
+
+// SetSubscriptionLimits sets the per-client and per-user subscription quotas.
+//
+// Params:
+// - limits: The quotas; zero values disable the corresponding limit.
+func (gw *WsGw) SetSubscriptionLimits(limits SubscriptionLimits) {
+	gw.limits = limits
+}
+
+// SetChannelHooks registers callbacks run when a channel gains its first or loses its last subscriber.
+//
+// Params:
+// - channel: The exact channel name.
+// - hooks: The activation callbacks.
+func (gw *WsGw) SetChannelHooks(channel string, hooks ChannelHooks) {
+	if gw.hooks == nil {
+		gw.hooks = make(map[string]ChannelHooks)
+	}
+	gw.hooks[channel] = hooks
+	if gw.manager != nil {
+		gw.manager.SetChannelHooks(channel, hooks)
+	}
+}
+
+// SetModeration moderates client publishes on a channel before they are fanned out to subscribers.
+//
+// Params:
+// - channel: The channel or pattern, e.g. "chat.*". The first matching registration applies.
+// - moderator: The moderator, e.g. a moderation.Chain of a profanity filter and a room rate limit.
+// - async: Moderate in the background instead of on the publisher's handler goroutine.
+func (gw *WsGw) SetModeration(channel string, moderator moderation.Moderator, async bool) {
+	gw.moderation = append(gw.moderation, roomModeration{channel: channel, moderator: moderator, async: async})
+	if gw.manager != nil {
+		gw.manager.SetModeration(channel, moderator, async)
+	}
+}
+
+// SetChannelRegistry sets the registry of declared channels consulted by the router and the subscription layer.
+//
+// Params:
+// - registry: The channel registry.
+func (gw *WsGw) SetChannelRegistry(registry *channels.Registry) {
+	gw.registry = registry
+}
+
+// SetDirectMessagePolicy sets the authorization checks applied to client-to-client direct messages.
+//
+// Params:
+// - authorizer: Decides whether a sender may message a recipient; nil allows all authenticated senders.
+// - blocks: Block list consulted before direct and channel delivery; a BlockList also enables sys/block. May be nil.
+func (gw *WsGw) SetDirectMessagePolicy(authorizer DirectMessageAuthorizer, blocks BlockChecker) {
+	gw.dmAuthorizer = authorizer
+	gw.blockChecker = blocks
+}
+
+// SetGeoResolver enables GeoIP enrichment of new connections, used by geo-restricted channels.
+//
+// Params:
+// - resolver: The GeoIP resolver, e.g. a geoip.MaxMindResolver.
+func (gw *WsGw) SetGeoResolver(resolver geoip.Resolver) {
+	gw.geoResolver = resolver
+}
+
+// SetAbuseDetector reports connection and message activity to a bot and abuse detector whose verdicts can
+// throttle, challenge or ban clients.
+//
+// Params:
+// - detector: The abuse detector, e.g. an abuse.RateDetector.
+// - challengeChannel: The application channel challenges such as CAPTCHAs are sent on; "" keeps "challenge".
+func (gw *WsGw) SetAbuseDetector(detector abuse.Detector, challengeChannel string) {
+	gw.abuseDetector = detector
+	gw.challenge = challengeChannel
+}
+
+// SetJSONLimits sets the nesting depth, array length, string length and field count limits checked before
+// client messages are decoded. The default is jsonguard.DefaultLimits.
+//
+// Params:
+// - limits: The structural limits; zero values disable a limit.
+func (gw *WsGw) SetJSONLimits(limits jsonguard.Limits) {
+	gw.jsonLimits = &limits
+}
+
+// SetIngressQueue sets the size of the per-client queue between the read loop and the handler, and the policy
+// applied when a slow handler lets it fill up.
+//
+// Params:
+// - size: The queue size; values below 1 keep the default.
+// - policy: The shed-load policy.
+func (gw *WsGw) SetIngressQueue(size int, policy ShedPolicy) {
+	gw.ingressQueueSize = size
+	gw.ingressShedPolicy = policy
+}
+
+// SetNodeID sets the node identifier sent to clients in the welcome banner. The default is the host name.
+func (gw *WsGw) SetNodeID(nodeID string) {
+	gw.nodeID = nodeID
+}
+
+// SetIDGenerator sets the generator of server-originated message IDs and resume tokens. The default generates
+// UUIDv7s. Generators must produce IDs that are unique across the cluster, e.g. snowflakes with distinct node IDs.
+func (gw *WsGw) SetIDGenerator(generator ids.Generator) {
+	gw.ids = generator
+}
+
+// SetMaintenance enters or leaves maintenance mode. It does nothing before Start.
+//
+// Params:
+// - active: True to reject new connections and notify clients, false to resume normal operation.
+// - message: The message shown to clients and rejected connections.
+// - drain: If positive, existing connections are closed after this delay.
+func (gw *WsGw) SetMaintenance(active bool, message string, drain time.Duration) {
+	if gw.manager == nil {
+		return
+	}
+	if active {
+		gw.manager.StartMaintenance(message, drain)
+	} else {
+		gw.manager.StopMaintenance()
+	}
+}
+
+// Publish sends an update to every client subscribed to the channel. It does nothing before Start.
+//
+// Params:
+// - channel: The channel the update is published on.
+// - updateType: The type of the update.
+// - data: The update payload.
+func (gw *WsGw) Publish(channel string, updateType string, data any) {
+	if gw.manager != nil {
+		gw.manager.Publish(channel, updateType, data)
+	}
 }
 
 // Start initiates the WebSocket server.
@@ -28,23 +291,175 @@ func NewWsGw(authenticator Authenticator) *WsGw {
 // It sets up the connection manager, configures server timeouts, and listens on the /ws endpoint.
 // The server logs information upon startup and handles errors if the server fails to start.
 func (gw *WsGw) Start() {
-	manager := NewConnectionManager(&DefaultClientConnectionHandler{}, gw.authenticator)
+	manager := NewConnectionManager(&DefaultClientConnectionHandler{Shadow: gw.shadow}, gw.authenticator, gw.config)
+	manager.signer = gw.signer
+	manager.subscriptionLimits = gw.limits
+	manager.dmAuthorizer = gw.dmAuthorizer
+	manager.blockChecker = gw.blockChecker
+	manager.geoResolver = gw.geoResolver
+	manager.abuseDetector = gw.abuseDetector
+	manager.experiments = gw.experiments
+	manager.analytics = gw.analytics
+	manager.stateHook = gw.stateHook
+	manager.archive = gw.archive
+	manager.auditSampling = gw.auditSampling
+	manager.meter = gw.meter
+	if gw.receipts != nil {
+		manager.receipts = gw.receipts
+		gw.receipts.SetNotifier(manager.pushReceipt)
+		gw.receipts.SetUnreadNotifier(manager.pushUnread)
+	}
+	manager.slaMonitor = gw.slaMonitor
+	manager.memoryCap = gw.memoryCap
+	manager.rooms = rooms.NewHub(gw.maxRooms)
+	manager.roomAuthorizer = gw.roomAuthorizer
+	if gw.ingressQueueSize > 0 {
+		manager.ingressQueueSize = gw.ingressQueueSize
+	}
+	manager.ingressShedPolicy = gw.ingressShedPolicy
+	if gw.egressQueueSize > 0 {
+		manager.egressQueueSize = gw.egressQueueSize
+	}
+	manager.egressPolicy = gw.egressPolicy
+	manager.egressTimeout = gw.egressTimeout
+	manager.Use(gw.middlewares...)
+	manager.enrichment = gw.enrichment
+	manager.authCache = gw.authCache
+	manager.tracer = gw.tracer
+	manager.broker = gw.broker
+	if gw.bridge != nil {
+		manager.bridge = gw.bridge
+		manager.bridgeChannels = gw.bridgeChannels
+		manager.Use(manager.bridgeMiddleware)
+	}
+	manager.revocations = gw.revocations
+	if gw.nodeID != "" {
+		manager.nodeID = gw.nodeID
+	}
+	if gw.ids != nil {
+		manager.ids = gw.ids
+	}
+	if gw.jsonLimits != nil {
+		manager.jsonLimits = *gw.jsonLimits
+	}
+	if gw.challenge != "" {
+		manager.challengeChannel = gw.challenge
+	}
+	if gw.registry != nil {
+		manager.registry = gw.registry
+	}
+	for channel, hooks := range gw.hooks {
+		manager.SetChannelHooks(channel, hooks)
+	}
+	for _, room := range gw.moderation {
+		manager.SetModeration(room.channel, room.moderator, room.async)
+	}
+	if gw.sessions != nil {
+		manager.sessions = gw.sessions
+	}
+	manager.resumeBuffer = gw.resumeBuffer
+	if gw.resumeBufferTTL > 0 {
+		manager.resumeBufferTTL = gw.resumeBufferTTL
+	}
+	if gw.drainGrace > 0 {
+		manager.drainGrace = gw.drainGrace
+	}
+	manager.drainLoopback = gw.drainLoopback
+	manager.maxPerUser = gw.maxPerUser
+	manager.rateLimits = gw.rateLimits
+	manager.partitions = gw.partitions
+	manager.peers = gw.peers
+	manager.maxMalformedFrames = gw.maxMalformedFrames
+	manager.duplicatePolicy = gw.duplicatePolicy
+	if gw.autoscaleTargets.ConnectionsPerReplica > 0 {
+		manager.autoscaleTargets.ConnectionsPerReplica = gw.autoscaleTargets.ConnectionsPerReplica
+	}
+	if gw.autoscaleTargets.MessagesPerReplica > 0 {
+		manager.autoscaleTargets.MessagesPerReplica = gw.autoscaleTargets.MessagesPerReplica
+	}
+	if gw.replayWindow > 0 {
+		manager.replayWindow = gw.replayWindow
+	}
+	for _, ch := range gw.replayChannels {
+		manager.replayChannels[ch] = true
+	}
+	if gw.endpoints != nil {
+		manager.SetClusterEndpoints(gw.endpoints)
+	}
+	gw.loadHandoff(manager)
+	if manager.revocations != nil {
+		go manager.watchRevocations(gw.revocationInterval)
+	}
+	if manager.broker != nil {
+		go manager.consumeBroker()
+	}
+	if manager.bridge != nil {
+		go manager.consumeBridge()
+	}
+	if gw.rpcAddr != "" {
+		manager.rpcServer = noderpc.NewServer(nodeRPCHandler{manager: manager})
+		go manager.serveNodeRPC(gw.rpcAddr)
+	}
+	go manager.sampleLoad()
+	gw.manager = manager
 
 	// Configure the HTTP server with appropriate timeouts
 	server := http.Server{
-		Addr:              "localhost:3000", // Address to listen on
-		ReadHeaderTimeout: 3 * time.Second,  // Time limit for reading headers
-		ReadTimeout:       1 * time.Second,  // Time limit for reading the request body
-		WriteTimeout:      1 * time.Second,  // Time limit for writing the response
-		IdleTimeout:       30 * time.Second, // Maximum idle time for connections
+		Addr:              gw.config.Addr,              // Address to listen on
+		ReadHeaderTimeout: gw.config.ReadHeaderTimeout, // Time limit for reading headers
+		ReadTimeout:       gw.config.ReadTimeout,       // Time limit for reading the request body
+		WriteTimeout:      gw.config.WriteTimeout,      // Time limit for writing the response
+		IdleTimeout:       gw.config.IdleTimeout,       // Maximum idle time for connections
+	}
+	gw.server = &server
+	http.HandleFunc(gw.config.Path, manager.ServeWs)                // WebSocket connection handler
+	http.HandleFunc("/version", manager.ServeVersion)               // Version and feature discovery
+	http.HandleFunc("/readyz", manager.serveReady)                  // Readiness, failing while draining
+	http.HandleFunc("/drain", manager.serveDrain)                   // Drain for preStop hooks
+	http.HandleFunc("/autoscaling", manager.serveAutoscaling)       // Load and replica recommendation
+	http.HandleFunc("/metrics/load", manager.serveLoadMetrics)      // Load metrics for KEDA and HPA
+	http.Handle("/metrics/handlers", handler.MetricsHandler())      // Handler duration histograms
+	http.HandleFunc("/admin/memory", manager.serveMemory)           // Per-connection memory accounting
+	http.HandleFunc("/admin/connections", manager.serveConnections) // Per-connection lifecycle state
+	http.HandleFunc("/admin/kick", manager.serveKick)               // Disconnect a user on every node
+	http.HandleFunc("/admin/ban", manager.serveBan)                 // Ban a user or IP on every node
+	http.HandleFunc("/admin/census", manager.serveCensus)           // Connection counts of the cluster
+	http.HandleFunc("/asyncapi.json", manager.serveAsyncAPI)        // AsyncAPI document
+	http.HandleFunc("/admin/examples", manager.serveExamples)       // Client snippets for the admin dashboard
+	http.Handle("/sdk/", http.StripPrefix("/sdk/", sdk.Handler()))  // Client SDKs
+	http.Handle("/", demo.Handler())                                // Demo frontend
+	if _, ok := gw.authenticator.(TenantAuthenticator); ok && !strings.HasSuffix(gw.config.Path, "/") {
+		http.HandleFunc(gw.config.Path+"/", manager.ServeWs) // Tenant endpoints below the path
+	}
+	if gw.revocations != nil {
+		http.HandleFunc("/admin/revoke", manager.serveRevoke) // Token revocation
+	}
+	if gw.partitions != nil {
+		http.HandleFunc("/route", manager.serveRoute) // Node owning a user
+	}
+	if gw.signer != nil {
+		http.Handle("/.well-known/jwks.json", gw.signer) // Message signing keys
+	}
+
+	if gw.tlsEnabled() {
+		tlsConfig, err := gw.serverTLSConfig()
+		if err != nil {
+			slog.Error("TLS setup failed", "error", err)
+			return
+		}
+		server.TLSConfig = tlsConfig
+		slog.Info("Server started", "addr", gw.config.Addr, "path", gw.config.Path, "profile", gw.config.Profile, "tls", true)
+		if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("ListenAndServeTLS:", "error", err)
+		}
+		return
 	}
-	http.HandleFunc("/ws", manager.ServeWs) // WebSocket connection handler
 
 	// Log the server startup
-	slog.Info("Server started on 0.0.0.0:3000")
+	slog.Info("Server started", "addr", gw.config.Addr, "path", gw.config.Path, "profile", gw.config.Profile)
 
 	// Start the HTTP server and log errors if the server fails
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("ListenAndServe:", "error", err, "abs", "dilan")
 	}
 }
@@ -53,6 +468,7 @@ func (gw *WsGw) Start() {
 //
 // This implementation initializes a message handler for each connected client.
 type DefaultClientConnectionHandler struct {
+	Shadow handler.HandlerFunc // Optional shadow handler for comparing a new handler implementation
 }
 
 // ClientConnected is triggered when a new WebSocket client successfully connects.
@@ -63,5 +479,6 @@ type DefaultClientConnectionHandler struct {
 // - client: A pointer to the WsClient representing the connected client.
 func (d DefaultClientConnectionHandler) ClientConnected(client *WsClient) {
 	clientHandler := handler.NewMsgHandler(client) // Create a new message handler
+	clientHandler.SetShadow(d.Shadow)              // Mirror messages to the shadow handler, if any
 	clientHandler.Start()                          // Start handling messages
 }
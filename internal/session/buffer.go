@@ -0,0 +1,113 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Frame is an egress frame buffered for replay to a resuming client.
+type Frame struct {
+	Seq  uint64 `json:"seq"`  // Sequence number of the frame on the session
+	Data []byte `json:"data"` // The frame as written to the connection
+}
+
+// Buffer keeps the recent egress frames of sessions, so a client resuming its session receives the frames it
+// missed while disconnected.
+type Buffer interface {
+	// Append adds a frame to the session's buffer. The buffer expires ttl after the last append.
+	Append(ctx context.Context, token string, frame Frame, ttl time.Duration) error
+	// Since returns the buffered frames with a sequence number above seq, oldest first.
+	Since(ctx context.Context, token string, seq uint64) ([]Frame, error)
+	// Ack discards the frames up to and including seq, which the client received.
+	Ack(ctx context.Context, token string, seq uint64) error
+}
+
+// memoryFrames is the buffer of a session held by MemoryBuffer.
+type memoryFrames struct {
+	frames  []Frame
+	expires time.Time
+}
+
+// MemoryBuffer is an in-process Buffer keeping a bounded number of frames per session. Frames are only
+// replayable on the node that buffered them.
+type MemoryBuffer struct {
+	sync.Mutex
+	size    int
+	entries map[string]*memoryFrames
+}
+
+// NewMemoryBuffer creates an empty MemoryBuffer.
+//
+// Params:
+// - size: The number of frames kept per session; older frames are discarded.
+//
+// Returns:
+// - A pointer to the initialized MemoryBuffer.
+func NewMemoryBuffer(size int) *MemoryBuffer {
+	return &MemoryBuffer{size: size, entries: make(map[string]*memoryFrames)}
+}
+
+// Append adds the frame, discarding the oldest one if the buffer is full.
+func (b *MemoryBuffer) Append(_ context.Context, token string, frame Frame, ttl time.Duration) error {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	entry, ok := b.entries[token]
+	if !ok || now.After(entry.expires) {
+		b.prune(now)
+		entry = &memoryFrames{}
+		b.entries[token] = entry
+	}
+	entry.frames = append(entry.frames, frame)
+	if len(entry.frames) > b.size {
+		entry.frames = append([]Frame(nil), entry.frames[len(entry.frames)-b.size:]...)
+	}
+	entry.expires = now.Add(ttl)
+	return nil
+}
+
+// Since returns copies of the frames after seq.
+func (b *MemoryBuffer) Since(_ context.Context, token string, seq uint64) ([]Frame, error) {
+	b.Lock()
+	defer b.Unlock()
+	entry, ok := b.entries[token]
+	if !ok || time.Now().After(entry.expires) {
+		delete(b.entries, token)
+		return nil, nil
+	}
+	frames := make([]Frame, 0, len(entry.frames))
+	for _, frame := range entry.frames {
+		if frame.Seq > seq {
+			frames = append(frames, frame)
+		}
+	}
+	return frames, nil
+}
+
+// Ack discards the frames up to seq.
+func (b *MemoryBuffer) Ack(_ context.Context, token string, seq uint64) error {
+	b.Lock()
+	defer b.Unlock()
+	entry, ok := b.entries[token]
+	if !ok {
+		return nil
+	}
+	kept := entry.frames[:0]
+	for _, frame := range entry.frames {
+		if frame.Seq > seq {
+			kept = append(kept, frame)
+		}
+	}
+	entry.frames = kept
+	return nil
+}
+
+// prune removes expired buffers. The caller must hold the lock.
+func (b *MemoryBuffer) prune(now time.Time) {
+	for token, entry := range b.entries {
+		if now.After(entry.expires) {
+			delete(b.entries, token)
+		}
+	}
+}
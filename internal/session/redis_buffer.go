@@ -0,0 +1,71 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// redisBufferPrefix namespaces frame buffer keys in Redis.
+const redisBufferPrefix = "wsgw:frames:"
+
+// RedisBuffer is a Buffer backed by Redis sorted sets scored by sequence number, so a client can resume its
+// session on any node of a cluster.
+type RedisBuffer struct {
+	client redis.UniversalClient
+	size   int64
+}
+
+// NewRedisBuffer creates a RedisBuffer using the given client.
+//
+// Params:
+// - client: The Redis client.
+// - size: The number of frames kept per session; older frames are discarded.
+//
+// Returns:
+// - A pointer to the initialized RedisBuffer.
+func NewRedisBuffer(client redis.UniversalClient, size int) *RedisBuffer {
+	return &RedisBuffer{client: client, size: int64(size)}
+}
+
+// Append adds the frame and trims the buffer to its size.
+func (b *RedisBuffer) Append(ctx context.Context, token string, frame Frame, ttl time.Duration) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	key := redisBufferPrefix + token
+	pipe := b.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(frame.Seq), Member: data})
+	pipe.ZRemRangeByRank(ctx, key, 0, -b.size-1)
+	pipe.Expire(ctx, key, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Since returns the frames scored above seq.
+func (b *RedisBuffer) Since(ctx context.Context, token string, seq uint64) ([]Frame, error) {
+	members, err := b.client.ZRangeByScore(ctx, redisBufferPrefix+token, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatUint(seq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, 0, len(members))
+	for _, member := range members {
+		frame := Frame{}
+		if err := json.Unmarshal([]byte(member), &frame); err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// Ack removes the frames scored up to seq.
+func (b *RedisBuffer) Ack(ctx context.Context, token string, seq uint64) error {
+	return b.client.ZRemRangeByScore(ctx, redisBufferPrefix+token, "-inf", strconv.FormatUint(seq, 10)).Err()
+}
@@ -0,0 +1,52 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// redisKeyPrefix namespaces session keys in Redis.
+const redisKeyPrefix = "wsgw:session:"
+
+// RedisStore is a Store backed by Redis, shared by all nodes of a cluster.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a RedisStore using the given client.
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Save stores the session as JSON with the given expiration.
+func (r *RedisStore) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, redisKeyPrefix+s.Token, data, ttl).Err()
+}
+
+// Load returns the session for the token or ErrNotFound.
+func (r *RedisStore) Load(ctx context.Context, token string) (*Session, error) {
+	data, err := r.client.Get(ctx, redisKeyPrefix+token).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Delete removes the session for the token.
+func (r *RedisStore) Delete(ctx context.Context, token string) error {
+	return r.client.Del(ctx, redisKeyPrefix+token).Err()
+}
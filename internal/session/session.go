@@ -0,0 +1,100 @@
+// Package session holds the resumable state of a client connection and its serialized form used to hand
+// sessions over between gateway processes.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// snapshotVersion is the current format version of a Snapshot.
+const snapshotVersion = 1
+
+// Session is the resumable state of a client connection.
+type Session struct {
+	Token   string         `json:"token"`   // Resume token presented by the client on reconnect
+	Subject string         `json:"sub"`     // Subject of the authenticated user, empty if not authenticated
+	Claims  map[string]any `json:"claims"`  // Claims of the authenticated user
+	Expire  int64          `json:"expire"`  // Authentication expiration time in Unix timestamp
+	Cursor  uint64         `json:"cursor"`  // Sequence number of the last message sent to the client
+	Updated int64          `json:"updated"` // Time the session was last saved in Unix timestamp
+
+	Experiments map[string]string `json:"experiments,omitempty"` // Experiment variants assigned on first connect
+}
+
+// Snapshot is the serialized state of all sessions of a gateway process.
+type Snapshot struct {
+	Version   int        `json:"version"`
+	CreatedAt int64      `json:"createdAt"`
+	Sessions  []*Session `json:"sessions"`
+}
+
+// NewToken generates a resume token from a sortable ID followed by a random secret, so tokens order by
+// creation time but cannot be guessed from the ID alone.
+//
+// Params:
+// - id: A unique ID, e.g. from an ids.Generator.
+func NewToken(id string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate resume token: %w", err)
+	}
+	return id + "." + hex.EncodeToString(b), nil
+}
+
+// NewSnapshot creates a snapshot of the given sessions.
+func NewSnapshot(sessions []*Session) *Snapshot {
+	return &Snapshot{Version: snapshotVersion, CreatedAt: time.Now().Unix(), Sessions: sessions}
+}
+
+// Write encodes the snapshot as JSON.
+func (s *Snapshot) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// ReadSnapshot decodes a snapshot previously written with Write.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("decode session snapshot: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported session snapshot version %d", snapshot.Version)
+	}
+	return snapshot, nil
+}
+
+// WriteSnapshotFile writes the snapshot to a file, replacing it atomically.
+func WriteSnapshotFile(path string, snapshot *Snapshot) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadSnapshotFile reads a snapshot file and removes it so sessions are only handed over once.
+func ReadSnapshotFile(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(path)
+	}()
+	return ReadSnapshot(f)
+}
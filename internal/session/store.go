@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Store when no session exists for a token.
+var ErrNotFound = errors.New("session not found")
+
+// Store persists sessions keyed by their resume token.
+//
+// A shared store such as Redis lets a client resume its session on any node of a cluster.
+type Store interface {
+	// Save stores the session. It expires after ttl.
+	Save(ctx context.Context, s *Session, ttl time.Duration) error
+	// Load returns the session for the token or ErrNotFound.
+	Load(ctx context.Context, token string) (*Session, error)
+	// Delete removes the session for the token.
+	Delete(ctx context.Context, token string) error
+}
+
+// memoryEntry is a session held by MemoryStore with its expiration.
+type memoryEntry struct {
+	session *Session
+	expires time.Time
+}
+
+// MemoryStore is an in-process Store. Sessions are only resumable on the node that stored them.
+type MemoryStore struct {
+	sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Save stores a copy of the session.
+func (m *MemoryStore) Save(_ context.Context, s *Session, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	for token, entry := range m.entries {
+		if now.After(entry.expires) {
+			delete(m.entries, token)
+		}
+	}
+	stored := *s
+	m.entries[s.Token] = memoryEntry{session: &stored, expires: now.Add(ttl)}
+	return nil
+}
+
+// Load returns a copy of the stored session.
+func (m *MemoryStore) Load(_ context.Context, token string) (*Session, error) {
+	m.Lock()
+	defer m.Unlock()
+	entry, ok := m.entries[token]
+	if !ok || time.Now().After(entry.expires) {
+		delete(m.entries, token)
+		return nil, ErrNotFound
+	}
+	loaded := *entry.session
+	return &loaded, nil
+}
+
+// Delete removes the session.
+func (m *MemoryStore) Delete(_ context.Context, token string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.entries, token)
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package signaling relays WebRTC offers, answers and ICE candidates between clients of the same room so
+// applications can use the gateway as their signaling server.
+package signaling
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"go-websocket-boilerplate/internal/handler"
+	"sync"
+	"time"
+)
+
+// Channel is the channel signaling messages are exchanged on.
+const Channel = "rtc"
+
+// JoinRequest joins a signaling room.
+type JoinRequest struct {
+	Room string `json:"room" validate:"required"`
+}
+
+// JoinResponse lists the connections already in the room.
+type JoinResponse struct {
+	Room  string `json:"room"`
+	Peers []int  `json:"peers"`
+}
+
+// RelayRequest carries an offer, answer or ICE candidate to another peer of the room.
+type RelayRequest struct {
+	Room    string `json:"room" validate:"required"`
+	To      int    `json:"to" validate:"required"`
+	Payload any    `json:"payload" validate:"required"` // SDP or ICE candidate, passed through unchanged
+}
+
+// RelayedMsg is delivered to the receiving peer.
+type RelayedMsg struct {
+	Room    string `json:"room"`
+	From    int    `json:"from"`
+	Payload any    `json:"payload"`
+}
+
+// TurnCredentials are short-lived credentials for a TURN server.
+type TurnCredentials struct {
+	URIs       []string `json:"uris"`
+	Username   string   `json:"username"`
+	Credential string   `json:"credential"`
+	TTL        int64    `json:"ttl"` // Lifetime in seconds
+}
+
+// TurnMinter mints TURN credentials for a client.
+type TurnMinter func(ctx context.Context, client handler.Client) (*TurnCredentials, error)
+
+// NewSharedSecretMinter mints credentials following the TURN REST API convention used by coturn's use-auth-secret.
+//
+// Params:
+// - secret: The shared secret configured on the TURN server.
+// - uris: The TURN server URIs returned to clients.
+// - ttl: The lifetime of minted credentials.
+func NewSharedSecretMinter(secret string, uris []string, ttl time.Duration) TurnMinter {
+	return func(_ context.Context, client handler.Client) (*TurnCredentials, error) {
+		subject, _ := client.Claims().GetSubject()
+		if subject == "" {
+			subject = fmt.Sprintf("con%d", client.ID())
+		}
+		username := fmt.Sprintf("%d:%s", time.Now().Add(ttl).Unix(), subject)
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(username))
+		return &TurnCredentials{
+			URIs:       uris,
+			Username:   username,
+			Credential: base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+			TTL:        int64(ttl.Seconds()),
+		}, nil
+	}
+}
+
+// rooms tracks the members of each signaling room.
+type rooms struct {
+	sync.Mutex
+	members map[string]map[int]handler.Client
+}
+
+// join adds the client to the room and returns the peers already present.
+func (r *rooms) join(room string, client handler.Client) []int {
+	r.Lock()
+	defer r.Unlock()
+	if r.members[room] == nil {
+		r.members[room] = make(map[int]handler.Client)
+	}
+	peers := make([]int, 0, len(r.members[room]))
+	for id := range r.members[room] {
+		peers = append(peers, id)
+	}
+	_, joined := r.members[room][client.ID()]
+	r.members[room][client.ID()] = client
+	if !joined {
+		go r.leaveOnClose(room, client)
+	}
+	return peers
+}
+
+// leave removes the client from the room.
+func (r *rooms) leave(room string, clientID int) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.members[room], clientID)
+	if len(r.members[room]) == 0 {
+		delete(r.members, room)
+	}
+}
+
+// leaveOnClose removes the client from the room when its connection ends.
+func (r *rooms) leaveOnClose(room string, client handler.Client) {
+	<-client.Context().Done()
+	r.leave(room, client.ID())
+}
+
+// contains reports whether the connection is a member of the room.
+func (r *rooms) contains(room string, clientID int) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.members[room][clientID]
+	return ok
+}
+
+// Register registers the signaling handlers on the "rtc" channel.
+//
+// Clients join a room with "join", exchange "offer", "answer" and "candidate" messages addressed to a peer
+// connection ID of the same room, "leave" the room, and request TURN credentials with "turn".
+//
+// Params:
+// - minter: Mints TURN credentials; nil disables the "turn" message.
+func Register(minter TurnMinter) {
+	r := &rooms{members: make(map[string]map[int]handler.Client)}
+
+	handler.RegisterHandler(Channel, "join", func(_ context.Context, client handler.Client, req JoinRequest) (*JoinResponse, error) {
+		return &JoinResponse{Room: req.Room, Peers: r.join(req.Room, client)}, nil
+	})
+	handler.RegisterHandler(Channel, "leave", func(_ context.Context, client handler.Client, req JoinRequest) (*JoinRequest, error) {
+		r.leave(req.Room, client.ID())
+		return &req, nil
+	})
+	for _, msgType := range []string{"offer", "answer", "candidate"} {
+		handler.RegisterHandler(Channel, msgType, func(_ context.Context, client handler.Client, req RelayRequest) (*RelayRequest, error) {
+			if !r.contains(req.Room, client.ID()) || !r.contains(req.Room, req.To) {
+				return nil, fmt.Errorf("peer not in room %s: %w", req.Room, handler.ErrNotFound)
+			}
+			relayed := &RelayedMsg{Room: req.Room, From: client.ID(), Payload: req.Payload}
+			if err := client.SendToClient(req.To, msgType, Channel, relayed); err != nil {
+				return nil, err
+			}
+			return &req, nil
+		})
+	}
+	if minter != nil {
+		handler.RegisterHandler(Channel, "turn", func(ctx context.Context, client handler.Client, _ struct{}) (*TurnCredentials, error) {
+			return minter(ctx, client)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package signing produces detached JWS signatures for server-originated messages and publishes
+// the verification keys as a JWKS document.
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// key is a single signing key with its identifier and creation time.
+type key struct {
+	id      string            // Key identifier published as "kid"
+	private *ecdsa.PrivateKey // Private key used for signing
+	created time.Time         // Time the key was generated
+}
+
+// JWK is the public representation of an ES256 signing key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// RotatingSigner signs payloads with ES256 and rotates its key periodically.
+//
+// Retired keys stay published in the JWKS so messages signed shortly before a rotation remain verifiable.
+type RotatingSigner struct {
+	sync.Mutex
+	keys          []*key        // Keys ordered from newest to oldest
+	rotationEvery time.Duration // Lifetime of a signing key
+	retain        int           // Number of keys kept in the JWKS, including the active one
+}
+
+// NewRotatingSigner creates a signer with a freshly generated key.
+//
+// Params:
+// - rotationEvery: How long a key is used before a new one is generated.
+// - retain: How many keys, including the active one, are published in the JWKS.
+//
+// Returns:
+// - A pointer to the initialized RotatingSigner, or an error if key generation failed.
+func NewRotatingSigner(rotationEvery time.Duration, retain int) (*RotatingSigner, error) {
+	if retain < 1 {
+		retain = 1
+	}
+	s := &RotatingSigner{rotationEvery: rotationEvery, retain: retain}
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// rotate generates a new active key and trims retired keys. The caller must hold the lock or own the signer.
+func (s *RotatingSigner) rotate() error {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate signing key: %w", err)
+	}
+	now := time.Now()
+	k := &key{id: fmt.Sprintf("%d", now.UnixNano()), private: private, created: now}
+	s.keys = append([]*key{k}, s.keys...)
+	if len(s.keys) > s.retain {
+		s.keys = s.keys[:s.retain]
+	}
+	return nil
+}
+
+// activeKey returns the current signing key, rotating it first if it has expired.
+func (s *RotatingSigner) activeKey() (*key, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.rotationEvery > 0 && time.Since(s.keys[0].created) >= s.rotationEvery {
+		if err := s.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return s.keys[0], nil
+}
+
+// Sign returns a detached compact JWS (header..signature) over the payload.
+func (s *RotatingSigner) Sign(payload []byte) (string, error) {
+	k, err := s.activeKey()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": k.id})
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	signingString := encodedHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := jwt.SigningMethodES256.Sign(signingString, k.private)
+	if err != nil {
+		return "", err
+	}
+	return encodedHeader + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// JWKS returns the currently published verification keys.
+func (s *RotatingSigner) JWKS() *JWKS {
+	s.Lock()
+	defer s.Unlock()
+	set := &JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		public, err := k.private.PublicKey.ECDH()
+		if err != nil {
+			continue
+		}
+		point := public.Bytes() // Uncompressed point: 0x04 || X || Y
+		set.Keys = append(set.Keys, JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+			Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+			Kid: k.id,
+			Alg: "ES256",
+			Use: "sig",
+		})
+	}
+	return set
+}
+
+// ServeHTTP serves the JWKS document.
+func (s *RotatingSigner) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.JWKS()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
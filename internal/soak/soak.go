@@ -0,0 +1,224 @@
+// Package soak runs synthetic clients against the gateway inside the process, for long-running soak tests and
+// leak detection in staging.
+//
+// Virtual clients connect over the real WebSocket endpoint, subscribe to soak channels, send requests and
+// reconnect periodically, while a publisher feeds the channels. Counters and runtime statistics are logged at
+// every report interval so growth in goroutines or heap across reconnect cycles stands out.
+package soak
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Publisher delivers updates to the subscribers of a channel.
+type Publisher interface {
+	Publish(channel string, updateType string, data any)
+}
+
+// Options configure a soak run.
+type Options struct {
+	URL             string                  // WebSocket URL of the gateway, e.g. ws://localhost:3000/ws
+	Clients         int                     // Number of virtual clients
+	Channels        int                     // Number of soak channels, named soak.0 to soak.N-1
+	PublishInterval time.Duration           // Interval between updates published to each channel
+	RequestInterval time.Duration           // Interval between requests sent by each client
+	Lifetime        time.Duration           // Time after which a client disconnects and reconnects
+	ReportInterval  time.Duration           // Interval between statistics reports
+	Token           func(client int) string // Returns the bearer token of a client, or "" to connect anonymously
+}
+
+// Stats are the counters of a soak run.
+type Stats struct {
+	Connects   atomic.Int64 // Successful connections
+	Failures   atomic.Int64 // Failed dials and broken connections
+	Published  atomic.Int64 // Updates published to soak channels
+	Received   atomic.Int64 // Updates received by clients on soak channels
+	Requests   atomic.Int64 // Requests sent by clients
+	Responses  atomic.Int64 // Responses received by clients
+	Subscribed atomic.Int64 // Successful subscriptions
+}
+
+// Run starts the publisher and the virtual clients and blocks until the context is done.
+//
+// Params:
+// - ctx: Stops the run when done.
+// - publisher: Publishes the updates to the soak channels, usually the gateway.
+// - options: The run configuration.
+//
+// Returns:
+// - The counters of the run.
+func Run(ctx context.Context, publisher Publisher, options Options) *Stats {
+	stats := &Stats{}
+	slog.Info("Soak test started", "clients", options.Clients, "channels", options.Channels, "url", options.URL)
+	go publish(ctx, publisher, options, stats)
+	for i := 0; i < options.Clients; i++ {
+		go runClient(ctx, i, options, stats)
+	}
+	go report(ctx, options.ReportInterval, stats)
+	<-ctx.Done()
+	return stats
+}
+
+// publish feeds every soak channel with a sequence of updates.
+func publish(ctx context.Context, publisher Publisher, options Options, stats *Stats) {
+	ticker := time.NewTicker(options.PublishInterval)
+	defer ticker.Stop()
+	var n int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n++
+			for ch := 0; ch < options.Channels; ch++ {
+				publisher.Publish(channelName(ch), "tick", map[string]any{"n": n, "at": time.Now().UnixMilli()})
+				stats.Published.Add(1)
+			}
+		}
+	}
+}
+
+// channelName returns the name of a soak channel.
+func channelName(ch int) string {
+	return fmt.Sprintf("soak.%d", ch)
+}
+
+// runClient connects the virtual client again and again until the context is done.
+func runClient(ctx context.Context, client int, options Options, stats *Stats) {
+	// Spread the connects so the clients do not reconnect in lockstep.
+	jitter := time.Duration(rand.Int64N(int64(options.Lifetime/4) + 1))
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(jitter):
+	}
+	for ctx.Err() == nil {
+		if err := session(ctx, client, options, stats); err != nil {
+			stats.Failures.Add(1)
+			slog.Debug("Soak client session failed", "client", client, "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// session runs one connection of the virtual client for its lifetime.
+func session(ctx context.Context, client int, options Options, stats *Stats) error {
+	header := http.Header{}
+	if options.Token != nil {
+		if token := options.Token(client); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ws, _, err := websocket.DefaultDialer.DialContext(dialCtx, options.URL, header)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	stats.Connects.Add(1)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- read(ws, stats)
+	}()
+
+	channel := channelName(client % max(options.Channels, 1))
+	if err := write(ws, "sub", "subscribe", "sys", map[string]any{"ch": channel}); err != nil {
+		return err
+	}
+	requests := time.NewTicker(options.RequestInterval)
+	defer requests.Stop()
+	lifetime := time.NewTimer(options.Lifetime)
+	defer lifetime.Stop()
+	for n := 0; ; n++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-lifetime.C:
+			_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "soak reconnect"))
+			return nil
+		case err := <-done:
+			return err
+		case <-requests.C:
+			if err := write(ws, fmt.Sprintf("req-%d", n), "greeting", "greeting", map[string]any{"name": fmt.Sprintf("soak-%d", client)}); err != nil {
+				return err
+			}
+			stats.Requests.Add(1)
+		}
+	}
+}
+
+// write sends a frame.
+func write(ws *websocket.Conn, id string, msgType string, channel string, data any) error {
+	_ = ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return ws.WriteJSON(map[string]any{"id": id, "type": msgType, "ch": channel, "data": data})
+}
+
+// read counts the frames received until the connection breaks.
+func read(ws *websocket.Conn, stats *Stats) error {
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		frame := struct {
+			ID      string `json:"id"`
+			Channel string `json:"ch"`
+			Type    string `json:"type"`
+		}{}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return err
+		}
+		switch {
+		case frame.ID == "sub":
+			stats.Subscribed.Add(1)
+		case frame.ID != "":
+			stats.Responses.Add(1)
+		case frame.Type == "tick":
+			stats.Received.Add(1)
+		}
+	}
+}
+
+// report logs the counters and runtime statistics at every interval.
+func report(ctx context.Context, interval time.Duration, stats *Stats) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			slog.Info("Soak test report",
+				"connects", stats.Connects.Load(),
+				"failures", stats.Failures.Load(),
+				"subscribed", stats.Subscribed.Load(),
+				"published", stats.Published.Load(),
+				"received", stats.Received.Load(),
+				"requests", stats.Requests.Load(),
+				"responses", stats.Responses.Load(),
+				"goroutines", runtime.NumGoroutine(),
+				"heapAlloc", mem.HeapAlloc,
+				"heapObjects", mem.HeapObjects,
+			)
+		}
+	}
+}
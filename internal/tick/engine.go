@@ -0,0 +1,165 @@
+// Package tick runs a fixed-rate game loop that collects state mutations and broadcasts per-room deltas and
+// snapshots, targeting small multiplayer game backends.
+package tick
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Publisher delivers updates to the subscribers of a channel.
+type Publisher interface {
+	Publish(channel string, updateType string, data any)
+}
+
+// Delta is broadcast on a room channel with the changes of a single tick.
+type Delta struct {
+	Tick    uint64         `json:"tick"`
+	Changed map[string]any `json:"changed,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+}
+
+// Snapshot is broadcast periodically on a room channel with the complete world state.
+type Snapshot struct {
+	Tick  uint64         `json:"tick"`
+	State map[string]any `json:"state"`
+}
+
+// room holds the world state of a room and the keys changed since the last tick.
+type room struct {
+	state   map[string]any
+	changed map[string]bool
+	removed map[string]bool
+}
+
+// Engine collects mutations from handlers and broadcasts them at a fixed rate.
+//
+// Mutations of the same key within a tick are conflated: only the latest value is broadcast.
+type Engine struct {
+	sync.Mutex
+	publisher     Publisher        // Destination of deltas and snapshots
+	interval      time.Duration    // Time between ticks
+	snapshotEvery uint64           // Number of ticks between full snapshots
+	tick          uint64           // Number of the current tick
+	rooms         map[string]*room // World state keyed by room channel
+}
+
+// NewEngine creates a tick engine.
+//
+// Params:
+// - hz: Ticks per second.
+// - snapshotEvery: Number of ticks between full snapshots, so late joiners catch up; 0 disables snapshots.
+// - publisher: Delivers the updates, e.g. the gateway.
+func NewEngine(hz int, snapshotEvery uint64, publisher Publisher) *Engine {
+	if hz <= 0 {
+		hz = 20
+	}
+	return &Engine{
+		publisher:     publisher,
+		interval:      time.Second / time.Duration(hz),
+		snapshotEvery: snapshotEvery,
+		rooms:         make(map[string]*room),
+	}
+}
+
+// roomLocked returns the room, creating it if needed. The caller must hold the lock.
+func (e *Engine) roomLocked(name string) *room {
+	r, ok := e.rooms[name]
+	if !ok {
+		r = &room{state: make(map[string]any), changed: make(map[string]bool), removed: make(map[string]bool)}
+		e.rooms[name] = r
+	}
+	return r
+}
+
+// Set changes a key of the room's world state. The change is broadcast on the next tick.
+func (e *Engine) Set(roomName string, key string, value any) {
+	e.Lock()
+	defer e.Unlock()
+	r := e.roomLocked(roomName)
+	r.state[key] = value
+	r.changed[key] = true
+	delete(r.removed, key)
+}
+
+// Delete removes a key from the room's world state. The removal is broadcast on the next tick.
+func (e *Engine) Delete(roomName string, key string) {
+	e.Lock()
+	defer e.Unlock()
+	r := e.roomLocked(roomName)
+	delete(r.state, key)
+	delete(r.changed, key)
+	r.removed[key] = true
+}
+
+// CloseRoom discards the world state of a room.
+func (e *Engine) CloseRoom(roomName string) {
+	e.Lock()
+	defer e.Unlock()
+	delete(e.rooms, roomName)
+}
+
+// Snapshot returns a copy of the room's world state.
+func (e *Engine) Snapshot(roomName string) *Snapshot {
+	e.Lock()
+	defer e.Unlock()
+	return e.snapshotLocked(e.roomLocked(roomName))
+}
+
+// snapshotLocked copies the room's world state. The caller must hold the lock.
+func (e *Engine) snapshotLocked(r *room) *Snapshot {
+	state := make(map[string]any, len(r.state))
+	for k, v := range r.state {
+		state[k] = v
+	}
+	return &Snapshot{Tick: e.tick, State: state}
+}
+
+// Run broadcasts deltas and snapshots until the context is done.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.step()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// step advances one tick and publishes the collected changes.
+func (e *Engine) step() {
+	type update struct {
+		channel    string
+		updateType string
+		data       any
+	}
+	e.Lock()
+	e.tick++
+	snapshot := e.snapshotEvery > 0 && e.tick%e.snapshotEvery == 0
+	updates := make([]update, 0, len(e.rooms))
+	for name, r := range e.rooms {
+		if snapshot {
+			updates = append(updates, update{name, "snapshot", e.snapshotLocked(r)})
+		} else if len(r.changed) > 0 || len(r.removed) > 0 {
+			delta := &Delta{Tick: e.tick, Changed: make(map[string]any, len(r.changed))}
+			for key := range r.changed {
+				delta.Changed[key] = r.state[key]
+			}
+			for key := range r.removed {
+				delta.Removed = append(delta.Removed, key)
+			}
+			updates = append(updates, update{name, "delta", delta})
+		}
+		r.changed = make(map[string]bool)
+		r.removed = make(map[string]bool)
+	}
+	e.Unlock()
+
+	for _, u := range updates {
+		e.publisher.Publish(u.channel, u.updateType, u.data)
+	}
+}